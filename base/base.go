@@ -19,6 +19,7 @@ var collectEventDependency CollectEventDependency
 
 var hashTagEventService *HashTagEventService
 var hashTagLoadedCache *cache.Cache
+var pubSubBroker *PubSubBroker
 
 var serverConfig *RoomServerConfig
 var taskConfig *RoomTaskConfig
@@ -29,7 +30,7 @@ var json = jsoniter.ConfigCompatibleWithStandardLibrary
 func initService(
 	loggerName string, logConfig map[string]interface{},
 	metricConfig MetricConfig, dbClusterConfig DBClusterConfig,
-) (*log.Logger, *MetricClient, *DBCluster, error) {
+) (*log.Logger, Metric, *DBCluster, error) {
 
 	logger, err := parseLogger(loggerName, logConfig)
 	if err != nil {
@@ -43,6 +44,14 @@ func initService(
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("init_db.%w", err)
 	}
+	if dbClusterConfig.AutoMigrate {
+		ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+		err = RunMigrations(ctx, databaseCluster)
+		cancel()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("migrate_db.%w", err)
+		}
+	}
 	return logger, metric, databaseCluster, nil
 }
 
@@ -84,6 +93,8 @@ func InitRoomServer(configPath string) error {
 
 	hashTagLoadedCache = cache.New(serverConfig.LoadKey.GetCacheDuration(), serverConfig.LoadKey.GetCacheCheckInterval())
 
+	pubSubBroker = NewPubSubBroker()
+
 	logger.Info(
 		"init room server service",
 		log.String("config", fmt.Sprintf("%+v", serverConfig)),
@@ -175,6 +186,10 @@ func GetHashTagLoadedCache() *cache.Cache {
 	return hashTagLoadedCache
 }
 
+func GetPubSubBroker() *PubSubBroker {
+	return pubSubBroker
+}
+
 func GetServerConfig() *RoomServerConfig {
 	return serverConfig
 }
@@ -203,11 +218,11 @@ const (
 )
 
 type redisRecordHook struct {
-	metricClient *MetricClient
+	metricClient Metric
 	logger       *log.Logger
 }
 
-func newRedisRecordHook(metricClient *MetricClient, logger *log.Logger) redisRecordHook {
+func newRedisRecordHook(metricClient Metric, logger *log.Logger) redisRecordHook {
 	return redisRecordHook{metricClient: metricClient, logger: logger}
 }
 
@@ -256,7 +271,7 @@ type Dependency struct {
 	Redis  *redis.ClusterClient
 	DB     *DBCluster
 	Logger *log.Logger
-	Metric *MetricClient
+	Metric Metric
 }
 
 var (
@@ -293,7 +308,7 @@ func GetTaskDependency() Dependency {
 type CollectEventDependency struct {
 	DB     *DBCluster
 	Logger *log.Logger
-	Metric *MetricClient
+	Metric Metric
 }
 
 func (dep CollectEventDependency) Check() error {
@@ -1,12 +1,14 @@
 package base
 
 import (
+	"bytepower_room/utility"
 	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -68,12 +70,76 @@ func (config Config) check() error {
 type RoomServerConfig struct {
 	EnablePProf         bool                      `yaml:"enable_pprof"`
 	IsDebug             bool                      `yaml:"is_debug"`
+	EnableAdminCommands bool                      `yaml:"enable_admin_commands"`
+	EnableDebugCommands bool                      `yaml:"enable_debug_commands"`
 	Log                 map[string]interface{}    `yaml:"log"`
 	Metric              MetricConfig              `yaml:"metric"`
 	LoadKey             LoadKeyConfig             `yaml:"load_key"`
 	HashTagEventService HashTagEventServiceConfig `yaml:"hash_tag_event_service"`
 	RedisCluster        RedisClusterConfig        `yaml:"redis_cluster"`
 	DB                  DBClusterConfig           `yaml:"db_cluster"`
+
+	// TransactionTimeout aborts transactions lasting longer than it, 0 means no timeout.
+	// Commands in multi are rejected and exec returns EXECABORT error after it.
+	RawTransactionTimeout string `yaml:"transaction_timeout"`
+	TransactionTimeout    time.Duration
+	// TransactionMaxQueuedCommands limits count of commands queued in a transaction, 0 means no limit.
+	TransactionMaxQueuedCommands int `yaml:"transaction_max_queued_commands"`
+
+	// CommandTimeout bounds duration of a command since its pipeline is received, 0 means no timeout.
+	// It covers loading keys of the command and executing it, a batch of pipelined commands is executed
+	// before the earliest deadline of them.
+	// TransactionCommandTimeout is the timeout of commands in transactions.
+	RawCommandTimeout            string `yaml:"command_timeout"`
+	CommandTimeout               time.Duration
+	RawTransactionCommandTimeout string `yaml:"transaction_command_timeout"`
+	TransactionCommandTimeout    time.Duration
+
+	// StartupHealthCheckTimeout is the timeout to check db connectivity and tables on startup,
+	// empty means defaultStartupHealthCheckTimeout.
+	RawStartupHealthCheckTimeout string `yaml:"startup_health_check_timeout"`
+	StartupHealthCheckTimeout    time.Duration
+
+	// ScriptTimeout stops scripts run by eval and evalsha running longer than it, empty means defaultScriptTimeout.
+	RawScriptTimeout string `yaml:"script_timeout"`
+	ScriptTimeout    time.Duration
+
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+
+	KeysCommand KeysCommandConfig `yaml:"keys_command"`
+
+	// MaxValueSizeBytes limits bytes of values written by a command to a key, 0 means no limit.
+	// MaxValueSizeByType overrides the limit of keys with the type, e.g. "hash": 1048576.
+	MaxValueSizeBytes  int            `yaml:"max_value_size_bytes"`
+	MaxValueSizeByType map[string]int `yaml:"max_value_size_by_type"`
+
+	// MaxHGetAllFields is the count of fields above which hgetall reads the hash by hscan in batches of this size,
+	// 0 means hgetall is always sent to redis. Replies are still buffered by room before they are written.
+	MaxHGetAllFields int `yaml:"max_hgetall_fields"`
+
+	ObjectEncoding ObjectEncodingConfig `yaml:"object_encoding"`
+
+	CommandFilter CommandFilterConfig `yaml:"command_filter"`
+
+	HashTag HashTagConfig `yaml:"hash_tag"`
+
+	WarmUp WarmUpConfig `yaml:"warm_up"`
+
+	Listener ListenerConfig `yaml:"listener"`
+}
+
+const defaultStartupHealthCheckTimeout = 10 * time.Second
+
+const defaultScriptTimeout = 5 * time.Second
+
+var maxValueSizeTypes = []string{"string", "list", "hash", "set", "zset"}
+
+// MaxValueSize returns the max bytes of values written to a key of valueType, 0 means no limit.
+func (config RoomServerConfig) MaxValueSize(valueType string) int {
+	if size, ok := config.MaxValueSizeByType[valueType]; ok {
+		return size
+	}
+	return config.MaxValueSizeBytes
 }
 
 func (config RoomServerConfig) Check() error {
@@ -99,6 +165,42 @@ func (config RoomServerConfig) check() error {
 	if err := config.DB.check(); err != nil {
 		return fmt.Errorf("db_cluster.%w", err)
 	}
+	if config.TransactionMaxQueuedCommands < 0 {
+		return fmt.Errorf(
+			"transaction_max_queued_commands=%d, it should be equal to or greater than 0", config.TransactionMaxQueuedCommands)
+	}
+	if err := config.RateLimit.check(); err != nil {
+		return fmt.Errorf("rate_limit.%w", err)
+	}
+	if err := config.KeysCommand.check(); err != nil {
+		return fmt.Errorf("keys_command.%w", err)
+	}
+	if config.MaxValueSizeBytes < 0 {
+		return fmt.Errorf("max_value_size_bytes=%d, it should be equal to or greater than 0", config.MaxValueSizeBytes)
+	}
+	if config.MaxHGetAllFields < 0 {
+		return fmt.Errorf("max_hgetall_fields=%d, it should be equal to or greater than 0", config.MaxHGetAllFields)
+	}
+	if err := config.ObjectEncoding.check(); err != nil {
+		return fmt.Errorf("object_encoding.%w", err)
+	}
+	if err := config.HashTag.check(); err != nil {
+		return fmt.Errorf("hash_tag.%w", err)
+	}
+	if err := config.WarmUp.check(); err != nil {
+		return fmt.Errorf("warm_up.%w", err)
+	}
+	if err := config.Listener.check(); err != nil {
+		return fmt.Errorf("listener.%w", err)
+	}
+	for valueType, size := range config.MaxValueSizeByType {
+		if !utility.StringSliceContains(maxValueSizeTypes, valueType) {
+			return fmt.Errorf("max_value_size_by_type.%s is not supported, it should be one of %v", valueType, maxValueSizeTypes)
+		}
+		if size < 0 {
+			return fmt.Errorf("max_value_size_by_type.%s=%d, it should be equal to or greater than 0", valueType, size)
+		}
+	}
 	return nil
 }
 
@@ -137,12 +239,30 @@ func (config *RoomServerConfig) init() error {
 	}
 	config.HashTagEventService.AggInterval = d
 
+	config.HashTagEventService.PerPrefixAggInterval = make(map[string]time.Duration)
+	for prefix, interval := range config.HashTagEventService.RawPerPrefixAggInterval {
+		d, err = time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("hash_tag_event_service.per_prefix_agg_interval.%s.%w", prefix, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("hash_tag_event_service.per_prefix_agg_interval.%s=%s, duration should be positive", prefix, interval)
+		}
+		config.HashTagEventService.PerPrefixAggInterval[prefix] = d
+	}
+
 	d, err = time.ParseDuration(config.HashTagEventService.RawMonitorInterval)
 	if err != nil {
 		return fmt.Errorf("hash_tag_event_service.monitor_interval.%w", err)
 	}
 	config.HashTagEventService.MonitorInterval = d
 
+	d, err = time.ParseDuration(config.HashTagEventService.RawDrainDuration)
+	if err != nil {
+		return fmt.Errorf("hash_tag_event_service.drain_duration.%w", err)
+	}
+	config.HashTagEventService.DrainDuration = d
+
 	d, err = time.ParseDuration(config.HashTagEventService.EventReport.RawRequestTimeout)
 	if err != nil {
 		return fmt.Errorf("hash_tag_event_service.event_report.request_timeout.%w", err)
@@ -167,9 +287,350 @@ func (config *RoomServerConfig) init() error {
 	}
 	config.HashTagEventService.EventReport.RequestIdleConnTimeout = d
 
+	config.HashTagEventService.EventReport.InitialBackoff = defaultReportEventsInitialBackoff
+	if config.HashTagEventService.EventReport.RawInitialBackoff != "" {
+		d, err = time.ParseDuration(config.HashTagEventService.EventReport.RawInitialBackoff)
+		if err != nil {
+			return fmt.Errorf("hash_tag_event_service.event_report.initial_backoff.%w", err)
+		}
+		config.HashTagEventService.EventReport.InitialBackoff = d
+	}
+
+	config.HashTagEventService.EventReport.MaxBackoff = defaultReportEventsMaxBackoff
+	if config.HashTagEventService.EventReport.RawMaxBackoff != "" {
+		d, err = time.ParseDuration(config.HashTagEventService.EventReport.RawMaxBackoff)
+		if err != nil {
+			return fmt.Errorf("hash_tag_event_service.event_report.max_backoff.%w", err)
+		}
+		config.HashTagEventService.EventReport.MaxBackoff = d
+	}
+	if config.HashTagEventService.EventReport.MaxBackoff < config.HashTagEventService.EventReport.InitialBackoff {
+		return fmt.Errorf(
+			"hash_tag_event_service.event_report.max_backoff=%s, it should not be less than initial_backoff=%s",
+			config.HashTagEventService.EventReport.MaxBackoff, config.HashTagEventService.EventReport.InitialBackoff)
+	}
+
+	if config.HashTagEventService.EventReport.RawProbeInterval != "" {
+		d, err = time.ParseDuration(config.HashTagEventService.EventReport.RawProbeInterval)
+		if err != nil {
+			return fmt.Errorf("hash_tag_event_service.event_report.probe_interval.%w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf(
+				"hash_tag_event_service.event_report.probe_interval=%s, duration should be positive",
+				config.HashTagEventService.EventReport.RawProbeInterval)
+		}
+		config.HashTagEventService.EventReport.ProbeInterval = d
+	}
+
+	if config.HashTagEventService.DeadLetter.Enabled {
+		d, err = time.ParseDuration(config.HashTagEventService.DeadLetter.RawRetryInterval)
+		if err != nil {
+			return fmt.Errorf("hash_tag_event_service.dead_letter.retry_interval.%w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf(
+				"hash_tag_event_service.dead_letter.retry_interval=%s, duration should be positive",
+				config.HashTagEventService.DeadLetter.RawRetryInterval)
+		}
+		config.HashTagEventService.DeadLetter.RetryInterval = d
+	}
+
+	if config.RawTransactionTimeout != "" {
+		d, err = time.ParseDuration(config.RawTransactionTimeout)
+		if err != nil {
+			return fmt.Errorf("transaction_timeout=%s is invalid %w", config.RawTransactionTimeout, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("transaction_timeout=%s, duration should be positive", config.RawTransactionTimeout)
+		}
+		config.TransactionTimeout = d
+	}
+
+	if config.RawCommandTimeout != "" {
+		d, err = time.ParseDuration(config.RawCommandTimeout)
+		if err != nil {
+			return fmt.Errorf("command_timeout=%s is invalid %w", config.RawCommandTimeout, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("command_timeout=%s, duration should be positive", config.RawCommandTimeout)
+		}
+		config.CommandTimeout = d
+	}
+	if config.RawTransactionCommandTimeout != "" {
+		d, err = time.ParseDuration(config.RawTransactionCommandTimeout)
+		if err != nil {
+			return fmt.Errorf("transaction_command_timeout=%s is invalid %w", config.RawTransactionCommandTimeout, err)
+		}
+		if d < config.CommandTimeout {
+			return fmt.Errorf(
+				"transaction_command_timeout=%s, it should not be less than command_timeout", config.RawTransactionCommandTimeout)
+		}
+		config.TransactionCommandTimeout = d
+	}
+
+	config.StartupHealthCheckTimeout = defaultStartupHealthCheckTimeout
+	if config.RawStartupHealthCheckTimeout != "" {
+		d, err = time.ParseDuration(config.RawStartupHealthCheckTimeout)
+		if err != nil {
+			return fmt.Errorf("startup_health_check_timeout=%s is invalid %w", config.RawStartupHealthCheckTimeout, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("startup_health_check_timeout=%s, duration should be positive", config.RawStartupHealthCheckTimeout)
+		}
+		config.StartupHealthCheckTimeout = d
+	}
+
+	config.ScriptTimeout = defaultScriptTimeout
+	if config.RawScriptTimeout != "" {
+		d, err = time.ParseDuration(config.RawScriptTimeout)
+		if err != nil {
+			return fmt.Errorf("script_timeout=%s is invalid %w", config.RawScriptTimeout, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("script_timeout=%s, duration should be positive", config.RawScriptTimeout)
+		}
+		config.ScriptTimeout = d
+	}
+
+	if config.WarmUp.Enabled {
+		d, err = time.ParseDuration(config.WarmUp.RawAccessedWithin)
+		if err != nil {
+			return fmt.Errorf("warm_up.accessed_within=%s is invalid %w", config.WarmUp.RawAccessedWithin, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("warm_up.accessed_within=%s, duration should be positive", config.WarmUp.RawAccessedWithin)
+		}
+		config.WarmUp.AccessedWithin = d
+	}
+
+	if config.Listener.RawBacklogDropCheckInterval != "" {
+		d, err = time.ParseDuration(config.Listener.RawBacklogDropCheckInterval)
+		if err != nil {
+			return fmt.Errorf(
+				"listener.backlog_drop_check_interval=%s is invalid %w", config.Listener.RawBacklogDropCheckInterval, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf(
+				"listener.backlog_drop_check_interval=%s, duration should be positive", config.Listener.RawBacklogDropCheckInterval)
+		}
+		config.Listener.BacklogDropCheckInterval = d
+	}
+
+	return nil
+}
+
+// RateLimitConfig limits commands per second of every connection,
+// rate limit is disabled if max_commands_per_second is 0.
+type RateLimitConfig struct {
+	MaxCommandsPerSecond int `yaml:"max_commands_per_second"`
+	BurstSize            int `yaml:"burst_size"`
+}
+
+func (config RateLimitConfig) check() error {
+	if config.MaxCommandsPerSecond < 0 {
+		return fmt.Errorf("max_commands_per_second=%d, it should be equal to or greater than 0", config.MaxCommandsPerSecond)
+	}
+	if config.IsEnabled() && config.BurstSize <= 0 {
+		return fmt.Errorf("burst_size=%d, it should be greater than 0", config.BurstSize)
+	}
+	return nil
+}
+
+func (config RateLimitConfig) IsEnabled() bool {
+	return config.MaxCommandsPerSecond > 0
+}
+
+// KeysCommandConfig is config of keys command, which scans keys of all hash tags in db.
+// It is disabled by default since it is O(keyspace).
+type KeysCommandConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxResultCount is the max count of matched keys, an error is returned if it is exceeded.
+	MaxResultCount int `yaml:"max_result_count"`
+}
+
+func (config KeysCommandConfig) check() error {
+	if config.Enabled && config.MaxResultCount <= 0 {
+		return fmt.Errorf("max_result_count=%d, it should be greater than 0", config.MaxResultCount)
+	}
+	return nil
+}
+
+// WarmUpConfig preloads keys of the most recently accessed hash tags in background on startup,
+// so that the first commands of popular hash tags do not load them from db.
+type WarmUpConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Count is the max count of preloaded hash tags.
+	Count int `yaml:"count"`
+	// AccessedWithin limits preloaded hash tags to those accessed within the duration before startup.
+	RawAccessedWithin string `yaml:"accessed_within"`
+	AccessedWithin    time.Duration
+	// Concurrency is the count of hash tags loaded at the same time.
+	Concurrency int `yaml:"concurrency"`
+}
+
+func (config WarmUpConfig) check() error {
+	if !config.Enabled {
+		return nil
+	}
+	if config.Count <= 0 {
+		return fmt.Errorf("count=%d, it should be greater than 0", config.Count)
+	}
+	if config.RawAccessedWithin == "" {
+		return errors.New("accessed_within should not be empty")
+	}
+	if config.Concurrency <= 0 {
+		return fmt.Errorf("concurrency=%d, it should be greater than 0", config.Concurrency)
+	}
+	return nil
+}
+
+// ListenerConfig is config of listeners of room server, listeners are created with SO_REUSEPORT,
+// so multiple processes could listen on the same address and kernel balances connections among them.
+type ListenerConfig struct {
+	// Backlog is the max length of queue of pending connections of a listener,
+	// 0 means system default, it is limited by net.core.somaxconn on linux.
+	Backlog int `yaml:"backlog"`
+	// ReusePortCount is count of listeners on the same address in a process, each of them accepts connections
+	// in its own goroutine, 0 means 1.
+	ReusePortCount int `yaml:"reuse_port_count"`
+	// BacklogDropCheckInterval is the interval to check connections dropped due to full backlog,
+	// which are recorded by metric accept.backlog_drop, check is disabled if it is empty.
+	RawBacklogDropCheckInterval string `yaml:"backlog_drop_check_interval"`
+	BacklogDropCheckInterval    time.Duration
+}
+
+func (config ListenerConfig) check() error {
+	if config.Backlog < 0 {
+		return fmt.Errorf("backlog=%d, it should be equal to or greater than 0", config.Backlog)
+	}
+	if config.ReusePortCount < 0 {
+		return fmt.Errorf("reuse_port_count=%d, it should be equal to or greater than 0", config.ReusePortCount)
+	}
+	return nil
+}
+
+// GetReusePortCount returns count of listeners on the same address, default value is 1.
+func (config ListenerConfig) GetReusePortCount() int {
+	if config.ReusePortCount <= 0 {
+		return 1
+	}
+	return config.ReusePortCount
+}
+
+// CommandFilterConfig forbids commands by name case-insensitively,
+// commands in Denylist are always denied, and only commands in Allowlist are allowed if it is not empty.
+// All commands are allowed if both of them are empty.
+type CommandFilterConfig struct {
+	Allowlist []string `yaml:"allowlist"`
+	Denylist  []string `yaml:"denylist"`
+}
+
+func (config CommandFilterConfig) IsAllowed(name string) bool {
+	for _, denied := range config.Denylist {
+		if strings.EqualFold(denied, name) {
+			return false
+		}
+	}
+	if len(config.Allowlist) == 0 {
+		return true
+	}
+	for _, allowed := range config.Allowlist {
+		if strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	HashTagSchemeBrace  = "brace"
+	HashTagSchemePrefix = "prefix"
+)
+
+// HashTagConfig configures how hash tags are extracted from keys.
+// Scheme brace, the default one, extracts the hash tag in braces like redis cluster, e.g. user of {user}:1,
+// scheme prefix extracts the prefix before the first Delimiter, e.g. user of user:1 with delimiter ":".
+type HashTagConfig struct {
+	Scheme    string `yaml:"scheme"`
+	Delimiter string `yaml:"delimiter"`
+}
+
+func (config HashTagConfig) check() error {
+	switch config.Scheme {
+	case "", HashTagSchemeBrace:
+		if config.Delimiter != "" {
+			return fmt.Errorf("delimiter=%s, it should be empty for scheme %s", config.Delimiter, HashTagSchemeBrace)
+		}
+	case HashTagSchemePrefix:
+		if config.Delimiter == "" {
+			return fmt.Errorf("delimiter should not be empty for scheme %s", HashTagSchemePrefix)
+		}
+	default:
+		return fmt.Errorf(
+			"scheme=%s is not supported, it should be one of %v",
+			config.Scheme, []string{HashTagSchemeBrace, HashTagSchemePrefix})
+	}
+	return nil
+}
+
+// ObjectEncodingConfig is thresholds of compact encodings reported by object encoding and debug object,
+// they have the same meanings as configurations of redis 7, 0 means the default value of redis.
+type ObjectEncodingConfig struct {
+	ListMaxListpackEntries int `yaml:"list_max_listpack_entries"`
+	ListMaxListpackValue   int `yaml:"list_max_listpack_value"`
+	HashMaxListpackEntries int `yaml:"hash_max_listpack_entries"`
+	HashMaxListpackValue   int `yaml:"hash_max_listpack_value"`
+	SetMaxIntsetEntries    int `yaml:"set_max_intset_entries"`
+	SetMaxListpackEntries  int `yaml:"set_max_listpack_entries"`
+	SetMaxListpackValue    int `yaml:"set_max_listpack_value"`
+	ZSetMaxListpackEntries int `yaml:"zset_max_listpack_entries"`
+	ZSetMaxListpackValue   int `yaml:"zset_max_listpack_value"`
+}
+
+func (config ObjectEncodingConfig) check() error {
+	for name, value := range map[string]int{
+		"list_max_listpack_entries": config.ListMaxListpackEntries,
+		"list_max_listpack_value":   config.ListMaxListpackValue,
+		"hash_max_listpack_entries": config.HashMaxListpackEntries,
+		"hash_max_listpack_value":   config.HashMaxListpackValue,
+		"set_max_intset_entries":    config.SetMaxIntsetEntries,
+		"set_max_listpack_entries":  config.SetMaxListpackEntries,
+		"set_max_listpack_value":    config.SetMaxListpackValue,
+		"zset_max_listpack_entries": config.ZSetMaxListpackEntries,
+		"zset_max_listpack_value":   config.ZSetMaxListpackValue,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s=%d, it should be equal to or greater than 0", name, value)
+		}
+	}
 	return nil
 }
 
+// WithDefaults returns config whose zero values are replaced by default values of redis.
+func (config ObjectEncodingConfig) WithDefaults() ObjectEncodingConfig {
+	defaults := []struct {
+		value        *int
+		defaultValue int
+	}{
+		{&config.ListMaxListpackEntries, 128},
+		{&config.ListMaxListpackValue, 64},
+		{&config.HashMaxListpackEntries, 128},
+		{&config.HashMaxListpackValue, 64},
+		{&config.SetMaxIntsetEntries, 512},
+		{&config.SetMaxListpackEntries, 128},
+		{&config.SetMaxListpackValue, 64},
+		{&config.ZSetMaxListpackEntries, 128},
+		{&config.ZSetMaxListpackValue, 64},
+	}
+	for _, item := range defaults {
+		if *item.value == 0 {
+			*item.value = item.defaultValue
+		}
+	}
+	return config
+}
+
 type LoadKeyConfig struct {
 	RetryTimes            int    `yaml:"retry_times"`
 	RawRetryInterval      string `yaml:"retry_interval"`
@@ -252,6 +713,8 @@ type RoomCollectEventConfig struct {
 	RawAggInterval string `yaml:"agg_interval"`
 	AggInterval    time.Duration
 
+	AdaptiveAgg CollectEventAdaptiveAggConfig `yaml:"adaptive_agg"`
+
 	ServerShutdownTimeoutSeconds int `yaml:"server_shutdown_timeout_seconds"`
 
 	RawMonitorInterval string `yaml:"monitor_interval"`
@@ -282,6 +745,9 @@ func (config RoomCollectEventConfig) check() error {
 	if config.RawAggInterval == "" {
 		return errors.New("agg_interval should not be empty")
 	}
+	if err := config.AdaptiveAgg.check(); err != nil {
+		return fmt.Errorf("adaptive_agg.%w", err)
+	}
 	if config.ServerShutdownTimeoutSeconds <= 0 {
 		return fmt.Errorf("server_shutdown_timeout_seconds is %d, it should be greater than 0", config.ServerShutdownTimeoutSeconds)
 	}
@@ -305,6 +771,12 @@ func (config *RoomCollectEventConfig) init() error {
 	}
 	config.SaveDB.FileAge = duration
 
+	if config.SaveDB.AccessedAtDebounce.Enabled {
+		if err := config.SaveDB.AccessedAtDebounce.init(); err != nil {
+			return fmt.Errorf("save_db.accessed_at_debounce.%w", err)
+		}
+	}
+
 	duration, err = time.ParseDuration(config.SaveFile.RawMaxFileAge)
 	if err != nil {
 		return fmt.Errorf("save_file.max_file_age.%w", err)
@@ -317,6 +789,12 @@ func (config *RoomCollectEventConfig) init() error {
 	}
 	config.AggInterval = duration
 
+	if config.AdaptiveAgg.Enabled {
+		if err := config.AdaptiveAgg.init(); err != nil {
+			return fmt.Errorf("adaptive_agg.%w", err)
+		}
+	}
+
 	duration, err = time.ParseDuration(config.RawMonitorInterval)
 	if err != nil {
 		return fmt.Errorf("monitor_interval is inavlid %w", err)
@@ -357,6 +835,8 @@ type CollectEventServiceSaveDBConfig struct {
 	FileAge    time.Duration
 
 	RateLimitPerSecond int `yaml:"rate_limit_per_second"`
+
+	AccessedAtDebounce AccessedAtDebounceConfig `yaml:"accessed_at_debounce"`
 }
 
 func (config CollectEventServiceSaveDBConfig) check() error {
@@ -375,6 +855,119 @@ func (config CollectEventServiceSaveDBConfig) check() error {
 	if config.RateLimitPerSecond <= 0 {
 		return fmt.Errorf("rate_limit_per_second is %d, it should be greater than 0", config.RateLimitPerSecond)
 	}
+	if err := config.AccessedAtDebounce.check(); err != nil {
+		return fmt.Errorf("accessed_at_debounce.%w", err)
+	}
+	return nil
+}
+
+// AccessedAtDebounceConfig skips updates of accessed_at of hash tags which only move it less than Granularity,
+// accessed_at older than InactiveDuration minus Granularity is always updated, so that hash tags accessed are not
+// cleaned for the skipped updates. InactiveDuration should be inactive_duration of clean_key_task.
+type AccessedAtDebounceConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	RawGranularity string        `yaml:"granularity"`
+	Granularity    time.Duration `yaml:"-"`
+
+	RawInactiveDuration string `yaml:"inactive_duration"`
+	InactiveDuration    time.Duration
+}
+
+func (config AccessedAtDebounceConfig) check() error {
+	if !config.Enabled {
+		return nil
+	}
+	if config.RawGranularity == "" {
+		return errors.New("granularity should not be empty")
+	}
+	if config.RawInactiveDuration == "" {
+		return errors.New("inactive_duration should not be empty")
+	}
+	return nil
+}
+
+func (config *AccessedAtDebounceConfig) init() error {
+	granularity, err := time.ParseDuration(config.RawGranularity)
+	if err != nil {
+		return fmt.Errorf("granularity=%s is invalid %w", config.RawGranularity, err)
+	}
+	if granularity <= 0 {
+		return fmt.Errorf("granularity=%s, duration should be positive", config.RawGranularity)
+	}
+	inactiveDuration, err := time.ParseDuration(config.RawInactiveDuration)
+	if err != nil {
+		return fmt.Errorf("inactive_duration=%s is invalid %w", config.RawInactiveDuration, err)
+	}
+	if inactiveDuration <= granularity {
+		return fmt.Errorf("inactive_duration=%s, it should be greater than granularity", config.RawInactiveDuration)
+	}
+	config.Granularity = granularity
+	config.InactiveDuration = inactiveDuration
+	return nil
+}
+
+// IsDebounced returns true if update of accessedAt to accessTime could be skipped at currentTime.
+func (config AccessedAtDebounceConfig) IsDebounced(accessedAt, accessTime, currentTime time.Time) bool {
+	if !config.Enabled || accessTime.Sub(accessedAt) >= config.Granularity {
+		return false
+	}
+	return currentTime.Sub(accessedAt) < config.InactiveDuration-config.Granularity
+}
+
+// CollectEventAdaptiveAggConfig adapts interval of collecting aggregated events to count of aggregated events,
+// interval is halved when the count reaches HighWaterMark and doubled when it is not greater than LowWaterMark,
+// within MinInterval and MaxInterval. agg_interval is the initial interval, it is fixed if adaptive_agg is disabled.
+type CollectEventAdaptiveAggConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	RawMinInterval string `yaml:"min_interval"`
+	MinInterval    time.Duration
+
+	RawMaxInterval string `yaml:"max_interval"`
+	MaxInterval    time.Duration
+
+	HighWaterMark int64 `yaml:"high_water_mark"`
+	LowWaterMark  int64 `yaml:"low_water_mark"`
+}
+
+func (config CollectEventAdaptiveAggConfig) check() error {
+	if !config.Enabled {
+		return nil
+	}
+	if config.RawMinInterval == "" {
+		return errors.New("min_interval should not be empty")
+	}
+	if config.RawMaxInterval == "" {
+		return errors.New("max_interval should not be empty")
+	}
+	if config.HighWaterMark <= 0 {
+		return fmt.Errorf("high_water_mark=%d, it should be greater than 0", config.HighWaterMark)
+	}
+	if config.LowWaterMark < 0 || config.LowWaterMark >= config.HighWaterMark {
+		return fmt.Errorf(
+			"low_water_mark=%d, it should be equal to or greater than 0 and less than high_water_mark", config.LowWaterMark)
+	}
+	return nil
+}
+
+func (config *CollectEventAdaptiveAggConfig) init() error {
+	minInterval, err := time.ParseDuration(config.RawMinInterval)
+	if err != nil {
+		return fmt.Errorf("min_interval=%s is invalid %w", config.RawMinInterval, err)
+	}
+	if minInterval <= 0 {
+		return fmt.Errorf("min_interval=%s, duration should be positive", config.RawMinInterval)
+	}
+	maxInterval, err := time.ParseDuration(config.RawMaxInterval)
+	if err != nil {
+		return fmt.Errorf("max_interval=%s is invalid %w", config.RawMaxInterval, err)
+	}
+	if maxInterval < minInterval {
+		return fmt.Errorf("max_interval=%s, it should not be less than min_interval", config.RawMaxInterval)
+	}
+	config.MinInterval = minInterval
+	config.MaxInterval = maxInterval
 	return nil
 }
 
@@ -408,6 +1001,11 @@ type RoomTaskConfig struct {
 	Coordinator  CoordinatorConfig      `yaml:"coordinator"`
 	SyncKeyTask  SyncKeyTaskConfig      `yaml:"sync_key_task"`
 	CleanKeyTask CleanKeyTaskConfig     `yaml:"clean_key_task"`
+
+	PurgeExpiredKeyTask  PurgeExpiredKeyTaskConfig  `yaml:"purge_expired_key_task"`
+	PurgeDeletedDataTask PurgeDeletedDataTaskConfig `yaml:"purge_deleted_data_task"`
+
+	AdminServer TaskAdminServerConfig `yaml:"admin_server"`
 }
 
 func (config RoomTaskConfig) check() error {
@@ -432,6 +1030,15 @@ func (config RoomTaskConfig) check() error {
 	if err := config.CleanKeyTask.check(); err != nil {
 		return fmt.Errorf("clean_key_task.%w", err)
 	}
+	if err := config.PurgeExpiredKeyTask.check(); err != nil {
+		return fmt.Errorf("purge_expired_key_task.%w", err)
+	}
+	if err := config.PurgeDeletedDataTask.check(); err != nil {
+		return fmt.Errorf("purge_deleted_data_task.%w", err)
+	}
+	if err := config.AdminServer.check(); err != nil {
+		return fmt.Errorf("admin_server.%w", err)
+	}
 	return nil
 }
 
@@ -447,12 +1054,30 @@ func (config *RoomTaskConfig) init() error {
 	}
 	config.SyncKeyTask.NoWrittenDuration = duration
 
+	if config.SyncKeyTask.WAL.Enabled {
+		rawReplayInterval := config.SyncKeyTask.WAL.RawReplayInterval
+		duration, err = time.ParseDuration(rawReplayInterval)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("sync_key_task.wal.replay_interval=%s is invalid", rawReplayInterval)
+		}
+		config.SyncKeyTask.WAL.ReplayInterval = duration
+	}
+
 	rawInactiveDuration := config.CleanKeyTask.RawInactiveDuration
 	duration, err = time.ParseDuration(rawInactiveDuration)
 	if err != nil {
 		return fmt.Errorf("clean_key_task.inactive_duration=%s is invalid %w", rawInactiveDuration, err)
 	}
 	config.CleanKeyTask.InactiveDuration = duration
+
+	if !config.PurgeDeletedDataTask.IsOff() {
+		rawRetentionDuration := config.PurgeDeletedDataTask.RawRetentionDuration
+		duration, err = time.ParseDuration(rawRetentionDuration)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("purge_deleted_data_task.retention_duration=%s is invalid", rawRetentionDuration)
+		}
+		config.PurgeDeletedDataTask.RetentionDuration = duration
+	}
 	return nil
 }
 
@@ -479,6 +1104,8 @@ type SyncKeyTaskConfig struct {
 
 	RawNoWrittenDuration string `yaml:"no_written_duration"`
 	NoWrittenDuration    time.Duration
+
+	WAL SyncKeyTaskWALConfig `yaml:"wal"`
 }
 
 func (config SyncKeyTaskConfig) check() error {
@@ -494,13 +1121,54 @@ func (config SyncKeyTaskConfig) check() error {
 	if config.RawNoWrittenDuration == "" {
 		return fmt.Errorf("no_written_duration should not be empty")
 	}
+	if err := config.WAL.check(); err != nil {
+		return fmt.Errorf("wal.%w", err)
+	}
 	return nil
 }
 
+// SyncKeyTaskWALConfig configures the local write ahead log of sync key task,
+// room data failed to be saved to db because of db connectivity errors is saved to the log
+// and replayed to db later, it trades consistency for availability so it is disabled by default.
+type SyncKeyTaskWALConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	FilePath    string `yaml:"file_path"`
+	MaxFileSize int64  `yaml:"max_file_size"`
+
+	RawReplayInterval string `yaml:"replay_interval"`
+	ReplayInterval    time.Duration
+}
+
+func (config SyncKeyTaskWALConfig) check() error {
+	if !config.Enabled {
+		return nil
+	}
+	if config.FilePath == "" {
+		return errors.New("file_path should not be empty")
+	}
+	if config.MaxFileSize <= 0 {
+		return fmt.Errorf("max_file_size is %d, it should be greater than 0", config.MaxFileSize)
+	}
+	if config.RawReplayInterval == "" {
+		return errors.New("replay_interval should not be empty")
+	}
+	return nil
+}
+
+const (
+	defaultCleanKeyTaskBatchSize           = 100
+	defaultCleanKeyTaskMaxExcludedHashTags = 2000
+)
+
 type CleanKeyTaskConfig struct {
 	IntervalMinutes    int  `yaml:"interval_minutes"`
 	Off                bool `yaml:"off"`
 	RateLimitPerSecond int  `yaml:"rate_limit_per_second"`
+	// BatchSize is count of records loaded from a shard at a time.
+	BatchSize int `yaml:"batch_size"`
+	// MaxExcludedHashTags is max count of hash tags failed to clean in a shard,
+	// they are excluded from queries of the shard and the task moves to the next shard when it is reached.
+	MaxExcludedHashTags int `yaml:"max_excluded_hash_tags"`
 
 	RawInactiveDuration string `yaml:"inactive_duration"`
 	InactiveDuration    time.Duration
@@ -513,8 +1181,121 @@ func (config CleanKeyTaskConfig) check() error {
 	if config.RateLimitPerSecond <= 0 {
 		return fmt.Errorf("rate_limit_per_second is %d, it should be greater than 0", config.RateLimitPerSecond)
 	}
+	if config.BatchSize < 0 {
+		return fmt.Errorf("batch_size=%d, it should not be negative", config.BatchSize)
+	}
+	if config.MaxExcludedHashTags < 0 {
+		return fmt.Errorf("max_excluded_hash_tags=%d, it should not be negative", config.MaxExcludedHashTags)
+	}
 	if config.RawInactiveDuration == "" {
 		return errors.New("inactive_duration should not be empty")
 	}
 	return nil
 }
+
+func (config CleanKeyTaskConfig) GetBatchSize() int {
+	if config.BatchSize == 0 {
+		return defaultCleanKeyTaskBatchSize
+	}
+	return config.BatchSize
+}
+
+func (config CleanKeyTaskConfig) GetMaxExcludedHashTags() int {
+	if config.MaxExcludedHashTags == 0 {
+		return defaultCleanKeyTaskMaxExcludedHashTags
+	}
+	return config.MaxExcludedHashTags
+}
+
+// PurgeExpiredKeyTaskConfig is config of task to remove expired keys from db,
+// the task is off if the section is absent, other fields are not checked when it is off.
+type PurgeExpiredKeyTaskConfig struct {
+	IntervalMinutes    int  `yaml:"interval_minutes"`
+	Off                bool `yaml:"off"`
+	BatchSize          int  `yaml:"batch_size"`
+	RateLimitPerSecond int  `yaml:"rate_limit_per_second"`
+}
+
+// IsOff returns true if the task is turned off or it is not configured.
+func (config PurgeExpiredKeyTaskConfig) IsOff() bool {
+	return config.Off || config == PurgeExpiredKeyTaskConfig{}
+}
+
+func (config PurgeExpiredKeyTaskConfig) check() error {
+	if config.IsOff() {
+		return nil
+	}
+	if config.IntervalMinutes <= 0 {
+		return fmt.Errorf("interval_minutes=%d, it should be greater than 0", config.IntervalMinutes)
+	}
+	if config.BatchSize <= 0 {
+		return fmt.Errorf("batch_size=%d, it should be greater than 0", config.BatchSize)
+	}
+	if config.RateLimitPerSecond <= 0 {
+		return fmt.Errorf("rate_limit_per_second is %d, it should be greater than 0", config.RateLimitPerSecond)
+	}
+	return nil
+}
+
+// PurgeDeletedDataTaskConfig is config of task to remove soft deleted room data from db,
+// rows are removed after they have been soft deleted for retention_duration.
+// The task is off if the section is absent, other fields are not checked when it is off.
+type PurgeDeletedDataTaskConfig struct {
+	IntervalMinutes int  `yaml:"interval_minutes"`
+	Off             bool `yaml:"off"`
+	BatchSize       int  `yaml:"batch_size"`
+
+	RawRetentionDuration string `yaml:"retention_duration"`
+	RetentionDuration    time.Duration
+}
+
+// IsOff returns true if the task is turned off or it is not configured.
+func (config PurgeDeletedDataTaskConfig) IsOff() bool {
+	return config.Off || config == PurgeDeletedDataTaskConfig{}
+}
+
+func (config PurgeDeletedDataTaskConfig) check() error {
+	if config.IsOff() {
+		return nil
+	}
+	if config.IntervalMinutes <= 0 {
+		return fmt.Errorf("interval_minutes=%d, it should be greater than 0", config.IntervalMinutes)
+	}
+	if config.BatchSize <= 0 {
+		return fmt.Errorf("batch_size=%d, it should be greater than 0", config.BatchSize)
+	}
+	if config.RawRetentionDuration == "" {
+		return errors.New("retention_duration should not be empty")
+	}
+	return nil
+}
+
+// TaskAdminServerConfig is config of http server to trigger and monitor tasks,
+// requests are authenticated by `Authorization: Bearer <token>` header.
+// The server is started only if Enabled is true, other fields are not checked otherwise.
+type TaskAdminServerConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	URL            string `yaml:"url"`
+	Token          string `yaml:"token"`
+	ReadTimeoutMS  int    `yaml:"read_timeout_ms"`
+	WriteTimeoutMS int    `yaml:"write_timeout_ms"`
+}
+
+func (config TaskAdminServerConfig) check() error {
+	if !config.Enabled {
+		return nil
+	}
+	if config.URL == "" {
+		return errors.New("url should not be empty")
+	}
+	if config.Token == "" {
+		return errors.New("token should not be empty")
+	}
+	if config.ReadTimeoutMS <= 0 {
+		return fmt.Errorf("read_timeout_ms is %d, it should be greater than 0", config.ReadTimeoutMS)
+	}
+	if config.WriteTimeoutMS <= 0 {
+		return fmt.Errorf("write_timeout_ms is %d, it should be greater than 0", config.WriteTimeoutMS)
+	}
+	return nil
+}
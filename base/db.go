@@ -2,10 +2,13 @@ package base
 
 import (
 	"bytepower_room/base/log"
+	"bytepower_room/utility"
 	"context"
 	"errors"
 	"fmt"
 	"hash/crc32"
+	"hash/crc64"
+	"hash/fnv"
 	"strings"
 	"time"
 
@@ -13,15 +16,81 @@ import (
 	"github.com/go-pg/pg/v10/orm"
 )
 
+const (
+	ShardingStrategyCRC32 = "crc32"
+	ShardingStrategyCRC64 = "crc64"
+	ShardingStrategyFNV1a = "fnv1a"
+)
+
+var ErrTableMissing = errors.New("table is missing")
+
+var ErrColumnMissing = errors.New("column is missing")
+
+// ErrShardUnavailable means no db client serves a table index, it is wrapped by ShardUnavailableError.
+var ErrShardUnavailable = errors.New("db shard is unavailable")
+
+// ShardUnavailableError is returned if the db client of a table index is not available,
+// the command could be retried since other shards are still available.
+type ShardUnavailableError struct {
+	TableIndex int
+}
+
+func (err *ShardUnavailableError) Error() string {
+	return fmt.Sprintf("%s, table_index=%d", ErrShardUnavailable.Error(), err.TableIndex)
+}
+
+func (err *ShardUnavailableError) Unwrap() error {
+	return ErrShardUnavailable
+}
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// shardingHashFuncs maps sharding strategy to hash function of sharding key.
+var shardingHashFuncs = map[string]func([]byte) uint64{
+	ShardingStrategyCRC32: func(data []byte) uint64 {
+		return uint64(crc32.ChecksumIEEE(data))
+	},
+	ShardingStrategyCRC64: func(data []byte) uint64 {
+		return crc64.Checksum(data, crc64Table)
+	},
+	ShardingStrategyFNV1a: func(data []byte) uint64 {
+		h := fnv.New32a()
+		h.Write(data)
+		return uint64(h.Sum32())
+	},
+}
+
 type DBClusterConfig struct {
 	ShardingCount int        `yaml:"sharding_count"`
 	Shardings     []DBConfig `yaml:"shardings"`
+
+	// ShardingStrategy is the hash function to map sharding key to table index,
+	// it is one of crc32(default), crc64 and fnv1a.
+	// IMPORTANT: changing the strategy of an existing cluster requires a full data migration,
+	// otherwise existing data can not be found.
+	ShardingStrategy string `yaml:"sharding_strategy"`
+
+	// AutoMigrate creates tables which do not exist on startup by running migrations.
+	AutoMigrate bool `yaml:"auto_migrate"`
+
+	// ReplicaPollIntervalMS is the interval to poll replay positions of replicas for wait command.
+	ReplicaPollIntervalMS int `yaml:"replica_poll_interval_ms"`
 }
 
 func (config DBClusterConfig) check() error {
 	if config.ShardingCount <= 0 {
 		return errors.New("sharding_count should be greater than 0")
 	}
+	if config.ReplicaPollIntervalMS < 0 {
+		return errors.New("replica_poll_interval_ms should be equal to or greater than 0")
+	}
+	if config.ShardingStrategy != "" {
+		if _, ok := shardingHashFuncs[config.ShardingStrategy]; !ok {
+			return fmt.Errorf(
+				"sharding_strategy=%s, it should be one of %s, %s and %s",
+				config.ShardingStrategy, ShardingStrategyCRC32, ShardingStrategyCRC64, ShardingStrategyFNV1a)
+		}
+	}
 	for index, sharding := range config.Shardings {
 		if err := sharding.check(); err != nil {
 			return fmt.Errorf("shardings.%d.%w", index, err)
@@ -30,8 +99,49 @@ func (config DBClusterConfig) check() error {
 	return nil
 }
 
+// checkShardingCoverage checks that every sharding index in [0, sharding_count)
+// is covered by exactly one sharding.
+func (config DBClusterConfig) checkShardingCoverage() error {
+	if config.ShardingCount <= 0 {
+		return errors.New("sharding_count should be greater than 0")
+	}
+	coveredCounts := make([]int, config.ShardingCount)
+	for index, sharding := range config.Shardings {
+		if sharding.StartShardingIndex < 0 || sharding.EndShardingIndex >= config.ShardingCount {
+			return fmt.Errorf(
+				"shardings.%d, range [%d, %d] is out of [0, %d)",
+				index, sharding.StartShardingIndex, sharding.EndShardingIndex, config.ShardingCount)
+		}
+		for i := sharding.StartShardingIndex; i <= sharding.EndShardingIndex; i++ {
+			coveredCounts[i]++
+		}
+	}
+	uncoveredIndexes := make([]int, 0)
+	overlappedIndexes := make([]int, 0)
+	for i, count := range coveredCounts {
+		if count == 0 {
+			uncoveredIndexes = append(uncoveredIndexes, i)
+		} else if count > 1 {
+			overlappedIndexes = append(overlappedIndexes, i)
+		}
+	}
+	problems := make([]string, 0, 2)
+	if len(uncoveredIndexes) > 0 {
+		problems = append(problems, fmt.Sprintf("sharding indexes %v are not covered by any sharding", uncoveredIndexes))
+	}
+	if len(overlappedIndexes) > 0 {
+		problems = append(problems, fmt.Sprintf("sharding indexes %v are covered by more than one sharding", overlappedIndexes))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
+}
+
 type DBConfig struct {
 	URL string `yaml:"url"`
+	// ReplicaURLs are urls of read replicas of the db, they share connection options with the db.
+	ReplicaURLs []string `yaml:"replica_urls"`
 
 	Connection connectionConfig `yaml:",inline"`
 
@@ -43,6 +153,11 @@ func (config DBConfig) check() error {
 	if config.URL == "" {
 		return errors.New("url should not be empty")
 	}
+	for index, url := range config.ReplicaURLs {
+		if url == "" {
+			return fmt.Errorf("replica_urls.%d should not be empty", index)
+		}
+	}
 	if err := config.Connection.check(); err != nil {
 		return err
 	}
@@ -59,14 +174,19 @@ func (config DBConfig) check() error {
 }
 
 type DBCluster struct {
-	clients       []dbClient
-	shardingCount int
+	clients             []dbClient
+	shardingCount       int
+	shardingStrategy    string
+	replicaPollInterval time.Duration
 }
 
 type dbClient struct {
 	startIndex int
 	endIndex   int
 	client     *pg.DB
+	replicas   []*pg.DB
+	// writeLSN is only tracked for db with replicas.
+	writeLSN *walLSN
 }
 
 func (client dbClient) string() string {
@@ -85,23 +205,72 @@ type Model interface {
 	GetTablePrefix() string
 }
 
-func NewDBClusterFromConfig(config DBClusterConfig, logger *log.Logger, metric *MetricClient) (*DBCluster, error) {
+// ShardingModel is implemented by models which choose table index by themselves instead of
+// hashing sharding key by the strategy of the cluster, e.g. records sharded by time bucket,
+// so that their tables could be sharded independently from data tables.
+type ShardingModel interface {
+	Model
+	// TableIndex returns index of the table of the model, it should be in [0, shardingCount).
+	TableIndex(shardingCount int) int
+}
+
+func NewDBClusterFromConfig(config DBClusterConfig, logger *log.Logger, metric Metric) (*DBCluster, error) {
 	shardingCount := config.ShardingCount
 	if shardingCount <= 0 {
 		return nil, errors.New("sharding_count should be greater than 0")
 	}
-	dbCluster := &DBCluster{shardingCount: shardingCount, clients: make([]dbClient, 0)}
+	if err := config.checkShardingCoverage(); err != nil {
+		return nil, err
+	}
+	shardingStrategy := config.ShardingStrategy
+	if shardingStrategy == "" {
+		shardingStrategy = ShardingStrategyCRC32
+	}
+	if _, ok := shardingHashFuncs[shardingStrategy]; !ok {
+		return nil, fmt.Errorf("sharding_strategy=%s is not supported", shardingStrategy)
+	}
+	dbCluster := &DBCluster{
+		shardingCount:       shardingCount,
+		shardingStrategy:    shardingStrategy,
+		clients:             make([]dbClient, 0),
+		replicaPollInterval: time.Duration(config.ReplicaPollIntervalMS) * time.Millisecond,
+	}
 	for _, cfg := range config.Shardings {
 		client, err := newDBClient(cfg)
 		if err != nil {
 			return nil, err
 		}
+		replicas := make([]*pg.DB, 0, len(cfg.ReplicaURLs))
+		for _, url := range cfg.ReplicaURLs {
+			replicaConfig := cfg
+			replicaConfig.URL = url
+			replica, err := newDBClient(replicaConfig)
+			if err != nil {
+				return nil, err
+			}
+			replicas = append(replicas, replica)
+		}
+		var writeLSN *walLSN
+		if len(replicas) > 0 {
+			writeLSN = &walLSN{}
+		}
+		client.AddQueryHook(dbLogger{
+			logger:       logger,
+			metricClient: metric,
+			sharding:     fmt.Sprintf("%d_%d", cfg.StartShardingIndex, cfg.EndShardingIndex),
+			primary:      client,
+			writeLSN:     writeLSN,
+		})
 		dbCluster.clients = append(
 			dbCluster.clients,
-			dbClient{startIndex: cfg.StartShardingIndex, endIndex: cfg.EndShardingIndex, client: client})
+			dbClient{
+				startIndex: cfg.StartShardingIndex,
+				endIndex:   cfg.EndShardingIndex,
+				client:     client,
+				replicas:   replicas,
+				writeLSN:   writeLSN,
+			})
 	}
-	queryHook := dbLogger{logger: logger, metricClient: metric}
-	dbCluster.AddQueryHook(queryHook)
 	return dbCluster, nil
 }
 
@@ -166,7 +335,9 @@ func (dbCluster *DBCluster) String() string {
 	for _, client := range dbCluster.clients {
 		clientStrings = append(clientStrings, client.string())
 	}
-	return fmt.Sprintf("sharding_count:%d, clients=[%s]", dbCluster.shardingCount, strings.Join(clientStrings, " "))
+	return fmt.Sprintf(
+		"sharding_count:%d, sharding_strategy:%s, clients=[%s]",
+		dbCluster.shardingCount, dbCluster.shardingStrategy, strings.Join(clientStrings, " "))
 }
 
 func (dbCluster *DBCluster) Model(model Model) (*orm.Query, error) {
@@ -181,7 +352,7 @@ func (dbCluster *DBCluster) Models(models interface{}, tablePrefix string, table
 	tableName := fmt.Sprintf("%s_%d", tablePrefix, tableIndex)
 	client := dbCluster.getClientByIndex(tableIndex)
 	if client == nil {
-		return nil, errors.New("no db client found")
+		return nil, &ShardUnavailableError{TableIndex: tableIndex}
 	}
 	return client.Model(models).Table(tableName), nil
 }
@@ -195,37 +366,206 @@ func (dbCluster *DBCluster) getClientByIndex(index int) *pg.DB {
 	return nil
 }
 
+// GetClientIndex returns index of the db client serving shardingKey in the cluster, -1 if there is none.
+func (dbCluster *DBCluster) GetClientIndex(shardingKey string) int {
+	tableIndex := dbCluster.GetShardingIndex(shardingKey)
+	for i, client := range dbCluster.clients {
+		if (client.startIndex <= tableIndex) && (tableIndex <= client.endIndex) {
+			return i
+		}
+	}
+	return -1
+}
+
 func (dbCluster *DBCluster) GetTableNameAndDBClientByModel(model Model) (string, *pg.DB, error) {
-	shardingKey := model.ShardingKey()
-	tableIndex := getTableIndex(shardingKey, dbCluster.shardingCount)
+	tableIndex, err := dbCluster.GetShardingIndexByModel(model)
+	if err != nil {
+		return "", nil, err
+	}
 	client := dbCluster.getClientByIndex(tableIndex)
 	if client == nil {
-		return "", nil, errors.New("no db client found")
+		return "", nil, &ShardUnavailableError{TableIndex: tableIndex}
 	}
 	tableName := fmt.Sprintf("%s_%d", model.GetTablePrefix(), tableIndex)
 	return tableName, client, nil
 }
 
+// HealthCheck checks that every sharding is reachable and tables with tablePrefixes exist,
+// it returns an error wrapping ErrTableMissing if any table is missing.
+func (dbCluster *DBCluster) HealthCheck(ctx context.Context, tablePrefixes ...string) error {
+	problems := make([]string, 0)
+	missingTables := make([]string, 0)
+	for _, client := range dbCluster.clients {
+		if _, err := client.client.ExecContext(ctx, "SELECT 1"); err != nil {
+			problems = append(
+				problems,
+				fmt.Sprintf("sharding [%d, %d] is unreachable, %s", client.startIndex, client.endIndex, err))
+			continue
+		}
+		tables, err := client.getMissingTables(ctx, tablePrefixes)
+		if err != nil {
+			problems = append(
+				problems,
+				fmt.Sprintf("sharding [%d, %d] query tables error, %s", client.startIndex, client.endIndex, err))
+			continue
+		}
+		missingTables = append(missingTables, tables...)
+	}
+	if len(missingTables) > 0 {
+		problems = append([]string{fmt.Sprintf("tables=%v", missingTables)}, problems...)
+		return fmt.Errorf("%w, %s", ErrTableMissing, strings.Join(problems, ", "))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+func (client dbClient) getMissingTables(ctx context.Context, tablePrefixes []string) ([]string, error) {
+	expectedTables := make([]string, 0)
+	for _, tablePrefix := range tablePrefixes {
+		for index := client.startIndex; index <= client.endIndex; index++ {
+			expectedTables = append(expectedTables, fmt.Sprintf("%s_%d", tablePrefix, index))
+		}
+	}
+	if len(expectedTables) == 0 {
+		return nil, nil
+	}
+	var existedTables []string
+	_, err := client.client.QueryContext(
+		ctx,
+		&existedTables,
+		"SELECT table_name::text FROM information_schema.tables WHERE table_schema = current_schema() AND table_name IN (?)",
+		pg.In(expectedTables))
+	if err != nil {
+		return nil, err
+	}
+	existed := make(map[string]bool, len(existedTables))
+	for _, table := range existedTables {
+		existed[table] = true
+	}
+	missingTables := make([]string, 0)
+	for _, table := range expectedTables {
+		if !existed[table] {
+			missingTables = append(missingTables, table)
+		}
+	}
+	return missingTables, nil
+}
+
+// CheckColumns checks that columns exist in every table with tablePrefix, columns added by migrations are missing
+// in existing deployments which neither run migrations nor upgrade sql, it returns an error wrapping ErrColumnMissing
+// if any column is missing.
+func (dbCluster *DBCluster) CheckColumns(ctx context.Context, tablePrefix string, columns ...string) error {
+	missingColumns := make([]string, 0)
+	for _, client := range dbCluster.clients {
+		tables := make([]string, 0, client.endIndex-client.startIndex+1)
+		for index := client.startIndex; index <= client.endIndex; index++ {
+			tables = append(tables, fmt.Sprintf("%s_%d", tablePrefix, index))
+		}
+		var existedColumns []string
+		_, err := client.client.QueryContext(
+			ctx,
+			&existedColumns,
+			`SELECT table_name::text || '.' || column_name::text FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name IN (?) AND column_name IN (?)`,
+			pg.In(tables), pg.In(columns))
+		if err != nil {
+			return fmt.Errorf("sharding [%d, %d] query columns error, %w", client.startIndex, client.endIndex, err)
+		}
+		existed := make(map[string]bool, len(existedColumns))
+		for _, column := range existedColumns {
+			existed[column] = true
+		}
+		for _, table := range tables {
+			for _, column := range columns {
+				if name := fmt.Sprintf("%s.%s", table, column); !existed[name] {
+					missingColumns = append(missingColumns, name)
+				}
+			}
+		}
+	}
+	if len(missingColumns) > 0 {
+		return fmt.Errorf("%w, columns=%v", ErrColumnMissing, missingColumns)
+	}
+	return nil
+}
+
 func (dbCluser *DBCluster) GetShardingCount() int {
 	return dbCluser.shardingCount
 }
 
+func (dbCluster *DBCluster) GetShardingStrategy() string {
+	return dbCluster.shardingStrategy
+}
+
 func (dbCluster *DBCluster) GetShardingIndex(shardingKey string) int {
-	return getTableIndex(shardingKey, dbCluster.shardingCount)
+	return getTableIndex(dbCluster.shardingStrategy, shardingKey, dbCluster.shardingCount)
+}
+
+// GetShardingIndexByModel returns table index of model, which is chosen by model if it implements ShardingModel,
+// otherwise sharding key of model is hashed by the strategy of the cluster.
+func (dbCluster *DBCluster) GetShardingIndexByModel(model Model) (int, error) {
+	shardingModel, ok := model.(ShardingModel)
+	if !ok {
+		return dbCluster.GetShardingIndex(model.ShardingKey()), nil
+	}
+	tableIndex := shardingModel.TableIndex(dbCluster.shardingCount)
+	if tableIndex < 0 || tableIndex >= dbCluster.shardingCount {
+		return 0, fmt.Errorf(
+			"table index %d of %s is out of range [0, %d)", tableIndex, model.GetTablePrefix(), dbCluster.shardingCount)
+	}
+	return tableIndex, nil
+}
+
+// GetTableIndexByStrategy returns table index of shardingKey by hash function of strategy,
+// it could be used by ShardingModel to hash by a strategy other than the one of the cluster.
+func GetTableIndexByStrategy(strategy string, shardingKey string, shardingCount int) int {
+	return getTableIndex(strategy, shardingKey, shardingCount)
 }
 
-func getTableIndex(shardingKey string, shardingCount int) int {
-	return int(crc32.ChecksumIEEE([]byte(shardingKey)) % uint32(shardingCount))
+// getTableIndex returns table index of shardingKey by hash function of strategy, crc32 is used by default.
+func getTableIndex(strategy string, shardingKey string, shardingCount int) int {
+	hashFunc, ok := shardingHashFuncs[strategy]
+	if !ok {
+		hashFunc = shardingHashFuncs[ShardingStrategyCRC32]
+	}
+	return int(hashFunc([]byte(shardingKey)) % uint64(shardingCount))
 }
 
 const (
 	dbQueryStartTimeContextKey = "query_start_time"
-	dbQueryDurationMetricKey   = "database.query.duration"
+	dbQueryDurationMetricKey   = "db.query.duration"
+	dbQueryErrorMetricKey      = "db.query.error"
+	dbQueryOperationOther      = "other"
 )
 
+var dbQueryOperations = []string{"select", "insert", "update", "delete"}
+
+// getDBQueryOperation infers operation of query from its first keyword,
+// dbQueryOperationOther is returned for queries other than select, insert, update and delete.
+func getDBQueryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return dbQueryOperationOther
+	}
+	operation := strings.ToLower(fields[0])
+	if !utility.StringSliceContains(dbQueryOperations, operation) {
+		return dbQueryOperationOther
+	}
+	return operation
+}
+
+// dbLogger logs queries and records query duration and errors of a db client,
+// metrics are tagged by sharding and operation, e.g. db.query.duration.sharding_0_511.select.
 type dbLogger struct {
 	logger       *log.Logger
-	metricClient *MetricClient
+	metricClient Metric
+	// sharding is the table index range of the db client, e.g. 0_511.
+	sharding string
+	primary  *pg.DB
+	// writeLSN is nil if the db has no replicas.
+	writeLSN *walLSN
 }
 
 func (d dbLogger) BeforeQuery(ctx context.Context, queryEvent *pg.QueryEvent) (context.Context, error) {
@@ -238,14 +578,29 @@ func (d dbLogger) AfterQuery(ctx context.Context, queryEvent *pg.QueryEvent) err
 		d.logger.Error("dbLogger error", log.Error(err))
 		return err
 	}
+	operation := getDBQueryOperation(string(query))
 	if startTime, ok := ctx.Value(dbQueryStartTimeContextKey).(time.Time); ok {
 		duration := time.Since(startTime)
 		d.logger.Debug(
 			dbQueryDurationMetricKey,
 			log.String("query", string(query)),
+			log.String("sharding", d.sharding),
 			log.String("duration", duration.String()),
 		)
 		d.metricClient.MetricTimeDuration(dbQueryDurationMetricKey, duration)
+		d.metricClient.MetricTimeDuration(d.metricKey(dbQueryDurationMetricKey, operation), duration)
+	}
+	// no rows is an expected result instead of a failure of query.
+	if queryEvent.Err != nil && !errors.Is(queryEvent.Err, pg.ErrNoRows) {
+		d.metricClient.MetricIncrease(dbQueryErrorMetricKey)
+		d.metricClient.MetricIncrease(d.metricKey(dbQueryErrorMetricKey, operation))
+	}
+	if d.writeLSN != nil && queryEvent.Err == nil && isDBWriteQuery(string(query)) {
+		d.recordWriteLSN(ctx)
 	}
 	return nil
 }
+
+func (d dbLogger) metricKey(key, operation string) string {
+	return fmt.Sprintf("%s.sharding_%s.%s", key, d.sharding, operation)
+}
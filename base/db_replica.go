@@ -0,0 +1,126 @@
+package base
+
+import (
+	"bytepower_room/base/log"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+const defaultReplicaPollInterval = 10 * time.Millisecond
+
+// walLSN saves the latest write ahead log position of writes to a primary db.
+type walLSN struct {
+	value uint64
+}
+
+func (lsn *walLSN) get() uint64 {
+	return atomic.LoadUint64(&lsn.value)
+}
+
+// update sets lsn to value if value is greater than the current one.
+func (lsn *walLSN) update(value uint64) {
+	for {
+		current := atomic.LoadUint64(&lsn.value)
+		if value <= current || atomic.CompareAndSwapUint64(&lsn.value, current, value) {
+			return
+		}
+	}
+}
+
+// parseWALLSN parses lsn of postgresql, e.g. 16/B374D848.
+func parseWALLSN(lsn string) (uint64, error) {
+	parts := strings.Split(lsn, "/")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid lsn %s", lsn)
+	}
+	high, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %s", lsn)
+	}
+	low, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %s", lsn)
+	}
+	return high<<32 | low, nil
+}
+
+func queryWALLSN(ctx context.Context, db *pg.DB, query string) (uint64, error) {
+	var lsn string
+	if _, err := db.QueryOneContext(ctx, pg.Scan(&lsn), query); err != nil {
+		return 0, err
+	}
+	return parseWALLSN(lsn)
+}
+
+func isDBWriteQuery(query string) bool {
+	switch getDBQueryOperation(query) {
+	case "insert", "update", "delete":
+		return true
+	}
+	return strings.EqualFold(strings.TrimSpace(query), "commit")
+}
+
+// recordWriteLSN saves the current lsn of primary db after a write query,
+// it is only called for primary dbs with replicas.
+func (d dbLogger) recordWriteLSN(ctx context.Context) {
+	lsn, err := queryWALLSN(ctx, d.primary, "SELECT pg_current_wal_lsn()::text")
+	if err != nil {
+		d.logger.Error("dbLogger record write lsn error", log.String("sharding", d.sharding), log.Error(err))
+		return
+	}
+	d.writeLSN.update(lsn)
+}
+
+func (dbCluster *DBCluster) ReplicaCount() int {
+	count := 0
+	for _, client := range dbCluster.clients {
+		count += len(client.replicas)
+	}
+	return count
+}
+
+// WaitForReplicas polls replicas of all shardings until at least numReplicas replicas
+// have replayed the latest writes to their primary dbs or ctx is done,
+// it returns count of replicas which have caught up.
+func (dbCluster *DBCluster) WaitForReplicas(ctx context.Context, numReplicas int) int {
+	interval := dbCluster.replicaPollInterval
+	if interval <= 0 {
+		interval = defaultReplicaPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		count := dbCluster.countCaughtUpReplicas(ctx)
+		if count >= numReplicas {
+			return count
+		}
+		select {
+		case <-ctx.Done():
+			return count
+		case <-ticker.C:
+		}
+	}
+}
+
+func (dbCluster *DBCluster) countCaughtUpReplicas(ctx context.Context) int {
+	count := 0
+	for _, client := range dbCluster.clients {
+		if len(client.replicas) == 0 {
+			continue
+		}
+		target := client.writeLSN.get()
+		for _, replica := range client.replicas {
+			lsn, err := queryWALLSN(ctx, replica, "SELECT pg_last_wal_replay_lsn()::text")
+			if err == nil && lsn >= target {
+				count++
+			}
+		}
+	}
+	return count
+}
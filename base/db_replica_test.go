@@ -0,0 +1,55 @@
+package base
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWALLSN(t *testing.T) {
+	lsn, err := parseWALLSN("16/B374D848")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0x16B374D848), lsn)
+
+	lsn1, _ := parseWALLSN("0/FFFFFFFF")
+	lsn2, _ := parseWALLSN("1/0")
+	assert.True(t, lsn1 < lsn2)
+
+	for _, invalid := range []string{"", "16", "16/", "x/1", "1/2/3"} {
+		_, err := parseWALLSN(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestWALLSNUpdate(t *testing.T) {
+	lsn := &walLSN{}
+	lsn.update(10)
+	assert.Equal(t, uint64(10), lsn.get())
+	lsn.update(5)
+	assert.Equal(t, uint64(10), lsn.get())
+	lsn.update(20)
+	assert.Equal(t, uint64(20), lsn.get())
+}
+
+func TestIsDBWriteQuery(t *testing.T) {
+	assert.True(t, isDBWriteQuery("INSERT INTO room_data_v2_0 VALUES (1)"))
+	assert.True(t, isDBWriteQuery("update room_data_v2_0 set value = 1"))
+	assert.True(t, isDBWriteQuery("DELETE FROM room_data_v2_0"))
+	assert.True(t, isDBWriteQuery("COMMIT"))
+	assert.False(t, isDBWriteQuery("SELECT 1"))
+	assert.False(t, isDBWriteQuery("BEGIN"))
+}
+
+func TestDBClusterWaitForReplicasWithoutReplicas(t *testing.T) {
+	dbCluster := &DBCluster{clients: []dbClient{{startIndex: 0, endIndex: 1}}}
+	assert.Equal(t, 0, dbCluster.ReplicaCount())
+	assert.Equal(t, 0, dbCluster.WaitForReplicas(context.Background(), 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	startTime := time.Now()
+	assert.Equal(t, 0, dbCluster.WaitForReplicas(ctx, 1))
+	assert.True(t, time.Since(startTime) >= 20*time.Millisecond)
+}
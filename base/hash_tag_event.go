@@ -1,12 +1,20 @@
 package base
 
 import (
+	"bufio"
 	"bytepower_room/base/log"
 	"bytepower_room/utility"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync/atomic"
 
@@ -25,14 +33,70 @@ var (
 
 const HTTPContentTypeJSON = "application/json"
 
+const (
+	HTTPHeaderContentType     = "Content-Type"
+	HTTPHeaderContentEncoding = "Content-Encoding"
+	CompressionAlgorithmGzip  = "gzip"
+)
+
+// HTTPHeaderEventPayloadVersion is the header of version of reported events payload, version 1 is assumed if it is absent.
+// Version 1 payload is `{"events":[...]}`,
+// version 2 payload is `{"metadata":{"version":2,"event_count":1,"created_at":"..."},"events":[...]}`.
+const HTTPHeaderEventPayloadVersion = "X-Room-Event-Version"
+
+const (
+	EventPayloadVersion1 = 1
+	EventPayloadVersion2 = 2
+)
+
+// HTTPStatusEventPayloadVersionMismatch is responded by event report server if version of payload is not supported.
+const HTTPStatusEventPayloadVersionMismatch = http.StatusPreconditionFailed
+
+// EventPayloadMetadata is the metadata envelope of payload since version 2.
+type EventPayloadMetadata struct {
+	Version    int       `json:"version"`
+	EventCount int       `json:"event_count"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// EventPayload is the payload of reported events, Metadata is nil in version 1.
+type EventPayload struct {
+	Metadata *EventPayloadMetadata `json:"metadata,omitempty"`
+	Events   []HashTagEvent        `json:"events"`
+}
+
+func NewEventPayload(version int, events []HashTagEvent, createdAt time.Time) EventPayload {
+	payload := EventPayload{Events: events}
+	if version >= EventPayloadVersion2 {
+		payload.Metadata = &EventPayloadMetadata{Version: version, EventCount: len(events), CreatedAt: createdAt}
+	}
+	return payload
+}
+
 const HashTagEventServiceName = "hash_tag_event_service"
 
 var (
 	metricReportEventsError   = fmt.Sprintf("%s.error.report_events", HashTagEventServiceName)
 	metricSendEventPanic      = fmt.Sprintf("%s.error.send_event_panic", HashTagEventServiceName)
+	metricReportEventsPanic   = fmt.Sprintf("%s.error.report_events_panic", HashTagEventServiceName)
+	metricWorkerPanic         = fmt.Sprintf("%s.error.worker.panic", HashTagEventServiceName)
 	metricAggregateEventError = fmt.Sprintf("%s.error.agg_event", HashTagEventServiceName)
+	metricDrainEventsTimeout  = fmt.Sprintf("%s.error.drain_events_timeout", HashTagEventServiceName)
+	metricUndrainedEventCount = fmt.Sprintf("%s.error.undrained_event", HashTagEventServiceName)
+
+	metricReportEventsRetryExhausted = fmt.Sprintf("%s.error.report_events_retry_exhausted", HashTagEventServiceName)
+
+	metricReportEventsVersionMismatch = fmt.Sprintf("%s.error.report_events_version_mismatch", HashTagEventServiceName)
 
 	metricReportEventsSuccess = fmt.Sprintf("%s.report_events", HashTagEventServiceName)
+	metricReportEventsRetry   = fmt.Sprintf("%s.report_events_retry", HashTagEventServiceName)
+
+	metricEventCompressionRatio = fmt.Sprintf("%s.event.compression.ratio", HashTagEventServiceName)
+
+	metricEventReportFailover = fmt.Sprintf("%s.event.report.failover", HashTagEventServiceName)
+	metricEventReportFailback = fmt.Sprintf("%s.event.report.failback", HashTagEventServiceName)
+
+	metricReadEventSampledOut = fmt.Sprintf("%s.read_event_sampled_out", HashTagEventServiceName)
 
 	metricEventCountInEventBuffer          = fmt.Sprintf("%s.event_in_buffer.total", HashTagEventServiceName)
 	metricEventCountInCollectedEventBuffer = fmt.Sprintf("%s.event_in_collected_buffer.total", HashTagEventServiceName)
@@ -125,28 +189,64 @@ func MergeEvents(event HashTagEvent, events ...HashTagEvent) (HashTagEvent, erro
 type HashTagEventServiceConfig struct {
 	EventReport HashTagEventServiceEventReportConfig `yaml:"event_report"`
 
+	DeadLetter HashTagEventDeadLetterConfig `yaml:"dead_letter"`
+
 	RawAggInterval string `yaml:"agg_interval"`
 	AggInterval    time.Duration
 
+	// RawPerPrefixAggInterval overrides agg_interval for hash tags with the prefix,
+	// the longest matched prefix is used if a hash tag matches multiple prefixes.
+	RawPerPrefixAggInterval map[string]string `yaml:"per_prefix_agg_interval"`
+	PerPrefixAggInterval    map[string]time.Duration
+
 	BufferLimit int `yaml:"buffer_limit"`
 
 	RawMonitorInterval string `yaml:"monitor_interval"`
 	MonitorInterval    time.Duration
+
+	// DrainDuration is max duration to report aggregated events when service stops.
+	RawDrainDuration string `yaml:"drain_duration"`
+	DrainDuration    time.Duration
+
+	// ReadEventSampleRate keeps 1 in ReadEventSampleRate read events, 0 or 1 means no sampling.
+	// Write events are never sampled out.
+	ReadEventSampleRate int `yaml:"read_event_sample_rate"`
+
+	// Disabled makes event service a no-op for read-only replicas,
+	// events are discarded and no goroutine is started.
+	Disabled bool `yaml:"disabled"`
 }
 
 func (config HashTagEventServiceConfig) check() error {
 	if err := config.EventReport.check(); err != nil {
 		return fmt.Errorf("event_report.%w", err)
 	}
+	if err := config.DeadLetter.check(); err != nil {
+		return fmt.Errorf("dead_letter.%w", err)
+	}
 	if config.RawAggInterval == "" {
 		return errors.New("agg_interval should not be empty")
 	}
+	for prefix, interval := range config.RawPerPrefixAggInterval {
+		if prefix == "" {
+			return errors.New("per_prefix_agg_interval prefix should not be empty")
+		}
+		if interval == "" {
+			return fmt.Errorf("per_prefix_agg_interval.%s should not be empty", prefix)
+		}
+	}
 	if config.BufferLimit <= 0 {
 		return fmt.Errorf("buffer_limit=%d, it should be greater than 0", config.BufferLimit)
 	}
 	if config.RawMonitorInterval == "" {
 		return errors.New("monitor_interval should not be empty")
 	}
+	if config.RawDrainDuration == "" {
+		return errors.New("drain_duration should not be empty")
+	}
+	if config.ReadEventSampleRate < 0 {
+		return fmt.Errorf("read_event_sample_rate=%d, it should not be less than 0", config.ReadEventSampleRate)
+	}
 	return nil
 
 }
@@ -171,6 +271,65 @@ type HashTagEventServiceEventReportConfig struct {
 	RequestIdleConnTimeout    time.Duration
 
 	RequestMaxConn int `yaml:"request_max_conn"`
+
+	// MaxRetries is the max retry times of a failed report request, 0 means no retry.
+	MaxRetries int `yaml:"max_retries"`
+
+	// Backoff before retries grows from InitialBackoff to MaxBackoff,
+	// empty means defaultReportEventsInitialBackoff and defaultReportEventsMaxBackoff.
+	RawInitialBackoff string `yaml:"initial_backoff"`
+	InitialBackoff    time.Duration
+
+	RawMaxBackoff string `yaml:"max_backoff"`
+	MaxBackoff    time.Duration
+
+	// Request body is compressed if CompressionEnabled is true,
+	// the event report server should support `Content-Encoding` header.
+	// Only gzip is supported now.
+	CompressionEnabled   bool   `yaml:"compression_enabled"`
+	CompressionAlgorithm string `yaml:"compression_algorithm"`
+
+	// Events are encoded and streamed to the request body if StreamEncodingEnabled is true,
+	// it reduces memory usage of large requests, and events are encoded again if the request is retried.
+	StreamEncodingEnabled bool `yaml:"stream_encoding_enabled"`
+
+	// FallbackURLs are tried in order if the request to the current url fails,
+	// the current url is switched to the next one after FailoverThreshold consecutive failures,
+	// and the primary url is probed every ProbeInterval to switch back when it recovers.
+	FallbackURLs      []string `yaml:"fallback_urls"`
+	FailoverThreshold int      `yaml:"failover_threshold"`
+
+	RawProbeInterval string `yaml:"probe_interval"`
+	ProbeInterval    time.Duration
+
+	// MaxErrorBodySize is the max bytes of a non-200 response body read into the report error,
+	// 0 means defaultReportEventsMaxErrorBodySize.
+	MaxErrorBodySize int64 `yaml:"max_error_body_size"`
+
+	// PayloadVersion is the version of request body, which is sent in header X-Room-Event-Version,
+	// 0 means version 1.
+	PayloadVersion int `yaml:"payload_version"`
+}
+
+const defaultReportEventsMaxErrorBodySize = 4 * 1024
+
+const (
+	defaultReportEventsInitialBackoff = 100 * time.Millisecond
+	defaultReportEventsMaxBackoff     = 2 * time.Second
+)
+
+func (config HashTagEventServiceEventReportConfig) payloadVersion() int {
+	if config.PayloadVersion == 0 {
+		return EventPayloadVersion1
+	}
+	return config.PayloadVersion
+}
+
+func (config HashTagEventServiceEventReportConfig) maxErrorBodySize() int64 {
+	if config.MaxErrorBodySize == 0 {
+		return defaultReportEventsMaxErrorBodySize
+	}
+	return config.MaxErrorBodySize
 }
 
 func (config HashTagEventServiceEventReportConfig) check() error {
@@ -198,6 +357,36 @@ func (config HashTagEventServiceEventReportConfig) check() error {
 	if config.RequestMaxConn <= 0 {
 		return fmt.Errorf("request_max_conn=%d, it should be greater than 0", config.RequestMaxConn)
 	}
+	if config.MaxRetries < 0 {
+		return fmt.Errorf("max_retries=%d, it should not be less than 0", config.MaxRetries)
+	}
+	if config.CompressionEnabled && config.CompressionAlgorithm != CompressionAlgorithmGzip {
+		return fmt.Errorf(
+			"compression_algorithm=%s is not supported, it should be %s",
+			config.CompressionAlgorithm, CompressionAlgorithmGzip)
+	}
+	for i, fallbackURL := range config.FallbackURLs {
+		if fallbackURL == "" {
+			return fmt.Errorf("fallback_urls[%d] should not be empty", i)
+		}
+	}
+	if len(config.FallbackURLs) > 0 {
+		if config.FailoverThreshold <= 0 {
+			return fmt.Errorf("failover_threshold=%d, it should be greater than 0", config.FailoverThreshold)
+		}
+		if config.RawProbeInterval == "" {
+			return errors.New("probe_interval should not be empty")
+		}
+	}
+	if config.MaxErrorBodySize < 0 {
+		return fmt.Errorf("max_error_body_size=%d, it should not be less than 0", config.MaxErrorBodySize)
+	}
+	if config.PayloadVersion != 0 &&
+		config.PayloadVersion != EventPayloadVersion1 && config.PayloadVersion != EventPayloadVersion2 {
+		return fmt.Errorf(
+			"payload_version=%d is not supported, it should be %d or %d",
+			config.PayloadVersion, EventPayloadVersion1, EventPayloadVersion2)
+	}
 	return nil
 }
 
@@ -208,17 +397,25 @@ type HashTagEventService struct {
 	eventCountInEventBuffer          int64
 	mutex                            sync.Mutex
 	events                           map[string]HashTagEvent
+	prefixEvents                     map[string]map[string]HashTagEvent
+	aggPrefixes                      []string
 	collectedEventBuffer             chan HashTagEvent
 	eventCountInCollectedEventBuffer int64
 	logger                           *log.Logger
-	metric                           *MetricClient
+	metric                           Metric
 	wg                               sync.WaitGroup
 	stopCh                           chan bool
 	stop                             int32
 	client                           *http.Client
+	urlSelector                      *URLSelector
+	deadLetterQueue                  chan []HashTagEvent
+	eventBatchCountInDeadLetterQueue int64
+	deadLetterFileMutex              sync.Mutex
+	// flushMutex serializes Flush calls and draining of events in Stop.
+	flushMutex sync.Mutex
 }
 
-func NewHashTagEventService(config *HashTagEventServiceConfig, logger *log.Logger, metric *MetricClient) (*HashTagEventService, error) {
+func NewHashTagEventService(config *HashTagEventServiceConfig, logger *log.Logger, metric Metric) (*HashTagEventService, error) {
 	if logger == nil {
 		return nil, errors.New("logger should not be nil")
 	}
@@ -236,6 +433,19 @@ func NewHashTagEventService(config *HashTagEventServiceConfig, logger *log.Logge
 			IdleConnTimeout:   config.EventReport.RequestIdleConnTimeout,
 		},
 	}
+	prefixEvents := make(map[string]map[string]HashTagEvent, len(config.PerPrefixAggInterval))
+	aggPrefixes := make([]string, 0, len(config.PerPrefixAggInterval))
+	for prefix := range config.PerPrefixAggInterval {
+		prefixEvents[prefix] = make(map[string]HashTagEvent)
+		aggPrefixes = append(aggPrefixes, prefix)
+	}
+	// longer prefixes are matched first.
+	sort.Slice(aggPrefixes, func(i, j int) bool {
+		if len(aggPrefixes[i]) != len(aggPrefixes[j]) {
+			return len(aggPrefixes[i]) > len(aggPrefixes[j])
+		}
+		return aggPrefixes[i] < aggPrefixes[j]
+	})
 	server := &HashTagEventService{
 		name:                             HashTagEventServiceName,
 		config:                           config,
@@ -243,6 +453,8 @@ func NewHashTagEventService(config *HashTagEventServiceConfig, logger *log.Logge
 		eventCountInEventBuffer:          0,
 		mutex:                            sync.Mutex{},
 		events:                           make(map[string]HashTagEvent),
+		prefixEvents:                     prefixEvents,
+		aggPrefixes:                      aggPrefixes,
 		collectedEventBuffer:             make(chan HashTagEvent, config.BufferLimit),
 		eventCountInCollectedEventBuffer: 0,
 		logger:                           logger,
@@ -251,31 +463,96 @@ func NewHashTagEventService(config *HashTagEventServiceConfig, logger *log.Logge
 		stopCh:                           make(chan bool),
 		stop:                             0,
 		client:                           client,
+		urlSelector:                      NewURLSelector(config.EventReport.URL, config.EventReport.FallbackURLs, config.EventReport.FailoverThreshold),
+		deadLetterQueue:                  make(chan []HashTagEvent, config.DeadLetter.QueueSize),
+		eventBatchCountInDeadLetterQueue: 0,
+		deadLetterFileMutex:              sync.Mutex{},
 	}
 	logger.Info(
 		"new hash_tag_event service",
 		log.String("config", fmt.Sprintf("%+v", config)))
+	if config.DeadLetter.Enabled && config.DeadLetter.ReplayOnStartup && !config.Disabled {
+		server.replayDeadLetterFile()
+	}
 	return server, nil
 }
 
+func (service *HashTagEventService) IsDisabled() bool {
+	return service.config.Disabled
+}
+
 func (service *HashTagEventService) Run() {
-	service.wg.Add(1)
-	go service.aggregateEvents()
-	service.wg.Add(1)
-	go service.collectAggregatedEvents()
+	if service.IsDisabled() {
+		service.logger.Info(fmt.Sprintf("%s: disabled", service.name))
+		return
+	}
+	service.runWorker("aggregate_events", service.aggregateEvents)
+	service.runWorker("collect_aggregated_events", func() {
+		service.collectAggregatedEvents("", service.config.AggInterval)
+	})
+	for prefix, interval := range service.config.PerPrefixAggInterval {
+		prefix, interval := prefix, interval
+		service.runWorker("collect_aggregated_events", func() {
+			service.collectAggregatedEvents(prefix, interval)
+		})
+	}
 	for i := 0; i < service.config.EventReport.RequestWorkerCount; i++ {
+		service.runWorker("report_events", service.reportEvents)
+	}
+	if service.config.DeadLetter.Enabled {
 		service.wg.Add(1)
-		go service.reportEvents()
+		go service.retryDeadLetterEvents()
+	}
+	if len(service.config.EventReport.FallbackURLs) > 0 {
+		service.wg.Add(1)
+		go service.probePrimaryURL(service.config.EventReport.ProbeInterval)
 	}
 	service.wg.Add(1)
 	go service.mointor(service.config.MonitorInterval)
 }
 
+// workerRestartDelay is replaced in tests.
+var workerRestartDelay = time.Second
+
+// runWorker runs worker in a goroutine supervised by the service,
+// the worker is restarted after workerRestartDelay if it panics, unless the service is stopping.
+func (service *HashTagEventService) runWorker(name string, worker func()) {
+	service.wg.Add(1)
+	go func() {
+		defer service.wg.Done()
+		for service.runWorkerOnce(name, worker) {
+			select {
+			case <-time.After(workerRestartDelay):
+			case <-service.stopCh:
+				return
+			}
+			service.logger.Info(fmt.Sprintf("%s: restart worker %s", service.name, name))
+		}
+	}()
+}
+
+// runWorkerOnce runs worker and recovers its panic, it returns true if the worker should be restarted.
+func (service *HashTagEventService) runWorkerOnce(name string, worker func()) (restart bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			service.logger.Error(
+				metricWorkerPanic,
+				log.String("worker", name),
+				log.String("info", fmt.Sprintf("%+v", r)),
+				log.String("stack", string(debug.Stack())),
+			)
+			service.metric.MetricIncrease(fmt.Sprintf("%s.%s", metricWorkerPanic, name))
+			restart = atomic.LoadInt32(&service.stop) == 0
+		}
+	}()
+	worker()
+	return false
+}
+
 // returns when channel `service.stopCh` is closed.
 func (service *HashTagEventService) aggregateEvents() {
 	defer func() {
 		service.logger.Info(fmt.Sprintf("%s: stop aggregate events", service.name))
-		service.wg.Done()
 	}()
 
 loop:
@@ -307,9 +584,10 @@ func (service *HashTagEventService) aggregateEvent(event HashTagEvent) error {
 	}
 	service.mutex.Lock()
 	defer service.mutex.Unlock()
+	events := service.getEventBucket(event.HashTag)
 	var newEvent HashTagEvent
 	var err error
-	if savedEvent, ok := service.events[event.HashTag]; ok {
+	if savedEvent, ok := events[event.HashTag]; ok {
 		newEvent, err = MergeEvents(savedEvent, event)
 		if err != nil {
 			return err
@@ -317,23 +595,36 @@ func (service *HashTagEventService) aggregateEvent(event HashTagEvent) error {
 	} else {
 		newEvent = event
 	}
-	service.events[event.HashTag] = newEvent
+	events[event.HashTag] = newEvent
 	return nil
 }
 
+// getEventBucket returns aggregated events of the longest prefix matched by hash tag,
+// `service.events` is returned if no prefix is matched.
+// It should be called with `service.mutex` locked.
+func (service *HashTagEventService) getEventBucket(hashTag string) map[string]HashTagEvent {
+	for _, prefix := range service.aggPrefixes {
+		if strings.HasPrefix(hashTag, prefix) {
+			return service.prefixEvents[prefix]
+		}
+	}
+	return service.events
+}
+
+// collectAggregatedEvents collects aggregated events of hash tags with prefix every interval,
+// empty prefix means hash tags not matching any prefix in per_prefix_agg_interval.
 // returns when channel `service.stopCh` is closed
-func (service *HashTagEventService) collectAggregatedEvents() {
-	ticker := time.NewTicker(service.config.AggInterval)
+func (service *HashTagEventService) collectAggregatedEvents(prefix string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer func() {
-		service.logger.Info(fmt.Sprintf("%s: stop collect aggregated events", service.name))
+		service.logger.Info(fmt.Sprintf("%s: stop collect aggregated events, prefix=%s", service.name, prefix))
 		ticker.Stop()
-		service.wg.Done()
 	}()
 loop:
 	for {
 		select {
 		case <-ticker.C:
-			events := service.collectEvents()
+			events := service.collectEventsByPrefix(prefix)
 			for _, event := range events {
 				service.collectedEventBuffer <- event
 				atomic.AddInt64(&service.eventCountInCollectedEventBuffer, 1)
@@ -344,13 +635,32 @@ loop:
 	}
 }
 
+// collectEvents collects aggregated events of all hash tags.
 func (service *HashTagEventService) collectEvents() []HashTagEvent {
 	events := make([]HashTagEvent, 0)
 	service.mutex.Lock()
 	defer service.mutex.Unlock()
-	for hashTag, event := range service.events {
+	events = collectEventsInBucket(events, service.events)
+	for _, bucket := range service.prefixEvents {
+		events = collectEventsInBucket(events, bucket)
+	}
+	return events
+}
+
+func (service *HashTagEventService) collectEventsByPrefix(prefix string) []HashTagEvent {
+	events := make([]HashTagEvent, 0)
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if prefix == "" {
+		return collectEventsInBucket(events, service.events)
+	}
+	return collectEventsInBucket(events, service.prefixEvents[prefix])
+}
+
+func collectEventsInBucket(events []HashTagEvent, bucket map[string]HashTagEvent) []HashTagEvent {
+	for hashTag, event := range bucket {
 		events = append(events, event)
-		delete(service.events, hashTag)
+		delete(bucket, hashTag)
 	}
 	return events
 }
@@ -361,7 +671,6 @@ func (service *HashTagEventService) reportEvents() {
 	defer func() {
 		service.logger.Info(fmt.Sprintf("%s: stop report events in hash_tag_event service", service.name))
 		ticker.Stop()
-		service.wg.Done()
 	}()
 	requestMaxEvent := service.config.EventReport.RequestMaxEvent
 	stop := false
@@ -390,9 +699,9 @@ func (service *HashTagEventService) reportEvents() {
 				break loop
 			}
 		}
-		err := service._reportEvents(events)
+		err := service.reportEventsWithRecovery(events)
 		if err != nil {
-			service.recordReportEventsError(events, err)
+			service.handleReportEventsError(events, err)
 		} else {
 			service.metric.MetricCount(metricReportEventsSuccess, len(events))
 		}
@@ -402,42 +711,338 @@ func (service *HashTagEventService) reportEvents() {
 	}
 }
 
+var (
+	errReportEventsPanic       = errors.New("report events panic")
+	errReportEventsNilResponse = errors.New("report events nil response")
+)
+
+// reportEventsWithRecovery recovers panics of reporting events so that the report worker keeps running,
+// events are handled as failed ones if a panic happens.
+func (service *HashTagEventService) reportEventsWithRecovery(events []HashTagEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			service.logger.Error(
+				metricReportEventsPanic,
+				log.Int("event_count", len(events)),
+				log.String("info", fmt.Sprintf("%+v", r)),
+				log.String("stack", string(debug.Stack())),
+			)
+			service.metric.MetricIncrease(metricReportEventsPanic)
+			err = fmt.Errorf("%w: %+v", errReportEventsPanic, r)
+		}
+	}()
+	return service.reportEventsWithRetry(events)
+}
+
+// reportEventsWithRetry retries to report events with exponential backoff if the error is retryable,
+// it stops retrying when the service is stopped.
+func (service *HashTagEventService) reportEventsWithRetry(events []HashTagEvent) error {
+	return service.reportEventsWithRetryContext(context.Background(), service.stopCh, events)
+}
+
+// reportEventsWithRetryContext stops retrying when ctx is done or stopCh is closed,
+// a nil stopCh is never closed.
+func (service *HashTagEventService) reportEventsWithRetryContext(
+	ctx context.Context, stopCh chan bool, events []HashTagEvent) error {
+
+	config := service.config.EventReport
+	var err error
+	for retry := 0; ; retry++ {
+		err = service._reportEventsWithContext(ctx, events)
+		if err == nil || !isReportEventsErrorRetryable(err) {
+			return err
+		}
+		if retry >= config.MaxRetries {
+			break
+		}
+		service.metric.MetricIncrease(metricReportEventsRetry)
+		select {
+		case <-time.After(reportEventsBackoff(config.InitialBackoff, config.MaxBackoff, retry)):
+		case <-ctx.Done():
+			return err
+		case <-stopCh:
+			return err
+		}
+	}
+	service.metric.MetricCount(metricReportEventsRetryExhausted, len(events))
+	return fmt.Errorf("retry %d times, %w", config.MaxRetries, err)
+}
+
+// reportEventsBackoff returns a duration in [backoff/2, backoff],
+// backoff is initialBackoff*2^retry and limited by maxBackoff.
+func reportEventsBackoff(initialBackoff, maxBackoff time.Duration, retry int) time.Duration {
+	backoff := maxBackoff
+	if retry < 32 && initialBackoff<<retry > 0 && initialBackoff<<retry < maxBackoff {
+		backoff = initialBackoff << retry
+	}
+	if backoff <= 1 {
+		return backoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+type reportEventsResponseError struct {
+	statusCode int
+	body       string
+	readErr    error
+}
+
+func (err reportEventsResponseError) Error() string {
+	if err.readErr != nil {
+		return fmt.Sprintf("response error, http_code=%d, read_body_err=%s", err.statusCode, err.readErr)
+	}
+	return fmt.Sprintf("response error, http_code=%d, body=%s", err.statusCode, err.body)
+}
+
+func (err reportEventsResponseError) Unwrap() error {
+	return err.readErr
+}
+
+// Errors of http requests (connection refused, timeout, etc.) and 5xx responses are retryable,
+// 4xx responses and other errors are not.
+func isReportEventsErrorRetryable(err error) bool {
+	var respErr reportEventsResponseError
+	if errors.As(err, &respErr) {
+		return respErr.statusCode >= http.StatusInternalServerError
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
 func (service *HashTagEventService) _reportEvents(events []HashTagEvent) error {
+	return service._reportEventsWithContext(context.Background(), events)
+}
+
+// _reportEventsWithContext reports events to the current url,
+// the other urls are tried in order if the error is retryable.
+func (service *HashTagEventService) _reportEventsWithContext(ctx context.Context, events []HashTagEvent) error {
 	if len(events) == 0 {
 		return nil
 	}
-	data := map[string][]HashTagEvent{"events": events}
-	bs, err := json.Marshal(data)
+	newBody, contentEncoding, err := service.newReportEventsBody(events)
 	if err != nil {
 		return err
 	}
-	requestBody := bytes.NewReader(bs)
-	resp, err := service.client.Post(service.config.EventReport.URL, HTTPContentTypeJSON, requestBody)
+	urls := service.urlSelector.Candidates()
+	for i, reportURL := range urls {
+		err = service.postEvents(ctx, reportURL, newBody(), contentEncoding)
+		if err == nil {
+			if i == 0 {
+				service.urlSelector.MarkSucceeded(reportURL)
+			}
+			return nil
+		}
+		if !isReportEventsErrorRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+		if i == 0 && service.urlSelector.MarkFailed(reportURL) {
+			current := service.urlSelector.Current()
+			service.logger.Error(
+				"event report url failover",
+				log.String("from", reportURL),
+				log.String("to", current),
+				log.Error(err))
+			service.metric.MetricIncrease(metricEventReportFailover)
+		}
+	}
+	return err
+}
+
+// newReportEventsBody returns a function to create request body of events and content encoding of the body.
+// The body is encoded in memory once and shared by requests by default,
+// it is encoded for every request and streamed to the request if stream encoding is enabled.
+func (service *HashTagEventService) newReportEventsBody(events []HashTagEvent) (func() io.Reader, string, error) {
+	config := service.config.EventReport
+	payload := NewEventPayload(config.payloadVersion(), events, time.Now())
+	if config.StreamEncodingEnabled {
+		contentEncoding := ""
+		if config.CompressionEnabled {
+			contentEncoding = CompressionAlgorithmGzip
+		}
+		return func() io.Reader {
+			return streamEncodeEvents(payload, config.CompressionEnabled)
+		}, contentEncoding, nil
+	}
+	bs, err := json.Marshal(payload)
 	if err != nil {
+		return nil, "", err
+	}
+	contentEncoding := ""
+	if config.CompressionEnabled {
+		compressed, err := gzipCompress(bs)
+		if err != nil {
+			return nil, "", err
+		}
+		service.metric.MetricHistogram(metricEventCompressionRatio, float64(len(compressed))/float64(len(bs)))
+		// send uncompressed body if compression does not help.
+		if len(compressed) < len(bs) {
+			bs = compressed
+			contentEncoding = CompressionAlgorithmGzip
+		}
+	}
+	return func() io.Reader {
+		return bytes.NewReader(bs)
+	}, contentEncoding, nil
+}
+
+// streamEncodeEvents encodes events one by one in a goroutine and returns a reader of the encoded data,
+// so only one event is encoded in memory at a time.
+// The goroutine returns when all data is read or the reader is closed.
+func streamEncodeEvents(payload EventPayload, compressed bool) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(encodeEvents(writer, payload, compressed))
+	}()
+	return reader
+}
+
+// encodeEvents writes payload to w in format `{"events":[...]}`, or `{"metadata":{...},"events":[...]}` with metadata.
+func encodeEvents(w io.Writer, payload EventPayload, compressed bool) error {
+	var gzipWriter *gzip.Writer
+	if compressed {
+		gzipWriter = gzip.NewWriter(w)
+		w = gzipWriter
+	}
+	bufWriter := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bufWriter)
+	prefix := `{"events":[`
+	if payload.Metadata != nil {
+		metadata, err := json.Marshal(payload.Metadata)
+		if err != nil {
+			return err
+		}
+		prefix = `{"metadata":` + string(metadata) + `,"events":[`
+	}
+	if _, err := bufWriter.WriteString(prefix); err != nil {
+		return err
+	}
+	for i, event := range payload.Events {
+		if i > 0 {
+			if err := bufWriter.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	if _, err := bufWriter.WriteString("]}"); err != nil {
+		return err
+	}
+	if err := bufWriter.Flush(); err != nil {
+		return err
+	}
+	if gzipWriter != nil {
+		return gzipWriter.Close()
+	}
+	return nil
+}
+
+func (service *HashTagEventService) postEvents(ctx context.Context, reportURL string, body io.Reader, contentEncoding string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, reportURL, body)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
 		return err
 	}
+	request.Header.Set(HTTPHeaderContentType, HTTPContentTypeJSON)
+	request.Header.Set(HTTPHeaderEventPayloadVersion, strconv.Itoa(service.config.EventReport.payloadVersion()))
+	if contentEncoding != "" {
+		request.Header.Set(HTTPHeaderContentEncoding, contentEncoding)
+	}
+	resp, err := service.client.Do(request)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return errReportEventsNilResponse
+	}
 	defer func() {
-		if resp.Body != nil {
+		if resp != nil && resp.Body != nil {
 			io.ReadAll(resp.Body)
 			resp.Body.Close()
 		}
 	}()
 	if resp.StatusCode != http.StatusOK {
-		respBody, err := io.ReadAll(resp.Body)
+		// the body of an error response is bounded to protect the reporter from huge bodies.
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, service.config.EventReport.maxErrorBodySize()))
 		if err != nil {
-			return fmt.Errorf("response error, http_code=%d, read_body_err=%w", resp.StatusCode, err)
+			return reportEventsResponseError{statusCode: resp.StatusCode, readErr: err}
 		}
-		return fmt.Errorf("response error, http_code=%d, body=%s", resp.StatusCode, utility.AnyToString(respBody))
+		return reportEventsResponseError{statusCode: resp.StatusCode, body: utility.AnyToString(respBody)}
 	}
 	return nil
 }
 
+// probePrimaryURL switches back to the primary url if it recovers,
+// returns when channel `service.stopCh` is closed.
+func (service *HashTagEventService) probePrimaryURL(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer func() {
+		ticker.Stop()
+		service.wg.Done()
+	}()
+	for {
+		select {
+		case <-ticker.C:
+			if service.urlSelector.IsPrimary() {
+				continue
+			}
+			primaryURL := service.urlSelector.Primary()
+			if err := service.probeURL(primaryURL); err != nil {
+				service.logger.Info("probe primary url", log.String("url", primaryURL), log.Error(err))
+				continue
+			}
+			if service.urlSelector.SwitchToPrimary() {
+				service.logger.Info("event report url failback", log.String("to", primaryURL))
+				service.metric.MetricIncrease(metricEventReportFailback)
+			}
+		case <-service.stopCh:
+			return
+		}
+	}
+}
+
+// probeURL reports empty events to reportURL.
+func (service *HashTagEventService) probeURL(reportURL string) error {
+	bs, err := json.Marshal(NewEventPayload(service.config.EventReport.payloadVersion(), []HashTagEvent{}, time.Now()))
+	if err != nil {
+		return err
+	}
+	return service.postEvents(context.Background(), reportURL, bytes.NewReader(bs), "")
+}
+
+func gzipCompress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
 func (service *HashTagEventService) recordReportEventsError(events []HashTagEvent, err error) {
 	eventsInStr := make([]string, 0, len(events))
 	for _, event := range events {
 		eventsInStr = append(eventsInStr, event.String())
 	}
 
+	// the receiver does not support payload version, events are retried from dead letter queue after it is upgraded.
+	var respErr reportEventsResponseError
+	if errors.As(err, &respErr) && respErr.statusCode == HTTPStatusEventPayloadVersionMismatch {
+		service.logger.Error(
+			metricReportEventsVersionMismatch,
+			log.Int("payload_version", service.config.EventReport.payloadVersion()),
+			log.Int("event_count", len(events)),
+			log.Error(err),
+		)
+		service.metric.MetricIncrease(metricReportEventsVersionMismatch)
+		return
+	}
 	service.logger.Error(
 		metricReportEventsError,
 		log.String("events", strings.Join(eventsInStr, " ")),
@@ -448,6 +1053,13 @@ func (service *HashTagEventService) recordReportEventsError(events []HashTagEven
 }
 
 func (service *HashTagEventService) SendEvent(hashTag string, keys []string, accessMode HashTagAccessMode, accessTime time.Time) error {
+	if service.IsDisabled() {
+		return nil
+	}
+	if service.isSampledOut(accessMode) {
+		service.metric.MetricIncrease(metricReadEventSampledOut)
+		return nil
+	}
 	event, err := NewHashTagEvent(hashTag, keys, accessMode, accessTime)
 	if err != nil {
 		return err
@@ -455,6 +1067,16 @@ func (service *HashTagEventService) SendEvent(hashTag string, keys []string, acc
 	return service.send(event)
 }
 
+// isSampledOut returns true if a read event should be dropped by sampling,
+// write events are always kept.
+func (service *HashTagEventService) isSampledOut(accessMode HashTagAccessMode) bool {
+	sampleRate := service.config.ReadEventSampleRate
+	if accessMode != HashTagAccessModeRead || sampleRate <= 1 {
+		return false
+	}
+	return rand.Intn(sampleRate) != 0
+}
+
 func (service *HashTagEventService) send(event HashTagEvent) error {
 	defer func() {
 		if r := recover(); r != nil {
@@ -477,11 +1099,83 @@ func (service *HashTagEventService) send(event HashTagEvent) error {
 }
 
 func (service *HashTagEventService) Stop() {
+	if service.IsDisabled() {
+		return
+	}
 	if atomic.CompareAndSwapInt32(&service.stop, 0, 1) {
 		close(service.stopCh)
 		service.wg.Wait()
+		service.flushMutex.Lock()
 		service.drainEvents()
+		service.flushMutex.Unlock()
+		service.saveDeadLetterQueueToFile()
+	}
+}
+
+var ErrEventServiceStopped = errors.New("hash_tag_event service is stopped")
+
+// Flush aggregates events in buffer, collects all aggregated events and reports them synchronously,
+// workers keep running after it returns. Events sent during flushing may be reported by workers later,
+// so are events held by report workers. Events failed to report are sent to dead letter queue,
+// the first error is returned.
+func (service *HashTagEventService) Flush(ctx context.Context) error {
+	if service.IsDisabled() {
+		return nil
+	}
+	service.flushMutex.Lock()
+	defer service.flushMutex.Unlock()
+	if atomic.LoadInt32(&service.stop) == 1 {
+		return ErrEventServiceStopped
+	}
+	// only events in buffer when flushing starts are taken, so it returns even if events are sent continuously.
+	for count := len(service.eventBuffer); count > 0; count-- {
+		select {
+		case event := <-service.eventBuffer:
+			atomic.AddInt64(&service.eventCountInEventBuffer, -1)
+			if err := service.aggregateEvent(event); err != nil {
+				service.recordAggregateEventError(event, err)
+			}
+		default:
+			count = 0
+		}
+	}
+	allEvents := make([]HashTagEvent, 0)
+	for count := len(service.collectedEventBuffer); count > 0; count-- {
+		select {
+		case event := <-service.collectedEventBuffer:
+			atomic.AddInt64(&service.eventCountInCollectedEventBuffer, -1)
+			allEvents = append(allEvents, event)
+		default:
+			count = 0
+		}
+	}
+	allEvents = append(allEvents, service.collectEvents()...)
+
+	requestMaxEvent := service.config.EventReport.RequestMaxEvent
+	var firstErr error
+	for start := 0; start < len(allEvents); start += requestMaxEvent {
+		end := start + requestMaxEvent
+		if end > len(allEvents) {
+			end = len(allEvents)
+		}
+		if err := ctx.Err(); err != nil {
+			service.sendToDeadLetterQueue(allEvents[start:])
+			if firstErr == nil {
+				firstErr = err
+			}
+			break
+		}
+		events := allEvents[start:end]
+		if err := service.reportEventsWithRetryContext(ctx, service.stopCh, events); err != nil {
+			service.handleReportEventsError(events, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			service.metric.MetricCount(metricReportEventsSuccess, len(events))
+		}
 	}
+	return firstErr
 }
 
 func (service *HashTagEventService) drainEvents() {
@@ -490,26 +1184,40 @@ func (service *HashTagEventService) drainEvents() {
 
 	requestMaxEvent := service.config.EventReport.RequestMaxEvent
 	allEvents := service.collectEvents()
-	events := make([]HashTagEvent, 0, requestMaxEvent)
 	service.logger.Info(fmt.Sprintf("%s: draining %d events", service.name, len(allEvents)))
-	for _, event := range allEvents {
-		events = append(events, event)
-		if len(events) == requestMaxEvent {
-			if err := service._reportEvents(events); err != nil {
-				service.recordReportEventsError(events, err)
-			} else {
-				service.metric.MetricCount(metricReportEventsSuccess, len(events))
-			}
-			events = make([]HashTagEvent, 0, requestMaxEvent)
+	// draining is bounded by drain_duration, failed requests are retried until drain_duration is reached,
+	// events not reported in time are sent to dead letter queue and saved to dead letter file.
+	ctx, cancel := context.WithTimeout(context.Background(), service.config.DrainDuration)
+	defer cancel()
+	for start := 0; start < len(allEvents); start += requestMaxEvent {
+		end := start + requestMaxEvent
+		if end > len(allEvents) {
+			end = len(allEvents)
+		}
+		if ctx.Err() != nil {
+			service.recordDrainTimeout(allEvents[start:])
+			service.sendToDeadLetterQueue(allEvents[start:])
+			return
+		}
+		events := allEvents[start:end]
+		if err := service.reportEventsWithRetryContext(ctx, nil, events); err != nil {
+			service.handleReportEventsError(events, err)
+		} else {
+			service.metric.MetricCount(metricReportEventsSuccess, len(events))
 		}
-	}
-	if err := service._reportEvents(events); err != nil {
-		service.recordReportEventsError(events, err)
-	} else {
-		service.metric.MetricCount(metricReportEventsSuccess, len(events))
 	}
 }
 
+func (service *HashTagEventService) recordDrainTimeout(events []HashTagEvent) {
+	service.logger.Error(
+		metricDrainEventsTimeout,
+		log.Int("undrained_event_count", len(events)),
+		log.String("drain_duration", service.config.DrainDuration.String()),
+	)
+	service.metric.MetricIncrease(metricDrainEventsTimeout)
+	service.metric.MetricCount(metricUndrainedEventCount, len(events))
+}
+
 func (service *HashTagEventService) closeAndEmptifyChannel(ch chan HashTagEvent, counter *int64) {
 	close(ch)
 	for event := range ch {
@@ -543,6 +1251,10 @@ loop:
 				metricAggregatedEventCount,
 				service.GetAggregatedEventCount(),
 			)
+			service.recordStat(
+				metricDeadLetterQueueDepth,
+				atomic.LoadInt64(&service.eventBatchCountInDeadLetterQueue),
+			)
 		case <-service.stopCh:
 			break loop
 		}
@@ -552,7 +1264,19 @@ loop:
 func (service *HashTagEventService) GetAggregatedEventCount() int64 {
 	service.mutex.Lock()
 	defer service.mutex.Unlock()
-	return int64(len(service.events))
+	count := len(service.events)
+	for _, bucket := range service.prefixEvents {
+		count += len(bucket)
+	}
+	return int64(count)
+}
+
+func (service *HashTagEventService) GetEventCountInEventBuffer() int64 {
+	return atomic.LoadInt64(&service.eventCountInEventBuffer)
+}
+
+func (service *HashTagEventService) GetEventCountInCollectedEventBuffer() int64 {
+	return atomic.LoadInt64(&service.eventCountInCollectedEventBuffer)
 }
 
 func (service *HashTagEventService) recordStat(metricName string, count int64) {
@@ -0,0 +1,251 @@
+package base
+
+import (
+	"bytepower_room/base/log"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var ErrDeadLetterFileFull = errors.New("dead letter file is full")
+
+var (
+	metricDeadLetterQueueDepth   = fmt.Sprintf("%s.event_batch_in_dlq.total", HashTagEventServiceName)
+	metricDeadLetterQueueDropped = fmt.Sprintf("%s.error.dlq_dropped", HashTagEventServiceName)
+	metricDeadLetterFileError    = fmt.Sprintf("%s.error.dlq_file", HashTagEventServiceName)
+
+	metricDeadLetterEvent             = fmt.Sprintf("%s.dlq_event", HashTagEventServiceName)
+	metricDeadLetterQueueRetrySuccess = fmt.Sprintf("%s.dlq_retry", HashTagEventServiceName)
+	metricDeadLetterFileSaved         = fmt.Sprintf("%s.dlq_file_saved", HashTagEventServiceName)
+	metricDeadLetterFileReplayed      = fmt.Sprintf("%s.dlq_file_replayed", HashTagEventServiceName)
+)
+
+// HashTagEventDeadLetterConfig is config of dead letter queue for events failed to report.
+// Batches in queue are retried every retry_interval,
+// batches which can not be queued or retried are appended to file_path as json lines,
+// every line is a request body could be posted to event report url.
+// Events failed to report are dropped if Enabled is false, other fields are not used then.
+type HashTagEventDeadLetterConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	QueueSize int `yaml:"queue_size"`
+
+	FilePath    string `yaml:"file_path"`
+	MaxFileSize int64  `yaml:"max_file_size"`
+
+	// ReplayOnStartup loads batches in file_path to dead letter queue when service is created,
+	// loaded batches are removed from the file and retried every retry_interval.
+	ReplayOnStartup bool `yaml:"replay_on_startup"`
+
+	RawRetryInterval string `yaml:"retry_interval"`
+	RetryInterval    time.Duration
+}
+
+func (config HashTagEventDeadLetterConfig) check() error {
+	if !config.Enabled {
+		return nil
+	}
+	if config.QueueSize <= 0 {
+		return fmt.Errorf("queue_size=%d, it should be greater than 0", config.QueueSize)
+	}
+	if config.FilePath == "" {
+		return errors.New("file_path should not be empty")
+	}
+	if config.MaxFileSize <= 0 {
+		return fmt.Errorf("max_file_size=%d, it should be greater than 0", config.MaxFileSize)
+	}
+	if config.RawRetryInterval == "" {
+		return errors.New("retry_interval should not be empty")
+	}
+	return nil
+}
+
+func (service *HashTagEventService) handleReportEventsError(events []HashTagEvent, err error) {
+	service.recordReportEventsError(events, err)
+	service.sendToDeadLetterQueue(events)
+}
+
+// sendToDeadLetterQueue saves events to dead letter file if dead letter queue is full,
+// events are dropped if dead letter queue is not enabled.
+func (service *HashTagEventService) sendToDeadLetterQueue(events []HashTagEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if !service.config.DeadLetter.Enabled {
+		service.metric.MetricCount(metricDeadLetterQueueDropped, len(events))
+		return
+	}
+	service.metric.MetricCount(metricDeadLetterEvent, len(events))
+	select {
+	case service.deadLetterQueue <- events:
+		atomic.AddInt64(&service.eventBatchCountInDeadLetterQueue, 1)
+	default:
+		service.saveToDeadLetterFile(events)
+	}
+}
+
+// returns when channel `service.stopCh` is closed,
+// batches left in dead letter queue are saved to file in `service.Stop`.
+func (service *HashTagEventService) retryDeadLetterEvents() {
+	ticker := time.NewTicker(service.config.DeadLetter.RetryInterval)
+	defer func() {
+		service.logger.Info(fmt.Sprintf("%s: stop retry dead letter events", service.name))
+		ticker.Stop()
+		service.wg.Done()
+	}()
+	for {
+		select {
+		case <-ticker.C:
+			service.retryDeadLetterQueue()
+		case <-service.stopCh:
+			return
+		}
+	}
+}
+
+// retryDeadLetterQueue reports every batch in dead letter queue once,
+// batches failed with retryable errors are put back to the queue.
+func (service *HashTagEventService) retryDeadLetterQueue() {
+	count := len(service.deadLetterQueue)
+	for i := 0; i < count; i++ {
+		var events []HashTagEvent
+		select {
+		case events = <-service.deadLetterQueue:
+			atomic.AddInt64(&service.eventBatchCountInDeadLetterQueue, -1)
+		default:
+			return
+		}
+		err := service._reportEvents(events)
+		if err == nil {
+			service.metric.MetricCount(metricDeadLetterQueueRetrySuccess, len(events))
+			service.metric.MetricCount(metricReportEventsSuccess, len(events))
+			continue
+		}
+		service.recordReportEventsError(events, err)
+		if isReportEventsErrorRetryable(err) {
+			service.sendToDeadLetterQueue(events)
+		} else {
+			service.saveToDeadLetterFile(events)
+		}
+	}
+}
+
+func (service *HashTagEventService) saveDeadLetterQueueToFile() {
+	for {
+		select {
+		case events := <-service.deadLetterQueue:
+			atomic.AddInt64(&service.eventBatchCountInDeadLetterQueue, -1)
+			service.saveToDeadLetterFile(events)
+		default:
+			return
+		}
+	}
+}
+
+// saveToDeadLetterFile drops events if they can not be saved to file.
+func (service *HashTagEventService) saveToDeadLetterFile(events []HashTagEvent) {
+	service.deadLetterFileMutex.Lock()
+	err := appendEventsToDeadLetterFile(service.config.DeadLetter.FilePath, service.config.DeadLetter.MaxFileSize, events)
+	service.deadLetterFileMutex.Unlock()
+	if err != nil {
+		service.logger.Error(
+			metricDeadLetterFileError,
+			log.String("file", service.config.DeadLetter.FilePath),
+			log.Int("event_count", len(events)),
+			log.Error(err),
+		)
+		service.metric.MetricIncrease(metricDeadLetterFileError)
+		service.metric.MetricCount(metricDeadLetterQueueDropped, len(events))
+		return
+	}
+	service.metric.MetricCount(metricDeadLetterFileSaved, len(events))
+}
+
+func appendEventsToDeadLetterFile(filePath string, maxFileSize int64, events []HashTagEvent) error {
+	bs, err := json.Marshal(map[string][]HashTagEvent{"events": events})
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size()+int64(len(bs)) > maxFileSize {
+		return fmt.Errorf("%w, size=%d, max_file_size=%d", ErrDeadLetterFileFull, info.Size(), maxFileSize)
+	}
+	_, err = file.Write(bs)
+	return err
+}
+
+// replayDeadLetterFile loads batches in dead letter file to dead letter queue,
+// batches which can not be loaded are kept in the file.
+func (service *HashTagEventService) replayDeadLetterFile() {
+	filePath := service.config.DeadLetter.FilePath
+	batchCount := len(service.deadLetterQueue)
+	service.deadLetterFileMutex.Lock()
+	count, err := loadDeadLetterFile(filePath, service.deadLetterQueue)
+	service.deadLetterFileMutex.Unlock()
+	atomic.AddInt64(&service.eventBatchCountInDeadLetterQueue, int64(len(service.deadLetterQueue)-batchCount))
+	if err != nil {
+		service.logger.Error(
+			metricDeadLetterFileError,
+			log.String("file", filePath),
+			log.Error(err),
+		)
+		service.metric.MetricIncrease(metricDeadLetterFileError)
+	}
+	if count > 0 {
+		service.logger.Info(
+			metricDeadLetterFileReplayed,
+			log.String("file", filePath),
+			log.Int("event_count", count),
+		)
+		service.metric.MetricCount(metricDeadLetterFileReplayed, count)
+	}
+}
+
+// loadDeadLetterFile sends batches in file to queue until queue is full,
+// the file is removed if all batches are sent, otherwise it is rewritten with batches left.
+// Lines which can not be decoded are left in the file.
+// It returns the count of events sent to queue.
+func loadDeadLetterFile(filePath string, queue chan []HashTagEvent) (int, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	var leftLines bytes.Buffer
+	for _, line := range bytes.Split(content, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		data := map[string][]HashTagEvent{}
+		if err := json.Unmarshal(line, &data); err == nil && len(data["events"]) > 0 {
+			select {
+			case queue <- data["events"]:
+				count += len(data["events"])
+				continue
+			default:
+			}
+		}
+		leftLines.Write(line)
+		leftLines.WriteByte('\n')
+	}
+	if leftLines.Len() == 0 {
+		return count, os.Remove(filePath)
+	}
+	return count, ioutil.WriteFile(filePath, leftLines.Bytes(), 0644)
+}
@@ -1,7 +1,21 @@
 package base
 
 import (
+	"bufio"
 	"bytepower_room/utility"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,12 +27,17 @@ func testInitHashTagEventService() *HashTagEventService {
 	logger, _ := parseLogger(
 		"event_service",
 		loggerConfig)
-	metric, _ := InitMetric(MetricConfig{Host: "localhost"})
+	metric := NewNoopMetricClient()
 	hashTagEventConfig := &HashTagEventServiceConfig{EventReport: HashTagEventServiceEventReportConfig{URL: "localhost"}}
 	service, _ := NewHashTagEventService(hashTagEventConfig, logger, metric)
 	return service
 }
 
+func testSetEventReportURL(service *HashTagEventService, url string) {
+	service.config.EventReport.URL = url
+	service.urlSelector = NewURLSelector(url, nil, 1)
+}
+
 func TestNewHashTagEvent(t *testing.T) {
 	hashTag := "xyz"
 	keys := []string{"{xyz}a", "{xyz}b", "{xyz}c"}
@@ -95,6 +114,40 @@ func TestHashTagEventAggregateEvent(t *testing.T) {
 	assert.ElementsMatch(t, append(keys2, keys4...), service.events[hashTag].Keys.ToSlice())
 }
 
+func TestHashTagEventReadEventSampling(t *testing.T) {
+	service := testInitHashTagEventService()
+	for _, sampleRate := range []int{0, 1} {
+		service.config.ReadEventSampleRate = sampleRate
+		for i := 0; i < 100; i++ {
+			assert.False(t, service.isSampledOut(HashTagAccessModeRead))
+		}
+	}
+
+	service.config.ReadEventSampleRate = 4
+	sampledOutCount := 0
+	for i := 0; i < 4000; i++ {
+		assert.False(t, service.isSampledOut(HashTagAccessModeWrite))
+		if service.isSampledOut(HashTagAccessModeRead) {
+			sampledOutCount++
+		}
+	}
+	assert.True(t, sampledOutCount > 2700 && sampledOutCount < 3300, sampledOutCount)
+}
+
+func TestHashTagEventServiceDisabled(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.config.Disabled = true
+	service.Run()
+
+	assert.Nil(t, service.SendEvent("{a}", []string{"{a}1"}, HashTagAccessModeWrite, time.Now()))
+	assert.Equal(t, 0, len(service.eventBuffer))
+	assert.Equal(t, int64(0), atomic.LoadInt64(&service.eventCountInEventBuffer))
+
+	// Stop returns immediately as no goroutine is started.
+	service.Stop()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&service.stop))
+}
+
 func TestHashTagEventCollectEvent(t *testing.T) {
 	service := testInitHashTagEventService()
 	events := []HashTagEvent{
@@ -178,3 +231,745 @@ func TestHashTagEventMerge(t *testing.T) {
 		}
 	}
 }
+
+func TestHashTagEventReportEventsWithRetry(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.config.EventReport.MaxRetries = 3
+	service.config.EventReport.InitialBackoff = time.Millisecond
+	service.config.EventReport.MaxBackoff = 5 * time.Millisecond
+	service.client = &http.Client{Timeout: time.Second}
+	events := []HashTagEvent{{HashTag: "a", Keys: utility.NewStringSet("{a}b"), AccessTime: time.Now()}}
+
+	testCases := []struct {
+		desc         string
+		statusCodes  []int
+		valid        bool
+		requestCount int32
+	}{
+		{"success without retry", []int{http.StatusOK}, true, 1},
+		{"success after retry", []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}, true, 3},
+		{"fail without retry on 4xx", []int{http.StatusBadRequest, http.StatusOK}, false, 1},
+		{"fail after exhausting retries", []int{http.StatusServiceUnavailable}, false, 4},
+	}
+	for _, testCase := range testCases {
+		var requestCount int32
+		statusCodes := testCase.statusCodes
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			index := int(atomic.AddInt32(&requestCount, 1)) - 1
+			if index >= len(statusCodes) {
+				index = len(statusCodes) - 1
+			}
+			w.WriteHeader(statusCodes[index])
+		}))
+		testSetEventReportURL(service, server.URL)
+		err := service.reportEventsWithRetry(events)
+		server.Close()
+		if testCase.valid {
+			assert.Nil(t, err, testCase.desc)
+		} else {
+			assert.NotNil(t, err, testCase.desc)
+		}
+		assert.Equal(t, testCase.requestCount, atomic.LoadInt32(&requestCount), testCase.desc)
+	}
+
+	// connection refused is retryable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	testSetEventReportURL(service, server.URL)
+	server.Close()
+	err := service._reportEvents(events)
+	assert.NotNil(t, err)
+	assert.True(t, isReportEventsErrorRetryable(err))
+}
+
+func TestHashTagEventReportEventsWithLargeErrorBody(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: time.Second}
+	events := []HashTagEvent{{HashTag: "a", Keys: utility.NewStringSet("{a}b"), AccessTime: time.Now()}}
+	errorBody := strings.Repeat("a", 1024*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(errorBody))
+	}))
+	defer server.Close()
+	testSetEventReportURL(service, server.URL)
+
+	testCases := []struct {
+		maxErrorBodySize int64
+		bodySize         int
+	}{
+		{0, defaultReportEventsMaxErrorBodySize},
+		{10, 10},
+		{int64(len(errorBody)) * 2, len(errorBody)},
+	}
+	for _, testCase := range testCases {
+		service.config.EventReport.MaxErrorBodySize = testCase.maxErrorBodySize
+		err := service._reportEvents(events)
+		var respErr reportEventsResponseError
+		assert.True(t, errors.As(err, &respErr))
+		assert.Equal(t, http.StatusBadRequest, respErr.statusCode)
+		assert.Equal(t, errorBody[:testCase.bodySize], respErr.body)
+	}
+}
+
+type testRoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f testRoundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestHashTagEventReportEventsWithRecovery(t *testing.T) {
+	service := testInitHashTagEventService()
+	events := []HashTagEvent{{HashTag: "a", Keys: utility.NewStringSet("{a}b"), AccessTime: time.Now()}}
+
+	service.client = &http.Client{Transport: testRoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		panic("transport panic")
+	})}
+	err := service.reportEventsWithRecovery(events)
+	assert.True(t, errors.Is(err, errReportEventsPanic))
+
+	// transport error without response does not panic
+	service.client = &http.Client{Transport: testRoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("no such host")
+	})}
+	err = service.reportEventsWithRecovery(events)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, errReportEventsPanic))
+}
+
+func TestReportEventsBackoff(t *testing.T) {
+	initialBackoff := 10 * time.Millisecond
+	maxBackoff := 100 * time.Millisecond
+	testCases := []struct {
+		retry   int
+		backoff time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{1, 20 * time.Millisecond},
+		{3, 80 * time.Millisecond},
+		{4, maxBackoff},
+		{100, maxBackoff},
+	}
+	for _, testCase := range testCases {
+		for i := 0; i < 10; i++ {
+			backoff := reportEventsBackoff(initialBackoff, maxBackoff, testCase.retry)
+			assert.GreaterOrEqual(t, int64(backoff), int64(testCase.backoff/2))
+			assert.LessOrEqual(t, int64(backoff), int64(testCase.backoff))
+		}
+	}
+}
+
+func testReadDeadLetterFile(t *testing.T, filePath string) [][]HashTagEvent {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	batches := make([][]HashTagEvent, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		data := map[string][]HashTagEvent{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &data))
+		batches = append(batches, data["events"])
+	}
+	return batches
+}
+
+func TestAppendEventsToDeadLetterFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "dead_letter.jsonl")
+	events := []HashTagEvent{{HashTag: "a", Keys: utility.NewStringSet("{a}b"), AccessTime: time.Now()}}
+
+	assert.Nil(t, appendEventsToDeadLetterFile(filePath, 1024, events))
+	assert.Nil(t, appendEventsToDeadLetterFile(filePath, 1024, events))
+	batches := testReadDeadLetterFile(t, filePath)
+	assert.Equal(t, 2, len(batches))
+	assert.Equal(t, "a", batches[1][0].HashTag)
+	assert.ElementsMatch(t, []string{"{a}b"}, batches[1][0].Keys.ToSlice())
+
+	info, _ := os.Stat(filePath)
+	err := appendEventsToDeadLetterFile(filePath, info.Size()+1, events)
+	assert.True(t, errors.Is(err, ErrDeadLetterFileFull))
+	assert.Equal(t, 2, len(testReadDeadLetterFile(t, filePath)))
+}
+
+func TestHashTagEventDeadLetterQueue(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: time.Second}
+	service.deadLetterQueue = make(chan []HashTagEvent, 2)
+	service.config.DeadLetter.Enabled = true
+	filePath := filepath.Join(t.TempDir(), "dead_letter.jsonl")
+	service.config.DeadLetter.FilePath = filePath
+	service.config.DeadLetter.MaxFileSize = 1024 * 1024
+	events := func(hashTag string) []HashTagEvent {
+		return []HashTagEvent{{HashTag: hashTag, Keys: utility.NewStringSet(), AccessTime: time.Now()}}
+	}
+
+	// batches are saved to file when queue is full
+	service.sendToDeadLetterQueue(events("a"))
+	service.sendToDeadLetterQueue(events("b"))
+	service.sendToDeadLetterQueue(events("c"))
+	assert.Equal(t, int64(2), service.eventBatchCountInDeadLetterQueue)
+	batches := testReadDeadLetterFile(t, filePath)
+	assert.Equal(t, 1, len(batches))
+	assert.Equal(t, "c", batches[0][0].HashTag)
+
+	// retryable failures are put back to queue
+	statusCode := int32(http.StatusServiceUnavailable)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&statusCode)))
+	}))
+	defer server.Close()
+	testSetEventReportURL(service, server.URL)
+	service.retryDeadLetterQueue()
+	assert.Equal(t, int64(2), service.eventBatchCountInDeadLetterQueue)
+	assert.Equal(t, 1, len(testReadDeadLetterFile(t, filePath)))
+
+	// non-retryable failures are saved to file
+	atomic.StoreInt32(&statusCode, http.StatusBadRequest)
+	service.sendToDeadLetterQueue(nil)
+	service.retryDeadLetterQueue()
+	assert.Equal(t, int64(0), service.eventBatchCountInDeadLetterQueue)
+	assert.Equal(t, 3, len(testReadDeadLetterFile(t, filePath)))
+
+	// successful batches are removed from queue
+	atomic.StoreInt32(&statusCode, http.StatusOK)
+	service.sendToDeadLetterQueue(events("d"))
+	service.retryDeadLetterQueue()
+	assert.Equal(t, int64(0), service.eventBatchCountInDeadLetterQueue)
+	assert.Equal(t, 0, len(service.deadLetterQueue))
+
+	// batches left in queue are saved to file
+	service.sendToDeadLetterQueue(events("e"))
+	service.saveDeadLetterQueueToFile()
+	assert.Equal(t, int64(0), service.eventBatchCountInDeadLetterQueue)
+	assert.Equal(t, 4, len(testReadDeadLetterFile(t, filePath)))
+}
+
+func TestHashTagEventDeadLetterQueueDisabled(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.deadLetterQueue = make(chan []HashTagEvent, 2)
+	filePath := filepath.Join(t.TempDir(), "dead_letter.jsonl")
+	service.config.DeadLetter.FilePath = filePath
+	service.config.DeadLetter.MaxFileSize = 1024 * 1024
+
+	// events are dropped.
+	service.sendToDeadLetterQueue([]HashTagEvent{{HashTag: "a", Keys: utility.NewStringSet(), AccessTime: time.Now()}})
+	assert.Equal(t, int64(0), service.eventBatchCountInDeadLetterQueue)
+	assert.Equal(t, 0, len(service.deadLetterQueue))
+	_, err := os.Stat(filePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHashTagEventReportEventsWithCompression(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: time.Second}
+	service.config.EventReport.CompressionEnabled = true
+	service.config.EventReport.CompressionAlgorithm = CompressionAlgorithmGzip
+
+	var contentEncoding string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentEncoding = r.Header.Get(HTTPHeaderContentEncoding)
+		var reader io.Reader = r.Body
+		if contentEncoding == CompressionAlgorithmGzip {
+			gzipReader, err := gzip.NewReader(r.Body)
+			assert.Nil(t, err)
+			reader = gzipReader
+		}
+		body, _ = io.ReadAll(reader)
+	}))
+	defer server.Close()
+	testSetEventReportURL(service, server.URL)
+
+	// body is compressed
+	keys := make([]string, 0)
+	for i := 0; i < 100; i++ {
+		keys = append(keys, fmt.Sprintf("{a}key_%d", i))
+	}
+	events := []HashTagEvent{{HashTag: "a", Keys: utility.NewStringSet(keys...), AccessTime: time.Now()}}
+	assert.Nil(t, service._reportEvents(events))
+	assert.Equal(t, CompressionAlgorithmGzip, contentEncoding)
+	data := map[string][]HashTagEvent{}
+	assert.Nil(t, json.Unmarshal(body, &data))
+	assert.ElementsMatch(t, keys, data["events"][0].Keys.ToSlice())
+
+	// body is not compressed if compressed body is not smaller
+	events = []HashTagEvent{{HashTag: "a", Keys: utility.NewStringSet(), AccessTime: time.Now()}}
+	assert.Nil(t, service._reportEvents(events))
+	assert.Equal(t, "", contentEncoding)
+	data = map[string][]HashTagEvent{}
+	assert.Nil(t, json.Unmarshal(body, &data))
+	assert.Equal(t, "a", data["events"][0].HashTag)
+}
+
+func TestHashTagEventReportEventsWithStreamEncoding(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: time.Second}
+	service.config.EventReport.StreamEncodingEnabled = true
+	service.config.EventReport.CompressionAlgorithm = CompressionAlgorithmGzip
+
+	var requestCount int32
+	bodies := make(chan []byte, 10)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reader io.Reader = r.Body
+		if r.Header.Get(HTTPHeaderContentEncoding) == CompressionAlgorithmGzip {
+			gzipReader, err := gzip.NewReader(r.Body)
+			assert.Nil(t, err)
+			reader = gzipReader
+		}
+		body, _ := io.ReadAll(reader)
+		bodies <- body
+		// the first request fails to check that body is encoded again for the fallback url
+		if atomic.AddInt32(&requestCount, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer primary.Close()
+	service.urlSelector = NewURLSelector(primary.URL, []string{primary.URL}, 10)
+
+	keys := make([]string, 0)
+	for i := 0; i < 100; i++ {
+		keys = append(keys, fmt.Sprintf("{a}key_%d", i))
+	}
+	events := []HashTagEvent{{HashTag: "a", Keys: utility.NewStringSet(keys...), AccessTime: time.Now()}}
+	for _, compressionEnabled := range []bool{false, true} {
+		atomic.StoreInt32(&requestCount, 0)
+		service.config.EventReport.CompressionEnabled = compressionEnabled
+		assert.Nil(t, service._reportEvents(events))
+		assert.Equal(t, 2, len(bodies))
+		for i := 0; i < 2; i++ {
+			data := map[string][]HashTagEvent{}
+			assert.Nil(t, json.Unmarshal(<-bodies, &data))
+			assert.ElementsMatch(t, keys, data["events"][0].Keys.ToSlice())
+		}
+	}
+}
+
+func TestHashTagEventReportEventsWithPayloadVersion(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: time.Second}
+
+	var version string
+	var body []byte
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version = r.Header.Get(HTTPHeaderEventPayloadVersion)
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+	testSetEventReportURL(service, server.URL)
+
+	events := []HashTagEvent{
+		{HashTag: "a", Keys: utility.NewStringSet("{a}b"), AccessTime: time.Now()},
+		{HashTag: "b", Keys: utility.NewStringSet("{b}c"), AccessTime: time.Now()},
+	}
+	for _, streamEncodingEnabled := range []bool{false, true} {
+		service.config.EventReport.StreamEncodingEnabled = streamEncodingEnabled
+		for _, payloadVersion := range []int{0, EventPayloadVersion1, EventPayloadVersion2} {
+			service.config.EventReport.PayloadVersion = payloadVersion
+			assert.Nil(t, service._reportEvents(events))
+			payload := EventPayload{}
+			assert.Nil(t, json.Unmarshal(body, &payload), string(body))
+			assert.Equal(t, 2, len(payload.Events))
+			assert.Equal(t, "a", payload.Events[0].HashTag)
+			if payloadVersion == EventPayloadVersion2 {
+				assert.Equal(t, "2", version)
+				assert.Equal(t, EventPayloadVersion2, payload.Metadata.Version)
+				assert.Equal(t, 2, payload.Metadata.EventCount)
+				assert.False(t, payload.Metadata.CreatedAt.IsZero())
+			} else {
+				assert.Equal(t, "1", version)
+				assert.Nil(t, payload.Metadata)
+				assert.NotContains(t, string(body), "metadata")
+			}
+		}
+	}
+
+	// version mismatch is not retryable.
+	statusCode = HTTPStatusEventPayloadVersionMismatch
+	err := service._reportEvents(events)
+	var respErr reportEventsResponseError
+	assert.True(t, errors.As(err, &respErr))
+	assert.Equal(t, HTTPStatusEventPayloadVersionMismatch, respErr.statusCode)
+	assert.False(t, isReportEventsErrorRetryable(err))
+	service.recordReportEventsError(events, err)
+}
+
+func benchmarkReportEvents(b *testing.B, streamEncodingEnabled bool) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: 10 * time.Second}
+	service.config.EventReport.StreamEncodingEnabled = streamEncodingEnabled
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+	testSetEventReportURL(service, server.URL)
+
+	events := make([]HashTagEvent, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		hashTag := fmt.Sprintf("hash_tag_%d", i)
+		keys := make([]string, 0, 100)
+		for j := 0; j < 100; j++ {
+			keys = append(keys, fmt.Sprintf("{%s}key_%d", hashTag, j))
+		}
+		events = append(events, HashTagEvent{HashTag: hashTag, Keys: utility.NewStringSet(keys...), AccessTime: time.Now()})
+	}
+	// peak-heap-B/op is the max heap growth while reporting events, sampled every millisecond.
+	runtime.GC()
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	baseHeap := memStats.HeapAlloc
+	var peakHeap uint64
+	stopCh := make(chan bool)
+	sampleDone := make(chan bool)
+	go func() {
+		defer close(sampleDone)
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > baseHeap && stats.HeapAlloc-baseHeap > peakHeap {
+					peakHeap = stats.HeapAlloc - baseHeap
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := service._reportEvents(events); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	close(stopCh)
+	<-sampleDone
+	b.ReportMetric(float64(peakHeap), "peak-heap-B/op")
+}
+
+func BenchmarkReportEventsWithBufferEncoding(b *testing.B) {
+	benchmarkReportEvents(b, false)
+}
+
+func BenchmarkReportEventsWithStreamEncoding(b *testing.B) {
+	benchmarkReportEvents(b, true)
+}
+
+func TestHashTagEventDrainEventsWithTimeout(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: time.Second}
+	service.eventBuffer = make(chan HashTagEvent)
+	service.collectedEventBuffer = make(chan HashTagEvent)
+	service.config.EventReport.RequestMaxEvent = 2
+	service.config.DrainDuration = 50 * time.Millisecond
+	service.deadLetterQueue = make(chan []HashTagEvent, 10)
+	service.config.DeadLetter.Enabled = true
+
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requestCount, 1)
+		time.Sleep(40 * time.Millisecond)
+	}))
+	defer server.Close()
+	testSetEventReportURL(service, server.URL)
+
+	for _, hashTag := range []string{"a", "b", "c", "d", "e", "f"} {
+		event, _ := NewHashTagEvent(hashTag, []string{}, HashTagAccessModeRead, time.Now())
+		service.aggregateEvent(event)
+	}
+	startTime := time.Now()
+	service.drainEvents()
+	assert.Less(t, int64(time.Since(startTime)), int64(200*time.Millisecond))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requestCount))
+
+	// the second request is cancelled and the last batch is not reported,
+	// both are sent to dead letter queue.
+	assert.Equal(t, 2, len(service.deadLetterQueue))
+	eventCount := 0
+	for len(service.deadLetterQueue) > 0 {
+		eventCount += len(<-service.deadLetterQueue)
+	}
+	assert.Equal(t, 4, eventCount)
+}
+
+func TestHashTagEventAggregateEventByPrefix(t *testing.T) {
+	loggerConfig := map[string]interface{}{"console": map[string]interface{}{"level": "debug"}}
+	logger, _ := parseLogger("event_service", loggerConfig)
+	metric := NewNoopMetricClient()
+	config := &HashTagEventServiceConfig{
+		EventReport:          HashTagEventServiceEventReportConfig{URL: "localhost"},
+		PerPrefixAggInterval: map[string]time.Duration{"hot": time.Second, "hot_a": 2 * time.Second},
+	}
+	service, err := NewHashTagEventService(config, logger, metric)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hot_a", "hot"}, service.aggPrefixes)
+
+	for _, hashTag := range []string{"hot_a1", "hot_a2", "hot_b", "cold", "ahot"} {
+		event, _ := NewHashTagEvent(hashTag, []string{}, HashTagAccessModeRead, time.Now())
+		assert.Nil(t, service.aggregateEvent(event))
+	}
+	assert.Equal(t, int64(5), service.GetAggregatedEventCount())
+	assert.Equal(t, 2, len(service.events))
+	assert.Equal(t, 1, len(service.prefixEvents["hot"]))
+	assert.Equal(t, 2, len(service.prefixEvents["hot_a"]))
+
+	events := service.collectEventsByPrefix("hot")
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "hot_b", events[0].HashTag)
+	assert.Equal(t, int64(4), service.GetAggregatedEventCount())
+
+	events = service.collectEventsByPrefix("")
+	hashTags := make([]string, 0)
+	for _, event := range events {
+		hashTags = append(hashTags, event.HashTag)
+	}
+	assert.ElementsMatch(t, []string{"cold", "ahot"}, hashTags)
+
+	events = service.collectEvents()
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, int64(0), service.GetAggregatedEventCount())
+}
+
+func TestURLSelector(t *testing.T) {
+	selector := NewURLSelector("primary", []string{"fallback1", "fallback2"}, 2)
+	assert.Equal(t, "primary", selector.Current())
+	assert.True(t, selector.IsPrimary())
+	assert.Equal(t, []string{"primary", "fallback1", "fallback2"}, selector.Candidates())
+
+	assert.False(t, selector.MarkFailed("primary"))
+	selector.MarkSucceeded("primary")
+	assert.False(t, selector.MarkFailed("primary"))
+	assert.True(t, selector.MarkFailed("primary"))
+	assert.Equal(t, "fallback1", selector.Current())
+	assert.False(t, selector.IsPrimary())
+	assert.Equal(t, []string{"fallback1", "fallback2", "primary"}, selector.Candidates())
+
+	// failures and successes of a url which is not current are ignored
+	assert.False(t, selector.MarkFailed("primary"))
+	assert.False(t, selector.MarkFailed("primary"))
+	assert.Equal(t, "fallback1", selector.Current())
+	assert.False(t, selector.MarkFailed("fallback1"))
+	selector.MarkSucceeded("primary")
+	assert.True(t, selector.MarkFailed("fallback1"))
+	assert.Equal(t, "fallback2", selector.Current())
+
+	assert.False(t, selector.MarkFailed("fallback2"))
+	assert.True(t, selector.MarkFailed("fallback2"))
+	assert.Equal(t, "primary", selector.Current())
+
+	assert.False(t, selector.SwitchToPrimary())
+	assert.False(t, selector.MarkFailed("primary"))
+	assert.True(t, selector.MarkFailed("primary"))
+	assert.True(t, selector.SwitchToPrimary())
+	assert.Equal(t, "primary", selector.Current())
+
+	// never switch without fallback urls
+	selector = NewURLSelector("primary", nil, 1)
+	assert.False(t, selector.MarkFailed("primary"))
+	assert.Equal(t, "primary", selector.Current())
+}
+
+func TestHashTagEventReportEventsWithFailover(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: time.Second}
+	events := []HashTagEvent{{HashTag: "a", Keys: utility.NewStringSet("{a}b"), AccessTime: time.Now()}}
+
+	var primaryDown int32 = 1
+	var primaryCount, fallbackCount int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCount, 1)
+		if atomic.LoadInt32(&primaryDown) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fallbackCount, 1)
+	}))
+	defer fallback.Close()
+	service.config.EventReport.URL = primary.URL
+	service.config.EventReport.FallbackURLs = []string{fallback.URL}
+	service.urlSelector = NewURLSelector(primary.URL, []string{fallback.URL}, 2)
+
+	// fallback url is tried if primary url fails
+	assert.Nil(t, service._reportEvents(events))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&primaryCount))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fallbackCount))
+	assert.True(t, service.urlSelector.IsPrimary())
+
+	// switch to fallback url after consecutive failures
+	assert.Nil(t, service._reportEvents(events))
+	assert.Equal(t, fallback.URL, service.urlSelector.Current())
+	assert.Nil(t, service._reportEvents(events))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&primaryCount))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fallbackCount))
+
+	// switch back to primary url when it recovers
+	service.wg.Add(1)
+	go service.probePrimaryURL(10 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, fallback.URL, service.urlSelector.Current())
+	atomic.StoreInt32(&primaryDown, 0)
+	time.Sleep(50 * time.Millisecond)
+	close(service.stopCh)
+	service.wg.Wait()
+	assert.True(t, service.urlSelector.IsPrimary())
+}
+
+func TestHashTagEventRunWorker(t *testing.T) {
+	originDelay := workerRestartDelay
+	workerRestartDelay = time.Millisecond
+	defer func() { workerRestartDelay = originDelay }()
+	service := testInitHashTagEventService()
+	backend := &testMetricBackend{}
+	service.metric = NewMetricClient(backend)
+
+	// worker is restarted after panic and the supervisor returns when the service is stopped.
+	var runCount int32
+	service.runWorker("test", func() {
+		if atomic.AddInt32(&runCount, 1) == 1 {
+			panic("worker panic")
+		}
+		<-service.stopCh
+	})
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runCount))
+	assert.Equal(t, []string{fmt.Sprintf("counter.%s.test:1", metricWorkerPanic)}, backend.records)
+	atomic.StoreInt32(&service.stop, 1)
+	close(service.stopCh)
+	service.wg.Wait()
+
+	// worker is not restarted if the service is stopping.
+	runCount = 0
+	service.runWorker("test", func() {
+		atomic.AddInt32(&runCount, 1)
+		panic("worker panic")
+	})
+	service.wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runCount))
+}
+
+func TestHashTagEventReplayDeadLetterFile(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.deadLetterQueue = make(chan []HashTagEvent, 2)
+	service.config.DeadLetter.Enabled = true
+	filePath := filepath.Join(t.TempDir(), "dead_letter.jsonl")
+	service.config.DeadLetter.FilePath = filePath
+	events := func(hashTag string) []HashTagEvent {
+		return []HashTagEvent{{HashTag: hashTag, Keys: utility.NewStringSet(), AccessTime: time.Now()}}
+	}
+
+	// no file to replay
+	service.replayDeadLetterFile()
+	assert.Equal(t, int64(0), service.eventBatchCountInDeadLetterQueue)
+
+	for _, hashTag := range []string{"a", "b", "c"} {
+		assert.Nil(t, appendEventsToDeadLetterFile(filePath, 1024*1024, events(hashTag)))
+	}
+	// batches exceeding queue size are kept in file
+	service.replayDeadLetterFile()
+	assert.Equal(t, int64(2), service.eventBatchCountInDeadLetterQueue)
+	assert.Equal(t, "a", (<-service.deadLetterQueue)[0].HashTag)
+	assert.Equal(t, "b", (<-service.deadLetterQueue)[0].HashTag)
+	batches := testReadDeadLetterFile(t, filePath)
+	assert.Equal(t, 1, len(batches))
+	assert.Equal(t, "c", batches[0][0].HashTag)
+	atomic.StoreInt64(&service.eventBatchCountInDeadLetterQueue, 0)
+
+	// file is removed after all batches are replayed
+	service.replayDeadLetterFile()
+	assert.Equal(t, int64(1), service.eventBatchCountInDeadLetterQueue)
+	_, err := os.Stat(filePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHashTagEventDrainEventsWithRetry(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: time.Second}
+	service.eventBuffer = make(chan HashTagEvent)
+	service.collectedEventBuffer = make(chan HashTagEvent)
+	service.config.EventReport.RequestMaxEvent = 10
+	service.config.EventReport.MaxRetries = 3
+	service.config.EventReport.InitialBackoff = time.Millisecond
+	service.config.EventReport.MaxBackoff = time.Millisecond
+	service.config.DrainDuration = time.Second
+	service.deadLetterQueue = make(chan []HashTagEvent, 10)
+	service.config.DeadLetter.Enabled = true
+	// drain is called after service is stopped.
+	close(service.stopCh)
+
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requestCount, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	testSetEventReportURL(service, server.URL)
+
+	event, _ := NewHashTagEvent("a", []string{}, HashTagAccessModeWrite, time.Now())
+	service.aggregateEvent(event)
+	service.drainEvents()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requestCount))
+	assert.Equal(t, 0, len(service.deadLetterQueue))
+}
+
+func TestHashTagEventFlush(t *testing.T) {
+	service := testInitHashTagEventService()
+	service.client = &http.Client{Timeout: time.Second}
+	service.eventBuffer = make(chan HashTagEvent, 10)
+	service.collectedEventBuffer = make(chan HashTagEvent, 10)
+	service.config.EventReport.RequestMaxEvent = 2
+	service.deadLetterQueue = make(chan []HashTagEvent, 10)
+	service.config.DeadLetter.Enabled = true
+
+	var failed int32
+	var mutex sync.Mutex
+	hashTags := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failed) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data := map[string][]HashTagEvent{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&data))
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, event := range data["events"] {
+			hashTags = append(hashTags, event.HashTag)
+		}
+	}))
+	defer server.Close()
+	testSetEventReportURL(service, server.URL)
+
+	// events in buffer, aggregated events and collected events are all reported.
+	for _, hashTag := range []string{"a", "b", "a"} {
+		assert.Nil(t, service.SendEvent(hashTag, []string{}, HashTagAccessModeRead, time.Now()))
+	}
+	event, _ := NewHashTagEvent("c", []string{}, HashTagAccessModeRead, time.Now())
+	assert.Nil(t, service.aggregateEvent(event))
+	event, _ = NewHashTagEvent("d", []string{}, HashTagAccessModeRead, time.Now())
+	service.collectedEventBuffer <- event
+	atomic.AddInt64(&service.eventCountInCollectedEventBuffer, 1)
+
+	assert.Nil(t, service.Flush(context.Background()))
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, hashTags)
+	assert.Equal(t, int64(0), service.GetEventCountInEventBuffer())
+	assert.Equal(t, int64(0), service.GetEventCountInCollectedEventBuffer())
+	assert.Equal(t, int64(0), service.GetAggregatedEventCount())
+	assert.Nil(t, service.Flush(context.Background()))
+
+	// events failed to report are sent to dead letter queue.
+	atomic.StoreInt32(&failed, 1)
+	assert.Nil(t, service.SendEvent("e", []string{}, HashTagAccessModeRead, time.Now()))
+	assert.NotNil(t, service.Flush(context.Background()))
+	assert.Equal(t, 1, len(service.deadLetterQueue))
+
+	atomic.StoreInt32(&service.stop, 1)
+	assert.Equal(t, ErrEventServiceStopped, service.Flush(context.Background()))
+}
@@ -2,6 +2,7 @@ package base
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"gopkg.in/alexcesaro/statsd.v2"
@@ -14,7 +15,14 @@ const (
 	histogramMetricPrefix = "histogram."
 )
 
+const (
+	MetricBackendStatsd = "statsd"
+	MetricBackendNoop   = "noop"
+)
+
 type MetricConfig struct {
+	// Backend is statsd by default, metrics are dropped by noop backend.
+	Backend            string   `yaml:"backend"`
 	Prefix             string   `yaml:"prefix"`
 	Host               string   `yaml:"host"`
 	Network            string   `yaml:"network"`
@@ -25,6 +33,13 @@ type MetricConfig struct {
 }
 
 func (config MetricConfig) check() error {
+	switch config.Backend {
+	case "", MetricBackendStatsd:
+	case MetricBackendNoop:
+		return nil
+	default:
+		return fmt.Errorf("backend %s is not supported", config.Backend)
+	}
 	if config.Host == "" {
 		return errors.New("host should not be empty")
 	}
@@ -34,11 +49,54 @@ func (config MetricConfig) check() error {
 	return nil
 }
 
+// MetricBackend is where metrics of MetricClient are sent to.
+type MetricBackend interface {
+	Count(bucket string, n interface{})
+	Timing(bucket string, value interface{})
+	Gauge(bucket string, value interface{})
+	Histogram(bucket string, value interface{})
+	Flush()
+	Close()
+}
+
+// NoopMetricBackend drops all metrics.
+type NoopMetricBackend struct{}
+
+func (backend NoopMetricBackend) Count(bucket string, n interface{})         {}
+func (backend NoopMetricBackend) Timing(bucket string, value interface{})    {}
+func (backend NoopMetricBackend) Gauge(bucket string, value interface{})     {}
+func (backend NoopMetricBackend) Histogram(bucket string, value interface{}) {}
+func (backend NoopMetricBackend) Flush()                                     {}
+func (backend NoopMetricBackend) Close()                                     {}
+
+// Metric records metrics of room, callers depend on it instead of MetricClient.
+type Metric interface {
+	MetricCount(key string, num interface{})
+	MetricIncrease(key string)
+	MetricTimeDuration(key string, duration time.Duration)
+	MetricTiming(key string, value interface{})
+	MetricGauge(bucket string, value interface{})
+	MetricHistogram(bucket string, value interface{})
+	Flush()
+	Close()
+}
+
+// MetricClient implements Metric by sending metrics with prefixes of their kinds to backend.
 type MetricClient struct {
-	*statsd.Client
+	backend MetricBackend
+}
+
+var _ Metric = (*MetricClient)(nil)
+
+func NewMetricClient(backend MetricBackend) *MetricClient {
+	return &MetricClient{backend: backend}
+}
+
+func NewNoopMetricClient() *MetricClient {
+	return NewMetricClient(NoopMetricBackend{})
 }
 
-// 初始化Metric (statsd)
+// 初始化Metric
 //
 // Returns:
 //   - metric连接对象的指针
@@ -47,6 +105,17 @@ func InitMetric(config MetricConfig) (*MetricClient, error) {
 	if err := config.check(); err != nil {
 		return nil, err
 	}
+	if config.Backend == MetricBackendNoop {
+		return NewNoopMetricClient(), nil
+	}
+	backend, err := newStatsdMetricBackend(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewMetricClient(backend), nil
+}
+
+func newStatsdMetricBackend(config MetricConfig) (MetricBackend, error) {
 	var opts []statsd.Option
 	opts = append(opts, statsd.Address(config.Host))
 	if config.Prefix != "" {
@@ -67,45 +136,46 @@ func InitMetric(config MetricConfig) (*MetricClient, error) {
 	if len(config.Tags) > 0 {
 		opts = append(opts, statsd.Tags(config.Tags...))
 	}
-	c := &MetricClient{}
-	client, err := statsd.New(opts...)
-	c.Client = client
-	return c, err
+	return statsd.New(opts...)
+}
+
+// Flush sends buffered metrics to backend.
+func (mc *MetricClient) Flush() {
+	mc.backend.Flush()
+}
+
+// Close flushes buffered metrics and closes backend.
+func (mc *MetricClient) Close() {
+	mc.backend.Close()
 }
 
 // MetricCount would change count on <num> for key.
-func (mc *MetricClient) MetricCount(key string, num interface{}) *MetricClient {
-	mc.Count(counterMetricPrefix+key, num)
-	return mc
+func (mc *MetricClient) MetricCount(key string, num interface{}) {
+	mc.backend.Count(counterMetricPrefix+key, num)
 }
 
 // MetricIncrease would increase count on 1 for key with statsd count.
-func (mc *MetricClient) MetricIncrease(key string) *MetricClient {
-	mc.Count(counterMetricPrefix+key, 1)
-	return mc
+func (mc *MetricClient) MetricIncrease(key string) {
+	mc.backend.Count(counterMetricPrefix+key, 1)
 }
 
 // MetricTimeDuration would record time duration for key with statsd timing.
 //
 // - Parameters:
 //   - duration: e.g. time.Now().Sub(oldTime)
-func (mc *MetricClient) MetricTimeDuration(key string, duration time.Duration) *MetricClient {
+func (mc *MetricClient) MetricTimeDuration(key string, duration time.Duration) {
 	ms := float64(duration) / float64(time.Millisecond)
-	mc.Timing(timeMetricPrefix+key, ms)
-	return mc
+	mc.backend.Timing(timeMetricPrefix+key, ms)
 }
 
-func (mc *MetricClient) MetricTiming(key string, value interface{}) *MetricClient {
-	mc.Timing(timeMetricPrefix+key, value)
-	return mc
+func (mc *MetricClient) MetricTiming(key string, value interface{}) {
+	mc.backend.Timing(timeMetricPrefix+key, value)
 }
 
-func (mc *MetricClient) MetricGauge(bucket string, value interface{}) *MetricClient {
-	mc.Gauge(gaugeMetricPrefix+bucket, value)
-	return mc
+func (mc *MetricClient) MetricGauge(bucket string, value interface{}) {
+	mc.backend.Gauge(gaugeMetricPrefix+bucket, value)
 }
 
-func (mc *MetricClient) MetricHistogram(bucket string, value interface{}) *MetricClient {
-	mc.Histogram(histogramMetricPrefix+bucket, value)
-	return mc
+func (mc *MetricClient) MetricHistogram(bucket string, value interface{}) {
+	mc.backend.Histogram(histogramMetricPrefix+bucket, value)
 }
@@ -0,0 +1,50 @@
+package base
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetricBackend struct {
+	NoopMetricBackend
+	records []string
+}
+
+func (backend *testMetricBackend) Count(bucket string, n interface{}) {
+	backend.records = append(backend.records, fmt.Sprintf("%s:%v", bucket, n))
+}
+
+func (backend *testMetricBackend) Timing(bucket string, value interface{}) {
+	backend.records = append(backend.records, fmt.Sprintf("%s:%v", bucket, value))
+}
+
+func (backend *testMetricBackend) Gauge(bucket string, value interface{}) {
+	backend.records = append(backend.records, fmt.Sprintf("%s:%v", bucket, value))
+}
+
+func TestMetricClientBackend(t *testing.T) {
+	backend := &testMetricBackend{}
+	client := NewMetricClient(backend)
+	client.MetricIncrease("a")
+	client.MetricCount("b", 3)
+	client.MetricTimeDuration("c", 2*time.Millisecond)
+	client.MetricGauge("d", 4)
+	assert.Equal(t, []string{"counter.a:1", "counter.b:3", "time.c:2", "gauge.d:4"}, backend.records)
+}
+
+func TestInitMetric(t *testing.T) {
+	client, err := InitMetric(MetricConfig{Backend: MetricBackendNoop})
+	assert.Nil(t, err)
+	assert.Equal(t, NoopMetricBackend{}, client.backend)
+	client.MetricIncrease("a")
+	client.Close()
+
+	_, err = InitMetric(MetricConfig{})
+	assert.NotNil(t, err)
+
+	_, err = InitMetric(MetricConfig{Backend: "otlp", Host: "localhost"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,141 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+const migrationTimeout = time.Minute
+
+// migration creates or alters tables with indexes in [startIndex, endIndex] of a sharding.
+// Only forward migrations are supported, a migration should not be changed after it is released,
+// add a new migration with a greater version instead.
+type migration struct {
+	version     int
+	description string
+	migrate     func(ctx context.Context, tx *pg.Tx, startIndex, endIndex int) error
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create room_data_v2 tables",
+		migrate: func(ctx context.Context, tx *pg.Tx, startIndex, endIndex int) error {
+			for index := startIndex; index <= endIndex; index++ {
+				_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS room_data_v2_%[1]d (
+    hash_tag character varying NOT NULL,
+    value jsonb NOT NULL,
+    deleted_at timestamp with time zone DEFAULT NULL,
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    version bigint NOT NULL DEFAULT 0,
+    CONSTRAINT room_data_v2_%[1]d_pkey PRIMARY KEY (hash_tag)
+)`, index))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     2,
+		description: "create room_hash_tag_keys tables",
+		migrate: func(ctx context.Context, tx *pg.Tx, startIndex, endIndex int) error {
+			for index := startIndex; index <= endIndex; index++ {
+				_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS room_hash_tag_keys_%[1]d (
+    hash_tag character varying NOT NULL,
+    keys text[] NOT NULL,
+    accessed_at timestamp with time zone NOT NULL,
+    written_at timestamp with time zone DEFAULT NULL,
+    synced_at timestamp with time zone DEFAULT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    status character varying NOT NULL,
+    version bigint NOT NULL DEFAULT 0,
+    CONSTRAINT room_hash_tag_keys_%[1]d_pkey PRIMARY KEY (hash_tag)
+);
+CREATE INDEX IF NOT EXISTS room_hash_tag_keys_status_accessed_at_%[1]d_idx
+    ON room_hash_tag_keys_%[1]d USING btree (status, accessed_at);
+CREATE INDEX IF NOT EXISTS room_hash_tag_keys_status_written_at_%[1]d_idx
+    ON room_hash_tag_keys_%[1]d USING btree (status, written_at)`, index))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     3,
+		description: "add frequency column to room_hash_tag_keys tables",
+		migrate: func(ctx context.Context, tx *pg.Tx, startIndex, endIndex int) error {
+			for index := startIndex; index <= endIndex; index++ {
+				_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+ALTER TABLE room_hash_tag_keys_%[1]d ADD COLUMN IF NOT EXISTS frequency integer NOT NULL DEFAULT 0`, index))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// RunMigrations applies migrations which are not applied yet to every sharding,
+// applied versions are recorded in table schema_migrations of the sharding.
+func RunMigrations(ctx context.Context, db *DBCluster) error {
+	for _, client := range db.clients {
+		if err := client.runMigrations(ctx, migrations); err != nil {
+			return fmt.Errorf("sharding [%d, %d] migration error, %w", client.startIndex, client.endIndex, err)
+		}
+	}
+	return nil
+}
+
+func (client dbClient) runMigrations(ctx context.Context, migrations []migration) error {
+	_, err := client.client.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version bigint NOT NULL PRIMARY KEY,
+    description character varying NOT NULL,
+    applied_at timestamp with time zone NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return err
+	}
+	return client.client.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		// block migrations of other instances until this transaction finishes.
+		if _, err := tx.ExecContext(ctx, "LOCK TABLE schema_migrations IN EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+		var versions []int
+		if _, err := tx.QueryContext(ctx, &versions, "SELECT version FROM schema_migrations"); err != nil {
+			return err
+		}
+		applied := make(map[int]bool, len(versions))
+		for _, version := range versions {
+			applied[version] = true
+		}
+		for _, m := range migrations {
+			if applied[m.version] {
+				continue
+			}
+			if err := m.migrate(ctx, tx, client.startIndex, client.endIndex); err != nil {
+				return fmt.Errorf("version=%d, %w", m.version, err)
+			}
+			_, err := tx.ExecContext(
+				ctx,
+				"INSERT INTO schema_migrations (version, description) VALUES (?, ?)",
+				m.version, m.description)
+			if err != nil {
+				return fmt.Errorf("version=%d, %w", m.version, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package base
+
+import (
+	"bytepower_room/utility"
+	"sort"
+	"sync"
+)
+
+type PubSubMessage struct {
+	Channel string
+	Message string
+}
+
+// PubSubBroker dispatches published messages to subscribers of channels in process,
+// messages are not shared between room server instances.
+type PubSubBroker struct {
+	mutex    sync.RWMutex
+	channels map[string][]chan PubSubMessage
+}
+
+func NewPubSubBroker() *PubSubBroker {
+	return &PubSubBroker{channels: make(map[string][]chan PubSubMessage)}
+}
+
+// Subscribe registers ch on channel, it returns false if ch has already subscribed the channel.
+func (broker *PubSubBroker) Subscribe(channel string, ch chan PubSubMessage) bool {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	for _, subscriber := range broker.channels[channel] {
+		if subscriber == ch {
+			return false
+		}
+	}
+	broker.channels[channel] = append(broker.channels[channel], ch)
+	return true
+}
+
+// Unsubscribe removes ch from channel, it returns false if ch has not subscribed the channel.
+func (broker *PubSubBroker) Unsubscribe(channel string, ch chan PubSubMessage) bool {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	subscribers := broker.channels[channel]
+	for i, subscriber := range subscribers {
+		if subscriber != ch {
+			continue
+		}
+		if len(subscribers) == 1 {
+			delete(broker.channels, channel)
+		} else {
+			broker.channels[channel] = append(subscribers[:i:i], subscribers[i+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+// Publish sends message to subscribers of channel without blocking,
+// it returns the count of subscribers receiving the message,
+// the message is dropped for subscribers whose buffers are full.
+func (broker *PubSubBroker) Publish(channel, message string) int {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+	count := 0
+	for _, subscriber := range broker.channels[channel] {
+		select {
+		case subscriber <- PubSubMessage{Channel: channel, Message: message}:
+			count++
+		default:
+		}
+	}
+	return count
+}
+
+// Channels returns sorted channels with at least one subscriber matching glob-style pattern,
+// all channels are returned if pattern is empty.
+func (broker *PubSubBroker) Channels(pattern string) []string {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+	channels := make([]string, 0, len(broker.channels))
+	for channel := range broker.channels {
+		if pattern == "" || utility.MatchPattern(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// NumSub returns the count of subscribers of channel.
+func (broker *PubSubBroker) NumSub(channel string) int {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+	return len(broker.channels[channel])
+}
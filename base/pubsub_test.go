@@ -0,0 +1,40 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPubSubBroker(t *testing.T) {
+	broker := NewPubSubBroker()
+	ch1 := make(chan PubSubMessage, 1)
+	ch2 := make(chan PubSubMessage, 1)
+
+	assert.True(t, broker.Subscribe("news", ch1))
+	assert.False(t, broker.Subscribe("news", ch1))
+	assert.True(t, broker.Subscribe("news", ch2))
+	assert.True(t, broker.Subscribe("sports", ch2))
+	assert.Equal(t, 2, broker.NumSub("news"))
+	assert.Equal(t, 0, broker.NumSub("weather"))
+	assert.Equal(t, []string{"news", "sports"}, broker.Channels(""))
+	assert.Equal(t, []string{"news"}, broker.Channels("n*"))
+	assert.Equal(t, []string{}, broker.Channels("w*"))
+
+	assert.Equal(t, 2, broker.Publish("news", "hello"))
+	assert.Equal(t, PubSubMessage{Channel: "news", Message: "hello"}, <-ch1)
+	assert.Equal(t, PubSubMessage{Channel: "news", Message: "hello"}, <-ch2)
+	assert.Equal(t, 0, broker.Publish("weather", "sunny"))
+
+	// message is dropped for subscriber whose buffer is full
+	assert.Equal(t, 1, broker.Publish("sports", "goal"))
+	assert.Equal(t, 1, broker.Publish("news", "bye"))
+	assert.Equal(t, PubSubMessage{Channel: "sports", Message: "goal"}, <-ch2)
+	assert.Equal(t, PubSubMessage{Channel: "news", Message: "bye"}, <-ch1)
+
+	assert.True(t, broker.Unsubscribe("news", ch1))
+	assert.False(t, broker.Unsubscribe("news", ch1))
+	assert.Equal(t, 1, broker.NumSub("news"))
+	assert.True(t, broker.Unsubscribe("news", ch2))
+	assert.Equal(t, []string{"sports"}, broker.Channels(""))
+}
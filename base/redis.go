@@ -91,7 +91,7 @@ func (config connectionConfig) check() error {
 	return nil
 }
 
-func NewRedisClusterFromConfig(config RedisClusterConfig, logger *log.Logger, metric *MetricClient) (*redis.ClusterClient, error) {
+func NewRedisClusterFromConfig(config RedisClusterConfig, logger *log.Logger, metric Metric) (*redis.ClusterClient, error) {
 	opt, err := initRedisOption(config)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,95 @@
+package base
+
+import "sync"
+
+// URLSelector selects url from a primary url and fallback urls.
+// It switches to the next url after `failureThreshold` consecutive failures of the current url,
+// and switches back to the primary url by SwitchToPrimary.
+type URLSelector struct {
+	mutex               sync.Mutex
+	urls                []string
+	current             int
+	consecutiveFailures int
+	failureThreshold    int
+}
+
+func NewURLSelector(primaryURL string, fallbackURLs []string, failureThreshold int) *URLSelector {
+	urls := make([]string, 0, len(fallbackURLs)+1)
+	urls = append(urls, primaryURL)
+	urls = append(urls, fallbackURLs...)
+	if failureThreshold <= 0 {
+		failureThreshold = 1
+	}
+	return &URLSelector{urls: urls, failureThreshold: failureThreshold}
+}
+
+// Current returns the url in use.
+func (selector *URLSelector) Current() string {
+	selector.mutex.Lock()
+	defer selector.mutex.Unlock()
+	return selector.urls[selector.current]
+}
+
+func (selector *URLSelector) Primary() string {
+	return selector.urls[0]
+}
+
+func (selector *URLSelector) IsPrimary() bool {
+	selector.mutex.Lock()
+	defer selector.mutex.Unlock()
+	return selector.current == 0
+}
+
+// Candidates returns all urls, starting from the current url and followed by the others in order.
+func (selector *URLSelector) Candidates() []string {
+	selector.mutex.Lock()
+	defer selector.mutex.Unlock()
+	urls := make([]string, 0, len(selector.urls))
+	urls = append(urls, selector.urls[selector.current:]...)
+	urls = append(urls, selector.urls[:selector.current]...)
+	return urls
+}
+
+// MarkFailed records a failure of url, it returns true if the selector switches to the next url.
+// It is ignored if url is not the current url, e.g. the selector has switched since url was selected.
+func (selector *URLSelector) MarkFailed(url string) bool {
+	selector.mutex.Lock()
+	defer selector.mutex.Unlock()
+	if selector.urls[selector.current] != url {
+		return false
+	}
+	return selector.markFailed()
+}
+
+func (selector *URLSelector) markFailed() bool {
+	selector.consecutiveFailures++
+	if len(selector.urls) == 1 || selector.consecutiveFailures < selector.failureThreshold {
+		return false
+	}
+	selector.current = (selector.current + 1) % len(selector.urls)
+	selector.consecutiveFailures = 0
+	return true
+}
+
+// MarkSucceeded resets consecutive failures if url is the current url,
+// a late success of a url which is not current does not reset failures of the current url.
+func (selector *URLSelector) MarkSucceeded(url string) {
+	selector.mutex.Lock()
+	defer selector.mutex.Unlock()
+	if selector.urls[selector.current] != url {
+		return
+	}
+	selector.consecutiveFailures = 0
+}
+
+// SwitchToPrimary returns true if the selector switches from a fallback url to the primary url.
+func (selector *URLSelector) SwitchToPrimary() bool {
+	selector.mutex.Lock()
+	defer selector.mutex.Unlock()
+	if selector.current == 0 {
+		return false
+	}
+	selector.current = 0
+	selector.consecutiveFailures = 0
+	return true
+}
@@ -0,0 +1,82 @@
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+
+	"github.com/go-pg/pg/v10"
+	"gopkg.in/yaml.v2"
+)
+
+// ValidateConfigFile reads config from file and validates it by ValidateConfig.
+func ValidateConfigFile(filePath string) []error {
+	bs, err := readFileFromPath(filePath)
+	if err != nil {
+		return []error{err}
+	}
+	config := Config{}
+	if err := yaml.NewDecoder(bytes.NewReader(bs)).Decode(&config); err != nil {
+		return []error{err}
+	}
+	return ValidateConfig(config)
+}
+
+// ValidateConfig runs all config checks and parses without opening any connection,
+// it returns all problems found instead of stopping at the first one.
+func ValidateConfig(config Config) []error {
+	errs := make([]error, 0)
+	server := config.Server
+	if err := server.init(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateURL(server.HashTagEventService.EventReport.URL); err != nil {
+		errs = append(errs, fmt.Errorf("room_server.hash_tag_event_service.event_report.url.%w", err))
+	}
+	for i, fallbackURL := range server.HashTagEventService.EventReport.FallbackURLs {
+		if err := validateURL(fallbackURL); err != nil {
+			errs = append(errs, fmt.Errorf("room_server.hash_tag_event_service.event_report.fallback_urls[%d].%w", i, err))
+		}
+	}
+	errs = append(errs, validateDBClusterConfig("room_server.db_cluster", server.DB)...)
+
+	collectEvent := config.CollectEvent
+	if err := collectEvent.init(); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, validateDBClusterConfig("room_collect_event.db_cluster", collectEvent.DB)...)
+
+	task := config.Task
+	if err := task.init(); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, validateDBClusterConfig("room_task.db_cluster", task.DB)...)
+	return errs
+}
+
+func validateDBClusterConfig(name string, config DBClusterConfig) []error {
+	errs := make([]error, 0)
+	if err := config.checkShardingCoverage(); err != nil {
+		errs = append(errs, fmt.Errorf("%s.%w", name, err))
+	}
+	for index, sharding := range config.Shardings {
+		if _, err := pg.ParseURL(sharding.URL); err != nil {
+			errs = append(errs, fmt.Errorf("%s.shardings.%d.url.%w", name, index, err))
+		}
+	}
+	return errs
+}
+
+func validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme=%s, it should be http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("host of %s should not be empty", rawURL)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package base
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfigFile(t *testing.T) {
+	errs := ValidateConfigFile("../test/config.yaml")
+	assert.Equal(t, 0, len(errs))
+}
+
+func TestValidateConfigWithProblems(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	config.Server.HashTagEventService.EventReport.URL = "127.0.0.1:8080/events"
+	config.Server.DB.Shardings[0].EndShardingIndex = 0
+	config.Task.SyncKeyTask.RawNoWrittenDuration = "1x"
+	errs := ValidateConfig(config)
+	assert.Equal(t, 3, len(errs))
+}
+
+func TestValidateHashTagConfig(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, hashTag := range []HashTagConfig{
+		{},
+		{Scheme: HashTagSchemeBrace},
+		{Scheme: HashTagSchemePrefix, Delimiter: ":"},
+	} {
+		config.Server.HashTag = hashTag
+		assert.Equal(t, 0, len(ValidateConfig(config)), hashTag)
+	}
+	for _, hashTag := range []HashTagConfig{
+		{Scheme: HashTagSchemeBrace, Delimiter: ":"},
+		{Scheme: HashTagSchemePrefix},
+		{Scheme: "suffix", Delimiter: ":"},
+	} {
+		config.Server.HashTag = hashTag
+		assert.Equal(t, 1, len(ValidateConfig(config)), hashTag)
+	}
+}
+
+func TestValidateWarmUpConfig(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, warmUp := range []WarmUpConfig{
+		{},
+		{Count: -1},
+		{Enabled: true, Count: 10, RawAccessedWithin: "1h", Concurrency: 2},
+	} {
+		config.Server.WarmUp = warmUp
+		assert.Equal(t, 0, len(ValidateConfig(config)), warmUp)
+	}
+	for _, warmUp := range []WarmUpConfig{
+		{Enabled: true, Count: 0, RawAccessedWithin: "1h", Concurrency: 2},
+		{Enabled: true, Count: 10, Concurrency: 2},
+		{Enabled: true, Count: 10, RawAccessedWithin: "1x", Concurrency: 2},
+		{Enabled: true, Count: 10, RawAccessedWithin: "-1h", Concurrency: 2},
+		{Enabled: true, Count: 10, RawAccessedWithin: "1h"},
+	} {
+		config.Server.WarmUp = warmUp
+		assert.Equal(t, 1, len(ValidateConfig(config)), warmUp)
+	}
+}
+
+func TestValidateEventPayloadVersion(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, version := range []int{0, EventPayloadVersion1, EventPayloadVersion2} {
+		config.Server.HashTagEventService.EventReport.PayloadVersion = version
+		assert.Equal(t, 0, len(ValidateConfig(config)), version)
+	}
+	for _, version := range []int{-1, 3} {
+		config.Server.HashTagEventService.EventReport.PayloadVersion = version
+		assert.Equal(t, 1, len(ValidateConfig(config)), version)
+	}
+}
+
+func TestValidateEventReportBackoff(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	server := config.Server
+	server.HashTagEventService.EventReport.RawInitialBackoff = ""
+	server.HashTagEventService.EventReport.RawMaxBackoff = ""
+	assert.Nil(t, server.init())
+	assert.Equal(t, defaultReportEventsInitialBackoff, server.HashTagEventService.EventReport.InitialBackoff)
+	assert.Equal(t, defaultReportEventsMaxBackoff, server.HashTagEventService.EventReport.MaxBackoff)
+
+	for _, backoffs := range [][2]string{{"10ms", ""}, {"", "5s"}, {"1s", "1s"}} {
+		config.Server.HashTagEventService.EventReport.RawInitialBackoff = backoffs[0]
+		config.Server.HashTagEventService.EventReport.RawMaxBackoff = backoffs[1]
+		assert.Equal(t, 0, len(ValidateConfig(config)), backoffs)
+	}
+	for _, backoffs := range [][2]string{{"1x", ""}, {"", "1x"}, {"3s", ""}, {"", "10ms"}} {
+		config.Server.HashTagEventService.EventReport.RawInitialBackoff = backoffs[0]
+		config.Server.HashTagEventService.EventReport.RawMaxBackoff = backoffs[1]
+		assert.Equal(t, 1, len(ValidateConfig(config)), backoffs)
+	}
+}
+
+func TestValidateDeadLetterConfig(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	config.Server.HashTagEventService.DeadLetter = HashTagEventDeadLetterConfig{}
+	assert.Equal(t, 0, len(ValidateConfig(config)))
+
+	config.Server.HashTagEventService.DeadLetter.Enabled = true
+	assert.Equal(t, 1, len(ValidateConfig(config)))
+
+	config.Server.HashTagEventService.DeadLetter = HashTagEventDeadLetterConfig{
+		Enabled:          true,
+		QueueSize:        10,
+		FilePath:         "/tmp/dead_letter.jsonl",
+		MaxFileSize:      1024,
+		RawRetryInterval: "1x",
+	}
+	assert.Equal(t, 1, len(ValidateConfig(config)))
+	config.Server.HashTagEventService.DeadLetter.RawRetryInterval = "1m"
+	assert.Equal(t, 0, len(ValidateConfig(config)))
+}
+
+func TestValidateMaxHGetAllFields(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, count := range []int{0, 1000} {
+		config.Server.MaxHGetAllFields = count
+		assert.Equal(t, 0, len(ValidateConfig(config)), count)
+	}
+	config.Server.MaxHGetAllFields = -1
+	assert.Equal(t, 1, len(ValidateConfig(config)))
+}
+
+func TestValidateListenerConfig(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, listener := range []ListenerConfig{
+		{},
+		{Backlog: 1024, ReusePortCount: 4, RawBacklogDropCheckInterval: "10s"},
+	} {
+		config.Server.Listener = listener
+		assert.Equal(t, 0, len(ValidateConfig(config)), listener)
+	}
+	for _, listener := range []ListenerConfig{
+		{Backlog: -1},
+		{ReusePortCount: -1},
+		{RawBacklogDropCheckInterval: "1x"},
+		{RawBacklogDropCheckInterval: "0s"},
+	} {
+		config.Server.Listener = listener
+		assert.Equal(t, 1, len(ValidateConfig(config)), listener)
+	}
+	assert.Equal(t, 1, ListenerConfig{}.GetReusePortCount())
+	assert.Equal(t, 4, ListenerConfig{ReusePortCount: 4}.GetReusePortCount())
+}
+
+func TestValidateCollectEventAdaptiveAggConfig(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, adaptiveAgg := range []CollectEventAdaptiveAggConfig{
+		{},
+		{HighWaterMark: -1},
+		{Enabled: true, RawMinInterval: "1m", RawMaxInterval: "10m", HighWaterMark: 100, LowWaterMark: 10},
+		{Enabled: true, RawMinInterval: "1m", RawMaxInterval: "1m", HighWaterMark: 100},
+	} {
+		config.CollectEvent.AdaptiveAgg = adaptiveAgg
+		assert.Equal(t, 0, len(ValidateConfig(config)), adaptiveAgg)
+	}
+	for _, adaptiveAgg := range []CollectEventAdaptiveAggConfig{
+		{Enabled: true, RawMaxInterval: "10m", HighWaterMark: 100},
+		{Enabled: true, RawMinInterval: "1m", HighWaterMark: 100},
+		{Enabled: true, RawMinInterval: "1x", RawMaxInterval: "10m", HighWaterMark: 100},
+		{Enabled: true, RawMinInterval: "0s", RawMaxInterval: "10m", HighWaterMark: 100},
+		{Enabled: true, RawMinInterval: "10m", RawMaxInterval: "1m", HighWaterMark: 100},
+		{Enabled: true, RawMinInterval: "1m", RawMaxInterval: "10m"},
+		{Enabled: true, RawMinInterval: "1m", RawMaxInterval: "10m", HighWaterMark: 100, LowWaterMark: 100},
+		{Enabled: true, RawMinInterval: "1m", RawMaxInterval: "10m", HighWaterMark: 100, LowWaterMark: -1},
+	} {
+		config.CollectEvent.AdaptiveAgg = adaptiveAgg
+		assert.Equal(t, 1, len(ValidateConfig(config)), adaptiveAgg)
+	}
+}
+
+func TestValidateAccessedAtDebounceConfig(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, debounce := range []AccessedAtDebounceConfig{
+		{},
+		{RawGranularity: "1x"},
+		{Enabled: true, RawGranularity: "1m", RawInactiveDuration: "2h"},
+	} {
+		config.CollectEvent.SaveDB.AccessedAtDebounce = debounce
+		assert.Equal(t, 0, len(ValidateConfig(config)), debounce)
+	}
+	for _, debounce := range []AccessedAtDebounceConfig{
+		{Enabled: true, RawInactiveDuration: "2h"},
+		{Enabled: true, RawGranularity: "1m"},
+		{Enabled: true, RawGranularity: "1x", RawInactiveDuration: "2h"},
+		{Enabled: true, RawGranularity: "0s", RawInactiveDuration: "2h"},
+		{Enabled: true, RawGranularity: "1m", RawInactiveDuration: "2x"},
+		{Enabled: true, RawGranularity: "1m", RawInactiveDuration: "1m"},
+	} {
+		config.CollectEvent.SaveDB.AccessedAtDebounce = debounce
+		assert.Equal(t, 1, len(ValidateConfig(config)), debounce)
+	}
+}
+
+func TestAccessedAtDebounce(t *testing.T) {
+	now := time.Now()
+	config := AccessedAtDebounceConfig{Enabled: true, Granularity: time.Minute, InactiveDuration: time.Hour}
+	assert.True(t, config.IsDebounced(now, now.Add(time.Second), now))
+	assert.True(t, config.IsDebounced(now.Add(-58*time.Minute), now.Add(-58*time.Minute+time.Second), now))
+	// moved at least granularity.
+	assert.False(t, config.IsDebounced(now, now.Add(time.Minute), now))
+	// close to the inactive duration, the hash tag could be cleaned if the update is skipped.
+	assert.False(t, config.IsDebounced(now.Add(-59*time.Minute), now.Add(-59*time.Minute+time.Second), now))
+	assert.False(t, config.IsDebounced(now.Add(-2*time.Hour), now.Add(-2*time.Hour+time.Second), now))
+
+	config.Enabled = false
+	assert.False(t, config.IsDebounced(now, now.Add(time.Second), now))
+}
+
+func TestValidateCommandTimeout(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, timeouts := range [][2]string{{"", ""}, {"1s", ""}, {"", "1s"}, {"1s", "1s"}, {"100ms", "5s"}} {
+		config.Server.RawCommandTimeout, config.Server.RawTransactionCommandTimeout = timeouts[0], timeouts[1]
+		assert.Equal(t, 0, len(ValidateConfig(config)), timeouts)
+	}
+	for _, timeouts := range [][2]string{{"1x", ""}, {"0s", ""}, {"-1s", "1s"}, {"1s", "1x"}, {"2s", "1s"}} {
+		config.Server.RawCommandTimeout, config.Server.RawTransactionCommandTimeout = timeouts[0], timeouts[1]
+		assert.Equal(t, 1, len(ValidateConfig(config)), timeouts)
+	}
+}
+
+func TestValidateTransactionTimeout(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, timeout := range []string{"", "1s", "1m"} {
+		config.Server.RawTransactionTimeout = timeout
+		assert.Equal(t, 0, len(ValidateConfig(config)), timeout)
+	}
+	for _, timeout := range []string{"1x", "0s", "-1s"} {
+		config.Server.RawTransactionTimeout = timeout
+		assert.Equal(t, 1, len(ValidateConfig(config)), timeout)
+	}
+}
+
+func TestValidateStartupHealthCheckTimeout(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	server := config.Server
+	server.RawStartupHealthCheckTimeout = ""
+	assert.Nil(t, server.init())
+	assert.Equal(t, 10*time.Second, server.StartupHealthCheckTimeout)
+
+	server.RawStartupHealthCheckTimeout = "3s"
+	assert.Nil(t, server.init())
+	assert.Equal(t, 3*time.Second, server.StartupHealthCheckTimeout)
+
+	for _, timeout := range []string{"1x", "0s", "-1s"} {
+		config.Server.RawStartupHealthCheckTimeout = timeout
+		assert.Equal(t, 1, len(ValidateConfig(config)), timeout)
+	}
+}
+
+func TestValidateScriptTimeout(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	server := config.Server
+	server.RawScriptTimeout = ""
+	assert.Nil(t, server.init())
+	assert.Equal(t, 5*time.Second, server.ScriptTimeout)
+
+	server.RawScriptTimeout = "100ms"
+	assert.Nil(t, server.init())
+	assert.Equal(t, 100*time.Millisecond, server.ScriptTimeout)
+
+	for _, timeout := range []string{"1x", "0s", "-1s"} {
+		config.Server.RawScriptTimeout = timeout
+		assert.Equal(t, 1, len(ValidateConfig(config)), timeout)
+	}
+}
+
+func TestValidatePurgeDeletedDataTaskConfig(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, purge := range []PurgeDeletedDataTaskConfig{
+		{IntervalMinutes: 60, BatchSize: 100, RawRetentionDuration: "168h"},
+		{IntervalMinutes: 60, BatchSize: 100, RawRetentionDuration: "1m", Off: true},
+		{Off: true},
+		{},
+	} {
+		config.Task.PurgeDeletedDataTask = purge
+		assert.Equal(t, 0, len(ValidateConfig(config)), purge)
+	}
+	for _, purge := range []PurgeDeletedDataTaskConfig{
+		{BatchSize: 100, RawRetentionDuration: "168h"},
+		{IntervalMinutes: 60, RawRetentionDuration: "168h"},
+		{IntervalMinutes: 60, BatchSize: 100},
+		{IntervalMinutes: 60, BatchSize: 100, RawRetentionDuration: "1x"},
+		{IntervalMinutes: 60, BatchSize: 100, RawRetentionDuration: "0s"},
+	} {
+		config.Task.PurgeDeletedDataTask = purge
+		assert.Equal(t, 1, len(ValidateConfig(config)), purge)
+	}
+}
+
+func TestValidatePurgeExpiredKeyTaskConfig(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	for _, purge := range []PurgeExpiredKeyTaskConfig{
+		{},
+		{Off: true},
+		{IntervalMinutes: 30, BatchSize: 100, RateLimitPerSecond: 100},
+	} {
+		config.Task.PurgeExpiredKeyTask = purge
+		assert.Equal(t, 0, len(ValidateConfig(config)), purge)
+	}
+	assert.True(t, PurgeExpiredKeyTaskConfig{}.IsOff())
+	for _, purge := range []PurgeExpiredKeyTaskConfig{
+		{BatchSize: 100, RateLimitPerSecond: 100},
+		{IntervalMinutes: 30, RateLimitPerSecond: 100},
+		{IntervalMinutes: 30, BatchSize: 100},
+	} {
+		config.Task.PurgeExpiredKeyTask = purge
+		assert.Equal(t, 1, len(ValidateConfig(config)), purge)
+	}
+}
+
+func TestValidateTaskAdminServerConfig(t *testing.T) {
+	config, err := newConfigFromFile("../test/config.yaml")
+	assert.Nil(t, err)
+
+	config.Task.AdminServer = TaskAdminServerConfig{}
+	assert.Equal(t, 0, len(ValidateConfig(config)))
+
+	config.Task.AdminServer.Enabled = true
+	assert.Equal(t, 1, len(ValidateConfig(config)))
+
+	config.Task.AdminServer = TaskAdminServerConfig{
+		Enabled: true, URL: "127.0.0.1:8090", Token: "token", ReadTimeoutMS: 1000, WriteTimeoutMS: 1000,
+	}
+	assert.Equal(t, 0, len(ValidateConfig(config)))
+}
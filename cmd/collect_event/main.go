@@ -4,10 +4,12 @@ import (
 	"bytepower_room/base"
 	"bytepower_room/base/log"
 	"bytepower_room/service"
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -16,6 +18,8 @@ var configPath = pflag.StringP("config", "c", "config.yaml", "config file path")
 var versionFlag = pflag.BoolP("version", "v", false, "service version")
 var version string
 
+const dbCheckTimeout = 10 * time.Second
+
 func main() {
 	pflag.Parse()
 	if *versionFlag {
@@ -33,6 +37,12 @@ func main() {
 	if err := dep.Check(); err != nil {
 		panic(err)
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbCheckTimeout)
+	err := service.CheckDBColumns(ctx, dep.DB)
+	cancel()
+	if err != nil {
+		panic(fmt.Errorf("db columns check error, %w", err))
+	}
 
 	config := base.GetCollectEventConfig()
 	serviceName := "collect_event_service"
@@ -4,6 +4,7 @@ import (
 	"bytepower_room/base"
 	"bytepower_room/base/log"
 	"bytepower_room/service"
+	"context"
 	"fmt"
 	_ "net/http/pprof"
 	"os"
@@ -17,6 +18,7 @@ var configPath = pflag.StringP("config", "c", "config.yaml", "config file path")
 var host = pflag.StringP("host", "h", "0.0.0.0", "server listen host")
 var port = pflag.IntP("port", "p", 6379, "server listen port")
 var versionFlag = pflag.BoolP("version", "v", false, "service version")
+var checkConfigFlag = pflag.Bool("check-config", false, "validate config file and exit")
 var version string
 
 func main() {
@@ -28,6 +30,17 @@ func main() {
 	if configPath == nil {
 		panic("config should not be empty")
 	}
+	if *checkConfigFlag {
+		errs := base.ValidateConfigFile(*configPath)
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+		fmt.Println("config is valid")
+		return
+	}
 	if host == nil {
 		panic("host should not be empty")
 	}
@@ -38,10 +51,20 @@ func main() {
 		panic(err)
 	}
 
-	base.StartServices()
 	dep := base.GetServerDependency()
 	logger := dep.Logger
 	config := base.GetServerConfig()
+	ctx, cancel := context.WithTimeout(context.Background(), config.StartupHealthCheckTimeout)
+	err := dep.DB.HealthCheck(ctx, service.RoomServerDBTablePrefixes()...)
+	if err == nil {
+		err = service.CheckDBColumns(ctx, dep.DB)
+	}
+	cancel()
+	if err != nil {
+		panic(fmt.Errorf("db health check error, %w", err))
+	}
+
+	base.StartServices()
 	roomService, err := service.NewRoomService(config, dep, *host, *port)
 	if err != nil {
 		panic(err)
@@ -4,6 +4,7 @@ import (
 	"bytepower_room/base"
 	"bytepower_room/base/log"
 	"bytepower_room/service"
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -16,6 +17,8 @@ var configPath = pflag.StringP("config", "c", "config.yaml", "config file path")
 var versionFlag = pflag.BoolP("version", "v", false, "service version")
 var version string
 
+const dbCheckTimeout = 10 * time.Second
+
 func main() {
 	pflag.Parse()
 	if *versionFlag {
@@ -30,6 +33,12 @@ func main() {
 		panic(err)
 	}
 	dep := base.GetTaskDependency()
+	ctx, cancel := context.WithTimeout(context.Background(), dbCheckTimeout)
+	err := service.CheckDBColumns(ctx, dep.DB)
+	cancel()
+	if err != nil {
+		panic(fmt.Errorf("db columns check error, %w", err))
+	}
 	coordinatorConfig := base.GetTaskConfig().Coordinator
 	coordinator := task.NewCoordinatorFromRedisCluster(coordinatorConfig.Name, coordinatorConfig.Addrs)
 
@@ -39,6 +48,7 @@ func main() {
 		upsertTryTimes := syncKeyTaskConfig.UpSertTryTimes
 		noWrittenDuration := syncKeyTaskConfig.NoWrittenDuration
 		rateLimitPerSecond := syncKeyTaskConfig.RateLimitPerSecond
+		service.InitSyncKeysWAL(dep, syncKeyTaskConfig.WAL, upsertTryTimes)
 		job, err := task.Periodic(syncKeyTask, service.SyncKeysTask, dep, upsertTryTimes, noWrittenDuration, rateLimitPerSecond).
 			EveryMinutes(syncKeyTaskConfig.IntervalMinutes).AtSecondInMinute(20)
 		if err != nil {
@@ -53,13 +63,51 @@ func main() {
 		cleanKeyTaskInterval := cleanKeyTaskConfig.IntervalMinutes
 		inactiveDuration := cleanKeyTaskConfig.InactiveDuration
 		rateLimtPerSecond := cleanKeyTaskConfig.RateLimitPerSecond
-		job, err := task.Periodic(cleanKeyTask, service.CleanKeysTask, dep, inactiveDuration, rateLimtPerSecond).
+		batchSize := cleanKeyTaskConfig.GetBatchSize()
+		maxExcludedHashTags := cleanKeyTaskConfig.GetMaxExcludedHashTags()
+		job, err := task.Periodic(
+			cleanKeyTask, service.CleanKeysTask, dep, inactiveDuration, rateLimtPerSecond, batchSize, maxExcludedHashTags).
 			EveryMinutes(cleanKeyTaskInterval).AtSecondInMinute(20)
 		if err != nil {
 			panic(err)
 		}
 		job.SetCoordinate(coordinator)
 	}
+
+	purgeExpiredKeyTaskConfig := base.GetTaskConfig().PurgeExpiredKeyTask
+	purgeExpiredKeyTask := service.PurgeExpiredKeysTaskName
+	if !purgeExpiredKeyTaskConfig.IsOff() {
+		purgeExpiredKeyTaskInterval := purgeExpiredKeyTaskConfig.IntervalMinutes
+		batchSize := purgeExpiredKeyTaskConfig.BatchSize
+		rateLimitPerSecond := purgeExpiredKeyTaskConfig.RateLimitPerSecond
+		timeout := time.Duration(purgeExpiredKeyTaskInterval) * time.Minute
+		job, err := task.Periodic(purgeExpiredKeyTask, service.PurgeExpiredKeysTask, dep, batchSize, rateLimitPerSecond, timeout).
+			EveryMinutes(purgeExpiredKeyTaskInterval).AtSecondInMinute(20)
+		if err != nil {
+			panic(err)
+		}
+		job.SetCoordinate(coordinator)
+	}
+
+	purgeDeletedDataTaskConfig := base.GetTaskConfig().PurgeDeletedDataTask
+	purgeDeletedDataTask := service.PurgeDeletedDataTaskName
+	if !purgeDeletedDataTaskConfig.IsOff() {
+		purgeDeletedDataTaskInterval := purgeDeletedDataTaskConfig.IntervalMinutes
+		batchSize := purgeDeletedDataTaskConfig.BatchSize
+		retentionDuration := purgeDeletedDataTaskConfig.RetentionDuration
+		timeout := time.Duration(purgeDeletedDataTaskInterval) * time.Minute
+		job, err := task.Periodic(purgeDeletedDataTask, service.PurgeDeletedDataTask, dep, batchSize, retentionDuration, timeout).
+			EveryMinutes(purgeDeletedDataTaskInterval).AtSecondInMinute(40)
+		if err != nil {
+			panic(err)
+		}
+		job.SetCoordinate(coordinator)
+	}
+	adminServerConfig := base.GetTaskConfig().AdminServer
+	if adminServerConfig.Enabled {
+		adminServer := service.NewTaskAdminServer(dep, base.GetTaskConfig())
+		go adminServer.Run()
+	}
 	go monitorScheduler(dep.Logger)
 	task.StartScheduler()
 }
@@ -17,6 +17,7 @@ SQL = {
                 ADD CONSTRAINT room_data_v2_{db_index}_pkey PRIMARY KEY (hash_tag);
             '''),
 
+        "upgrade": "-- room_data_v2_{db_index} needs no upgrade",
         "count": "select 'room_data_v2_{db_index}' as table_name, count(*) as count from room_data_v2_{db_index}",
         "truncate": "truncate table room_data_v2_{db_index};",
         "sum": "select sum(count), 'room_data_v2' as table_name from ({sql}) as t;",
@@ -32,7 +33,8 @@ SQL = {
                 created_at timestamp with time zone NOT NULL DEFAULT now(),
                 updated_at timestamp with time zone NOT NULL DEFAULT now(),
                 status character varying NOT NULL,
-                version bigint NOT NULL DEFAULT 0
+                version bigint NOT NULL DEFAULT 0,
+                frequency integer NOT NULL DEFAULT 0
             );
 
             ALTER TABLE ONLY public.room_hash_tag_keys_{db_index}
@@ -42,6 +44,7 @@ SQL = {
 
             CREATE INDEX room_hash_tag_keys_status_written_at_{db_index}_idx ON public.room_hash_tag_keys_{db_index} USING btree (status, written_at);
         '''),
+        "upgrade": "ALTER TABLE public.room_hash_tag_keys_{db_index} ADD COLUMN IF NOT EXISTS frequency integer NOT NULL DEFAULT 0;",
         "count": "select 'room_hash_tag_keys_{db_index}' as table_name, count(*) as count from room_hash_tag_keys_{db_index}",
         "truncate": "truncate table room_hash_tag_keys_{db_index};",
         "sum": "select sum(count), 'room_hash_tag_keys' as table_name from ({sql}) as t;",
@@ -52,6 +55,7 @@ SQL = {
 def generate_sql(database, sql_type, table, start_index, end_index):
     joins = {
         "create": "\n",
+        "upgrade": "\n",
         "truncate": "\n",
         "count": "\nunion all\n",
         "sum": "\nunion all\n",
@@ -79,7 +83,7 @@ if __name__ == "__main__":
     parser = argparse.ArgumentParser(description="Generate SQL")
     parser.add_argument(
         "--sql",
-        choices=["create", "count", "sum", "truncate"],
+        choices=["create", "upgrade", "count", "sum", "truncate"],
         required=True)
     parser.add_argument("-d", "--database", required=True)
     parser.add_argument(
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytepower_room/base"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// room_dlq_replay posts every line of a dead letter file of hash_tag_event service to event report url,
+// lines failed to post are written to `<file>.failed`.
+
+var (
+	filePath = pflag.StringP("file", "f", "", "dead letter file path")
+	url      = pflag.StringP("url", "u", "", "event report url")
+	timeout  = pflag.DurationP("timeout", "t", 3*time.Second, "request timeout")
+	dryRun   = pflag.BoolP("dryrun", "d", true, "is dry run or not")
+)
+
+const scriptName = "room_dlq_replay"
+
+// maxLineSize is max size of a line in dead letter file.
+const maxLineSize = 64 * 1024 * 1024
+
+func parseAndCheckCommandOptions() error {
+	pflag.Parse()
+	if filePath == nil || *filePath == "" {
+		return errors.New("parameter file should be set")
+	}
+	if url == nil || *url == "" {
+		return errors.New("parameter url should be set")
+	}
+	if timeout == nil || *timeout <= 0 {
+		return errors.New("parameter timeout should be greater than 0")
+	}
+	return nil
+}
+
+func main() {
+	logger := log.New(os.Stdout, fmt.Sprintf("%s ", scriptName), log.LstdFlags)
+	if err := parseAndCheckCommandOptions(); err != nil {
+		logger.Fatalf("command options error %s\n", err)
+	}
+	file, err := os.Open(*filePath)
+	if err != nil {
+		logger.Fatalf("open file error %s\n", err)
+	}
+	defer file.Close()
+	failedFilePath := fmt.Sprintf("%s.failed", *filePath)
+	var failedFile *os.File
+	if !*dryRun {
+		failedFile, err = os.OpenFile(failedFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			logger.Fatalf("open failed file error %s\n", err)
+		}
+		defer failedFile.Close()
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	startTime := time.Now()
+	logger.Printf("start to replay, file=%s, url=%s, dryrun=%t\n", *filePath, *url, *dryRun)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	lineCount := 0
+	successCount := 0
+	failedCount := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		lineCount++
+		if *dryRun {
+			continue
+		}
+		if err := postEvents(client, *url, line); err != nil {
+			logger.Printf("replay line %d error %s\n", lineCount, err)
+			failedCount++
+			if _, err := failedFile.Write(append(append([]byte{}, line...), '\n')); err != nil {
+				logger.Fatalf("write failed file error %s\n", err)
+			}
+			continue
+		}
+		successCount++
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Fatalf("read file error %s\n", err)
+	}
+	logger.Printf(
+		"replay finished, line count %d, success count %d, failed count %d, failed file %s, duration %s\n",
+		lineCount, successCount, failedCount, failedFilePath, time.Since(startTime))
+}
+
+func postEvents(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, base.HTTPContentTypeJSON, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("response error, http_code=%d, body=%s", resp.StatusCode, respBody)
+	}
+	return nil
+}
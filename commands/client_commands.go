@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	ClientSubCommandID      = "id"
+	ClientSubCommandGetName = "getname"
+	ClientSubCommandSetName = "setname"
+	ClientSubCommandList    = "list"
+)
+
+func newClientUnknownSubCommandError(subCommand string) error {
+	return fmt.Errorf("ERR Unknown subcommand or wrong number of arguments for '%s'. Try CLIENT HELP.", subCommand)
+}
+
+var errClientNameInvalid = errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+
+// ClientCommand manages connections of room server, it is processed by room server instead of redis.
+// client id
+// client getname
+// client setname name
+// client list
+type ClientCommand struct {
+	subCommand string
+	clientName string
+	commonCommand
+}
+
+func NewClientCommand(args []string) (Commander, error) {
+	command := &ClientCommand{}
+	command.init(args)
+	if len(args) < 2 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.subCommand = strings.ToLower(args[1])
+	switch command.subCommand {
+	case ClientSubCommandID, ClientSubCommandGetName, ClientSubCommandList:
+		if len(args) != 2 {
+			return nil, newClientUnknownSubCommandError(args[1])
+		}
+	case ClientSubCommandSetName:
+		if len(args) != 3 {
+			return nil, newClientUnknownSubCommandError(args[1])
+		}
+		if !isValidClientName(args[2]) {
+			return nil, errClientNameInvalid
+		}
+		command.clientName = args[2]
+	default:
+		return nil, newClientUnknownSubCommandError(args[1])
+	}
+	return command, nil
+}
+
+// client name should only contain characters from '!' to '~'.
+func isValidClientName(name string) bool {
+	for _, c := range name {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func (command *ClientCommand) SubCommand() string {
+	return command.subCommand
+}
+
+func (command *ClientCommand) ClientName() string {
+	return command.clientName
+}
+
+func (command *ClientCommand) Cmd() redis.Cmder {
+	return redis.NewCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
@@ -18,16 +18,21 @@ type NewCommandFunc func([]string) (Commander, error)
 
 var supportedCommands = map[string]NewCommandFunc{
 	// keys commands
+	"copy":      NewCopyCommand,
 	"del":       NewDelCommand,
+	"dump":      NewDumpCommand,
 	"exists":    NewExistsCommand,
 	"expire":    NewExpireCommand,
 	"expireat":  NewExpireAtCommand,
+	"keys":      NewKeysCommand,
+	"object":    NewObjectCommand,
 	"persist":   NewPersistCommand,
 	"pexpire":   NewPExpireCommand,
 	"pexpireat": NewExpireAtCommand,
 	"pttl":      NewPTTLCommand,
 	"rename":    NewRenameCommand,
 	"renamenx":  NewRenameNXCommand,
+	"restore":   NewRestoreCommand,
 	"ttl":       NewTTLCommand,
 	"type":      NewTypeCommand,
 
@@ -50,6 +55,8 @@ var supportedCommands = map[string]NewCommandFunc{
 	"setnx":       NewSetNXCommand,
 	"setrange":    NewSetRangeCommand,
 	"strlen":      NewStrlenCommand,
+	// substr is the deprecated name of getrange.
+	"substr": NewGetRangeCommand,
 
 	// list commands
 	"lindex":    NewLIndexCommand,
@@ -89,6 +96,7 @@ var supportedCommands = map[string]NewCommandFunc{
 	// hash commands
 	"hdel":         NewHDelCommand,
 	"hexists":      NewHExistsCommand,
+	"hexpire":      NewHExpireCommand,
 	"hget":         NewHGetCommand,
 	"hgetall":      NewHGetAllCommand,
 	"hincrby":      NewHIncrByCommand,
@@ -97,9 +105,11 @@ var supportedCommands = map[string]NewCommandFunc{
 	"hlen":         NewHLenCommand,
 	"hmget":        NewHMGetCommand,
 	"hmset":        NewHMSetCommand,
+	"hrandfield":   NewHRandFieldCommand,
 	"hset":         NewHSetCommand,
 	"hsetnx":       NewHSetNXCommand,
 	"hstrlen":      NewHStrlenCommand,
+	"httl":         NewHTTLCommand,
 	"hvals":        NewHValsCommand,
 
 	//zset commands
@@ -109,6 +119,8 @@ var supportedCommands = map[string]NewCommandFunc{
 	"zdiff":            NewZDiffCommand,
 	"zdiffstore":       NewZDiffStoreCommand,
 	"zincrby":          NewZIncrByCommand,
+	"zinter":           NewZInterCommand,
+	"zinterstore":      NewZInterStoreCommand,
 	"zlexcount":        NewZLexCountCommand,
 	"zpopmax":          NewZPopMaxCommand,
 	"zpopmin":          NewZPopMinCommand,
@@ -116,6 +128,7 @@ var supportedCommands = map[string]NewCommandFunc{
 	"zrangebylex":      NewZRangeByLexCommand,
 	"zrevrangebylex":   NewZRevRangeByLexCommand,
 	"zrangebyscore":    NewZRangeByScoreCommand,
+	"zrangestore":      NewZRangeStoreCommand,
 	"zrank":            NewZRankCommand,
 	"zrem":             NewZRemCommand,
 	"zremrangebylex":   NewZRemRangeByLexCommand,
@@ -125,12 +138,37 @@ var supportedCommands = map[string]NewCommandFunc{
 	"zrevrangebyscore": NewZRevRangeByScoreCommand,
 	"zrevrank":         NewZRevRankCommand,
 	"zscore":           NewZScoreCommand,
+	"zunion":           NewZUnionCommand,
+	"zunionstore":      NewZUnionStoreCommand,
 	"zmscore":          NewZMScoreCommand,
 
+	// stream commands
+	"xadd":      NewXAddCommand,
+	"xlen":      NewXLenCommand,
+	"xrange":    NewXRangeCommand,
+	"xrevrange": NewXRevRangeCommand,
+	"xread":     NewXReadCommand,
+
 	// server commands
+	"client":  NewClientCommand,
 	"command": NewCommandCommand,
+	"debug":   NewDebugCommand,
 	"echo":    NewEchoCommand,
+	"info":    NewInfoCommand,
 	"ping":    NewPingCommand,
+	"reset":   NewResetCommand,
+	"wait":    NewWaitCommand,
+
+	// pubsub commands
+	"subscribe":   NewSubscribeCommand,
+	"unsubscribe": NewUnsubscribeCommand,
+	"publish":     NewPublishCommand,
+	"pubsub":      NewPubSubCommand,
+
+	// scripting commands
+	"eval":    NewEvalCommand,
+	"evalsha": NewEvalShaCommand,
+	"script":  NewScriptCommand,
 
 	// transaction commands
 	"watch":   NewWatchCommand,
@@ -138,6 +176,10 @@ var supportedCommands = map[string]NewCommandFunc{
 	"exec":    NewExecCommand,
 	"discard": NewDiscardCommand,
 	"unwatch": NewUnwatchCommand,
+
+	// room commands
+	"room.evict":     NewRoomEvictCommand,
+	"room.resetstat": NewRoomResetStatCommand,
 }
 
 type RESPType string
@@ -150,6 +192,9 @@ const (
 	ArrayRespType        RESPType = "array"
 	NilRespType          RESPType = "nil"
 	NilArrayRespType     RESPType = "nil_array"
+	// MultipleRespType is a list of replies written one by one,
+	// it is used by commands replying once for every argument, such as subscribe.
+	MultipleRespType RESPType = "multiple"
 )
 
 type RESPData struct {
@@ -179,6 +224,13 @@ func (data RESPData) String() string {
 		result = result + " }"
 	case NilArrayRespType:
 		result = "na:na"
+	case MultipleRespType:
+		replies := data.Value.([]RESPData)
+		result = fmt.Sprintf("m:%d{ ", len(replies))
+		for _, item := range replies {
+			result = result + item.String() + " "
+		}
+		result = result + " }"
 	}
 	return result
 }
@@ -262,6 +314,192 @@ func CheckAndGetCommandKeysHashTag(command Commander) (string, error) {
 	return hashTag, nil
 }
 
+const (
+	ValueTypeString = "string"
+	ValueTypeList   = "list"
+	ValueTypeHash   = "hash"
+	ValueTypeSet    = "set"
+	ValueTypeZSet   = "zset"
+	ValueTypeStream = "stream"
+)
+
+// commandValueTypes are types of values which keys of commands must hold,
+// commands working on keys of any type or overwriting keys, such as del, set and sinterstore, are not included.
+var commandValueTypes = map[string]string{
+	"get": ValueTypeString, "append": ValueTypeString, "decr": ValueTypeString, "decrby": ValueTypeString,
+	"getrange": ValueTypeString, "getset": ValueTypeString, "incr": ValueTypeString, "incrby": ValueTypeString,
+	"incrbyfloat": ValueTypeString, "setrange": ValueTypeString, "strlen": ValueTypeString, "substr": ValueTypeString,
+
+	"lindex": ValueTypeList, "linsert": ValueTypeList, "llen": ValueTypeList, "lpop": ValueTypeList,
+	"lpos": ValueTypeList, "lpush": ValueTypeList, "lpushx": ValueTypeList, "lrange": ValueTypeList,
+	"lrem": ValueTypeList, "lset": ValueTypeList, "ltrim": ValueTypeList, "rpop": ValueTypeList,
+	"rpoplpush": ValueTypeList, "lmove": ValueTypeList, "rpush": ValueTypeList, "rpushx": ValueTypeList,
+
+	"sadd": ValueTypeSet, "scard": ValueTypeSet, "sdiff": ValueTypeSet, "sinter": ValueTypeSet,
+	"sismember": ValueTypeSet, "smismember": ValueTypeSet, "smembers": ValueTypeSet, "smove": ValueTypeSet,
+	"spop": ValueTypeSet, "srandmember": ValueTypeSet, "srem": ValueTypeSet, "sunion": ValueTypeSet,
+
+	"hdel": ValueTypeHash, "hexists": ValueTypeHash, "hget": ValueTypeHash, "hgetall": ValueTypeHash,
+	"hincrby": ValueTypeHash, "hincrbyfloat": ValueTypeHash, "hkeys": ValueTypeHash, "hlen": ValueTypeHash,
+	"hmget": ValueTypeHash, "hmset": ValueTypeHash, "hrandfield": ValueTypeHash, "hset": ValueTypeHash, "hsetnx": ValueTypeHash,
+	"hstrlen": ValueTypeHash, "hvals": ValueTypeHash, "hexpire": ValueTypeHash, "httl": ValueTypeHash,
+
+	"zadd": ValueTypeZSet, "zcard": ValueTypeZSet, "zcount": ValueTypeZSet, "zincrby": ValueTypeZSet,
+	"zlexcount": ValueTypeZSet, "zpopmax": ValueTypeZSet, "zpopmin": ValueTypeZSet, "zrange": ValueTypeZSet,
+	"zrangebylex": ValueTypeZSet, "zrevrangebylex": ValueTypeZSet, "zrangebyscore": ValueTypeZSet,
+	"zrank": ValueTypeZSet, "zrem": ValueTypeZSet, "zremrangebylex": ValueTypeZSet,
+	"zremrangebyrank": ValueTypeZSet, "zremrangebyscore": ValueTypeZSet, "zrevrange": ValueTypeZSet,
+	"zrevrangebyscore": ValueTypeZSet, "zrevrank": ValueTypeZSet, "zscore": ValueTypeZSet, "zmscore": ValueTypeZSet,
+
+	"xadd": ValueTypeStream, "xlen": ValueTypeStream, "xrange": ValueTypeStream,
+	"xrevrange": ValueTypeStream, "xread": ValueTypeStream,
+}
+
+// GetCommandValueType returns type of values which keys of command must hold, it is empty if keys could hold any type.
+func GetCommandValueType(command Commander) string {
+	return commandValueTypes[command.Name()]
+}
+
+// ValueWriterCommander is implemented by commands writing values to a key,
+// WrittenValueSize returns type of the key and bytes of values written by the command.
+type ValueWriterCommander interface {
+	Commander
+	WrittenValueSize() (string, int)
+}
+
+// ConditionalWriterCommander is implemented by commands which write keys only on some conditions,
+// IsWritten returns false if keys are not written according to result of the command.
+type ConditionalWriterCommander interface {
+	Commander
+	IsWritten(result RESPData) bool
+}
+
+// CommandForEvent returns command with its write keys taken as read keys if they are not written according to result,
+// so that a read event instead of a write event is sent for them.
+func CommandForEvent(command Commander, result RESPData) Commander {
+	c, ok := command.(ConditionalWriterCommander)
+	if !ok || c.IsWritten(result) {
+		return command
+	}
+	return &readOnlyCommand{Commander: command}
+}
+
+type readOnlyCommand struct {
+	Commander
+}
+
+func (command *readOnlyCommand) ReadKeys() []string {
+	return append(append([]string{}, command.Commander.ReadKeys()...), command.Commander.WriteKeys()...)
+}
+
+func (command *readOnlyCommand) WriteKeys() []string {
+	return []string{}
+}
+
+func stringsSize(values []string) int {
+	size := 0
+	for _, value := range values {
+		size += len(value)
+	}
+	return size
+}
+
+// MultipleHashTagsCommander is implemented by commands whose keys could have different hash tags,
+// the command is split into commands of each hash tag and integer results of them are summed up,
+// commands processed by room are split only to load keys and send events of each hash tag.
+type MultipleHashTagsCommander interface {
+	Commander
+	SplitByHashTag() ([]Commander, error)
+}
+
+// SplitCommandByHashTag returns commands of each hash tag in order of their first keys,
+// command itself is returned if its keys could not have different hash tags.
+func SplitCommandByHashTag(command Commander) ([]Commander, error) {
+	c, ok := command.(MultipleHashTagsCommander)
+	if !ok {
+		return []Commander{command}, nil
+	}
+	return c.SplitByHashTag()
+}
+
+// hashTagPartCommand is a part of a command with keys of one hash tag,
+// it is only used to load keys and send events of the hash tag for commands processed by room.
+type hashTagPartCommand struct {
+	readKeys  []string
+	writeKeys []string
+	Commander
+}
+
+func (command *hashTagPartCommand) ReadKeys() []string {
+	return command.readKeys
+}
+
+func (command *hashTagPartCommand) WriteKeys() []string {
+	return command.writeKeys
+}
+
+// splitCommandKeysByHashTag splits read and write keys of command into commands of each hash tag
+// in order of their first keys, command itself is returned if its keys have the same hash tag.
+func splitCommandKeysByHashTag(command Commander) ([]Commander, error) {
+	indexes := make(map[string]int)
+	parts := make([]*hashTagPartCommand, 0)
+	partOfKey := func(key string) (*hashTagPartCommand, error) {
+		hashTag := ExtractHashTagFromKey(key)
+		if hashTag == "" {
+			return nil, errCommandKeyNoHashTag
+		}
+		index, ok := indexes[hashTag]
+		if !ok {
+			index = len(parts)
+			indexes[hashTag] = index
+			parts = append(parts, &hashTagPartCommand{readKeys: []string{}, writeKeys: []string{}, Commander: command})
+		}
+		return parts[index], nil
+	}
+	for _, key := range command.ReadKeys() {
+		part, err := partOfKey(key)
+		if err != nil {
+			return nil, err
+		}
+		part.readKeys = append(part.readKeys, key)
+	}
+	for _, key := range command.WriteKeys() {
+		part, err := partOfKey(key)
+		if err != nil {
+			return nil, err
+		}
+		part.writeKeys = append(part.writeKeys, key)
+	}
+	if len(parts) <= 1 {
+		return []Commander{command}, nil
+	}
+	cmds := make([]Commander, 0, len(parts))
+	for _, part := range parts {
+		cmds = append(cmds, part)
+	}
+	return cmds, nil
+}
+
+// splitKeysByHashTag groups keys by hash tag in order of their first keys, duplicated keys are kept.
+func splitKeysByHashTag(keys []string) ([][]string, error) {
+	indexes := make(map[string]int)
+	groups := make([][]string, 0)
+	for _, key := range keys {
+		hashTag := ExtractHashTagFromKey(key)
+		if hashTag == "" {
+			return nil, errCommandKeyNoHashTag
+		}
+		index, ok := indexes[hashTag]
+		if !ok {
+			index = len(groups)
+			indexes[hashTag] = index
+			groups = append(groups, []string{})
+		}
+		groups[index] = append(groups[index], key)
+	}
+	return groups, nil
+}
+
 func ParseCommand(args []string) (Commander, error) {
 	if len(args) == 0 {
 		return nil, errEmptyCommand
@@ -276,8 +514,13 @@ func ParseCommand(args []string) (Commander, error) {
 }
 
 func ExecuteCommand(redisCluster *redis.ClusterClient, command Commander) RESPData {
+	return ExecuteCommandWithContext(contextTODO, redisCluster, command)
+}
+
+// ExecuteCommandWithContext executes command in redis, it fails if ctx is done before the reply is read.
+func ExecuteCommandWithContext(ctx context.Context, redisCluster *redis.ClusterClient, command Commander) RESPData {
 	cmd := command.Cmd()
-	if err := redisCluster.Process(contextTODO, cmd); err != nil {
+	if err := redisCluster.Process(ctx, cmd); err != nil {
 		return ConvertErrorToRESPData(err)
 	}
 
@@ -306,8 +549,12 @@ func (c CommandBatch) AddCommand(index int, cmd Commander) {
 }
 
 func (c CommandBatch) Execute(ctx context.Context, redisCluster *redis.ClusterClient) map[int]RESPData {
-	indexes := c.getSortedIndexes()
 	result := make(map[int]RESPData, len(c.cmds))
+	// empty batches are flushed before local and transaction commands, they need no round trip to redis.
+	if len(c.cmds) == 0 {
+		return result
+	}
+	indexes := c.getSortedIndexes()
 	pipeline := redisCluster.Pipeline()
 	for _, index := range indexes {
 		pipeline.Process(ctx, c.cmds[index].Cmd())
@@ -319,7 +566,46 @@ func (c CommandBatch) Execute(ctx context.Context, redisCluster *redis.ClusterCl
 	return result
 }
 
+// HashTagExtractor returns hash tag of key, it is empty if key has no hash tag.
+type HashTagExtractor func(key string) string
+
+var hashTagExtractor HashTagExtractor = extractBraceHashTag
+
+// NewHashTagExtractor returns the extractor of the hash tag scheme in config.
+func NewHashTagExtractor(config base.HashTagConfig) (HashTagExtractor, error) {
+	switch config.Scheme {
+	case "", base.HashTagSchemeBrace:
+		return extractBraceHashTag, nil
+	case base.HashTagSchemePrefix:
+		if config.Delimiter == "" {
+			return nil, errors.New("delimiter of hash tag should not be empty")
+		}
+		delimiter := config.Delimiter
+		return func(key string) string {
+			return extractPrefixHashTag(key, delimiter)
+		}, nil
+	}
+	return nil, fmt.Errorf("hash tag scheme %s is not supported", config.Scheme)
+}
+
+// SetHashTagExtractor sets the extractor used by all commands, it should be called before commands are processed.
+func SetHashTagExtractor(extractor HashTagExtractor) {
+	hashTagExtractor = extractor
+}
+
 func ExtractHashTagFromKey(key string) string {
+	return hashTagExtractor(key)
+}
+
+func extractPrefixHashTag(key string, delimiter string) string {
+	index := strings.Index(key, delimiter)
+	if index <= 0 {
+		return ""
+	}
+	return key[:index]
+}
+
+func extractBraceHashTag(key string) string {
 	leftBraceIndex := strings.Index(key, "{")
 	if leftBraceIndex == -1 {
 		return ""
@@ -386,6 +672,13 @@ func convertCmdResultToRESPData(cmd redis.Cmder) RESPData {
 		} else {
 			result = convertSliceToRESPData(r)
 		}
+	case *redis.StringStringMapCmd:
+		r, err := command.Result()
+		if err != nil {
+			result = ConvertErrorToRESPData(err)
+		} else {
+			result = ConvertHashToRESPData(r)
+		}
 	case *redis.CommandsInfoCmd:
 		r, err := command.Result()
 		if err != nil {
@@ -406,6 +699,22 @@ func convertCmdResultToRESPData(cmd redis.Cmder) RESPData {
 	return result
 }
 
+// ConvertHashToRESPData converts hash to alternating fields and values, fields are sorted to be deterministic.
+func ConvertHashToRESPData(hash map[string]string) RESPData {
+	fields := make([]string, 0, len(hash))
+	for field := range hash {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	value := make([]RESPData, 0, 2*len(fields))
+	for _, field := range fields {
+		value = append(value,
+			RESPData{DataType: BulkStringRespType, Value: field},
+			RESPData{DataType: BulkStringRespType, Value: hash[field]})
+	}
+	return RESPData{DataType: ArrayRespType, Value: value}
+}
+
 func convertSliceToRESPData(slice []interface{}) RESPData {
 	data := RESPData{DataType: ArrayRespType}
 	value := make([]RESPData, 0)
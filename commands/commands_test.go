@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -82,6 +83,38 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeWrite,
 		valid:      true,
 		cmdType:    &redis.IntCmd{},
+	}, {
+		name:       "copy",
+		args:       []string{"copy", "{a}1", "{a}2"},
+		writeKeys:  []string{"{a}2"},
+		readKeys:   []string{"{a}1"},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:       "copy",
+		args:       []string{"copy", "{a}1", "{a}2", "DB", "0", "REPLACE"},
+		writeKeys:  []string{"{a}2"},
+		readKeys:   []string{"{a}1"},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:  "copy",
+		args:  []string{"copy", "{a}1"},
+		valid: false,
+	}, {
+		name:  "copy",
+		args:  []string{"copy", "{a}1", "{a}2", "db", "1"},
+		valid: false,
+	}, {
+		name:  "copy",
+		args:  []string{"copy", "{a}1", "{a}2", "db"},
+		valid: false,
+	}, {
+		name:  "copy",
+		args:  []string{"copy", "{a}1", "{a}2", "absttl"},
+		valid: false,
 	}, {
 		name:       "del",
 		args:       []string{"del", "{a}123", "{a}1234"},
@@ -358,6 +391,66 @@ var testCommandCases = []testCommandCase{
 		name:  "set",
 		args:  []string{"set", "{a}123", "value", "keepttl", "100"},
 		valid: false,
+	}, {
+		name:       "set",
+		args:       []string{"set", "{a}123", "value", "exat", "1893456000"},
+		writeKeys:  []string{"{a}123"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:       "set",
+		args:       []string{"set", "{a}123", "value", "pxat", "1893456000000", "xx"},
+		writeKeys:  []string{"{a}123"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:       "set",
+		args:       []string{"set", "{a}123", "value", "keepttl", "xx", "get"},
+		writeKeys:  []string{"{a}123"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StringCmd{},
+	}, {
+		name:       "set",
+		args:       []string{"set", "{a}123", "value", "EX", "10", "ex", "20", "nx", "nx"},
+		writeKeys:  []string{"{a}123"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "set",
+		args:  []string{"set", "{a}123", "value", "nx", "xx"},
+		valid: false,
+	}, {
+		name:  "set",
+		args:  []string{"set", "{a}123", "value", "ex", "10", "px", "100"},
+		valid: false,
+	}, {
+		name:  "set",
+		args:  []string{"set", "{a}123", "value", "keepttl", "ex", "10"},
+		valid: false,
+	}, {
+		name:  "set",
+		args:  []string{"set", "{a}123", "value", "pxat", "100", "keepttl"},
+		valid: false,
+	}, {
+		name:  "set",
+		args:  []string{"set", "{a}123", "value", "ex", "0"},
+		valid: false,
+	}, {
+		name:  "set",
+		args:  []string{"set", "{a}123", "value", "px", "-1"},
+		valid: false,
+	}, {
+		name:  "set",
+		args:  []string{"set", "{a}123", "value", "exat"},
+		valid: false,
 	}, {
 		name:       "get",
 		args:       []string{"get", "{a}123"},
@@ -374,6 +467,18 @@ var testCommandCases = []testCommandCase{
 		name:  "get",
 		args:  []string{"get"},
 		valid: false,
+	}, {
+		name:       "substr",
+		args:       []string{"substr", "{a}123", "0", "-1"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}123"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringCmd{},
+	}, {
+		name:  "substr",
+		args:  []string{"substr", "{a}123", "0"},
+		valid: false,
 	}, {
 		name:       "decr",
 		args:       []string{"decr", "{a}123"},
@@ -402,6 +507,14 @@ var testCommandCases = []testCommandCase{
 		name:  "decrby",
 		args:  []string{"decrby", "{a}123", "non"},
 		valid: false,
+	}, {
+		name:  "decrby",
+		args:  []string{"decrby", "{a}123", "-9223372036854775808"},
+		valid: false,
+	}, {
+		name:  "decrby",
+		args:  []string{"decrby", "{a}123", "9223372036854775808"},
+		valid: false,
 	}, {
 		name:  "decrby",
 		args:  []string{"decrby"},
@@ -482,6 +595,26 @@ var testCommandCases = []testCommandCase{
 		name:  "setex",
 		args:  []string{"setex", "{a}123", "nan", "value"},
 		valid: false,
+	}, {
+		name:  "setex",
+		args:  []string{"setex", "{a}123", "0", "value"},
+		valid: false,
+	}, {
+		name:       "psetex",
+		args:       []string{"psetex", "{a}123", "100", "value"},
+		writeKeys:  []string{"{a}123"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "psetex",
+		args:  []string{"psetex", "{a}123", "-100", "value"},
+		valid: false,
+	}, {
+		name:  "psetex",
+		args:  []string{"psetex", "{a}123", "100"},
+		valid: false,
 	}, {
 		name:       "setnx",
 		args:       []string{"setnx", "{a}123", "value"},
@@ -534,6 +667,22 @@ var testCommandCases = []testCommandCase{
 		name:  "lindex",
 		args:  []string{"lindex"},
 		valid: false,
+	}, {
+		name:       "linsert",
+		args:       []string{"linsert", "{a}list", "BEFORE", "pivot", "value"},
+		writeKeys:  []string{"{a}list"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:  "linsert",
+		args:  []string{"linsert", "{a}list", "middle", "pivot", "value"},
+		valid: false,
+	}, {
+		name:  "linsert",
+		args:  []string{"linsert", "{a}list", "after", "pivot"},
+		valid: false,
 	}, {
 		name:       "llen",
 		args:       []string{"llen", "{a}list"},
@@ -658,6 +807,22 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeWrite,
 		valid:      true,
 		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:       "lset",
+		args:       []string{"lset", "{a}list", "-1", "value"},
+		writeKeys:  []string{"{a}list"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "lset",
+		args:  []string{"lset", "{a}list", "a", "value"},
+		valid: false,
+	}, {
+		name:  "lset",
+		args:  []string{"lset", "{a}list", "0"},
+		valid: false,
 	}, {
 		name:       "ltrim",
 		args:       []string{"ltrim", "{a}list", "0", "2"},
@@ -666,6 +831,22 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeWrite,
 		valid:      true,
 		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:       "ltrim",
+		args:       []string{"ltrim", "{a}list", "-2", "-1"},
+		writeKeys:  []string{"{a}list"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "ltrim",
+		args:  []string{"ltrim", "{a}list", "a", "2"},
+		valid: false,
+	}, {
+		name:  "ltrim",
+		args:  []string{"ltrim", "{a}list", "0"},
+		valid: false,
 	}, {
 		name:       "rpop",
 		args:       []string{"rpop", "{a}list"},
@@ -782,6 +963,22 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeRead,
 		valid:      true,
 		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "sinter",
+		args:       []string{"sinter", "{a}set1", "{b}set2"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}set1", "{b}set2"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "sinter",
+		args:  []string{"sinter"},
+		valid: false,
+	}, {
+		name:  "sinterstore",
+		args:  []string{"sinterstore", "{a}set1"},
+		valid: false,
 	}, {
 		name:       "sinterstore",
 		args:       []string{"sinterstore", "{a}set1", "{a}set2", "{a}set3"},
@@ -838,6 +1035,22 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeWrite,
 		valid:      true,
 		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "spop",
+		args:  []string{"spop", "{a}set1", "-1"},
+		valid: false,
+	}, {
+		name:       "spop",
+		args:       []string{"spop", "{a}set1", "0"},
+		writeKeys:  []string{"{a}set1"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "spop",
+		args:  []string{"spop", "{a}set1", "a"},
+		valid: false,
 	}, {
 		name:       "srandmember",
 		args:       []string{"srandmember", "{a}set1"},
@@ -854,6 +1067,26 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeRead,
 		valid:      true,
 		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "srandmember",
+		args:       []string{"srandmember", "{a}set1", "-10"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}set1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "srandmember",
+		args:  []string{"srandmember", "{a}set1", "a"},
+		valid: false,
+	}, {
+		name:  "srandmember",
+		args:  []string{"srandmember", "{a}set1", "-9223372036854775808"},
+		valid: false,
+	}, {
+		name:  "srandmember",
+		args:  []string{"srandmember", "{a}set1", "1", "2"},
+		valid: false,
 	}, {
 		name:       "srem",
 		args:       []string{"srem", "{a}set1", "a", "b"},
@@ -913,7 +1146,59 @@ var testCommandCases = []testCommandCase{
 		readKeys:   []string{"{a}hash1"},
 		accessMode: base.HashTagAccessModeRead,
 		valid:      true,
-		cmdType:    &redis.StringSliceCmd{},
+		cmdType:    &redis.StringStringMapCmd{},
+	}, {
+		name:       "hexpire",
+		args:       []string{"hexpire", "{a}hash1", "100", "fields", "2", "a", "b"},
+		writeKeys:  []string{"{a}hash1"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntSliceCmd{},
+	}, {
+		name:       "hexpire",
+		args:       []string{"hexpire", "{a}hash1", "100", "NX", "FIELDS", "1", "a"},
+		writeKeys:  []string{"{a}hash1"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntSliceCmd{},
+	}, {
+		name:  "hexpire",
+		args:  []string{"hexpire", "{a}hash1", "-1", "fields", "1", "a"},
+		valid: false,
+	}, {
+		name:  "hexpire",
+		args:  []string{"hexpire", "{a}hash1", "100", "a", "1", "a"},
+		valid: false,
+	}, {
+		name:  "hexpire",
+		args:  []string{"hexpire", "{a}hash1", "100", "fields", "2", "a"},
+		valid: false,
+	}, {
+		name:  "hexpire",
+		args:  []string{"hexpire", "{a}hash1", "100", "fields", "0"},
+		valid: false,
+	}, {
+		name:  "hexpire",
+		args:  []string{"hexpire", "{a}hash1", "100", "fields", "1"},
+		valid: false,
+	}, {
+		name:       "httl",
+		args:       []string{"httl", "{a}hash1", "fields", "2", "a", "b"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}hash1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.IntSliceCmd{},
+	}, {
+		name:  "httl",
+		args:  []string{"httl", "{a}hash1", "fields", "1"},
+		valid: false,
+	}, {
+		name:  "httl",
+		args:  []string{"httl", "{a}hash1", "fields", "1", "a", "b"},
+		valid: false,
 	}, {
 		name:       "hincrby",
 		args:       []string{"hincrby", "{a}hash1", "a", "10"},
@@ -994,6 +1279,54 @@ var testCommandCases = []testCommandCase{
 		name:  "hmset",
 		args:  []string{"hmset", "{a}hash1", "a", "b", "c", "d", "e"},
 		valid: false,
+	}, {
+		name:       "hrandfield",
+		args:       []string{"hrandfield", "{a}hash1"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}hash1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringCmd{},
+	}, {
+		name:       "hrandfield",
+		args:       []string{"hrandfield", "{a}hash1", "-2"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}hash1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "hrandfield",
+		args:       []string{"hrandfield", "{a}hash1", "2", "WITHVALUES"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}hash1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "hrandfield",
+		args:  []string{"hrandfield"},
+		valid: false,
+	}, {
+		name:  "hrandfield",
+		args:  []string{"hrandfield", "{a}hash1", "a"},
+		valid: false,
+	}, {
+		name:  "hrandfield",
+		args:  []string{"hrandfield", "{a}hash1", "2", "values"},
+		valid: false,
+	}, {
+		name:  "hrandfield",
+		args:  []string{"hrandfield", "{a}hash1", "2", "withvalues", "extra"},
+		valid: false,
+	}, {
+		name:  "hrandfield",
+		args:  []string{"hrandfield", "{a}hash1", "-9223372036854775808"},
+		valid: false,
+	}, {
+		name:  "hrandfield",
+		args:  []string{"hrandfield", "{a}hash1", "-4611686018427387904", "withvalues"},
+		valid: false,
 	}, {
 		name:       "hset",
 		args:       []string{"hset", "{a}hash1", "a", "b"},
@@ -1098,6 +1431,34 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeWrite,
 		valid:      true,
 		cmdType:    &redis.StringCmd{},
+	}, {
+		name:  "zadd",
+		args:  []string{"zadd", "{a}zset1", "nx", "xx", "0.5", "a"},
+		valid: false,
+	}, {
+		name:  "zadd",
+		args:  []string{"zadd", "{a}zset1", "gt", "lt", "0.5", "a"},
+		valid: false,
+	}, {
+		name:  "zadd",
+		args:  []string{"zadd", "{a}zset1", "nx", "gt", "0.5", "a"},
+		valid: false,
+	}, {
+		name:  "zadd",
+		args:  []string{"zadd", "{a}zset1", "incr", "0.5", "a", "0.6", "b"},
+		valid: false,
+	}, {
+		name:  "zadd",
+		args:  []string{"zadd", "{a}zset1", "nx", "ch"},
+		valid: false,
+	}, {
+		name:  "zadd",
+		args:  []string{"zadd", "{a}zset1", "0.5", "a", "0.6"},
+		valid: false,
+	}, {
+		name:  "zadd",
+		args:  []string{"zadd", "{a}zset1", "a", "0.5"},
+		valid: false,
 	}, {
 		name:       "zcard",
 		args:       []string{"zcard", "{a}zset1"},
@@ -1137,29 +1498,137 @@ var testCommandCases = []testCommandCase{
 		readKeys:   []string{"{a}zset1", "{a}zset2", "{a}zset3"},
 		accessMode: base.HashTagAccessModeWrite,
 		valid:      true,
-		cmdType:    &redis.StringSliceCmd{},
+		cmdType:    &redis.IntCmd{},
 	}, {
-		name:       "zincrby",
-		args:       []string{"zincrby", "{a}zset1", "10.5", "a"},
-		writeKeys:  []string{"{a}zset1"},
-		readKeys:   []string{},
+		name:       "zdiffstore",
+		args:       []string{"zdiffstore", "{a}zset0", "1", "{b}zset1"},
+		writeKeys:  []string{"{a}zset0"},
+		readKeys:   []string{"{b}zset1"},
 		accessMode: base.HashTagAccessModeWrite,
 		valid:      true,
-		cmdType:    &redis.StringCmd{},
-	}, {
-		name:       "zlexcount",
-		args:       []string{"zlexcount", "{a}zset1", "a", "b"},
-		writeKeys:  []string{},
-		readKeys:   []string{"{a}zset1"},
-		accessMode: base.HashTagAccessModeRead,
-		valid:      true,
 		cmdType:    &redis.IntCmd{},
 	}, {
-		name:       "zpopmax",
-		args:       []string{"zpopmax", "{a}zset1"},
-		writeKeys:  []string{"{a}zset1"},
-		readKeys:   []string{},
-		accessMode: base.HashTagAccessModeWrite,
+		name:  "zdiff",
+		args:  []string{"zdiff", "0", "{a}zset1"},
+		valid: false,
+	}, {
+		name:  "zdiff",
+		args:  []string{"zdiff", "3", "{a}zset1", "{a}zset2"},
+		valid: false,
+	}, {
+		name:  "zdiff",
+		args:  []string{"zdiff", "1", "{a}zset1", "weights", "1"},
+		valid: false,
+	}, {
+		name:  "zdiff",
+		args:  []string{"zdiff", "1", "{a}zset1", "aggregate", "min"},
+		valid: false,
+	}, {
+		name:  "zdiffstore",
+		args:  []string{"zdiffstore", "{a}zset0", "1", "{a}zset1", "withscores"},
+		valid: false,
+	}, {
+		name:       "zinter",
+		args:       []string{"zinter", "2", "{a}zset1", "{b}zset2"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1", "{b}zset2"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "zinter",
+		args:       []string{"zinter", "2", "{a}zset1", "{a}zset2", "WEIGHTS", "2", "-inf", "AGGREGATE", "MAX", "WITHSCORES"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1", "{a}zset2"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "zinterstore",
+		args:       []string{"zinterstore", "{a}zset0", "2", "{a}zset1", "{a}zset2", "aggregate", "min", "weights", "1", "0.5"},
+		writeKeys:  []string{"{a}zset0"},
+		readKeys:   []string{"{a}zset1", "{a}zset2"},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:       "zunion",
+		args:       []string{"zunion", "1", "{a}zset1", "withscores"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "zunionstore",
+		args:       []string{"zunionstore", "{b}zset0", "2", "{a}zset1", "{b}zset2", "weights", "2", "3"},
+		writeKeys:  []string{"{b}zset0"},
+		readKeys:   []string{"{a}zset1", "{b}zset2"},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:  "zinter",
+		args:  []string{"zinter", "2", "{a}zset1", "{a}zset2", "weights", "1"},
+		valid: false,
+	}, {
+		name:  "zinter",
+		args:  []string{"zinter", "2", "{a}zset1", "{a}zset2", "weights", "1", "x"},
+		valid: false,
+	}, {
+		name:  "zinter",
+		args:  []string{"zinter", "2", "{a}zset1", "{a}zset2", "weights", "1", "nan"},
+		valid: false,
+	}, {
+		name:  "zinter",
+		args:  []string{"zinter", "1", "{a}zset1", "aggregate", "avg"},
+		valid: false,
+	}, {
+		name:  "zinter",
+		args:  []string{"zinter", "1", "{a}zset1", "aggregate"},
+		valid: false,
+	}, {
+		name:  "zinterstore",
+		args:  []string{"zinterstore", "{a}zset0", "0", "{a}zset1"},
+		valid: false,
+	}, {
+		name:  "zinterstore",
+		args:  []string{"zinterstore", "{a}zset0", "1"},
+		valid: false,
+	}, {
+		name:  "zunion",
+		args:  []string{"zunion", "a", "{a}zset1"},
+		valid: false,
+	}, {
+		name:  "zunionstore",
+		args:  []string{"zunionstore", "{a}zset0", "1", "{a}zset1", "withscores"},
+		valid: false,
+	}, {
+		name:  "zunion",
+		args:  []string{"zunion", "1", "{a}zset1", "limit", "1"},
+		valid: false,
+	}, {
+		name:       "zincrby",
+		args:       []string{"zincrby", "{a}zset1", "10.5", "a"},
+		writeKeys:  []string{"{a}zset1"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StringCmd{},
+	}, {
+		name:       "zlexcount",
+		args:       []string{"zlexcount", "{a}zset1", "a", "b"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:       "zpopmax",
+		args:       []string{"zpopmax", "{a}zset1"},
+		writeKeys:  []string{"{a}zset1"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
 		valid:      true,
 		cmdType:    &redis.StringSliceCmd{},
 	}, {
@@ -1202,42 +1671,38 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeRead,
 		valid:      true,
 		cmdType:    &redis.StringSliceCmd{},
-		// TODO: other options available in 6.2.
-		// 	}, {
-		// 		name:      "zrange",
-		// 		args:      []string{"zrange", "{a}zset1", "byscore", "1.2", "10.8", "withscores"},
-		// 		writeKeys: []string{},
-		// 		readKeys:  []string{"{a}zset1"},
-		// 		valid:     true,
-		// 		cmdType:   &redis.StringSliceCmd{},
-		// 	}, {
-		// 		name:      "zrange",
-		// 		args:      []string{"zrange", "{a}zset1", "bylex", "(a", "(b", "withscores"},
-		// 		writeKeys: []string{},
-		// 		readKeys:  []string{"{a}zset1"},
-		// 		valid:     true,
-		// 		cmdType:   &redis.StringSliceCmd{},
-		// 	}, {
-		// 		name:      "zrange",
-		// 		args:      []string{"zrange", "{a}zset1", "rev", "(a", "(b", "withscores"},
-		// 		writeKeys: []string{},
-		// 		readKeys:  []string{"{a}zset1"},
-		// 		valid:     true,
-		// 		cmdType:   &redis.StringSliceCmd{},
-		// 	}, {
-		// 		name:      "zrange",
-		// 		args:      []string{"zrange", "{a}zset1", "rev", "1", "10", "withscores"},
-		// 		writeKeys: []string{},
-		// 		readKeys:  []string{"{a}zset1"},
-		// 		valid:     true,
-		// 		cmdType:   &redis.StringSliceCmd{},
-		// 	}, {
-		// 		name:      "zrange",
-		// 		args:      []string{"zrange", "{a}zset1", "rev", "1", "10", "limit", "2", "5", "withscores"},
-		// 		writeKeys: []string{},
-		// 		readKeys:  []string{"{a}zset1"},
-		// 		valid:     true,
-		// 		cmdType:   &redis.StringSliceCmd{},
+	}, {
+		name:       "zrange",
+		args:       []string{"zrange", "{a}zset1", "1.2", "(10.8", "byscore", "withscores"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "zrange",
+		args:       []string{"zrange", "{a}zset1", "(b", "[a", "BYLEX", "REV", "LIMIT", "0", "10"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "zrange",
+		args:       []string{"zrange", "{a}zset1", "1", "10", "rev", "withscores"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "zrange",
+		args:  []string{"zrange", "{a}zset1", "1", "10", "rev", "limit", "2", "5"},
+		valid: false,
+	}, {
+		name:  "zrange",
+		args:  []string{"zrange", "{a}zset1", "(a", "(b", "bylex", "withscores"},
+		valid: false,
 	}, {
 		name:       "zrangebylex",
 		args:       []string{"zrangebylex", "{a}zset1", "(a", "(b"},
@@ -1270,6 +1735,42 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeRead,
 		valid:      true,
 		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "zrangebylex",
+		args:       []string{"zrangebylex", "{a}zset1", "-", "+", "LIMIT", "1", "-1"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "zrangebylex",
+		args:  []string{"zrangebylex", "{a}zset1", "a", "(b"},
+		valid: false,
+	}, {
+		name:  "zrangebylex",
+		args:  []string{"zrangebylex", "{a}zset1", "[a", ""},
+		valid: false,
+	}, {
+		name:  "zrangebylex",
+		args:  []string{"zrangebylex", "{a}zset1", "[a", "[b", "limit", "0"},
+		valid: false,
+	}, {
+		name:  "zrangebylex",
+		args:  []string{"zrangebylex", "{a}zset1", "[a", "[b", "offset", "0", "1"},
+		valid: false,
+	}, {
+		name:  "zrangebylex",
+		args:  []string{"zrangebylex", "{a}zset1", "[a", "[b", "limit", "x", "1"},
+		valid: false,
+	}, {
+		name:  "zrevrangebylex",
+		args:  []string{"zrevrangebylex", "{a}zset1", "+", "b"},
+		valid: false,
+	}, {
+		name:  "zrevrangebylex",
+		args:  []string{"zrevrangebylex", "{a}zset1", "+", "-", "limit", "0", "x"},
+		valid: false,
 	}, {
 		name:       "zrangebyscore",
 		args:       []string{"zrangebyscore", "{a}zset1", "(1", "5"},
@@ -1294,6 +1795,102 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeRead,
 		valid:      true,
 		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "zrangebyscore",
+		args:       []string{"zrangebyscore", "{a}zset1", "-inf", "+inf", "LIMIT", "100", "10", "WITHSCORES"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "zrangebyscore",
+		args:       []string{"zrangebyscore", "{a}zset1", "(-inf", "(inf"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "zrangebyscore",
+		args:  []string{"zrangebyscore", "{a}zset1", "a", "5"},
+		valid: false,
+	}, {
+		name:  "zrangebyscore",
+		args:  []string{"zrangebyscore", "{a}zset1", "(", "5"},
+		valid: false,
+	}, {
+		name:  "zrangebyscore",
+		args:  []string{"zrangebyscore", "{a}zset1", "1", "nan"},
+		valid: false,
+	}, {
+		name:  "zrangebyscore",
+		args:  []string{"zrangebyscore", "{a}zset1", "1", "5", "limit", "0"},
+		valid: false,
+	}, {
+		name:  "zrangebyscore",
+		args:  []string{"zrangebyscore", "{a}zset1", "1", "5", "limit", "x", "1"},
+		valid: false,
+	}, {
+		name:  "zrangebyscore",
+		args:  []string{"zrangebyscore", "{a}zset1", "1", "5", "withscore"},
+		valid: false,
+	}, {
+		name:       "zrangestore",
+		args:       []string{"zrangestore", "{a}dst", "{a}zset1", "0", "-1"},
+		writeKeys:  []string{"{a}dst"},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:       "zrangestore",
+		args:       []string{"zrangestore", "{a}dst", "{a}zset1", "(1", "5", "BYSCORE", "REV", "LIMIT", "0", "10"},
+		writeKeys:  []string{"{a}dst"},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:       "zrangestore",
+		args:       []string{"zrangestore", "{a}dst", "{a}zset1", "[a", "(c", "bylex"},
+		writeKeys:  []string{"{a}dst"},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:  "zrangestore",
+		args:  []string{"zrangestore", "{a}dst", "{a}zset1", "0"},
+		valid: false,
+	}, {
+		name:  "zrangestore",
+		args:  []string{"zrangestore", "{a}dst", "{a}zset1", "a", "-1"},
+		valid: false,
+	}, {
+		name:  "zrangestore",
+		args:  []string{"zrangestore", "{a}dst", "{a}zset1", "0", "-1", "limit", "0", "1"},
+		valid: false,
+	}, {
+		name:  "zrangestore",
+		args:  []string{"zrangestore", "{a}dst", "{a}zset1", "0", "-1", "withscores"},
+		valid: false,
+	}, {
+		name:  "zrangestore",
+		args:  []string{"zrangestore", "{a}dst", "{a}zset1", "a", "b", "byscore"},
+		valid: false,
+	}, {
+		name:  "zrangestore",
+		args:  []string{"zrangestore", "{a}dst", "{a}zset1", "a", "b", "bylex"},
+		valid: false,
+	}, {
+		name:  "zrangestore",
+		args:  []string{"zrangestore", "{a}dst", "{a}zset1", "0", "1", "byscore", "bylex"},
+		valid: false,
+	}, {
+		name:  "zrangestore",
+		args:  []string{"zrangestore", "{a}dst", "{a}zset1", "0", "1", "byscore", "limit", "0"},
+		valid: false,
 	}, {
 		name:       "zrank",
 		args:       []string{"zrank", "{a}zset1", "a"},
@@ -1366,6 +1963,22 @@ var testCommandCases = []testCommandCase{
 		accessMode: base.HashTagAccessModeRead,
 		valid:      true,
 		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:       "zrevrangebyscore",
+		args:       []string{"zrevrangebyscore", "{a}zset1", "+inf", "-inf", "withscores", "limit", "1", "1"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}zset1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "zrevrangebyscore",
+		args:  []string{"zrevrangebyscore", "{a}zset1", "+inf", "(x"},
+		valid: false,
+	}, {
+		name:  "zrevrangebyscore",
+		args:  []string{"zrevrangebyscore", "{a}zset1", "+inf", "-inf", "limit", "1", "x"},
+		valid: false,
 	}, {
 		name:       "zrevrank",
 		args:       []string{"zrevrank", "{a}zset1", "a"},
@@ -1423,13 +2036,441 @@ var testCommandCases = []testCommandCase{
 		valid:      true,
 		cmdType:    &redis.Cmd{},
 	}, {
-		name:       "multi",
-		args:       []string{"multi"},
+		name:       "multi",
+		args:       []string{"multi"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:       "room.evict",
+		args:       []string{"room.evict", "{a}1"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:       "room.evict",
+		args:       []string{"room.evict", "{a}1", "force"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:  "room.evict",
+		args:  []string{"room.evict", "{a}1", "x"},
+		valid: false,
+	}, {
+		name:  "room.evict",
+		args:  []string{"room.evict"},
+		valid: false,
+	}, {
+		name:       "room.resetstat",
+		args:       []string{"room.resetstat"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "room.resetstat",
+		args:  []string{"room.resetstat", "x"},
+		valid: false,
+	}, {
+		name:       "client",
+		args:       []string{"client", "id"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:       "client",
+		args:       []string{"client", "setname", "worker-1"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:  "client",
+		args:  []string{"client", "setname", "worker 1"},
+		valid: false,
+	}, {
+		name:  "client",
+		args:  []string{"client", "list", "x"},
+		valid: false,
+	}, {
+		name:  "client",
+		args:  []string{"client", "kill"},
+		valid: false,
+	}, {
+		name:       "subscribe",
+		args:       []string{"subscribe", "news", "sports"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:  "subscribe",
+		args:  []string{"subscribe"},
+		valid: false,
+	}, {
+		name:       "unsubscribe",
+		args:       []string{"unsubscribe"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:       "publish",
+		args:       []string{"publish", "news", "hello"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:  "publish",
+		args:  []string{"publish", "news"},
+		valid: false,
+	}, {
+		name:       "pubsub",
+		args:       []string{"pubsub", "channels", "n*"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:       "pubsub",
+		args:       []string{"pubsub", "numsub", "news", "sports"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:  "pubsub",
+		args:  []string{"pubsub", "numpat", "x"},
+		valid: false,
+	}, {
+		name:  "pubsub",
+		args:  []string{"pubsub", "shardchannels"},
+		valid: false,
+	}, {
+		name:       "xadd",
+		args:       []string{"xadd", "{a}s", "*", "f1", "v1", "f2", "v2"},
+		writeKeys:  []string{"{a}s"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StringCmd{},
+	}, {
+		name:       "xadd",
+		args:       []string{"xadd", "{a}s", "MAXLEN", "~", "100", "1-1", "f1", "v1"},
+		writeKeys:  []string{"{a}s"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StringCmd{},
+	}, {
+		name:  "xadd",
+		args:  []string{"xadd", "{a}s", "*", "f1", "v1", "f2"},
+		valid: false,
+	}, {
+		name:  "xadd",
+		args:  []string{"xadd", "{a}s", "maxlen", "x", "*", "f1", "v1"},
+		valid: false,
+	}, {
+		name:       "xlen",
+		args:       []string{"xlen", "{a}s"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}s"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:       "xrange",
+		args:       []string{"xrange", "{a}s", "-", "+", "COUNT", "10"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}s"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.SliceCmd{},
+	}, {
+		name:  "xrange",
+		args:  []string{"xrange", "{a}s", "-", "+", "limit", "10"},
+		valid: false,
+	}, {
+		name:       "xrevrange",
+		args:       []string{"xrevrange", "{a}s", "+", "-"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}s"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.SliceCmd{},
+	}, {
+		name:       "xread",
+		args:       []string{"xread", "COUNT", "2", "BLOCK", "100", "STREAMS", "{a}s1", "{a}s2", "0", "$"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}s1", "{a}s2"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.SliceCmd{},
+	}, {
+		name:  "xread",
+		args:  []string{"xread", "streams", "{a}s1", "{a}s2", "0"},
+		valid: false,
+	}, {
+		name:  "xread",
+		args:  []string{"xread", "block", "-1", "streams", "{a}s1", "0"},
+		valid: false,
+	}, {
+		name:  "xread",
+		args:  []string{"xread", "COUNT", "1", "BLOCK", "0"},
+		valid: false,
+	}, {
+		name:  "xread",
+		args:  []string{"xread", "count", "1", "{a}s1", "0"},
+		valid: false,
+	}, {
+		name:       "keys",
+		args:       []string{"keys", "{a}*"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringSliceCmd{},
+	}, {
+		name:  "keys",
+		args:  []string{"keys"},
+		valid: false,
+	}, {
+		name:       "dump",
+		args:       []string{"dump", "{a}1"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringCmd{},
+	}, {
+		name:  "dump",
+		args:  []string{"dump", "{a}1", "{a}2"},
+		valid: false,
+	}, {
+		name:       "restore",
+		args:       []string{"restore", "{a}1", "0", "value", "REPLACE"},
+		writeKeys:  []string{"{a}1"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "restore",
+		args:  []string{"restore", "{a}1", "-1", "value"},
+		valid: false,
+	}, {
+		name:  "restore",
+		args:  []string{"restore", "{a}1", "0", "value", "absttl"},
+		valid: false,
+	}, {
+		name:       "object",
+		args:       []string{"object", "refcount", "{a}1"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:       "object",
+		args:       []string{"object", "FREQ", "{a}1"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:       "object",
+		args:       []string{"object", "encoding", "{a}1"},
+		writeKeys:  []string{},
+		readKeys:   []string{"{a}1"},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:       "object",
+		args:       []string{"object", "IdleTime", "{a}1"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:  "object",
+		args:  []string{"object", "idletime", "a"},
+		valid: false,
+	}, {
+		name:  "object",
+		args:  []string{"object", "refcount"},
+		valid: false,
+	}, {
+		name:  "object",
+		args:  []string{"object", "unknown", "{a}1"},
+		valid: false,
+	}, {
+		name:       "info",
+		args:       []string{"info"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringCmd{},
+	}, {
+		name:       "info",
+		args:       []string{"info", "server", "room"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StringCmd{},
+	}, {
+		name:       "debug",
+		args:       []string{"debug", "sleep", "0.5"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "debug",
+		args:  []string{"debug", "sleep", "-1"},
+		valid: false,
+	}, {
+		name:  "debug",
+		args:  []string{"debug", "sleep", "a"},
+		valid: false,
+	}, {
+		name:  "debug",
+		args:  []string{"debug", "reload"},
+		valid: false,
+	}, {
+		name:       "debug",
+		args:       []string{"debug", "object", "{a}1"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "debug",
+		args:  []string{"debug", "object", "a"},
+		valid: false,
+	}, {
+		name:       "debug",
+		args:       []string{"debug", "set-active-expire", "0"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "debug",
+		args:  []string{"debug", "set-active-expire", "2"},
+		valid: false,
+	}, {
+		name:  "debug",
+		args:  []string{"debug", "set-active-expire"},
+		valid: false,
+	}, {
+		name:       "reset",
+		args:       []string{"reset"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.StatusCmd{},
+	}, {
+		name:  "reset",
+		args:  []string{"reset", "all"},
+		valid: false,
+	}, {
+		name:       "wait",
+		args:       []string{"wait", "1", "100"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.IntCmd{},
+	}, {
+		name:  "wait",
+		args:  []string{"wait", "1", "-1"},
+		valid: false,
+	}, {
+		name:  "wait",
+		args:  []string{"wait", "x", "100"},
+		valid: false,
+	}, {
+		name:  "wait",
+		args:  []string{"wait", "1"},
+		valid: false,
+	}, {
+		name:       "eval",
+		args:       []string{"eval", "return redis.call('get', KEYS[1])", "1", "{a}1", "x"},
+		writeKeys:  []string{"{a}1"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:       "eval",
+		args:       []string{"eval", "return 1", "0"},
+		writeKeys:  []string{},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeRead,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:  "eval",
+		args:  []string{"eval", "return 1", "2", "{a}1"},
+		valid: false,
+	}, {
+		name:  "eval",
+		args:  []string{"eval", "return 1", "-1"},
+		valid: false,
+	}, {
+		name:       "evalsha",
+		args:       []string{"evalsha", "e0e1f9fabfc9d4800c877a703b823ac0578ff8db", "1", "{a}1"},
+		writeKeys:  []string{"{a}1"},
+		readKeys:   []string{},
+		accessMode: base.HashTagAccessModeWrite,
+		valid:      true,
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:  "evalsha",
+		args:  []string{"evalsha", "e0e1f9fabfc9d4800c877a703b823ac0578ff8db"},
+		valid: false,
+	}, {
+		name:       "script",
+		args:       []string{"script", "load", "return 1"},
 		writeKeys:  []string{},
 		readKeys:   []string{},
 		accessMode: base.HashTagAccessModeRead,
 		valid:      true,
-		cmdType:    &redis.StatusCmd{},
+		cmdType:    &redis.Cmd{},
+	}, {
+		name:  "script",
+		args:  []string{"script", "exists"},
+		valid: false,
+	}, {
+		name:  "script",
+		args:  []string{"script", "flush"},
+		valid: false,
 	},
 }
 
@@ -1652,6 +2693,33 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		respData:    RESPData{DataType: SimpleStringRespType, Value: "list"},
 		compareFn:   testCompareEqual,
 		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "type",
+		description: "type a hash key",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}123", "f", "v"},
+		args:        []string{"type", "{a}123"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "hash"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "type",
+		description: "type a set key",
+		prepareFn:   testNewSetKey,
+		prepareArgs: []interface{}{"{a}123", "a"},
+		args:        []string{"type", "{a}123"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "set"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "type",
+		description: "type a zset key",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}123", "a", "1"},
+		args:        []string{"type", "{a}123"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "zset"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
 	}, {
 		name:        "type",
 		description: "type an non-existed key",
@@ -1715,6 +2783,42 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
 		compareFn:   testCompareEqual,
 		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "set",
+		description: "set get a key when it is existed",
+		prepareFn:   testNewStringKeys,
+		prepareArgs: []string{"{a}123"},
+		args:        []string{"set", "{a}123", "value", "get"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: "{a}123"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "set",
+		description: "set xx get a key when it is not existed",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []string{},
+		args:        []string{"set", "{a}123", "value", "xx", "get"},
+		respData:    RESPData{DataType: NilRespType, Value: nil},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "set",
+		description: "set a key with keepttl",
+		prepareFn:   testNewStringKeyWithExpiration,
+		prepareArgs: []interface{}{"{a}123", 100 * time.Second},
+		args:        []string{"set", "{a}123", "value", "keepttl"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "set",
+		description: "set a key with unix time expiration in milliseconds",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []string{},
+		args:        []string{"set", "{a}123", "value", "pxat", "32503680000000"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
 	}, {
 		name:        "get",
 		description: "get an existed key",
@@ -1935,6 +3039,150 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		respData:    RESPData{DataType: IntegerRespType, Value: int64(0)},
 		compareFn:   testCompareEqual,
 		emptyKeys:   []string{},
+	}, {
+		name:        "strlen",
+		description: "strlen a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"strlen", "{a}list1"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "getrange",
+		description: "getrange a key",
+		prepareFn:   testNewStringKeyValue,
+		prepareArgs: []string{"{a}123", "This is a string"},
+		args:        []string{"getrange", "{a}123", "0", "3"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: "This"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "getrange",
+		description: "getrange a key with negative indexes",
+		prepareFn:   testNewStringKeyValue,
+		prepareArgs: []string{"{a}123", "This is a string"},
+		args:        []string{"getrange", "{a}123", "-3", "-1"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: "ing"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "getrange",
+		description: "getrange a key with out of range indexes",
+		prepareFn:   testNewStringKeyValue,
+		prepareArgs: []string{"{a}123", "This is a string"},
+		args:        []string{"getrange", "{a}123", "-100", "100"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: "This is a string"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "getrange",
+		description: "getrange a key with start greater than end",
+		prepareFn:   testNewStringKeyValue,
+		prepareArgs: []string{"{a}123", "This is a string"},
+		args:        []string{"getrange", "{a}123", "5", "3"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: ""},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "getrange",
+		description: "getrange a non-existed key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []string{},
+		args:        []string{"getrange", "{a}123", "0", "3"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: ""},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{},
+	}, {
+		name:        "getrange",
+		description: "getrange a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"getrange", "{a}list1", "0", "3"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "substr",
+		description: "substr a key like getrange",
+		prepareFn:   testNewStringKeyValue,
+		prepareArgs: []string{"{a}123", "This is a string"},
+		args:        []string{"substr", "{a}123", "-3", "-1"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: "ing"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "substr",
+		description: "substr a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"substr", "{a}list1", "0", "3"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "setrange",
+		description: "setrange a key",
+		prepareFn:   testNewStringKeyValue,
+		prepareArgs: []string{"{a}123", "Hello World"},
+		args:        []string{"setrange", "{a}123", "6", "Redis"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(11)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "setrange",
+		description: "setrange a key past the end",
+		prepareFn:   testNewStringKeyValue,
+		prepareArgs: []string{"{a}123", "Hello"},
+		args:        []string{"setrange", "{a}123", "10", "World"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(15)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "setrange",
+		description: "setrange a non-existed key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []string{},
+		args:        []string{"setrange", "{a}123", "3", "abc"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(6)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "setrange",
+		description: "setrange a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"setrange", "{a}list1", "0", "abc"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "append",
+		description: "append a key",
+		prepareFn:   testNewStringKeyValue,
+		prepareArgs: []string{"{a}123", "Hello"},
+		args:        []string{"append", "{a}123", " World"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(11)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "append",
+		description: "append a non-existed key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []string{},
+		args:        []string{"append", "{a}123", "Hello"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(5)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}123"},
+	}, {
+		name:        "append",
+		description: "append a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"append", "{a}list1", "abc"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}list1"},
 	}, {
 		name:        "lindex",
 		description: "lindex a list key",
@@ -1953,6 +3201,141 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		respData:    RESPData{DataType: BulkStringRespType, Value: "y"},
 		compareFn:   testCompareEqual,
 		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "linsert",
+		description: "linsert before the head of a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"linsert", "{a}list1", "before", "x", "a"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(4)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "linsert",
+		description: "linsert after the tail of a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"linsert", "{a}list1", "after", "z", "a"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(4)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "linsert",
+		description: "linsert a list key without pivot",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"linsert", "{a}list1", "after", "b", "a"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(-1)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "linsert",
+		description: "linsert a non existed key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []string{},
+		args:        []string{"linsert", "{a}list1", "after", "x", "a"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(0)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{},
+	}, {
+		name:        "linsert",
+		description: "linsert a string key",
+		prepareFn:   testNewStringKeyValue,
+		prepareArgs: []string{"{a}list1", "x"},
+		args:        []string{"linsert", "{a}list1", "after", "x", "a"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "lset",
+		description: "lset a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"lset", "{a}list1", "1", "a"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "lset",
+		description: "lset a list key with negative index",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"lset", "{a}list1", "-3", "a"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "lset",
+		description: "lset a list key with out of range index",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"lset", "{a}list1", "-4", "a"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "lset",
+		description: "lset a non existed key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []string{},
+		args:        []string{"lset", "{a}list1", "0", "a"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{},
+	}, {
+		name:        "ltrim",
+		description: "ltrim a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"ltrim", "{a}list1", "0", "1"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "ltrim",
+		description: "ltrim a list key with negative indexes",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"ltrim", "{a}list1", "-2", "-1"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "ltrim",
+		description: "ltrim a list key with out of range indexes",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"ltrim", "{a}list1", "-10", "10"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "ltrim",
+		description: "ltrim a list key to empty with start greater than stop",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"ltrim", "{a}list1", "2", "1"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "ltrim",
+		description: "ltrim a list key to empty with start beyond the end",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"ltrim", "{a}list1", "3", "10"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "ltrim",
+		description: "ltrim a non existed key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []string{},
+		args:        []string{"ltrim", "{a}list1", "0", "1"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{},
 	}, {
 		name:        "llen",
 		description: "llen a list key",
@@ -2012,10 +3395,37 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		description: "lpushx a non existed list key",
 		prepareFn:   testPrepareNOOP,
 		prepareArgs: []interface{}{},
-		args:        []string{"lpushx", "{a}list1", "a", "b"},
+		args:        []string{"lpushx", "{a}list1", "a", "b"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(0)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{""},
+	}, {
+		name:        "lpushx",
+		description: "lpushx a key of wrong type",
+		prepareFn:   testNewStringKeys,
+		prepareArgs: []string{"{a}string"},
+		args:        []string{"lpushx", "{a}string", "a"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}string"},
+	}, {
+		name:        "rpushx",
+		description: "rpushx a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x", "y", "z"},
+		args:        []string{"rpushx", "{a}list1", "a", "b"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(5)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}list1"},
+	}, {
+		name:        "rpushx",
+		description: "rpushx a non existed list key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []interface{}{},
+		args:        []string{"rpushx", "{a}list1", "a", "b"},
 		respData:    RESPData{DataType: IntegerRespType, Value: int64(0)},
 		compareFn:   testCompareEqual,
-		emptyKeys:   []string{""},
+		emptyKeys:   []string{"{a}list1"},
 	}, {
 		name:        "lrange",
 		description: "lrange list key from 0 to -1",
@@ -2232,6 +3642,35 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		},
 		compareFn: testCompareSameElement,
 		emptyKeys: []string{"{a}set1"},
+	}, {
+		name:        "spop",
+		description: "spop more elements than a set key has",
+		prepareFn:   testNewSetKey,
+		prepareArgs: []interface{}{"{a}set1", "a", "b"},
+		args:        []string{"spop", "{a}set1", "10"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{
+				{
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "b",
+				},
+			},
+		},
+		compareFn: testCompareSameElement,
+		emptyKeys: []string{"{a}set1"},
+	}, {
+		name:        "spop",
+		description: "spop zero elements in a set key",
+		prepareFn:   testNewSetKey,
+		prepareArgs: []interface{}{"{a}set1", "a", "b"},
+		args:        []string{"spop", "{a}set1", "0"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}set1"},
 	}, {
 		name:        "spop",
 		description: "spop single element in a set key",
@@ -2262,6 +3701,94 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		},
 		compareFn: testCompareSameElement,
 		emptyKeys: []string{},
+	}, {
+		name:        "srandmember",
+		description: "srandmember a set key",
+		prepareFn:   testNewSetKey,
+		prepareArgs: []interface{}{"{a}set1", "a"},
+		args:        []string{"srandmember", "{a}set1"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: "a"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}set1"},
+	}, {
+		name:        "srandmember",
+		description: "srandmember a non existed set key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []interface{}{},
+		args:        []string{"srandmember", "{a}set1"},
+		respData:    RESPData{DataType: NilRespType, Value: nil},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{},
+	}, {
+		name:        "srandmember",
+		description: "srandmember a non existed set key with count",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []interface{}{},
+		args:        []string{"srandmember", "{a}set1", "-3"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{},
+	}, {
+		name:        "srandmember",
+		description: "srandmember a set key with positive count",
+		prepareFn:   testNewSetKey,
+		prepareArgs: []interface{}{"{a}set1", "a", "b"},
+		args:        []string{"srandmember", "{a}set1", "5"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{
+				{
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "b",
+				},
+			},
+		},
+		compareFn: testCompareSameElement,
+		emptyKeys: []string{"{a}set1"},
+	}, {
+		name:        "srandmember",
+		description: "srandmember a set key with negative count",
+		prepareFn:   testNewSetKey,
+		prepareArgs: []interface{}{"{a}set1", "a"},
+		args:        []string{"srandmember", "{a}set1", "-3"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{
+				{
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "a",
+				},
+			},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}set1"},
+	}, {
+		name:        "smove",
+		description: "smove a member of a set key",
+		prepareFn:   testNewSetKey,
+		prepareArgs: []interface{}{"{a}set1", "a", "b"},
+		args:        []string{"smove", "{a}set1", "{a}set2", "a"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(1)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}set1", "{a}set2"},
+	}, {
+		name:        "smove",
+		description: "smove a member not in a set key",
+		prepareFn:   testNewSetKey,
+		prepareArgs: []interface{}{"{a}set1", "a", "b"},
+		args:        []string{"smove", "{a}set1", "{a}set2", "c"},
+		respData:    RESPData{DataType: IntegerRespType, Value: int64(0)},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}set1", "{a}set2"},
 	}, {
 		name:        "srem",
 		description: "srem a set key",
@@ -2350,6 +3877,25 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		},
 		compareFn: testCompareSameElementAndOrder,
 		emptyKeys: []string{"{a}hash1"},
+	}, {
+		name:        "hgetall",
+		description: "hgetall returns fields in sorted order",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "c", "3", "a", "1", "b", "2"},
+		args:        []string{"hgetall", "{a}hash1"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{
+				{DataType: BulkStringRespType, Value: "a"},
+				{DataType: BulkStringRespType, Value: "1"},
+				{DataType: BulkStringRespType, Value: "b"},
+				{DataType: BulkStringRespType, Value: "2"},
+				{DataType: BulkStringRespType, Value: "c"},
+				{DataType: BulkStringRespType, Value: "3"},
+			},
+		},
+		compareFn: testCompareSameElementAndOrder,
+		emptyKeys: []string{"{a}hash1"},
 	}, {
 		name:        "hincrby",
 		description: "hincrby a hash key",
@@ -2386,6 +3932,42 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		respData:    RESPData{DataType: BulkStringRespType, Value: "2.7"},
 		compareFn:   testCompareEqual,
 		emptyKeys:   []string{"{a}hash1"},
+	}, {
+		name:        "hincrby",
+		description: "hincrby overflows",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "9223372036854775807"},
+		args:        []string{"hincrby", "{a}hash1", "a", "1"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}hash1"},
+	}, {
+		name:        "hincrby",
+		description: "hincrby a key of wrong type",
+		prepareFn:   testNewStringKeys,
+		prepareArgs: []string{"{a}string"},
+		args:        []string{"hincrby", "{a}string", "a", "1"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}string"},
+	}, {
+		name:        "hincrbyfloat",
+		description: "hincrbyfloat a non existed field",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "1"},
+		args:        []string{"hincrbyfloat", "{a}hash1", "b", "0.1"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: "0.1"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}hash1"},
+	}, {
+		name:        "hincrbyfloat",
+		description: "hincrbyfloat a field with no float value",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "1", "c", "d"},
+		args:        []string{"hincrbyfloat", "{a}hash1", "c", "1.5"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}hash1"},
 	}, {
 		name:        "hkeys",
 		description: "hkeys a hash key",
@@ -2436,62 +4018,232 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		compareFn:   testCompareEqual,
 		emptyKeys:   []string{},
 	}, {
-		name:        "hmget",
-		description: "hmget a hash key",
+		name:        "hmget",
+		description: "hmget a hash key",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "b", "c", "d"},
+		args:        []string{"hmget", "{a}hash1", "a", "c", "x", "y"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{{
+				DataType: BulkStringRespType,
+				Value:    "b",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "d",
+			}, {
+				DataType: NilRespType,
+				Value:    nil,
+			}, {
+				DataType: NilRespType,
+				Value:    nil,
+			}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}hash1"},
+	}, {
+		name:        "hmget",
+		description: "hmget a non existed hash key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []interface{}{},
+		args:        []string{"hmget", "{a}hash1", "a", "c", "x", "y"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{{
+				DataType: NilRespType,
+				Value:    nil,
+			}, {
+				DataType: NilRespType,
+				Value:    nil,
+			}, {
+				DataType: NilRespType,
+				Value:    nil,
+			}, {
+				DataType: NilRespType,
+				Value:    nil,
+			}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}hash1"},
+	}, {
+		name:        "hmget",
+		description: "hmget all fields of a hash key in requested order",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "b", "c", "d"},
+		args:        []string{"hmget", "{a}hash1", "c", "a", "c"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{{
+				DataType: BulkStringRespType,
+				Value:    "d",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "b",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "d",
+			}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}hash1"},
+	}, {
+		name:        "hmget",
+		description: "hmget a key of wrong type",
+		prepareFn:   testNewStringKeys,
+		prepareArgs: []string{"{a}string"},
+		args:        []string{"hmget", "{a}string", "a"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}string"},
+	}, {
+		name:        "hmset",
+		description: "hmset a hash key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []interface{}{},
+		args:        []string{"hmset", "{a}hash1", "a", "b", "c", "d"},
+		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}hash1"},
+	}, {
+		name:        "hrandfield",
+		description: "hrandfield a hash key",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "1"},
+		args:        []string{"hrandfield", "{a}hash1"},
+		respData:    RESPData{DataType: BulkStringRespType, Value: "a"},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}hash1"},
+	}, {
+		name:        "hrandfield",
+		description: "hrandfield a non-existed key",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []interface{}{},
+		args:        []string{"hrandfield", "{a}hash1"},
+		respData:    RESPData{DataType: NilRespType, Value: nil},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{},
+	}, {
+		name:        "hrandfield",
+		description: "hrandfield a non-existed key with count",
+		prepareFn:   testPrepareNOOP,
+		prepareArgs: []interface{}{},
+		args:        []string{"hrandfield", "{a}hash1", "2"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{},
+	}, {
+		name:        "hrandfield",
+		description: "hrandfield a hash key with zero count",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "1", "b", "2"},
+		args:        []string{"hrandfield", "{a}hash1", "0"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}hash1"},
+	}, {
+		name:        "hrandfield",
+		description: "hrandfield a hash key with positive count",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "1", "b", "2"},
+		args:        []string{"hrandfield", "{a}hash1", "5"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{
+				{
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "b",
+				},
+			},
+		},
+		compareFn: testCompareSameElement,
+		emptyKeys: []string{"{a}hash1"},
+	}, {
+		name:        "hrandfield",
+		description: "hrandfield a hash key with positive count and values",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "1", "b", "2"},
+		args:        []string{"hrandfield", "{a}hash1", "5", "withvalues"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{
+				{
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "1",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "b",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "2",
+				},
+			},
+		},
+		compareFn: testCompareSameElementAndOrder,
+		emptyKeys: []string{"{a}hash1"},
+	}, {
+		name:        "hrandfield",
+		description: "hrandfield a hash key with negative count",
 		prepareFn:   testNewHashKey,
-		prepareArgs: []interface{}{"{a}hash1", "a", "b", "c", "d"},
-		args:        []string{"hmget", "{a}hash1", "a", "c", "x", "y"},
+		prepareArgs: []interface{}{"{a}hash1", "a", "1"},
+		args:        []string{"hrandfield", "{a}hash1", "-3"},
 		respData: RESPData{
 			DataType: ArrayRespType,
-			Value: []RESPData{{
-				DataType: BulkStringRespType,
-				Value:    "b",
-			}, {
-				DataType: BulkStringRespType,
-				Value:    "d",
-			}, {
-				DataType: NilRespType,
-				Value:    nil,
-			}, {
-				DataType: NilRespType,
-				Value:    nil,
-			}},
+			Value: []RESPData{
+				{
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "a",
+				},
+			},
 		},
 		compareFn: testCompareEqual,
 		emptyKeys: []string{"{a}hash1"},
 	}, {
-		name:        "hmget",
-		description: "hmget a non existed hash key",
-		prepareFn:   testPrepareNOOP,
-		prepareArgs: []interface{}{},
-		args:        []string{"hmget", "{a}hash1", "a", "c", "x", "y"},
+		name:        "hrandfield",
+		description: "hrandfield a hash key with negative count and values",
+		prepareFn:   testNewHashKey,
+		prepareArgs: []interface{}{"{a}hash1", "a", "1"},
+		args:        []string{"hrandfield", "{a}hash1", "-2", "WITHVALUES"},
 		respData: RESPData{
 			DataType: ArrayRespType,
-			Value: []RESPData{{
-				DataType: NilRespType,
-				Value:    nil,
-			}, {
-				DataType: NilRespType,
-				Value:    nil,
-			}, {
-				DataType: NilRespType,
-				Value:    nil,
-			}, {
-				DataType: NilRespType,
-				Value:    nil,
-			}},
+			Value: []RESPData{
+				{
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "1",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "a",
+				}, {
+					DataType: BulkStringRespType,
+					Value:    "1",
+				},
+			},
 		},
 		compareFn: testCompareEqual,
 		emptyKeys: []string{"{a}hash1"},
 	}, {
-		name:        "hmset",
-		description: "hmset a hash key",
-		prepareFn:   testPrepareNOOP,
-		prepareArgs: []interface{}{},
-		args:        []string{"hmset", "{a}hash1", "a", "b", "c", "d"},
-		respData:    RESPData{DataType: SimpleStringRespType, Value: "OK"},
-		compareFn:   testCompareEqual,
-		emptyKeys:   []string{"{a}hash1"},
+		name:        "hrandfield",
+		description: "hrandfield a list key",
+		prepareFn:   testNewListKey,
+		prepareArgs: []interface{}{"{a}list1", "x"},
+		args:        []string{"hrandfield", "{a}list1", "1"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}list1"},
 	}, {
 		name:        "hset",
 		description: "hset a hash key",
@@ -2767,38 +4519,289 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		compareFn: testCompareEqual,
 		emptyKeys: []string{"{a}zset1"},
 	}, {
-		name:        "zrangebylex",
-		description: "zrangebylex a zset key",
+		name:        "zrange",
+		description: "zrange a zset key with score ties",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "c", "0.5", "a", "0.5", "d", "0.25", "b", "0.5"},
+		args:        []string{"zrange", "{a}zset1", "0", "-1", "withscores"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{{
+				DataType: BulkStringRespType,
+				Value:    "d",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "0.25",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "a",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "0.5",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "b",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "0.5",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "c",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "0.5",
+			}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrange",
+		description: "zrange a zset key with negative indexes",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0.5", "b", "0.25", "c", "1.25", "d", "2"},
+		args:        []string{"zrange", "{a}zset1", "-3", "-2"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{{
+				DataType: BulkStringRespType,
+				Value:    "a",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "c",
+			}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrange",
+		description: "zrange a zset key with out of range negative start",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0.5", "b", "0.25"},
+		args:        []string{"zrange", "{a}zset1", "-100", "-2"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{{
+				DataType: BulkStringRespType,
+				Value:    "b",
+			}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrange",
+		description: "zrange a string key",
+		prepareFn:   testNewStringKeys,
+		prepareArgs: []string{"{a}zset1"},
+		args:        []string{"zrange", "{a}zset1", "0", "-1"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0.5", "b", "0.5", "c", "0.5"},
+		args:        []string{"zrangebylex", "{a}zset1", "[a", "(c"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{{
+				DataType: BulkStringRespType,
+				Value:    "a",
+			}, {
+				DataType: BulkStringRespType,
+				Value:    "b",
+			}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrevrangebylex",
+		description: "zrevrangebylex a zset key",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0.5", "b", "0.5", "c", "0.5"},
+		args:        []string{"zrevrangebylex", "{a}zset1", "(c", "(a"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value: []RESPData{{
+				DataType: BulkStringRespType,
+				Value:    "b",
+			}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key from - to +",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "-", "+"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "a"}, {DataType: BulkStringRespType, Value: "b"}, {DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "e"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key with inclusive bounds",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "[b", "[d"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "b"}, {DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "d"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key with exclusive bounds",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "(b", "(d"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "c"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key from - to exclusive max",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "-", "(c"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "a"}, {DataType: BulkStringRespType, Value: "b"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key from inclusive min to +",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "[d", "+"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "e"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key with min greater than max",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "[d", "[b"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key from + to -",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "+", "-"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key with limit",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "-", "+", "limit", "1", "2"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "b"}, {DataType: BulkStringRespType, Value: "c"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key with negative count of limit",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "-", "+", "limit", "3", "-1"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "e"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a zset key with offset of limit out of range",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrangebylex", "{a}zset1", "-", "+", "limit", "10", "1"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrevrangebylex",
+		description: "zrevrangebylex a zset key from + to -",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrevrangebylex", "{a}zset1", "+", "-"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "e"}, {DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "b"}, {DataType: BulkStringRespType, Value: "a"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrevrangebylex",
+		description: "zrevrangebylex a zset key with inclusive bounds",
 		prepareFn:   testNewZSetKey,
-		prepareArgs: []interface{}{"{a}zset1", "a", "0.5", "b", "0.5", "c", "0.5"},
-		args:        []string{"zrangebylex", "{a}zset1", "[a", "(c"},
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrevrangebylex", "{a}zset1", "[d", "[b"},
 		respData: RESPData{
 			DataType: ArrayRespType,
-			Value: []RESPData{{
-				DataType: BulkStringRespType,
-				Value:    "a",
-			}, {
-				DataType: BulkStringRespType,
-				Value:    "b",
-			}},
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "b"}},
 		},
 		compareFn: testCompareEqual,
 		emptyKeys: []string{"{a}zset1"},
 	}, {
 		name:        "zrevrangebylex",
-		description: "zrevrangebylex a zset key",
+		description: "zrevrangebylex a zset key with max less than min",
 		prepareFn:   testNewZSetKey,
-		prepareArgs: []interface{}{"{a}zset1", "a", "0.5", "b", "0.5", "c", "0.5"},
-		args:        []string{"zrevrangebylex", "{a}zset1", "(c", "(a"},
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrevrangebylex", "{a}zset1", "[b", "[d"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrevrangebylex",
+		description: "zrevrangebylex a zset key with limit",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "0", "b", "0", "c", "0", "d", "0", "e", "0"},
+		args:        []string{"zrevrangebylex", "{a}zset1", "+", "(b", "limit", "1", "2"},
 		respData: RESPData{
 			DataType: ArrayRespType,
-			Value: []RESPData{{
-				DataType: BulkStringRespType,
-				Value:    "b",
-			}},
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "c"}},
 		},
 		compareFn: testCompareEqual,
 		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a not existed key",
+		prepareFn:   testPrepareNOOP,
+		args:        []string{"zrangebylex", "{a}zset1", "-", "+"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrangebylex",
+		description: "zrangebylex a string key",
+		prepareFn:   testNewStringKeys,
+		prepareArgs: []string{"{a}zset1"},
+		args:        []string{"zrangebylex", "{a}zset1", "-", "+"},
+		respData:    RESPData{DataType: ErrorRespType, Value: nil},
+		compareFn:   testIsErrorType,
+		emptyKeys:   []string{"{a}zset1"},
 	}, {
 		name:        "zrangebyscore",
 		description: "zrangebyscore a zset key",
@@ -2976,6 +4979,171 @@ var testExecuteCommandCases = []testExecuteCommandCase{
 		},
 		compareFn: testCompareEqual,
 		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key from -inf",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "-inf", "0.5"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "a"}, {DataType: BulkStringRespType, Value: "b"}, {DataType: BulkStringRespType, Value: "c"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key to +inf",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "0.5", "+inf"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "e"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key with exclusive infinite bounds",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "(-inf", "(+inf"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "b"}, {DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "d"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key with exclusive bounds",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "(0.25", "(1.25"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "c"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key with min greater than max",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "1", "0"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key with infinite scores and withscores",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "-inf", "+inf", "withscores"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "a"}, {DataType: BulkStringRespType, Value: "-inf"}, {DataType: BulkStringRespType, Value: "b"}, {DataType: BulkStringRespType, Value: "0.25"}, {DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "0.5"}, {DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "1.25"}, {DataType: BulkStringRespType, Value: "e"}, {DataType: BulkStringRespType, Value: "inf"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key with limit and withscores",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "-inf", "+inf", "withscores", "limit", "1", "2"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "b"}, {DataType: BulkStringRespType, Value: "0.25"}, {DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "0.5"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key with limit before withscores",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "-inf", "+inf", "limit", "3", "-1", "withscores"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "1.25"}, {DataType: BulkStringRespType, Value: "e"}, {DataType: BulkStringRespType, Value: "inf"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key with large offset of limit",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "-inf", "+inf", "limit", "1000000", "10"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrangebyscore",
+		description: "zrangebyscore a zset key with zero count of limit",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrangebyscore", "{a}zset1", "-inf", "+inf", "limit", "0", "0"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrevrangebyscore",
+		description: "zrevrangebyscore a zset key from +inf to -inf",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrevrangebyscore", "{a}zset1", "+inf", "-inf"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "e"}, {DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "b"}, {DataType: BulkStringRespType, Value: "a"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrevrangebyscore",
+		description: "zrevrangebyscore a zset key with exclusive bounds",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrevrangebyscore", "{a}zset1", "(+inf", "(0.25"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "c"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrevrangebyscore",
+		description: "zrevrangebyscore a zset key with max less than min",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrevrangebyscore", "{a}zset1", "0", "1"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
+	}, {
+		name:        "zrevrangebyscore",
+		description: "zrevrangebyscore a zset key with limit and withscores",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrevrangebyscore", "{a}zset1", "+inf", "-inf", "withscores", "limit", "1", "2"},
+		respData: RESPData{
+			DataType: ArrayRespType,
+			Value:    []RESPData{{DataType: BulkStringRespType, Value: "d"}, {DataType: BulkStringRespType, Value: "1.25"}, {DataType: BulkStringRespType, Value: "c"}, {DataType: BulkStringRespType, Value: "0.5"}},
+		},
+		compareFn: testCompareEqual,
+		emptyKeys: []string{"{a}zset1"},
+	}, {
+		name:        "zrevrangebyscore",
+		description: "zrevrangebyscore a zset key with large offset of limit",
+		prepareFn:   testNewZSetKey,
+		prepareArgs: []interface{}{"{a}zset1", "a", "-inf", "b", "0.25", "c", "0.5", "d", "1.25", "e", "+inf"},
+		args:        []string{"zrevrangebyscore", "{a}zset1", "+inf", "-inf", "limit", "1000000", "10"},
+		respData:    RESPData{DataType: ArrayRespType, Value: []RESPData{}},
+		compareFn:   testCompareEqual,
+		emptyKeys:   []string{"{a}zset1"},
 	}, {
 		name:        "zrevrank",
 		description: "zrevrank a zset key",
@@ -3271,6 +5439,67 @@ func TestExtractHashTagFromKey(t *testing.T) {
 	}
 }
 
+func TestNewHashTagExtractor(t *testing.T) {
+	extractor, err := NewHashTagExtractor(base.HashTagConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, "a", extractor("x{a}b"))
+	assert.Equal(t, "", extractor("a:b"))
+
+	extractor, err = NewHashTagExtractor(base.HashTagConfig{Scheme: base.HashTagSchemeBrace})
+	assert.Nil(t, err)
+	assert.Equal(t, "a", extractor("{a}:b"))
+
+	extractor, err = NewHashTagExtractor(base.HashTagConfig{Scheme: base.HashTagSchemePrefix, Delimiter: ":"})
+	assert.Nil(t, err)
+	cases := []struct {
+		key     string
+		hashTag string
+	}{
+		{"a", ""},
+		{"", ""},
+		{":a", ""},
+		{"a:", "a"},
+		{"a:b", "a"},
+		{"ab:c:d", "ab"},
+		{"{a}:b", "{a}"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.hashTag, extractor(c.key), c.key)
+	}
+
+	extractor, err = NewHashTagExtractor(base.HashTagConfig{Scheme: base.HashTagSchemePrefix, Delimiter: "::"})
+	assert.Nil(t, err)
+	assert.Equal(t, "a:b", extractor("a:b::c"))
+
+	_, err = NewHashTagExtractor(base.HashTagConfig{Scheme: base.HashTagSchemePrefix})
+	assert.NotNil(t, err)
+	_, err = NewHashTagExtractor(base.HashTagConfig{Scheme: "suffix"})
+	assert.NotNil(t, err)
+}
+
+func TestCommandKeysHashTagWithPrefixScheme(t *testing.T) {
+	extractor, err := NewHashTagExtractor(base.HashTagConfig{Scheme: base.HashTagSchemePrefix, Delimiter: ":"})
+	assert.Nil(t, err)
+	SetHashTagExtractor(extractor)
+	defer SetHashTagExtractor(extractBraceHashTag)
+
+	command, err := ParseCommand([]string{"mget", "user:1", "user:2"})
+	assert.Nil(t, err)
+	hashTag, err := CheckAndGetCommandKeysHashTag(command)
+	assert.Nil(t, err)
+	assert.Equal(t, "user", hashTag)
+
+	command, err = ParseCommand([]string{"mget", "user:1", "order:1"})
+	assert.Nil(t, err)
+	_, err = CheckAndGetCommandKeysHashTag(command)
+	assert.Equal(t, errCommnandKeysMultipleHashTags, err)
+
+	command, err = ParseCommand([]string{"get", "{user}1"})
+	assert.Nil(t, err)
+	_, err = CheckAndGetCommandKeysHashTag(command)
+	assert.Equal(t, errCommandKeyNoHashTag, err)
+}
+
 func TestCommandKeysHashTag(t *testing.T) {
 	for _, testCase := range testCommandHashTagCases {
 		newFn := supportedCommands[testCase.name]
@@ -3318,6 +5547,31 @@ var testCommandHashTagCases = []testCommandHashTagCase{
 		args:    []string{"sadd", "x{abc}", "a", "b", "c"},
 		valid:   true,
 		hashTag: "abc",
+	}, {
+		name:    "smove",
+		args:    []string{"smove", "{a}set1", "{a}set2", "a"},
+		valid:   true,
+		hashTag: "a",
+	}, {
+		name:  "smove",
+		args:  []string{"smove", "{a}set1", "{b}set2", "a"},
+		valid: false,
+		err:   errCommnandKeysMultipleHashTags,
+	}, {
+		name:  "copy",
+		args:  []string{"copy", "{a}1", "{b}1"},
+		valid: false,
+		err:   errCommnandKeysMultipleHashTags,
+	}, {
+		name:  "rename",
+		args:  []string{"rename", "{a}1", "{b}1"},
+		valid: false,
+		err:   errCommnandKeysMultipleHashTags,
+	}, {
+		name:  "renamenx",
+		args:  []string{"renamenx", "{a}1", "{b}1"},
+		valid: false,
+		err:   errCommnandKeysMultipleHashTags,
 	}, {
 		name:    "exec",
 		args:    []string{"exec"},
@@ -3326,6 +5580,206 @@ var testCommandHashTagCases = []testCommandHashTagCase{
 	},
 }
 
+func TestSetCommandOptions(t *testing.T) {
+	key := "{a}set_options"
+	defer testEmptyKeysInRedis(key)
+	redisCluster := base.GetServerDependency().Redis
+
+	execute := func(args ...string) RESPData {
+		command, err := NewSetCommand(append([]string{"set", key}, args...))
+		assert.Nil(t, err)
+		return ExecuteCommand(redisCluster, command)
+	}
+
+	// xx does not create a missing key.
+	assert.Equal(t, NilRespType, execute("v1", "xx").DataType)
+	assert.Equal(t, int64(0), redisCluster.Exists(contextTODO, key).Val())
+
+	// nx does not overwrite an existing key.
+	assert.Equal(t, "OK", execute("v1", "nx").Value)
+	assert.Equal(t, NilRespType, execute("v2", "nx").DataType)
+	assert.Equal(t, "v1", redisCluster.Get(contextTODO, key).Val())
+
+	// keepttl retains ttl of the key, while a plain set discards it.
+	assert.Equal(t, "OK", execute("v3", "ex", "100").Value)
+	assert.Equal(t, "OK", execute("v4", "keepttl").Value)
+	assert.Greater(t, int64(redisCluster.TTL(contextTODO, key).Val()), int64(0))
+	assert.Equal(t, "OK", execute("v5").Value)
+	assert.Equal(t, time.Duration(-1), redisCluster.TTL(contextTODO, key).Val())
+
+	// get returns old value with xx.
+	result := execute("v6", "xx", "get")
+	assert.Equal(t, RESPData{DataType: BulkStringRespType, Value: "v5"}, result)
+	assert.Equal(t, "v6", redisCluster.Get(contextTODO, key).Val())
+
+	// exat sets expiration by unix time in seconds.
+	expireAt := time.Now().Add(100 * time.Second).Unix()
+	assert.Equal(t, "OK", execute("v7", "exat", strconv.FormatInt(expireAt, 10)).Value)
+	assert.Greater(t, int64(redisCluster.TTL(contextTODO, key).Val()), int64(0))
+}
+
+func TestZAddCommandOptions(t *testing.T) {
+	key := "{a}zadd_options"
+	defer testEmptyKeysInRedis(key)
+	redisCluster := base.GetServerDependency().Redis
+
+	for _, testCase := range []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"nx", "xx", "1", "a"}, errZAddXXAndNXNotCompatible},
+		{[]string{"XX", "nx", "gt", "1", "a"}, errZAddXXAndNXNotCompatible},
+		{[]string{"gt", "lt", "1", "a"}, errZAddGTLTNXNotCompatible},
+		{[]string{"nx", "lt", "1", "a"}, errZAddGTLTNXNotCompatible},
+		{[]string{"incr", "1", "a", "2", "b"}, errZAddIncrMultiplePairs},
+		{[]string{"ch", "incr"}, errSyntaxError},
+		{[]string{"1", "a", "2"}, errSyntaxError},
+		{[]string{"a", "1"}, errInvalidFloat},
+	} {
+		_, err := NewZAddCommand(append([]string{"zadd", key}, testCase.args...))
+		assert.Equal(t, testCase.err, err, testCase.args)
+	}
+
+	command, err := NewZAddCommand([]string{"zadd", key, "XX", "Gt", "CH", "incr", "1", "a"})
+	assert.Nil(t, err)
+	zadd := command.(*ZAddCommand)
+	assert.Equal(t, keyExistModeXX, zadd.existMode)
+	assert.Equal(t, "gt", zadd.scoreCompare)
+	assert.True(t, zadd.returnChangedCount)
+	assert.True(t, zadd.incr)
+	assert.Equal(t, []string{"1", "a"}, zadd.scoreMembers)
+
+	execute := func(args ...string) RESPData {
+		command, err := NewZAddCommand(append([]string{"zadd", key}, args...))
+		assert.Nil(t, err)
+		return ExecuteCommand(redisCluster, command)
+	}
+
+	// xx never adds members.
+	assert.Equal(t, RESPData{DataType: IntegerRespType, Value: int64(0)}, execute("xx", "1", "a"))
+	assert.Equal(t, NilRespType, execute("xx", "incr", "1", "a").DataType)
+	assert.Equal(t, int64(0), redisCluster.Exists(contextTODO, key).Val())
+
+	// nx never updates members.
+	assert.Equal(t, RESPData{DataType: IntegerRespType, Value: int64(2)}, execute("nx", "1", "a", "2", "b"))
+	assert.Equal(t, RESPData{DataType: IntegerRespType, Value: int64(1)}, execute("nx", "ch", "10", "a", "3", "c"))
+	assert.Equal(t, NilRespType, execute("nx", "incr", "1", "a").DataType)
+	assert.Equal(t, float64(1), redisCluster.ZScore(contextTODO, key, "a").Val())
+
+	// ch counts updated members too.
+	assert.Equal(t, RESPData{DataType: IntegerRespType, Value: int64(0)}, execute("5", "a", "2", "b"))
+	assert.Equal(t, RESPData{DataType: IntegerRespType, Value: int64(2)}, execute("ch", "6", "a", "2", "b", "4", "d"))
+	assert.Equal(t, RESPData{DataType: IntegerRespType, Value: int64(1)}, execute("xx", "ch", "7", "a", "5", "e"))
+	assert.Equal(t, int64(4), redisCluster.ZCard(contextTODO, key).Val())
+
+	// incr works like zincrby.
+	assert.Equal(t, RESPData{DataType: BulkStringRespType, Value: "8.5"}, execute("xx", "incr", "1.5", "a"))
+}
+
+func TestZRangeCommandOptions(t *testing.T) {
+	scoreKey, lexKey := "{a}zrange_score", "{a}zrange_lex"
+	defer testEmptyKeysInRedis(scoreKey, lexKey)
+	redisCluster := base.GetServerDependency().Redis
+	members := []string{"a", "b", "c", "d", "e"}
+	for index, member := range members {
+		redisCluster.ZAdd(contextTODO, scoreKey, &redis.Z{Score: float64(index + 1), Member: member})
+		redisCluster.ZAdd(contextTODO, lexKey, &redis.Z{Score: 0, Member: member})
+	}
+
+	for _, testCase := range []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"0"}, newWrongNumberOfArgumentsError("zrange")},
+		{[]string{"a", "-1"}, errInvalidInteger},
+		{[]string{"0", "-1", "limit", "0", "1"}, errZRangeLimitWithoutBy},
+		{[]string{"0", "-1", "byscore", "bylex"}, errSyntaxError},
+		{[]string{"0", "-1", "byscore", "limit", "0"}, errSyntaxError},
+		{[]string{"0", "-1", "byscore", "limit", "a", "1"}, errInvalidInteger},
+		{[]string{"0", "-1", "unknown"}, errSyntaxError},
+		{[]string{"a", "(b", "byscore"}, errZRangeScoreInvalidItem},
+		{[]string{"a", "+", "bylex"}, errZRangeLexInvalidItem},
+		{[]string{"-", "+", "bylex", "withscores"}, errZRangeWithScoresByLex},
+	} {
+		_, err := NewZRangeCommand(append([]string{"zrange", scoreKey}, testCase.args...))
+		assert.Equal(t, testCase.err, err, testCase.args)
+	}
+
+	execute := func(args ...string) RESPData {
+		command, err := NewZRangeCommand(args)
+		assert.Nil(t, err, args)
+		return ExecuteCommand(redisCluster, command)
+	}
+	array := func(values ...string) RESPData {
+		data := make([]RESPData, 0, len(values))
+		for _, value := range values {
+			data = append(data, RESPData{DataType: BulkStringRespType, Value: value})
+		}
+		return RESPData{DataType: ArrayRespType, Value: data}
+	}
+
+	// range by index.
+	assert.Equal(t, array("b", "c"), execute("zrange", scoreKey, "1", "2"))
+	assert.Equal(t, array("d", "4", "c", "3"), execute("zrange", scoreKey, "1", "2", "REV", "WITHSCORES"))
+	assert.Equal(t, array(), execute("zrange", scoreKey, "3", "1"))
+
+	// all combinations of range type, rev, limit and withscores, range is (b, +inf) in order of min and max.
+	for _, by := range []string{zRangeByScore, zRangeByLex} {
+		for _, rev := range []bool{false, true} {
+			for _, limit := range []bool{false, true} {
+				for _, withScores := range []bool{false, true} {
+					key, min, max := scoreKey, "(1", "+inf"
+					if by == zRangeByLex {
+						key, min, max = lexKey, "(a", "+"
+					}
+					if rev {
+						min, max = max, min
+					}
+					args := []string{"zrange", key, min, max, by}
+					expected := []string{"b", "c", "d", "e"}
+					if rev {
+						args = append(args, "rev")
+						expected = []string{"e", "d", "c", "b"}
+					}
+					if limit {
+						args = append(args, "limit", "1", "2")
+						expected = expected[1:3]
+					}
+					if withScores {
+						args = append(args, "withscores")
+					}
+
+					command, err := NewZRangeCommand(args)
+					if withScores && by == zRangeByLex {
+						assert.Equal(t, errZRangeWithScoresByLex, err, args)
+						continue
+					}
+					assert.Nil(t, err, args)
+					zrange := command.(*ZRangeCommand)
+					assert.Equal(t, by, zrange.by, args)
+					assert.Equal(t, rev, zrange.rev, args)
+					assert.Equal(t, limit, zrange.limit != nil, args)
+					assert.Equal(t, withScores, zrange.withScores, args)
+
+					values := make([]string, 0, 2*len(expected))
+					for _, member := range expected {
+						values = append(values, member)
+						if withScores {
+							values = append(values, strconv.Itoa(int(member[0]-'a')+1))
+						}
+					}
+					assert.Equal(t, array(values...), ExecuteCommand(redisCluster, command), args)
+				}
+			}
+		}
+	}
+
+	// exclusive and unbounded items.
+	assert.Equal(t, array("a", "b"), execute("zrange", scoreKey, "-inf", "(3", "byscore"))
+	assert.Equal(t, array("c", "b", "a"), execute("zrange", lexKey, "[c", "-", "bylex", "rev"))
+	assert.Equal(t, array(), execute("zrange", scoreKey, "(5", "+inf", "byscore"))
+}
+
 func TestCommandBatch(t *testing.T) {
 	defer testEmptyKeysInRedis("{b}2")
 
@@ -3354,3 +5808,142 @@ func TestCommandBatch(t *testing.T) {
 		assert.Equal(t, expectedResults[index].Value, result.Value)
 	}
 }
+
+func TestSplitCommandByHashTag(t *testing.T) {
+	command, _ := ParseCommand([]string{"del", "{a}1", "{b}1", "{a}2", "{a}1"})
+	cmds, err := SplitCommandByHashTag(command)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(cmds))
+	assert.Equal(t, []string{"{a}1", "{a}2", "{a}1"}, cmds[0].WriteKeys())
+	assert.Equal(t, []string{"{b}1"}, cmds[1].WriteKeys())
+
+	command, _ = ParseCommand([]string{"exists", "{a}1", "b"})
+	_, err = SplitCommandByHashTag(command)
+	assert.Equal(t, errCommandKeyNoHashTag, err)
+
+	command, _ = ParseCommand([]string{"get", "{a}1"})
+	cmds, err = SplitCommandByHashTag(command)
+	assert.Nil(t, err)
+	assert.Equal(t, []Commander{command}, cmds)
+
+	command, _ = ParseCommand([]string{"sinterstore", "{b}dst", "{a}set1", "{b}set2", "{a}set3"})
+	cmds, err = SplitCommandByHashTag(command)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(cmds))
+	assert.Equal(t, []string{"{a}set1", "{a}set3"}, cmds[0].ReadKeys())
+	assert.Equal(t, []string{}, cmds[0].WriteKeys())
+	assert.Equal(t, []string{"{b}set2"}, cmds[1].ReadKeys())
+	assert.Equal(t, []string{"{b}dst"}, cmds[1].WriteKeys())
+
+	command, _ = ParseCommand([]string{"zrangestore", "{a}dst", "{a}src", "0", "-1"})
+	cmds, err = SplitCommandByHashTag(command)
+	assert.Nil(t, err)
+	assert.Equal(t, []Commander{command}, cmds)
+
+	command, _ = ParseCommand([]string{"zrangestore", "{b}dst", "{a}src", "0", "-1"})
+	cmds, err = SplitCommandByHashTag(command)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(cmds))
+	assert.Equal(t, []string{"{a}src"}, cmds[0].ReadKeys())
+	assert.Equal(t, base.HashTagAccessModeRead, GetCommnadKeysAccessMode(cmds[0]))
+	assert.Equal(t, []string{"{b}dst"}, cmds[1].WriteKeys())
+	assert.Equal(t, base.HashTagAccessModeWrite, GetCommnadKeysAccessMode(cmds[1]))
+}
+
+func TestCommandForEvent(t *testing.T) {
+	command, _ := ParseCommand([]string{"hsetnx", "{a}hash", "field", "value"})
+	for _, result := range []RESPData{
+		{DataType: IntegerRespType, Value: int64(1)},
+		{DataType: SimpleStringRespType, Value: "QUEUED"},
+	} {
+		assert.Equal(t, command, CommandForEvent(command, result))
+	}
+	eventCommand := CommandForEvent(command, RESPData{DataType: IntegerRespType, Value: int64(0)})
+	assert.Equal(t, []string{"{a}hash"}, eventCommand.ReadKeys())
+	assert.Equal(t, []string{}, eventCommand.WriteKeys())
+	assert.Equal(t, base.HashTagAccessModeRead, GetCommnadKeysAccessMode(eventCommand))
+
+	for _, name := range []string{"lpushx", "rpushx"} {
+		command, _ = ParseCommand([]string{name, "{a}list", "a"})
+		assert.Equal(t, command, CommandForEvent(command, RESPData{DataType: IntegerRespType, Value: int64(2)}))
+		eventCommand = CommandForEvent(command, RESPData{DataType: IntegerRespType, Value: int64(0)})
+		assert.Equal(t, base.HashTagAccessModeRead, GetCommnadKeysAccessMode(eventCommand), name)
+	}
+
+	command, _ = ParseCommand([]string{"hset", "{a}hash", "field", "value"})
+	assert.Equal(t, command, CommandForEvent(command, RESPData{DataType: IntegerRespType, Value: int64(0)}))
+}
+
+func TestHSetNXCommandConcurrently(t *testing.T) {
+	redisCluster := base.GetServerDependency().Redis
+	key := "{a}hsetnx_concurrently"
+	testEmptyKeysInRedis(key)
+	defer testEmptyKeysInRedis(key)
+
+	count := 10
+	var wg sync.WaitGroup
+	results := make(chan RESPData, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			command, _ := ParseCommand([]string{"hsetnx", key, "field", strconv.Itoa(i)})
+			results <- ExecuteCommand(redisCluster, command)
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+	written := 0
+	for result := range results {
+		assert.Equal(t, IntegerRespType, result.DataType)
+		written += int(result.Value.(int64))
+	}
+	// only one of commands sets the field.
+	assert.Equal(t, 1, written)
+	assert.Equal(t, int64(1), redisCluster.HLen(context.TODO(), key).Val())
+}
+
+func TestZRangeStoreRangeCmd(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		rangeCmd []interface{}
+	}{
+		{
+			[]string{"zrangestore", "{a}dst", "{a}src", "0", "-1"},
+			[]interface{}{"zrange", "{a}src", "0", "-1", "withscores"},
+		},
+		{
+			[]string{"zrangestore", "{a}dst", "{a}src", "0", "-1", "REV"},
+			[]interface{}{"zrevrange", "{a}src", "0", "-1", "withscores"},
+		},
+		{
+			[]string{"zrangestore", "{a}dst", "{a}src", "(1", "+inf", "byscore", "limit", "1", "2"},
+			[]interface{}{"zrangebyscore", "{a}src", "(1", "+inf", "withscores", "limit", int64(1), int64(2)},
+		},
+		{
+			[]string{"zrangestore", "{a}dst", "{a}src", "+inf", "(1", "rev", "byscore"},
+			[]interface{}{"zrevrangebyscore", "{a}src", "+inf", "(1", "withscores"},
+		},
+		{
+			[]string{"zrangestore", "{a}dst", "{a}src", "[a", "+", "BYLEX"},
+			[]interface{}{"zrangebylex", "{a}src", "[a", "+"},
+		},
+		{
+			[]string{"zrangestore", "{a}dst", "{a}src", "+", "[a", "bylex", "rev", "limit", "0", "1"},
+			[]interface{}{"zrevrangebylex", "{a}src", "+", "[a", "limit", int64(0), int64(1)},
+		},
+	}
+	for _, testCase := range testCases {
+		command, err := ParseCommand(testCase.args)
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.rangeCmd, command.(*ZRangeStoreCommand).RangeCmd().Args(), testCase.args)
+	}
+}
+
+func TestParseSubstrCommand(t *testing.T) {
+	command, err := ParseCommand([]string{"SUBSTR", "{a}123", "0", "3"})
+	assert.Nil(t, err)
+	assert.IsType(t, &GetRangeCommand{}, command)
+	assert.Equal(t, "substr", command.Name())
+	assert.Equal(t, ValueTypeString, GetCommandValueType(command))
+}
@@ -10,6 +10,14 @@ func newWrongNumberOfArgumentsError(command string) error {
 	return fmt.Errorf("ERR wrong number of arguments for '%s' command", command)
 }
 
+func newInvalidExpireTimeError(command string) error {
+	return fmt.Errorf("ERR invalid expire time in '%s' command", command)
+}
+
+func newZSetAggregateNoKeyError(command string) error {
+	return fmt.Errorf("ERR at least 1 input key is needed for '%s' command", command)
+}
+
 func newUnknownCommand(command string, args []string) error {
 	argSlice := []string{}
 	for _, arg := range args {
@@ -28,6 +36,25 @@ var (
 	errInvalidFloat                 = errors.New("ERR value is not a valid float")
 	errInvalidOffset                = errors.New("ERR offset is out of range")
 	errInvalidIndex                 = errors.New("ERR index out of range")
+	errValueNotPositive             = errors.New("ERR value is out of range, must be positive")
+	errValueOutOfRange              = errors.New("ERR value is out of range")
+	errDecrementOverflow            = errors.New("ERR decrement would overflow")
 	errCommnandKeysMultipleHashTags = errors.New("ERR keys not have the same hash tag")
 	errCommandKeyNoHashTag          = errors.New("ERR key have no hash tag")
+	errTimeoutNegative              = errors.New("ERR timeout is negative")
+	errTimeoutNotInteger            = errors.New("ERR timeout is not an integer or out of range")
+	errInvalidTTL                   = errors.New("ERR Invalid TTL value, must be >= 0")
+	errCopyToOtherDB                = errors.New("ERR Copying to another database is not allowed in cluster mode")
+	errZAddXXAndNXNotCompatible     = errors.New("ERR XX and NX options at the same time are not compatible")
+	errZAddGTLTNXNotCompatible      = errors.New("ERR GT, LT, and/or NX options at the same time are not compatible")
+	errZAddIncrMultiplePairs        = errors.New("ERR INCR option supports a single increment-element pair")
+	errZRangeLexInvalidItem         = errors.New("ERR min or max not valid string range item")
+	errZRangeScoreInvalidItem       = errors.New("ERR min or max is not a float")
+	errZRangeLimitWithoutBy         = errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	errZRangeWithScoresByLex        = errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	errZSetWeightNotFloat           = errors.New("ERR weight value is not a float")
+	errXReadUnbalancedStreams       = errors.New("ERR Unbalanced XREAD list of streams: for each stream key an ID or '$' must be specified.")
+	errHashFieldsArgMissing         = errors.New("ERR Mandatory argument FIELDS is missing or not at the right position")
+	errHashNumFieldsNotPositive     = errors.New("ERR Parameter `numFields` should be greater than 0")
+	errHashNumFieldsMismatch        = errors.New("ERR The `numfields` parameter must match the number of arguments")
 )
@@ -1,7 +1,9 @@
 package commands
 
 import (
+	"math"
 	"strconv"
+	"strings"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -56,6 +58,68 @@ func (command *HExists) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.name, command.key, command.field)
 }
 
+// hexpire key seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+// Expiration of fields is supported since redis 7.4, the whole key is removed by redis if all fields expire.
+type HExpireCommand struct {
+	key       string
+	seconds   int64
+	condition string
+	fields    []string
+	commonCommand
+}
+
+func NewHExpireCommand(args []string) (Commander, error) {
+	command := &HExpireCommand{}
+	command.init(args)
+	if len(args) < 6 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	seconds, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, errInvalidInteger
+	}
+	if seconds < 0 {
+		return nil, newInvalidExpireTimeError(command.name)
+	}
+	index := 3
+	switch condition := strings.ToLower(args[index]); condition {
+	case "nx", "xx", "gt", "lt":
+		command.condition = condition
+		index++
+	}
+	fields, err := parseHashFieldsArgs(args[index:])
+	if err != nil {
+		return nil, err
+	}
+	command.key = args[1]
+	command.seconds = seconds
+	command.fields = fields
+	return command, nil
+}
+
+func (command *HExpireCommand) WriteKeys() []string {
+	return []string{command.key}
+}
+
+func (command *HExpireCommand) Cmd() redis.Cmder {
+	return redis.NewIntSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// parseHashFieldsArgs parses `FIELDS numfields field [field ...]` of hash field expiration commands.
+func parseHashFieldsArgs(args []string) ([]string, error) {
+	if len(args) < 2 || strings.ToLower(args[0]) != "fields" {
+		return nil, errHashFieldsArgMissing
+	}
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || count <= 0 {
+		return nil, errHashNumFieldsNotPositive
+	}
+	if count != int64(len(args)-2) {
+		return nil, errHashNumFieldsMismatch
+	}
+	return args[2:], nil
+}
+
 type HGetCommand struct {
 	key   string
 	field string
@@ -96,12 +160,17 @@ func NewHGetAllCommand(args []string) (Commander, error) {
 	return command, nil
 }
 
+func (command *HGetAllCommand) Key() string {
+	return command.key
+}
+
 func (command *HGetAllCommand) ReadKeys() []string {
 	return []string{command.key}
 }
 
+// Cmd of hgetall reads the hash as a map, fields in reply are sorted by ConvertHashToRESPData.
 func (command *HGetAllCommand) Cmd() redis.Cmder {
-	return redis.NewStringSliceCmd(contextTODO, command.name, command.key)
+	return redis.NewStringStringMapCmd(contextTODO, command.name, command.key)
 }
 
 type HIncrByCommand struct {
@@ -267,6 +336,53 @@ func (command *HMSetCommand) Cmd() redis.Cmder {
 	return redis.NewStatusCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
 
+// hrandfield key [count [WITHVALUES]]
+type HRandFieldCommand struct {
+	key        string
+	count      *int64
+	withValues bool
+	commonCommand
+}
+
+func NewHRandFieldCommand(args []string) (Commander, error) {
+	command := &HRandFieldCommand{}
+	command.init(args)
+	if len(args) < 2 || len(args) > 4 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.key = args[1]
+	if len(args) == 2 {
+		return command, nil
+	}
+	if len(args) == 4 {
+		if strings.ToLower(args[3]) != "withvalues" {
+			return nil, errSyntaxError
+		}
+		command.withValues = true
+	}
+	count, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, errInvalidInteger
+	}
+	// count of replied items is doubled with values, it should not overflow like redis.
+	if count == math.MinInt64 || (command.withValues && count < -math.MaxInt64/2) {
+		return nil, errValueOutOfRange
+	}
+	command.count = &count
+	return command, nil
+}
+
+func (command *HRandFieldCommand) ReadKeys() []string {
+	return []string{command.key}
+}
+
+func (command *HRandFieldCommand) Cmd() redis.Cmder {
+	if command.count != nil {
+		return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
+	}
+	return redis.NewStringCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
 type HSetCommand struct {
 	key        string
 	fieldPairs map[string]string
@@ -293,6 +409,14 @@ func (command *HSetCommand) WriteKeys() []string {
 	return []string{command.key}
 }
 
+func (command *HSetCommand) WrittenValueSize() (string, int) {
+	size := 0
+	for field, value := range command.fieldPairs {
+		size += len(field) + len(value)
+	}
+	return ValueTypeHash, size
+}
+
 func (command *HSetCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
@@ -320,6 +444,11 @@ func (command *HSetNXCommand) WriteKeys() []string {
 	return []string{command.key}
 }
 
+// IsWritten returns false if field exists and it is not set, the check and set is atomic in redis.
+func (command *HSetNXCommand) IsWritten(result RESPData) bool {
+	return !(result.DataType == IntegerRespType && result.Value == int64(0))
+}
+
 func (command *HSetNXCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.name, command.key, command.field, command.value)
 }
@@ -349,6 +478,36 @@ func (command *HStrlenCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.name, command.key, command.field)
 }
 
+// httl key FIELDS numfields field [field ...]
+type HTTLCommand struct {
+	key    string
+	fields []string
+	commonCommand
+}
+
+func NewHTTLCommand(args []string) (Commander, error) {
+	command := &HTTLCommand{}
+	command.init(args)
+	if len(args) < 5 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	fields, err := parseHashFieldsArgs(args[2:])
+	if err != nil {
+		return nil, err
+	}
+	command.key = args[1]
+	command.fields = fields
+	return command, nil
+}
+
+func (command *HTTLCommand) ReadKeys() []string {
+	return []string{command.key}
+}
+
+func (command *HTTLCommand) Cmd() redis.Cmder {
+	return redis.NewIntSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
 type HValsCommand struct {
 	key string
 	commonCommand
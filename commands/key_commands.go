@@ -1,11 +1,78 @@
 package commands
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// copy source destination [DB destination-db] [REPLACE]
+// Only db 0 is supported since redis cluster has a single database.
+type CopyCommand struct {
+	source      string
+	destination string
+	replace     bool
+	commonCommand
+}
+
+func NewCopyCommand(args []string) (Commander, error) {
+	command := &CopyCommand{}
+	command.init(args)
+	if len(args) < 3 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.source = args[1]
+	command.destination = args[2]
+	for index := 3; index < len(args); index++ {
+		switch strings.ToLower(args[index]) {
+		case "replace":
+			command.replace = true
+		case "db":
+			index++
+			if index >= len(args) {
+				return nil, errSyntaxError
+			}
+			db, err := strconv.ParseInt(args[index], 10, 64)
+			if err != nil {
+				return nil, errInvalidInteger
+			}
+			if db != 0 {
+				return nil, errCopyToOtherDB
+			}
+		default:
+			return nil, errSyntaxError
+		}
+	}
+	return command, nil
+}
+
+func (command *CopyCommand) Source() string {
+	return command.source
+}
+
+func (command *CopyCommand) Destination() string {
+	return command.destination
+}
+
+func (command *CopyCommand) Replace() bool {
+	return command.replace
+}
+
+func (command *CopyCommand) ReadKeys() []string {
+	return []string{command.source}
+}
+
+func (command *CopyCommand) WriteKeys() []string {
+	return []string{command.destination}
+}
+
+func (command *CopyCommand) Cmd() redis.Cmder {
+	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
 type DelCommand struct {
 	keys []string
 	commonCommand
@@ -29,6 +96,52 @@ func (command *DelCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
 
+// SplitByHashTag splits del into commands of each hash tag, the sum of their results is count of removed keys.
+func (command *DelCommand) SplitByHashTag() ([]Commander, error) {
+	groups, err := splitKeysByHashTag(command.keys)
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]Commander, 0, len(groups))
+	for _, keys := range groups {
+		cmd, err := NewDelCommand(append([]string{command.name}, keys...))
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+// dump key
+// Dump is processed by room server, the serialized value is only restorable by room.
+type DumpCommand struct {
+	key string
+	commonCommand
+}
+
+func NewDumpCommand(args []string) (Commander, error) {
+	command := &DumpCommand{}
+	command.init(args)
+	if len(args) != 2 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.key = args[1]
+	return command, nil
+}
+
+func (command *DumpCommand) Key() string {
+	return command.key
+}
+
+func (command *DumpCommand) ReadKeys() []string {
+	return []string{command.key}
+}
+
+func (command *DumpCommand) Cmd() redis.Cmder {
+	return redis.NewStringCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
 type ExistsCommand struct {
 	keys []string
 	commonCommand
@@ -52,6 +165,24 @@ func (command *ExistsCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
 
+// SplitByHashTag splits exists into commands of each hash tag, the sum of their results is count of existing keys,
+// duplicated keys are counted multiple times like redis.
+func (command *ExistsCommand) SplitByHashTag() ([]Commander, error) {
+	groups, err := splitKeysByHashTag(command.keys)
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]Commander, 0, len(groups))
+	for _, keys := range groups {
+		cmd, err := NewExistsCommand(append([]string{command.name}, keys...))
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
 type ExpireCommand struct {
 	key     string
 	seconds int64
@@ -110,6 +241,75 @@ func (command *ExpireAtCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.name, command.key, command.timestamp)
 }
 
+const (
+	ObjectSubCommandRefCount = "refcount"
+	ObjectSubCommandFreq     = "freq"
+	ObjectSubCommandEncoding = "encoding"
+	ObjectSubCommandIdleTime = "idletime"
+)
+
+func newObjectUnknownSubCommandError(subCommand string) error {
+	return fmt.Errorf("ERR Unknown subcommand or wrong number of arguments for '%s'. Try OBJECT HELP.", subCommand)
+}
+
+// object refcount key
+// object freq key
+// object encoding key
+// object idletime key
+// Object is processed by room server with data in redis since objects are not shared between keys in room.
+// Key of object idletime is not loaded so that access time of its hash tag is not updated.
+type ObjectCommand struct {
+	subCommand string
+	key        string
+	commonCommand
+}
+
+func NewObjectCommand(args []string) (Commander, error) {
+	command := &ObjectCommand{}
+	command.init(args)
+	if len(args) < 2 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.subCommand = strings.ToLower(args[1])
+	switch command.subCommand {
+	case ObjectSubCommandRefCount, ObjectSubCommandFreq, ObjectSubCommandEncoding:
+		if len(args) != 3 {
+			return nil, newObjectUnknownSubCommandError(args[1])
+		}
+		command.key = args[2]
+	case ObjectSubCommandIdleTime:
+		if len(args) != 3 {
+			return nil, newObjectUnknownSubCommandError(args[1])
+		}
+		if ExtractHashTagFromKey(args[2]) == "" {
+			return nil, errCommandKeyNoHashTag
+		}
+		command.key = args[2]
+	default:
+		return nil, newObjectUnknownSubCommandError(args[1])
+	}
+	return command, nil
+}
+
+func (command *ObjectCommand) SubCommand() string {
+	return command.subCommand
+}
+
+func (command *ObjectCommand) Key() string {
+	return command.key
+}
+
+func (command *ObjectCommand) ReadKeys() []string {
+	if command.subCommand == ObjectSubCommandIdleTime {
+		return []string{}
+	}
+	return []string{command.key}
+}
+
+func (command *ObjectCommand) Cmd() redis.Cmder {
+	return redis.NewCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
 type PersistCommand struct {
 	key string
 	commonCommand
@@ -264,6 +464,65 @@ func (command *RenameNXCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.name, command.key, command.newKey)
 }
 
+// restore key ttl serialized-value [REPLACE]
+// ttl is in milliseconds, key is created without expiration if ttl is 0.
+type RestoreCommand struct {
+	key     string
+	ttl     int64
+	value   string
+	replace bool
+	commonCommand
+}
+
+func NewRestoreCommand(args []string) (Commander, error) {
+	command := &RestoreCommand{}
+	command.init(args)
+	if len(args) < 4 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.key = args[1]
+	ttl, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, errInvalidInteger
+	}
+	if ttl < 0 {
+		return nil, errInvalidTTL
+	}
+	command.ttl = ttl
+	command.value = args[3]
+	for _, option := range args[4:] {
+		if strings.ToLower(option) != "replace" {
+			return nil, errSyntaxError
+		}
+		command.replace = true
+	}
+	return command, nil
+}
+
+func (command *RestoreCommand) Key() string {
+	return command.key
+}
+
+func (command *RestoreCommand) TTL() time.Duration {
+	return time.Duration(command.ttl) * time.Millisecond
+}
+
+func (command *RestoreCommand) Value() string {
+	return command.value
+}
+
+func (command *RestoreCommand) Replace() bool {
+	return command.replace
+}
+
+func (command *RestoreCommand) WriteKeys() []string {
+	return []string{command.key}
+}
+
+func (command *RestoreCommand) Cmd() redis.Cmder {
+	return redis.NewStatusCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
 type TTLCommand struct {
 	key string
 	commonCommand
@@ -309,3 +568,29 @@ func (command *TypeCommand) ReadKeys() []string {
 func (command *TypeCommand) Cmd() redis.Cmder {
 	return redis.NewStatusCmd(contextTODO, command.name, command.key)
 }
+
+// keys pattern
+// The command is processed by room server, it scans keys of all hash tags saved in db,
+// so keys not synced to db yet are not returned.
+type KeysCommand struct {
+	pattern string
+	commonCommand
+}
+
+func NewKeysCommand(args []string) (Commander, error) {
+	command := &KeysCommand{}
+	command.init(args)
+	if len(args) != 2 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.pattern = args[1]
+	return command, nil
+}
+
+func (command *KeysCommand) Pattern() string {
+	return command.pattern
+}
+
+func (command *KeysCommand) Cmd() redis.Cmder {
+	return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
@@ -204,6 +204,10 @@ func (command *LPushCommand) WriteKeys() []string {
 	return []string{command.key}
 }
 
+func (command *LPushCommand) WrittenValueSize() (string, int) {
+	return ValueTypeList, stringsSize(command.elements)
+}
+
 func (command *LPushCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
@@ -229,6 +233,11 @@ func (command *LPushXCommand) WriteKeys() []string {
 	return []string{command.key}
 }
 
+// IsWritten returns false if key does not exist and nothing is pushed, the check and push is atomic in redis.
+func (command *LPushXCommand) IsWritten(result RESPData) bool {
+	return !(result.DataType == IntegerRespType && result.Value == int64(0))
+}
+
 func (command *LPushXCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
@@ -462,6 +471,10 @@ func (command *RPushCommand) WriteKeys() []string {
 	return []string{command.key}
 }
 
+func (command *RPushCommand) WrittenValueSize() (string, int) {
+	return ValueTypeList, stringsSize(command.elements)
+}
+
 func (command *RPushCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
@@ -487,6 +500,11 @@ func (command *RPushXCommand) WriteKeys() []string {
 	return []string{command.key}
 }
 
+// IsWritten returns false if key does not exist and nothing is pushed, the check and push is atomic in redis.
+func (command *RPushXCommand) IsWritten(result RESPData) bool {
+	return !(result.DataType == IntegerRespType && result.Value == int64(0))
+}
+
 func (command *RPushXCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	PubSubSubCommandChannels = "channels"
+	PubSubSubCommandNumSub   = "numsub"
+	PubSubSubCommandNumPat   = "numpat"
+)
+
+func newPubSubUnknownSubCommandError(subCommand string) error {
+	return fmt.Errorf("ERR Unknown subcommand or wrong number of arguments for '%s'. Try PUBSUB HELP.", subCommand)
+}
+
+// Pub/Sub commands are processed by room server instead of redis,
+// messages are only delivered to subscribers connected to the same room server.
+
+// subscribe channel [channel ...]
+type SubscribeCommand struct {
+	channels []string
+	commonCommand
+}
+
+func NewSubscribeCommand(args []string) (Commander, error) {
+	command := &SubscribeCommand{}
+	command.init(args)
+	if len(args) < 2 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.channels = args[1:]
+	return command, nil
+}
+
+func (command *SubscribeCommand) Channels() []string {
+	return command.channels
+}
+
+func (command *SubscribeCommand) Cmd() redis.Cmder {
+	return redis.NewCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// unsubscribe [channel [channel ...]]
+// all channels are unsubscribed if no channel is given.
+type UnsubscribeCommand struct {
+	channels []string
+	commonCommand
+}
+
+func NewUnsubscribeCommand(args []string) (Commander, error) {
+	command := &UnsubscribeCommand{}
+	command.init(args)
+	command.channels = args[1:]
+	return command, nil
+}
+
+func (command *UnsubscribeCommand) Channels() []string {
+	return command.channels
+}
+
+func (command *UnsubscribeCommand) Cmd() redis.Cmder {
+	return redis.NewCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// publish channel message
+type PublishCommand struct {
+	channel string
+	message string
+	commonCommand
+}
+
+func NewPublishCommand(args []string) (Commander, error) {
+	command := &PublishCommand{}
+	command.init(args)
+	if len(args) != 3 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.channel = args[1]
+	command.message = args[2]
+	return command, nil
+}
+
+func (command *PublishCommand) Channel() string {
+	return command.channel
+}
+
+func (command *PublishCommand) Message() string {
+	return command.message
+}
+
+func (command *PublishCommand) Cmd() redis.Cmder {
+	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// pubsub channels [pattern]
+// pubsub numsub [channel [channel ...]]
+// pubsub numpat
+type PubSubCommand struct {
+	subCommand string
+	pattern    string
+	channels   []string
+	commonCommand
+}
+
+func NewPubSubCommand(args []string) (Commander, error) {
+	command := &PubSubCommand{}
+	command.init(args)
+	if len(args) < 2 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.subCommand = strings.ToLower(args[1])
+	switch command.subCommand {
+	case PubSubSubCommandChannels:
+		if len(args) > 3 {
+			return nil, newPubSubUnknownSubCommandError(args[1])
+		}
+		if len(args) == 3 {
+			command.pattern = args[2]
+		}
+	case PubSubSubCommandNumSub:
+		command.channels = args[2:]
+	case PubSubSubCommandNumPat:
+		if len(args) != 2 {
+			return nil, newPubSubUnknownSubCommandError(args[1])
+		}
+	default:
+		return nil, newPubSubUnknownSubCommandError(args[1])
+	}
+	return command, nil
+}
+
+func (command *PubSubCommand) SubCommand() string {
+	return command.subCommand
+}
+
+func (command *PubSubCommand) Pattern() string {
+	return command.pattern
+}
+
+func (command *PubSubCommand) Channels() []string {
+	return command.channels
+}
+
+func (command *PubSubCommand) Cmd() redis.Cmder {
+	return redis.NewCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RoomEvictCommand evicts all keys of the hash tag of key from redis.
+// room.evict <key> [force]
+// It is processed by room server instead of redis, so keys are not exposed
+// by ReadKeys and WriteKeys to avoid loading the hash tag before eviction.
+type RoomEvictCommand struct {
+	key   string
+	force bool
+	commonCommand
+}
+
+func NewRoomEvictCommand(args []string) (Commander, error) {
+	command := &RoomEvictCommand{}
+	command.init(args)
+	if len(args) != 2 && len(args) != 3 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	if len(args) == 3 {
+		if strings.ToLower(args[2]) != "force" {
+			return nil, errSyntaxError
+		}
+		command.force = true
+	}
+	command.key = args[1]
+	return command, nil
+}
+
+func (command *RoomEvictCommand) Key() string {
+	return command.key
+}
+
+func (command *RoomEvictCommand) HashTag() (string, error) {
+	hashTag := ExtractHashTagFromKey(command.key)
+	if hashTag == "" {
+		return "", errCommandKeyNoHashTag
+	}
+	return hashTag, nil
+}
+
+func (command *RoomEvictCommand) Force() bool {
+	return command.force
+}
+
+func (command *RoomEvictCommand) Cmd() redis.Cmder {
+	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// RoomResetStatCommand resets counters of room server shown by info command.
+// room.resetstat
+type RoomResetStatCommand struct {
+	commonCommand
+}
+
+func NewRoomResetStatCommand(args []string) (Commander, error) {
+	command := &RoomResetStatCommand{}
+	command.init(args)
+	if len(args) != 1 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	return command, nil
+}
+
+func (command *RoomResetStatCommand) Cmd() redis.Cmder {
+	return redis.NewStatusCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	ScriptSubCommandLoad   = "load"
+	ScriptSubCommandExists = "exists"
+)
+
+var (
+	errScriptNumKeysNegative = errors.New("ERR Number of keys can't be negative")
+	errScriptNumKeysTooLarge = errors.New("ERR Number of keys can't be greater than number of args")
+)
+
+func newScriptUnknownSubCommandError(subCommand string) error {
+	return fmt.Errorf("ERR Unknown subcommand or wrong number of arguments for '%s'. Try SCRIPT HELP.", subCommand)
+}
+
+// Scripts are executed by room server instead of redis,
+// commands called by a script are sent to redis one by one.
+
+// parseScriptKeysAndArgs parses `numkeys key [key ...] arg [arg ...]` of eval and evalsha.
+func parseScriptKeysAndArgs(args []string) ([]string, []string, error) {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, nil, errInvalidInteger
+	}
+	if numKeys < 0 {
+		return nil, nil, errScriptNumKeysNegative
+	}
+	if numKeys > len(args)-1 {
+		return nil, nil, errScriptNumKeysTooLarge
+	}
+	return args[1 : numKeys+1], args[numKeys+1:], nil
+}
+
+// eval script numkeys key [key ...] arg [arg ...]
+type EvalCommand struct {
+	script     string
+	keys       []string
+	scriptArgs []string
+	commonCommand
+}
+
+func NewEvalCommand(args []string) (Commander, error) {
+	command := &EvalCommand{}
+	command.init(args)
+	if len(args) < 3 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	keys, scriptArgs, err := parseScriptKeysAndArgs(args[2:])
+	if err != nil {
+		return nil, err
+	}
+	command.script = args[1]
+	command.keys = keys
+	command.scriptArgs = scriptArgs
+	return command, nil
+}
+
+func (command *EvalCommand) Script() string {
+	return command.script
+}
+
+func (command *EvalCommand) Keys() []string {
+	return command.keys
+}
+
+func (command *EvalCommand) ScriptArgs() []string {
+	return command.scriptArgs
+}
+
+// keys of a script are regarded as written since the script may modify them.
+func (command *EvalCommand) WriteKeys() []string {
+	return command.keys
+}
+
+func (command *EvalCommand) Cmd() redis.Cmder {
+	return redis.NewCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// evalsha sha1 numkeys key [key ...] arg [arg ...]
+type EvalShaCommand struct {
+	sha1       string
+	keys       []string
+	scriptArgs []string
+	commonCommand
+}
+
+func NewEvalShaCommand(args []string) (Commander, error) {
+	command := &EvalShaCommand{}
+	command.init(args)
+	if len(args) < 3 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	keys, scriptArgs, err := parseScriptKeysAndArgs(args[2:])
+	if err != nil {
+		return nil, err
+	}
+	command.sha1 = strings.ToLower(args[1])
+	command.keys = keys
+	command.scriptArgs = scriptArgs
+	return command, nil
+}
+
+func (command *EvalShaCommand) SHA1() string {
+	return command.sha1
+}
+
+func (command *EvalShaCommand) Keys() []string {
+	return command.keys
+}
+
+func (command *EvalShaCommand) ScriptArgs() []string {
+	return command.scriptArgs
+}
+
+func (command *EvalShaCommand) WriteKeys() []string {
+	return command.keys
+}
+
+func (command *EvalShaCommand) Cmd() redis.Cmder {
+	return redis.NewCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// script load script
+// script exists sha1 [sha1 ...]
+type ScriptCommand struct {
+	subCommand string
+	script     string
+	sha1s      []string
+	commonCommand
+}
+
+func NewScriptCommand(args []string) (Commander, error) {
+	command := &ScriptCommand{}
+	command.init(args)
+	if len(args) < 2 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.subCommand = strings.ToLower(args[1])
+	switch command.subCommand {
+	case ScriptSubCommandLoad:
+		if len(args) != 3 {
+			return nil, newScriptUnknownSubCommandError(args[1])
+		}
+		command.script = args[2]
+	case ScriptSubCommandExists:
+		if len(args) < 3 {
+			return nil, newScriptUnknownSubCommandError(args[1])
+		}
+		for _, sha1 := range args[2:] {
+			command.sha1s = append(command.sha1s, strings.ToLower(sha1))
+		}
+	default:
+		return nil, newScriptUnknownSubCommandError(args[1])
+	}
+	return command, nil
+}
+
+func (command *ScriptCommand) SubCommand() string {
+	return command.subCommand
+}
+
+func (command *ScriptCommand) Script() string {
+	return command.script
+}
+
+func (command *ScriptCommand) SHA1s() []string {
+	return command.sha1s
+}
+
+func (command *ScriptCommand) Cmd() redis.Cmder {
+	return redis.NewCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
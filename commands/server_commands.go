@@ -2,6 +2,11 @@ package commands
 
 import (
 	"bytepower_room/utility"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -98,3 +103,192 @@ func (command *PingCommand) Cmd() redis.Cmder {
 	}
 	return redis.NewStringCmd(contextTODO, command.name, *command.message)
 }
+
+// info [section [section ...]]
+// The command is processed by room server, it returns sections of stats of the room server,
+// sections are case-insensitive and unknown sections are ignored.
+type InfoCommand struct {
+	sections []string
+	commonCommand
+}
+
+func NewInfoCommand(args []string) (Commander, error) {
+	command := &InfoCommand{}
+	command.init(args)
+	for _, section := range args[1:] {
+		command.sections = append(command.sections, strings.ToLower(section))
+	}
+	return command, nil
+}
+
+// Sections returns lower case sections of the command, it is empty if no section is specified.
+func (command *InfoCommand) Sections() []string {
+	return command.sections
+}
+
+func (command *InfoCommand) Cmd() redis.Cmder {
+	return redis.NewStringCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// wait numreplicas timeout
+// The command is processed by room server, it waits for read replicas of db to replay the latest writes.
+// It blocks until numreplicas replicas have caught up or timeout in milliseconds is reached,
+// 0 timeout blocks forever.
+type WaitCommand struct {
+	numReplicas int
+	timeout     time.Duration
+	commonCommand
+}
+
+func NewWaitCommand(args []string) (Commander, error) {
+	command := &WaitCommand{}
+	command.init(args)
+	if len(args) != 3 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	numReplicas, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, errInvalidInteger
+	}
+	timeout, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, errTimeoutNotInteger
+	}
+	if timeout < 0 {
+		return nil, errTimeoutNegative
+	}
+	command.numReplicas = numReplicas
+	command.timeout = time.Duration(timeout) * time.Millisecond
+	return command, nil
+}
+
+func (command *WaitCommand) NumReplicas() int {
+	return command.numReplicas
+}
+
+func (command *WaitCommand) Timeout() time.Duration {
+	return command.timeout
+}
+
+func (command *WaitCommand) Cmd() redis.Cmder {
+	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// reset
+// The command is processed by room server, it resets the connection to its initial state
+// without closing it.
+type ResetCommand struct {
+	commonCommand
+}
+
+func NewResetCommand(args []string) (Commander, error) {
+	command := &ResetCommand{}
+	command.init(args)
+	if len(args) != 1 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	return command, nil
+}
+
+func (command *ResetCommand) Cmd() redis.Cmder {
+	return redis.NewStatusCmd(contextTODO, command.name)
+}
+
+const (
+	DebugSubCommandSleep           = "sleep"
+	DebugSubCommandObject          = "object"
+	DebugSubCommandSetActiveExpire = "set-active-expire"
+)
+
+func newDebugUnknownSubCommandError(subCommand string) error {
+	return fmt.Errorf("ERR Unknown subcommand or wrong number of arguments for '%s'. Try DEBUG HELP.", subCommand)
+}
+
+// debug sleep seconds
+// debug object key
+// debug set-active-expire 0|1
+// The command is processed by room server and only used for testing,
+// seconds could be a float number, e.g. 0.1.
+// Key of debug object is not loaded so that access time of its hash tag is not updated.
+// debug set-active-expire is sent to all master nodes of redis cluster.
+type DebugCommand struct {
+	subCommand   string
+	duration     time.Duration
+	key          string
+	activeExpire bool
+	commonCommand
+}
+
+func NewDebugCommand(args []string) (Commander, error) {
+	command := &DebugCommand{}
+	command.init(args)
+	if len(args) < 2 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.subCommand = strings.ToLower(args[1])
+	switch command.subCommand {
+	case DebugSubCommandSleep:
+		if len(args) != 3 {
+			return nil, newDebugUnknownSubCommandError(args[1])
+		}
+		seconds, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return nil, errInvalidFloat
+		}
+		if seconds < 0 {
+			return nil, errTimeoutNegative
+		}
+		command.duration = time.Duration(seconds * float64(time.Second))
+	case DebugSubCommandObject:
+		if len(args) != 3 {
+			return nil, newDebugUnknownSubCommandError(args[1])
+		}
+		if ExtractHashTagFromKey(args[2]) == "" {
+			return nil, errCommandKeyNoHashTag
+		}
+		command.key = args[2]
+	case DebugSubCommandSetActiveExpire:
+		if len(args) != 3 {
+			return nil, newDebugUnknownSubCommandError(args[1])
+		}
+		switch args[2] {
+		case "0":
+			command.activeExpire = false
+		case "1":
+			command.activeExpire = true
+		default:
+			return nil, errInvalidInteger
+		}
+	default:
+		return nil, newDebugUnknownSubCommandError(args[1])
+	}
+	return command, nil
+}
+
+func (command *DebugCommand) SubCommand() string {
+	return command.subCommand
+}
+
+func (command *DebugCommand) Key() string {
+	return command.key
+}
+
+func (command *DebugCommand) ActiveExpire() bool {
+	return command.activeExpire
+}
+
+// Sleep pauses for duration of the command, it returns early if ctx is done.
+func (command *DebugCommand) Sleep(ctx context.Context) RESPData {
+	timer := time.NewTimer(command.duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return ConvertErrorToRESPData(ctx.Err())
+	}
+	return RESPData{DataType: SimpleStringRespType, Value: "OK"}
+}
+
+func (command *DebugCommand) Cmd() redis.Cmder {
+	return redis.NewStatusCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
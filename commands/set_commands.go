@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"math"
 	"strconv"
 
 	"github.com/go-redis/redis/v8"
@@ -27,6 +28,10 @@ func (command *SAddCommand) WriteKeys() []string {
 	return []string{command.key}
 }
 
+func (command *SAddCommand) WrittenValueSize() (string, int) {
+	return ValueTypeSet, stringsSize(command.members)
+}
+
 func (command *SAddCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
@@ -54,110 +59,6 @@ func (command *SCardCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.name, command.key)
 }
 
-type SDiffCommand struct {
-	keys []string
-	commonCommand
-}
-
-func NewSDiffCommand(args []string) (Commander, error) {
-	command := &SDiffCommand{}
-	command.init(args)
-	if len(args) < 2 {
-		return nil, newWrongNumberOfArgumentsError(command.name)
-	}
-	command.keys = args[1:]
-	return command, nil
-}
-
-func (command *SDiffCommand) ReadKeys() []string {
-	return command.keys
-}
-
-func (command *SDiffCommand) Cmd() redis.Cmder {
-	return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
-}
-
-type SDiffStoreCommand struct {
-	destKey    string
-	sourceKeys []string
-	commonCommand
-}
-
-func NewSDiffStoreCommand(args []string) (Commander, error) {
-	command := &SDiffStoreCommand{}
-	command.init(args)
-	if len(args) < 3 {
-		return nil, newWrongNumberOfArgumentsError(command.name)
-	}
-	command.destKey = args[1]
-	command.sourceKeys = args[2:]
-	return command, nil
-}
-
-func (command *SDiffStoreCommand) WriteKeys() []string {
-	return []string{command.destKey}
-}
-
-func (command *SDiffStoreCommand) ReadKeys() []string {
-	return command.sourceKeys
-}
-
-func (command *SDiffStoreCommand) Cmd() redis.Cmder {
-	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
-}
-
-type SInterCommand struct {
-	keys []string
-	commonCommand
-}
-
-func NewSInterCommand(args []string) (Commander, error) {
-	command := &SInterCommand{}
-	command.init(args)
-	if len(args) == 1 {
-		return nil, newWrongNumberOfArgumentsError(command.name)
-	}
-	command.keys = args[1:]
-	return command, nil
-}
-
-func (command *SInterCommand) ReadKeys() []string {
-	return command.keys
-}
-
-func (command *SInterCommand) Cmd() redis.Cmder {
-	return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
-}
-
-type SInterStoreCommand struct {
-	destKey    string
-	sourceKeys []string
-	commonCommand
-}
-
-func NewSInterStoreCommand(args []string) (Commander, error) {
-	command := &SInterStoreCommand{}
-	command.init(args)
-	if len(args) < 3 {
-		return nil, newWrongNumberOfArgumentsError(command.name)
-	}
-	command.destKey = args[1]
-	command.sourceKeys = args[2:]
-	return command, nil
-}
-
-func (command *SInterStoreCommand) WriteKeys() []string {
-	return []string{command.destKey}
-}
-
-func (command *SInterStoreCommand) ReadKeys() []string {
-	return command.sourceKeys
-}
-
-func (command *SInterStoreCommand) Cmd() redis.Cmder {
-	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
-}
-
 type SIsMemberCommand struct {
 	key    string
 	member string
@@ -277,7 +178,7 @@ func NewSPopCommand(args []string) (Commander, error) {
 			return nil, errInvalidInteger
 		}
 		if count < 0 {
-			return nil, errInvalidIndex
+			return nil, errValueNotPositive
 		}
 		command.count = &count
 	}
@@ -313,6 +214,10 @@ func NewSRandMemberCommand(args []string) (Commander, error) {
 		if err != nil {
 			return nil, errInvalidInteger
 		}
+		// negative count is negated for count of replied members, it should not overflow like redis.
+		if count == math.MinInt64 {
+			return nil, errValueOutOfRange
+		}
 		command.count = &count
 	}
 	return command, nil
@@ -354,54 +259,95 @@ func (command *SRemCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
 
-type SUnionCommand struct {
-	keys []string
+// sdiff key [key ...]
+// sdiffstore destination key [key ...]
+// sinter key [key ...]
+// sinterstore destination key [key ...]
+// sunion key [key ...]
+// sunionstore destination key [key ...]
+// These commands are processed by room server, so that keys could have different hash tags,
+// commands of keys in one slot of redis cluster are processed by redis.
+type SetAggregateCommand struct {
+	operation   string
+	destination string
+	keys        []string
 	commonCommand
 }
 
-func NewSUnionCommand(args []string) (Commander, error) {
-	command := &SUnionCommand{}
+func newSetAggregateCommand(args []string, operation string, store bool) (Commander, error) {
+	command := &SetAggregateCommand{operation: operation}
 	command.init(args)
-	if len(args) < 2 {
+	index := 1
+	if store {
+		if len(args) < 3 {
+			return nil, newWrongNumberOfArgumentsError(command.name)
+		}
+		command.destination = args[index]
+		index++
+	} else if len(args) < 2 {
 		return nil, newWrongNumberOfArgumentsError(command.name)
 	}
-	command.keys = args[1:]
+	command.keys = args[index:]
 	return command, nil
 }
 
-func (command *SUnionCommand) ReadKeys() []string {
-	return command.keys
+func NewSDiffCommand(args []string) (Commander, error) {
+	return newSetAggregateCommand(args, ZSetAggregateDiff, false)
 }
 
-func (command *SUnionCommand) Cmd() redis.Cmder {
-	return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
+func NewSDiffStoreCommand(args []string) (Commander, error) {
+	return newSetAggregateCommand(args, ZSetAggregateDiff, true)
 }
 
-type SUnionStoreCommand struct {
-	destKey    string
-	sourceKeys []string
-	commonCommand
+func NewSInterCommand(args []string) (Commander, error) {
+	return newSetAggregateCommand(args, ZSetAggregateInter, false)
+}
+
+func NewSInterStoreCommand(args []string) (Commander, error) {
+	return newSetAggregateCommand(args, ZSetAggregateInter, true)
+}
+
+func NewSUnionCommand(args []string) (Commander, error) {
+	return newSetAggregateCommand(args, ZSetAggregateUnion, false)
 }
 
 func NewSUnionStoreCommand(args []string) (Commander, error) {
-	command := &SUnionStoreCommand{}
-	command.init(args)
-	if len(args) <= 3 {
-		return nil, newWrongNumberOfArgumentsError(command.name)
-	}
-	command.destKey = args[1]
-	command.sourceKeys = args[2:]
-	return command, nil
+	return newSetAggregateCommand(args, ZSetAggregateUnion, true)
 }
 
-func (command *SUnionStoreCommand) ReadKeys() []string {
-	return command.sourceKeys
+// Operation returns one of ZSetAggregateDiff, ZSetAggregateInter and ZSetAggregateUnion.
+func (command *SetAggregateCommand) Operation() string {
+	return command.operation
 }
 
-func (command *SUnionStoreCommand) WriteKeys() []string {
-	return []string{command.destKey}
+// Destination returns destination key of store variants, it is empty for others.
+func (command *SetAggregateCommand) Destination() string {
+	return command.destination
 }
 
-func (command *SUnionStoreCommand) Cmd() redis.Cmder {
-	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
+func (command *SetAggregateCommand) Keys() []string {
+	return command.keys
+}
+
+func (command *SetAggregateCommand) ReadKeys() []string {
+	return command.keys
+}
+
+func (command *SetAggregateCommand) WriteKeys() []string {
+	if command.destination == "" {
+		return []string{}
+	}
+	return []string{command.destination}
+}
+
+func (command *SetAggregateCommand) Cmd() redis.Cmder {
+	if command.destination != "" {
+		return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
+	}
+	return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// SplitByHashTag splits command into commands of each hash tag if its keys have different hash tags.
+func (command *SetAggregateCommand) SplitByHashTag() ([]Commander, error) {
+	return splitCommandKeysByHashTag(command)
 }
@@ -0,0 +1,197 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// xadd key [MAXLEN [~|=] count] *|id field value [field value ...]
+type XAddCommand struct {
+	key    string
+	id     string
+	fields []string
+	commonCommand
+}
+
+func NewXAddCommand(args []string) (Commander, error) {
+	command := &XAddCommand{}
+	command.init(args)
+	if len(args) < 5 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.key = args[1]
+	index := 2
+	if strings.ToLower(args[index]) == "maxlen" {
+		index++
+		if index < len(args) && (args[index] == "~" || args[index] == "=") {
+			index++
+		}
+		if index >= len(args) {
+			return nil, errSyntaxError
+		}
+		count, err := strconv.ParseInt(args[index], 10, 64)
+		if err != nil || count < 0 {
+			return nil, errInvalidInteger
+		}
+		index++
+	}
+	if index >= len(args) {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.id = args[index]
+	command.fields = args[index+1:]
+	if len(command.fields) == 0 || len(command.fields)%2 != 0 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	return command, nil
+}
+
+func (command *XAddCommand) WriteKeys() []string {
+	return []string{command.key}
+}
+
+func (command *XAddCommand) Cmd() redis.Cmder {
+	return redis.NewStringCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// xlen key
+type XLenCommand struct {
+	key string
+	commonCommand
+}
+
+func NewXLenCommand(args []string) (Commander, error) {
+	command := &XLenCommand{}
+	command.init(args)
+	if len(args) != 2 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.key = args[1]
+	return command, nil
+}
+
+func (command *XLenCommand) ReadKeys() []string {
+	return []string{command.key}
+}
+
+func (command *XLenCommand) Cmd() redis.Cmder {
+	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// xrange key start end [COUNT count]
+// xrevrange key end start [COUNT count]
+type XRangeCommand struct {
+	key   string
+	start string
+	end   string
+	count *int64
+	commonCommand
+}
+
+func newXRangeCommand(args []string) (*XRangeCommand, error) {
+	command := &XRangeCommand{}
+	command.init(args)
+	if len(args) != 4 && len(args) != 6 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.key = args[1]
+	command.start = args[2]
+	command.end = args[3]
+	if len(args) == 6 {
+		if strings.ToLower(args[4]) != "count" {
+			return nil, errSyntaxError
+		}
+		count, err := strconv.ParseInt(args[5], 10, 64)
+		if err != nil {
+			return nil, errInvalidInteger
+		}
+		command.count = &count
+	}
+	return command, nil
+}
+
+func NewXRangeCommand(args []string) (Commander, error) {
+	command, err := newXRangeCommand(args)
+	if err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+func (command *XRangeCommand) ReadKeys() []string {
+	return []string{command.key}
+}
+
+func (command *XRangeCommand) Cmd() redis.Cmder {
+	return redis.NewSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+type XRevRangeCommand struct {
+	XRangeCommand
+}
+
+func NewXRevRangeCommand(args []string) (Commander, error) {
+	command, err := newXRangeCommand(args)
+	if err != nil {
+		return nil, err
+	}
+	return &XRevRangeCommand{XRangeCommand: *command}, nil
+}
+
+// xread [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+// The command is blocked in redis with BLOCK,
+// so milliseconds should be less than read timeout of redis connections.
+type XReadCommand struct {
+	keys []string
+	ids  []string
+	commonCommand
+}
+
+func NewXReadCommand(args []string) (Commander, error) {
+	command := &XReadCommand{}
+	command.init(args)
+	if len(args) < 4 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	index := 1
+	for ; index < len(args); index++ {
+		option := strings.ToLower(args[index])
+		if option == "streams" {
+			break
+		}
+		if option != "count" && option != "block" {
+			return nil, errSyntaxError
+		}
+		index++
+		if index >= len(args) {
+			return nil, errSyntaxError
+		}
+		value, err := strconv.ParseInt(args[index], 10, 64)
+		if err != nil {
+			return nil, errInvalidInteger
+		}
+		if option == "block" && value < 0 {
+			return nil, errTimeoutNegative
+		}
+	}
+	if index >= len(args) {
+		return nil, errSyntaxError
+	}
+	streams := args[index+1:]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		return nil, errXReadUnbalancedStreams
+	}
+	command.keys = streams[:len(streams)/2]
+	command.ids = streams[len(streams)/2:]
+	return command, nil
+}
+
+func (command *XReadCommand) ReadKeys() []string {
+	return command.keys
+}
+
+func (command *XReadCommand) Cmd() redis.Cmder {
+	return redis.NewSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"math"
 	"strconv"
 	"strings"
 
@@ -40,29 +41,38 @@ func NewSetCommand(args []string) (Commander, error) {
 	return command, nil
 }
 
+// parseOtherOptions parses [EX seconds|PX milliseconds|EXAT timestamp|PXAT milliseconds-timestamp|KEEPTTL] [NX|XX] [GET],
+// an option could be repeated, but conflicting options are syntax errors like redis.
 func (command *SetCommand) parseOtherOptions(options []string) error {
 	for len(options) != 0 {
 		item := strings.ToLower(options[0])
 		switch item {
-		case "ex", "px":
-			if len(options) < 2 {
+		case "ex", "px", "exat", "pxat":
+			if len(options) < 2 || (command.expireUnit != "" && command.expireUnit != item) {
 				return errSyntaxError
 			}
-			command.expireUnit = item
-			i, err := strconv.Atoi(options[1])
+			i, err := strconv.ParseInt(options[1], 10, 64)
 			if err != nil {
 				return errInvalidInteger
 			}
-			command.expire = int64(i)
+			if i <= 0 {
+				return newInvalidExpireTimeError(command.name)
+			}
+			command.expireUnit = item
+			command.expire = i
 			options = options[2:]
 		case "keepttl":
+			if command.expireUnit != "" && command.expireUnit != item {
+				return errSyntaxError
+			}
+			command.expireUnit = item
 			command.expire = -1
 			options = options[1:]
-		case "nx":
-			command.existMode = keyExistModeNX
-			options = options[1:]
-		case "xx":
-			command.existMode = keyExistModeXX
+		case "nx", "xx":
+			if command.existMode != "" && command.existMode != keyExistMode(item) {
+				return errSyntaxError
+			}
+			command.existMode = keyExistMode(item)
 			options = options[1:]
 		case "get":
 			command.returnOld = true
@@ -89,6 +99,10 @@ func (command *SetCommand) WriteKeys() []string {
 	return []string{command.key}
 }
 
+func (command *SetCommand) WrittenValueSize() (string, int) {
+	return ValueTypeString, len(command.value)
+}
+
 type GetCommand struct {
 	key string
 	commonCommand
@@ -141,6 +155,10 @@ func (command *AppendCommand) ReadKeys() []string {
 	return []string{}
 }
 
+func (command *AppendCommand) WrittenValueSize() (string, int) {
+	return ValueTypeString, len(command.value)
+}
+
 func (command *AppendCommand) Cmd() redis.Cmder {
 	return redis.NewIntCmd(contextTODO, command.name, command.key, command.value)
 }
@@ -215,6 +233,10 @@ func NewDecrByCommand(args []string) (Commander, error) {
 	if err != nil {
 		return nil, errInvalidInteger
 	}
+	// decrement is negated to increment in redis, which overflows for math.MinInt64.
+	if decrement == math.MinInt64 {
+		return nil, errDecrementOverflow
+	}
 	command.key = args[1]
 	command.decrement = decrement
 	return command, nil
@@ -442,13 +464,16 @@ type PSetEXCommand struct {
 func NewPSetEXCommand(args []string) (Commander, error) {
 	command := &PSetEXCommand{}
 	command.init(args)
-	if len(args) != 3 {
+	if len(args) != 4 {
 		return nil, newWrongNumberOfArgumentsError(command.name)
 	}
 	d, err := strconv.ParseInt(args[2], 10, 64)
 	if err != nil {
 		return nil, errInvalidInteger
 	}
+	if d <= 0 {
+		return nil, newInvalidExpireTimeError(command.name)
+	}
 	command.key = args[1]
 	command.value = args[3]
 	command.milliseconds = d
@@ -467,7 +492,7 @@ type SetEXCommand struct {
 	key     string
 	value   string
 	seconds int64
-	CommandCommand
+	commonCommand
 }
 
 func NewSetEXCommand(args []string) (Commander, error) {
@@ -480,6 +505,9 @@ func NewSetEXCommand(args []string) (Commander, error) {
 	if err != nil {
 		return nil, errInvalidInteger
 	}
+	if d <= 0 {
+		return nil, newInvalidExpireTimeError(command.name)
+	}
 	command.key = args[1]
 	command.value = args[3]
 	command.seconds = d
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -15,7 +17,6 @@ type TransactionCloseReason string
 const (
 	TransactionCloseReasonTxClosed                 TransactionCloseReason = "transaction is closed"
 	TransactionCloseReasonConnClosed               TransactionCloseReason = "connection is closed"
-	TransactionCloseReasonInvalidCommand           TransactionCloseReason = "command is invalid"
 	TransactionCloseReasonDiscard                  TransactionCloseReason = "execute discard command"
 	TransactionCloseReasonUnwatch                  TransactionCloseReason = "execute unwatch command"
 	TransactionCloseReasonExec                     TransactionCloseReason = "execute exec command"
@@ -23,6 +24,11 @@ const (
 	TransactionCloseReasonResetInWatch             TransactionCloseReason = "reset old transaction in watch command"
 	TransactionCloseReasonResetInExec              TransactionCloseReason = "reset old transaction in exec command"
 	TransactionCloseReasonWatchedKeysNotInSameSlot TransactionCloseReason = "watched keys not in the same slot"
+	TransactionCloseReasonTimeout                  TransactionCloseReason = "transaction is timeout"
+	TransactionCloseReasonResetConn                TransactionCloseReason = "execute reset command"
+	TransactionCloseReasonExecAbort                TransactionCloseReason = "execute exec command of aborted transaction"
+	TransactionCloseReasonWatchedVersionChanged    TransactionCloseReason = "room data of watched keys changed in db"
+	TransactionCloseReasonWatchVersionFailed       TransactionCloseReason = "failed to load room data version of watched keys"
 )
 
 type TransactionStatus string
@@ -36,18 +42,107 @@ const (
 type Transaction struct {
 	tx          *redis.Tx
 	watchedKeys []string
+	// watchedVersions records version of room data in db for every watched hash tag,
+	// key is hash tag, value is version or RoomDataVersionNotExist if the row does not exist.
+	watchedVersions map[string]int
+	// loadVersion loads versions of watched hash tags, versions are not checked if it is nil.
+	loadVersion RoomDataVersionLoader
 	keys        []string
 	status      TransactionStatus
 	commands    []redis.Cmder
-	dep         base.Dependency
+	// maxQueuedCommands limits count of commands queued in multi, 0 means no limit,
+	// the transaction is aborted when it is exceeded and exec returns error.
+	maxQueuedCommands int
+	// aborted is set when a command fails to be queued in multi, exec discards the transaction then.
+	aborted bool
+	// expired is set when the transaction is timeout, commands are not queued any more.
+	expired   bool
+	dep       base.Dependency
+	createdAt time.Time
+	// mutex protects transaction from being closed by other goroutines while processing commands.
+	mutex *sync.Mutex
 }
 
 func NewTransaction(dep base.Dependency) *Transaction {
-	return &Transaction{status: TransactionStatusInited, dep: dep}
+	return &Transaction{
+		status:          TransactionStatusInited,
+		dep:             dep,
+		watchedVersions: make(map[string]int),
+		createdAt:       time.Now(),
+		mutex:           &sync.Mutex{},
+	}
 }
 
+// RoomDataVersionNotExist is the version of hash tags whose room data does not exist in db.
+const RoomDataVersionNotExist = -1
+
+// RoomDataVersionLoader loads version of room data of hash tag in db, it is provided by service,
+// RoomDataVersionNotExist is returned if room data does not exist.
+// It is used to detect modifications of watched hash tags made by other room servers.
+type RoomDataVersionLoader func(hashTag string) (int, error)
+
 var errTxKeysNotInSameSlot = errors.New("ERR keys in transaction should be in the same slot")
 
+var errTxMaxQueuedCommandsExceeded = errors.New("ERR max queued commands of transaction exceeded")
+
+var errTxExecAbort = errors.New("EXECABORT Transaction discarded because of previous errors.")
+
+var errTxTimeout = errors.New("ERR transaction is timeout")
+
+// SetMaxQueuedCommands sets max count of commands queued in multi, 0 means no limit.
+func (transaction *Transaction) SetMaxQueuedCommands(count int) {
+	transaction.mutex.Lock()
+	defer transaction.mutex.Unlock()
+	transaction.maxQueuedCommands = count
+}
+
+// SetRoomDataVersionLoader sets loader of versions of watched hash tags, exec fails if they are changed in db.
+func (transaction *Transaction) SetRoomDataVersionLoader(loader RoomDataVersionLoader) {
+	transaction.mutex.Lock()
+	defer transaction.mutex.Unlock()
+	transaction.loadVersion = loader
+}
+
+// Abort marks a started transaction as aborted like redis does when a command is rejected before queued,
+// so exec returns EXECABORT error, it is ignored if multi is not called.
+func (transaction *Transaction) Abort() {
+	transaction.mutex.Lock()
+	defer transaction.mutex.Unlock()
+	if transaction.isStarted() {
+		transaction.aborted = true
+	}
+}
+
+// IsWatching returns true if the transaction is started or keys are watched,
+// the client expects exec to run or discard the transaction in these states.
+func (transaction *Transaction) IsWatching() bool {
+	transaction.mutex.Lock()
+	defer transaction.mutex.Unlock()
+	return transaction.isStarted() || len(transaction.watchedKeys) != 0
+}
+
+// Expire aborts a timeout transaction and releases its redis connection, but the transaction is still used by the client,
+// so commands in multi are rejected and exec returns EXECABORT error instead of running commands out of the transaction.
+// It returns false if the transaction is closed or has been expired.
+func (transaction *Transaction) Expire() bool {
+	transaction.mutex.Lock()
+	defer transaction.mutex.Unlock()
+	if transaction.status == TransactionStatusClosed || transaction.expired {
+		return false
+	}
+	if transaction.tx != nil {
+		if err := transaction.tx.Close(contextTODO); err != nil {
+			recordTransactionCloseError(transaction.dep.Logger, transaction.dep.Metric, err, TransactionCloseReasonTimeout)
+		}
+		transaction.tx = nil
+	}
+	transaction.commands = make([]redis.Cmder, 0)
+	transaction.keys = make([]string, 0)
+	transaction.expired = true
+	transaction.aborted = true
+	return true
+}
+
 func newRedisTransaction(redisCluster *redis.ClusterClient, keys ...string) (*redis.Tx, error) {
 	if len(keys) == 0 {
 		return redisCluster.NewTransation(contextTODO, "")
@@ -59,7 +154,7 @@ func newRedisTransaction(redisCluster *redis.ClusterClient, keys ...string) (*re
 }
 
 func (transaction *Transaction) multi() RESPData {
-	if transaction.IsStarted() {
+	if transaction.isStarted() {
 		return RESPData{DataType: ErrorRespType, Value: errors.New("ERR MULTI calls can not be nested")}
 	}
 	transaction.status = TransactionStatusStarted
@@ -75,14 +170,17 @@ func (transaction *Transaction) reset(reason TransactionCloseReason, status Tran
 		transaction.tx = nil
 	}
 	transaction.watchedKeys = make([]string, 0)
+	transaction.watchedVersions = make(map[string]int)
 	transaction.keys = make([]string, 0)
 	transaction.commands = make([]redis.Cmder, 0)
+	transaction.aborted = false
+	transaction.expired = false
 	transaction.status = status
 	return nil
 }
 
 func (transaction *Transaction) watch(keys ...string) RESPData {
-	if transaction.IsStarted() {
+	if transaction.isStarted() {
 		return RESPData{DataType: ErrorRespType, Value: errors.New("ERR WATCH inside MULTI is not allowed")}
 	}
 	if len(keys) == 0 {
@@ -101,7 +199,7 @@ func (transaction *Transaction) watch(keys ...string) RESPData {
 		tx, err := newRedisTransaction(transaction.dep.Redis, keys...)
 		if err != nil {
 			if err == errTxKeysNotInSameSlot {
-				transaction.Close(TransactionCloseReasonWatchedKeysNotInSameSlot)
+				transaction.close(TransactionCloseReasonWatchedKeysNotInSameSlot)
 			}
 			return ConvertErrorToRESPData(err)
 		}
@@ -116,14 +214,64 @@ func (transaction *Transaction) watch(keys ...string) RESPData {
 	if _, err := transaction.tx.Watch(contextTODO, keys...).Result(); err != nil {
 		return ConvertErrorToRESPData(err)
 	}
+	if err := transaction.watchVersions(keys...); err != nil {
+		// keys are unwatched in redis by closing, exec should not run with keys whose versions are unknown.
+		transaction.close(TransactionCloseReasonWatchVersionFailed)
+		return ConvertErrorToRESPData(err)
+	}
 	transaction.watchedKeys = append(transaction.watchedKeys, keys...)
 	transaction.status = TransactionStatusInited
 	return RESPData{DataType: SimpleStringRespType, Value: "OK"}
 }
 
+func (transaction *Transaction) watchVersions(keys ...string) error {
+	if transaction.loadVersion == nil {
+		return nil
+	}
+	for _, key := range keys {
+		hashTag := ExtractHashTagFromKey(key)
+		if hashTag == "" {
+			continue
+		}
+		if _, ok := transaction.watchedVersions[hashTag]; ok {
+			continue
+		}
+		version, err := transaction.loadVersion(hashTag)
+		if err != nil {
+			return err
+		}
+		transaction.watchedVersions[hashTag] = version
+	}
+	return nil
+}
+
+// isWatchedVersionsChanged checks whether room data of watched hash tags are modified in db after watch.
+func (transaction *Transaction) isWatchedVersionsChanged() (bool, error) {
+	for hashTag, watchedVersion := range transaction.watchedVersions {
+		version, err := transaction.loadVersion(hashTag)
+		if err != nil {
+			return false, err
+		}
+		if version != watchedVersion {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (transaction *Transaction) addCommand(command Commander) RESPData {
 	var result RESPData
-	if transaction.IsStarted() {
+	if transaction.isStarted() {
+		if transaction.expired {
+			return ConvertErrorToRESPData(errTxTimeout)
+		}
+		if transaction.maxQueuedCommands > 0 && len(transaction.commands) >= transaction.maxQueuedCommands {
+			if !transaction.aborted {
+				transaction.dep.Metric.MetricIncrease("transaction.aborted.max_queued_commands")
+			}
+			transaction.aborted = true
+			return ConvertErrorToRESPData(errTxMaxQueuedCommandsExceeded)
+		}
 		transaction.commands = append(transaction.commands, command.Cmd())
 		transaction.keys = append(transaction.keys, append(command.ReadKeys(), command.WriteKeys()...)...)
 		result = RESPData{DataType: SimpleStringRespType, Value: "QUEUED"}
@@ -134,11 +282,24 @@ func (transaction *Transaction) addCommand(command Commander) RESPData {
 }
 
 func (transaction *Transaction) exec() RESPData {
-	if !transaction.IsStarted() {
+	if !transaction.isStarted() {
 		return ConvertErrorToRESPData(errors.New("ERR EXEC without MULTI"))
 	}
+	if transaction.aborted {
+		transaction.close(TransactionCloseReasonExecAbort)
+		return ConvertErrorToRESPData(errTxExecAbort)
+	}
+	changed, err := transaction.isWatchedVersionsChanged()
+	if err != nil {
+		transaction.close(TransactionCloseReasonExec)
+		return ConvertErrorToRESPData(err)
+	}
+	if changed {
+		transaction.close(TransactionCloseReasonWatchedVersionChanged)
+		return RESPData{DataType: NilArrayRespType}
+	}
 	defer func() {
-		transaction.Close(TransactionCloseReasonExec)
+		transaction.close(TransactionCloseReasonExec)
 	}()
 	if !redis.AreKeysInSameSlot(transaction.keys...) {
 		return ConvertErrorToRESPData(errTxKeysNotInSameSlot)
@@ -150,6 +311,7 @@ func (transaction *Transaction) exec() RESPData {
 			}
 			transaction.tx = nil
 			transaction.watchedKeys = make([]string, 0)
+			transaction.watchedVersions = make(map[string]int)
 		}
 	}
 
@@ -187,46 +349,71 @@ func (transaction *Transaction) exec() RESPData {
 }
 
 func (transaction *Transaction) Close(reason TransactionCloseReason) error {
-	if transaction.IsClosed() {
+	transaction.mutex.Lock()
+	defer transaction.mutex.Unlock()
+	return transaction.close(reason)
+}
+
+func (transaction *Transaction) close(reason TransactionCloseReason) error {
+	if transaction.status == TransactionStatusClosed {
 		return nil
 	}
 	return transaction.reset(reason, TransactionStatusClosed)
 }
 
 func (transaction *Transaction) IsClosed() bool {
-	return transaction.status == TransactionStatusClosed
+	return transaction.Status() == TransactionStatusClosed
 }
 
 func (transaction *Transaction) IsStarted() bool {
+	return transaction.Status() == TransactionStatusStarted
+}
+
+func (transaction *Transaction) isStarted() bool {
 	return transaction.status == TransactionStatusStarted
 }
 
 func (transaction *Transaction) Status() TransactionStatus {
+	transaction.mutex.Lock()
+	defer transaction.mutex.Unlock()
 	return transaction.status
 }
 
+func (transaction *Transaction) CreatedAt() time.Time {
+	return transaction.createdAt
+}
+
 func (transaction *Transaction) discard() RESPData {
-	if !transaction.IsStarted() {
+	if !transaction.isStarted() {
+		// discard does not unwatch keys outside multi, close the transaction only when nothing is watched,
+		// so the connection goes back to non-transactional state.
+		if transaction.tx == nil && len(transaction.watchedKeys) == 0 {
+			if err := transaction.close(TransactionCloseReasonDiscard); err != nil {
+				return ConvertErrorToRESPData(err)
+			}
+		}
 		return ConvertErrorToRESPData(errors.New("ERR DISCARD without MULTI"))
 	}
-	if err := transaction.Close(TransactionCloseReasonDiscard); err != nil {
+	if err := transaction.close(TransactionCloseReasonDiscard); err != nil {
 		return ConvertErrorToRESPData(err)
 	}
 	return RESPData{DataType: SimpleStringRespType, Value: "OK"}
 }
 
 func (transaction *Transaction) unwatch() RESPData {
-	if transaction.IsStarted() {
+	if transaction.isStarted() {
 		command, _ := NewUnwatchCommand([]string{"unwatch"})
 		return transaction.addCommand(command)
 	}
-	if err := transaction.Close(TransactionCloseReasonUnwatch); err != nil {
+	if err := transaction.close(TransactionCloseReasonUnwatch); err != nil {
 		return ConvertErrorToRESPData(err)
 	}
 	return RESPData{DataType: SimpleStringRespType, Value: "OK"}
 }
 
 func (transaction *Transaction) Process(command Commander) RESPData {
+	transaction.mutex.Lock()
+	defer transaction.mutex.Unlock()
 	var result RESPData
 	switch command.Name() {
 	case "watch":
@@ -340,7 +527,7 @@ func (command *UnwatchCommand) Cmd() redis.Cmder {
 	return redis.NewStatusCmd(contextTODO, command.name)
 }
 
-func recordTransactionCloseError(logger *log.Logger, metric *base.MetricClient, err error, reason TransactionCloseReason) {
+func recordTransactionCloseError(logger *log.Logger, metric base.Metric, err error, reason TransactionCloseReason) {
 	logger.Error(
 		"transaction close error",
 		log.String("reason", string(reason)),
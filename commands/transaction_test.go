@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytepower_room/base"
+	"errors"
 	"testing"
 
 	"github.com/go-redis/redis/v8"
@@ -213,6 +214,68 @@ func TestExec(t *testing.T) {
 	testEmptyKeysInRedis("{a}1", "{a}2")
 }
 
+// test commands:
+// multi
+// set {a}1 10
+// set {a}2 100
+// get {a}1
+// exec
+func TestExecAbortWithMaxQueuedCommands(t *testing.T) {
+	dep := base.GetServerDependency()
+	transaction := NewTransaction(dep)
+	transaction.SetMaxQueuedCommands(2)
+
+	command, _ := NewMultiCommand([]string{"multi"})
+	transaction.Process(command)
+
+	command, _ = NewSetCommand([]string{"set", "{a}1", "10"})
+	result := transaction.Process(command)
+	assert.Equal(t, RESPData{DataType: SimpleStringRespType, Value: "QUEUED"}, result)
+
+	command, _ = NewSetCommand([]string{"set", "{a}2", "100"})
+	result = transaction.Process(command)
+	assert.Equal(t, RESPData{DataType: SimpleStringRespType, Value: "QUEUED"}, result)
+
+	command, _ = NewGetCommand([]string{"get", "{a}1"})
+	result = transaction.Process(command)
+	assert.Equal(t, RESPData{DataType: ErrorRespType, Value: errTxMaxQueuedCommandsExceeded}, result)
+	assert.Equal(t, TransactionStatusStarted, transaction.Status())
+
+	command, _ = NewExecCommand([]string{"exec"})
+	result = transaction.Process(command)
+	assert.Equal(t, RESPData{DataType: ErrorRespType, Value: errTxExecAbort}, result)
+	assert.True(t, transaction.IsClosed())
+
+	n, err := dep.Redis.Exists(contextTODO, "{a}1", "{a}2").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestTransactionAbort(t *testing.T) {
+	dep := base.GetServerDependency()
+	defer dep.Redis.Del(contextTODO, "{a}1")
+	transaction := NewTransaction(dep)
+
+	// abort is ignored outside multi.
+	transaction.Abort()
+	command, _ := NewMultiCommand([]string{"multi"})
+	transaction.Process(command)
+	command, _ = NewSetCommand([]string{"set", "{a}1", "10"})
+	result := transaction.Process(command)
+	assert.Equal(t, RESPData{DataType: SimpleStringRespType, Value: "QUEUED"}, result)
+
+	transaction.Abort()
+	assert.Equal(t, TransactionStatusStarted, transaction.Status())
+	command, _ = NewExecCommand([]string{"exec"})
+	result = transaction.Process(command)
+	assert.Equal(t, RESPData{DataType: ErrorRespType, Value: errTxExecAbort}, result)
+	assert.True(t, transaction.IsClosed())
+
+	n, err := dep.Redis.Exists(contextTODO, "{a}1").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
 // test commands:
 // tx1: multi
 // tx2: multi
@@ -449,6 +512,18 @@ func TestDiscardWithoutMulti(t *testing.T) {
 	assert.Equal(t, ErrorRespType, result.DataType)
 }
 
+// test commands:
+// discard
+func TestDiscardWithoutMultiInTransaction(t *testing.T) {
+	dep := base.GetServerDependency()
+	transaction := NewTransaction(dep)
+	command, _ := NewDiscardCommand([]string{"discard"})
+	result := transaction.Process(command)
+	assert.Equal(t, ErrorRespType, result.DataType)
+	assert.Equal(t, "ERR DISCARD without MULTI", result.Value.(error).Error())
+	assert.True(t, transaction.IsClosed())
+}
+
 // test commands:
 // watch {a}1 {a}2
 // discard
@@ -492,13 +567,20 @@ func TestDiscardUnwatchSuccess(t *testing.T) {
 	command, _ = NewMultiCommand([]string{"multi"})
 	tx1.Process(command)
 	command, _ = NewDiscardCommand([]string{"discard"})
-	tx1.Process(command)
+	result := tx1.Process(command)
+	assert.Equal(t, RESPData{DataType: SimpleStringRespType, Value: "OK"}, result)
+	assert.True(t, tx1.IsClosed())
+	assert.Equal(t, 0, len(tx1.watchedKeys))
+	assert.Equal(t, 0, len(tx1.watchedVersions))
+	assert.Nil(t, tx1.tx)
+
 	command, _ = NewMultiCommand([]string{"multi"})
 	tx1.Process(command)
 	command, _ = NewSetCommand([]string{"set", "{a}1", "100"})
-	tx1.Process(command)
+	result = tx1.Process(command)
+	assert.Equal(t, RESPData{DataType: SimpleStringRespType, Value: "QUEUED"}, result)
 	command, _ = NewExecCommand([]string{"exec"})
-	result := tx1.Process(command)
+	result = tx1.Process(command)
 	assert.Equal(
 		t,
 		RESPData{
@@ -531,6 +613,7 @@ func TestUnwatch(t *testing.T) {
 
 	assert.True(t, transaction.IsClosed())
 	assert.Equal(t, 0, len(transaction.watchedKeys))
+	assert.Equal(t, 0, len(transaction.watchedVersions))
 	assert.Nil(t, transaction.tx)
 }
 
@@ -602,3 +685,62 @@ func TestExecAfterUnWatch(t *testing.T) {
 	testCloseTransaction(t, tx1, tx2)
 	testEmptyKeysInRedis("{a}1")
 }
+
+// test commands:
+// tx1: watch {a}1 {a}2
+// room data version of hash tag a in db is changed
+// tx1: multi
+// tx1: set {a}2 b
+// tx1: exec
+func TestTransactionFailWithDBVersionChanged(t *testing.T) {
+	dep := base.GetServerDependency()
+	tx1 := NewTransaction(dep)
+	versions := map[string]int{"a": RoomDataVersionNotExist}
+	tx1.SetRoomDataVersionLoader(func(hashTag string) (int, error) {
+		return versions[hashTag], nil
+	})
+	watchedKeys := []string{"{a}1", "{a}2"}
+	command, _ := NewWatchCommand(append([]string{"watch"}, watchedKeys...))
+	result := tx1.Process(command)
+	assert.Equal(t, RESPData{DataType: SimpleStringRespType, Value: "OK"}, result)
+	assert.Equal(t, map[string]int{"a": RoomDataVersionNotExist}, tx1.watchedVersions)
+
+	// simulate room data is synced to db by other room servers after watch.
+	versions["a"] = 10
+
+	command, _ = NewMultiCommand([]string{"multi"})
+	tx1.Process(command)
+	command, _ = NewSetCommand([]string{"set", "{a}2", "b"})
+	result = tx1.Process(command)
+	assert.Equal(t, RESPData{DataType: SimpleStringRespType, Value: "QUEUED"}, result)
+
+	command, _ = NewExecCommand([]string{"exec"})
+	result = tx1.Process(command)
+	assert.Equal(t, RESPData{DataType: NilArrayRespType, Value: nil}, result)
+	assert.True(t, tx1.IsClosed())
+	assert.Equal(t, 0, len(tx1.watchedVersions))
+
+	command, _ = NewGetCommand([]string{"get", "{a}2"})
+	result = ExecuteCommand(dep.Redis, command)
+	assert.Equal(t, RESPData{DataType: NilRespType, Value: nil}, result)
+
+	testCloseTransaction(t, tx1)
+}
+
+// test commands:
+// tx1: watch {a}1
+// loading version of room data fails
+func TestTransactionWatchWithVersionLoadError(t *testing.T) {
+	dep := base.GetServerDependency()
+	tx1 := NewTransaction(dep)
+	tx1.SetRoomDataVersionLoader(func(hashTag string) (int, error) {
+		return 0, errors.New("ERR load version")
+	})
+	command, _ := NewWatchCommand([]string{"watch", "{a}1"})
+	result := tx1.Process(command)
+	assert.Equal(t, "err:ERR load version", result.String())
+	// keys are unwatched and the transaction is closed, so exec never runs without versions of watched keys.
+	assert.True(t, tx1.IsClosed())
+	assert.Nil(t, tx1.tx)
+	assert.Equal(t, 0, len(tx1.watchedKeys))
+}
@@ -2,16 +2,19 @@ package commands
 
 import (
 	"bytepower_room/utility"
+	"math"
 	"strconv"
 	"strings"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// zadd key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
 type ZAddCommand struct {
-	key                string
-	existMode          keyExistMode
-	scoreCompare       *string
+	key       string
+	existMode keyExistMode
+	// scoreCompare is "gt", "lt" or empty.
+	scoreCompare       string
 	returnChangedCount bool
 	incr               bool
 	scoreMembers       []string
@@ -26,16 +29,16 @@ func NewZAddCommand(args []string) (Commander, error) {
 	}
 	command.key = args[1]
 	options := args[2:]
-	scoreStartIndex := command.parseOtherOptions(options)
-	if scoreStartIndex >= len(options) {
-		return nil, newWrongNumberOfArgumentsError(command.name)
+	scoreStartIndex, err := command.parseOtherOptions(options)
+	if err != nil {
+		return nil, err
 	}
 	scoreMemberCount := len(options) - scoreStartIndex
-	if scoreMemberCount%2 != 0 {
-		return nil, newWrongNumberOfArgumentsError(command.name)
+	if scoreMemberCount == 0 || scoreMemberCount%2 != 0 {
+		return nil, errSyntaxError
 	}
 	if command.incr && (scoreMemberCount != 2) {
-		return nil, newWrongNumberOfArgumentsError(command.name)
+		return nil, errZAddIncrMultiplePairs
 	}
 	for index := scoreStartIndex; index < len(options)-1; index += 2 {
 		score := options[index]
@@ -49,33 +52,57 @@ func NewZAddCommand(args []string) (Commander, error) {
 	return command, nil
 }
 
-func (command *ZAddCommand) parseOtherOptions(options []string) int {
+// parseOtherOptions returns index of the first score in options.
+func (command *ZAddCommand) parseOtherOptions(options []string) (int, error) {
+	var nx, xx, gt, lt bool
+	scoreStartIndex := len(options)
+loop:
 	for index, option := range options {
-		item := strings.ToLower(option)
-		switch item {
+		switch strings.ToLower(option) {
 		case "nx":
-			command.existMode = keyExistModeNX
+			nx = true
 		case "xx":
-			command.existMode = keyExistModeXX
+			xx = true
 		case "gt":
-			command.scoreCompare = &option
+			gt = true
 		case "lt":
-			command.scoreCompare = &option
+			lt = true
 		case "ch":
 			command.returnChangedCount = true
 		case "incr":
 			command.incr = true
 		default:
-			return index
+			scoreStartIndex = index
+			break loop
 		}
 	}
-	return len(options)
+	if nx && xx {
+		return 0, errZAddXXAndNXNotCompatible
+	}
+	if (gt && lt) || (nx && (gt || lt)) {
+		return 0, errZAddGTLTNXNotCompatible
+	}
+	if nx {
+		command.existMode = keyExistModeNX
+	} else if xx {
+		command.existMode = keyExistModeXX
+	}
+	if gt {
+		command.scoreCompare = "gt"
+	} else if lt {
+		command.scoreCompare = "lt"
+	}
+	return scoreStartIndex, nil
 }
 
 func (command *ZAddCommand) WriteKeys() []string {
 	return []string{command.key}
 }
 
+func (command *ZAddCommand) WrittenValueSize() (string, int) {
+	return ValueTypeZSet, stringsSize(command.scoreMembers)
+}
+
 func (command *ZAddCommand) Cmd() redis.Cmder {
 	args := utility.StringSliceToInterfaceSlice(command.args)
 	if command.incr {
@@ -148,88 +175,167 @@ func isLimit(s string) bool {
 	return strings.ToLower(s) == "limit"
 }
 
-type ZDiffCommand struct {
-	numKeys    int64
-	keys       []string
-	withScores bool
+const (
+	ZSetAggregateDiff  = "diff"
+	ZSetAggregateInter = "inter"
+	ZSetAggregateUnion = "union"
+
+	ZSetAggregateSum = "sum"
+	ZSetAggregateMin = "min"
+	ZSetAggregateMax = "max"
+)
+
+// zdiff numkeys key [key ...] [WITHSCORES]
+// zdiffstore destination numkeys key [key ...]
+// zinter numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+// zinterstore destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+// zunion numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+// zunionstore destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+// These commands are processed by room server, so that keys could have different hash tags,
+// commands of keys in one slot of redis cluster are processed by redis.
+type ZSetAggregateCommand struct {
+	operation   string
+	destination string
+	keys        []string
+	weights     []float64
+	aggregate   string
+	withScores  bool
 	commonCommand
 }
 
-func NewZDiffCommand(args []string) (Commander, error) {
-	command := &ZDiffCommand{}
+func newZSetAggregateCommand(args []string, operation string, store bool) (Commander, error) {
+	command := &ZSetAggregateCommand{operation: operation, aggregate: ZSetAggregateSum}
 	command.init(args)
-	if len(args) < 3 {
+	index := 1
+	if store {
+		if len(args) < 4 {
+			return nil, newWrongNumberOfArgumentsError(command.name)
+		}
+		command.destination = args[index]
+		index++
+	} else if len(args) < 3 {
 		return nil, newWrongNumberOfArgumentsError(command.name)
 	}
-	numKeys, err := strconv.ParseInt(args[1], 10, 64)
+	numKeys, err := strconv.ParseInt(args[index], 10, 64)
 	if err != nil {
 		return nil, errInvalidInteger
 	}
-	command.numKeys = numKeys
-	keys := args[2:]
-	if int64(len(keys)) == numKeys {
-		command.keys = keys
-	} else if (int64(len(keys)) == numKeys+1) && isWithScores(keys[len(keys)-1]) {
-		command.keys = keys[:len(keys)-1]
-		command.withScores = true
-	} else {
-		return nil, newWrongNumberOfArgumentsError(command.name)
+	if numKeys < 1 {
+		return nil, newZSetAggregateNoKeyError(command.name)
+	}
+	index++
+	if numKeys > int64(len(args)-index) {
+		return nil, errSyntaxError
+	}
+	command.keys = args[index : index+int(numKeys)]
+	command.weights = make([]float64, numKeys)
+	for i := range command.weights {
+		command.weights[i] = 1
+	}
+	for index += int(numKeys); index < len(args); index++ {
+		option := strings.ToLower(args[index])
+		remaining := len(args) - index - 1
+		switch {
+		case option == "weights" && operation != ZSetAggregateDiff && remaining >= int(numKeys):
+			for i := range command.weights {
+				index++
+				weight, err := strconv.ParseFloat(args[index], 64)
+				if err != nil || math.IsNaN(weight) {
+					return nil, errZSetWeightNotFloat
+				}
+				command.weights[i] = weight
+			}
+		case option == "aggregate" && operation != ZSetAggregateDiff && remaining >= 1:
+			index++
+			aggregate := strings.ToLower(args[index])
+			if aggregate != ZSetAggregateSum && aggregate != ZSetAggregateMin && aggregate != ZSetAggregateMax {
+				return nil, errSyntaxError
+			}
+			command.aggregate = aggregate
+		case isWithScores(option) && !store:
+			command.withScores = true
+		default:
+			return nil, errSyntaxError
+		}
 	}
 	return command, nil
 }
 
-func (command *ZDiffCommand) ReadKeys() []string {
+func NewZDiffCommand(args []string) (Commander, error) {
+	return newZSetAggregateCommand(args, ZSetAggregateDiff, false)
+}
+
+func NewZDiffStoreCommand(args []string) (Commander, error) {
+	return newZSetAggregateCommand(args, ZSetAggregateDiff, true)
+}
+
+func NewZInterCommand(args []string) (Commander, error) {
+	return newZSetAggregateCommand(args, ZSetAggregateInter, false)
+}
+
+func NewZInterStoreCommand(args []string) (Commander, error) {
+	return newZSetAggregateCommand(args, ZSetAggregateInter, true)
+}
+
+func NewZUnionCommand(args []string) (Commander, error) {
+	return newZSetAggregateCommand(args, ZSetAggregateUnion, false)
+}
+
+func NewZUnionStoreCommand(args []string) (Commander, error) {
+	return newZSetAggregateCommand(args, ZSetAggregateUnion, true)
+}
+
+// Operation returns one of ZSetAggregateDiff, ZSetAggregateInter and ZSetAggregateUnion.
+func (command *ZSetAggregateCommand) Operation() string {
+	return command.operation
+}
+
+// Destination returns destination key of store variants, it is empty for others.
+func (command *ZSetAggregateCommand) Destination() string {
+	return command.destination
+}
+
+func (command *ZSetAggregateCommand) Keys() []string {
 	return command.keys
 }
 
-func (command *ZDiffCommand) Cmd() redis.Cmder {
-	return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
+// Weights returns weights of keys, all weights are 1 if weights option is not set.
+func (command *ZSetAggregateCommand) Weights() []float64 {
+	return command.weights
 }
 
-type ZDiffStoreCommand struct {
-	destKey    string
-	numKeys    int64
-	keys       []string
-	withScores bool
-	commonCommand
+// Aggregate returns one of ZSetAggregateSum, ZSetAggregateMin and ZSetAggregateMax.
+func (command *ZSetAggregateCommand) Aggregate() string {
+	return command.aggregate
 }
 
-func NewZDiffStoreCommand(args []string) (Commander, error) {
-	command := &ZDiffStoreCommand{}
-	command.init(args)
-	if len(args) < 4 {
-		return nil, newWrongNumberOfArgumentsError(command.name)
-	}
-	command.destKey = args[1]
-	numKeys, err := strconv.ParseInt(args[2], 10, 64)
-	if err != nil {
-		return nil, errInvalidInteger
-	}
-	command.numKeys = numKeys
-	keys := args[3:]
-	if int64(len(keys)) == numKeys {
-		command.keys = keys
-	} else if (int64(len(keys)) == numKeys+1) && isWithScores(keys[len(keys)-1]) {
-		command.keys = keys[:len(keys)-1]
-		command.withScores = true
-	} else {
-		return nil, newWrongNumberOfArgumentsError(command.name)
-	}
-	return command, nil
+func (command *ZSetAggregateCommand) WithScores() bool {
+	return command.withScores
 }
 
-func (command *ZDiffStoreCommand) ReadKeys() []string {
+func (command *ZSetAggregateCommand) ReadKeys() []string {
 	return command.keys
 }
 
-func (command *ZDiffStoreCommand) WriteKeys() []string {
-	return []string{command.destKey}
+func (command *ZSetAggregateCommand) WriteKeys() []string {
+	if command.destination == "" {
+		return []string{}
+	}
+	return []string{command.destination}
 }
 
-func (command *ZDiffStoreCommand) Cmd() redis.Cmder {
+func (command *ZSetAggregateCommand) Cmd() redis.Cmder {
+	if command.destination != "" {
+		return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
+	}
 	return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
 
+// SplitByHashTag splits command into commands of each hash tag if its keys have different hash tags.
+func (command *ZSetAggregateCommand) SplitByHashTag() ([]Commander, error) {
+	return splitCommandKeysByHashTag(command)
+}
+
 type ZIncrByCommand struct {
 	key       string
 	increment float64
@@ -354,38 +460,33 @@ func (command *ZPopMinCommand) Cmd() redis.Cmder {
 	return redis.NewStringSliceCmd(contextTODO, command.name, command.key, command.count)
 }
 
+// zrange key min max [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+// Options added in redis 6.2 are checked like redis and command is sent to redis as it is.
 type ZRangeCommand struct {
-	key        string
-	startIndex int64
-	stopIndex  int64
-	withScores bool
+	key string
+	min string
+	max string
+	zRangeOptions
 	commonCommand
 }
 
 func NewZRangeCommand(args []string) (Commander, error) {
 	command := &ZRangeCommand{}
 	command.init(args)
-	if (len(args) != 4) && (len(args) != 5) {
+	if len(args) < 4 {
 		return nil, newWrongNumberOfArgumentsError(command.name)
 	}
 	command.key = args[1]
-	startIndex, err := strconv.ParseInt(args[2], 10, 64)
-	if err != nil {
-		return nil, errInvalidInteger
-	}
-	stopIndex, err := strconv.ParseInt(args[3], 10, 64)
+	command.min = args[2]
+	command.max = args[3]
+	options, err := parseZRangeOptions(command.min, command.max, args[4:])
 	if err != nil {
-		return nil, errInvalidInteger
+		return nil, err
 	}
-	command.startIndex = startIndex
-	command.stopIndex = stopIndex
-	if len(args) == 5 {
-		if isWithScores(args[4]) {
-			command.withScores = true
-		} else {
-			return nil, errSyntaxError
-		}
+	if options.withScores && options.by == zRangeByLex {
+		return nil, errZRangeWithScoresByLex
 	}
+	command.zRangeOptions = options
 	return command, nil
 }
 
@@ -394,11 +495,7 @@ func (command *ZRangeCommand) ReadKeys() []string {
 }
 
 func (command *ZRangeCommand) Cmd() redis.Cmder {
-	args := []interface{}{command.name, command.key, command.startIndex, command.stopIndex}
-	if command.withScores {
-		args = append(args, "withscores")
-	}
-	return redis.NewStringSliceCmd(contextTODO, args...)
+	return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
 
 type zRangeLimit struct {
@@ -422,29 +519,49 @@ func NewZRangeByLexCommand(args []string) (Commander, error) {
 	command.key = args[1]
 	command.min = args[2]
 	command.max = args[3]
+	if !isValidLexRangeItem(command.min) || !isValidLexRangeItem(command.max) {
+		return nil, errZRangeLexInvalidItem
+	}
 	if len(args) == 7 {
-		if err := command.parseLimitOptions(args[4:]); err != nil {
+		limit, err := parseZRangeLimit(args[4:])
+		if err != nil {
 			return nil, err
 		}
+		command.limit = limit
 	}
 	return command, nil
 }
 
-func (command *ZRangeByLexCommand) parseLimitOptions(options []string) error {
+// isValidLexRangeItem checks min or max of zrangebylex like redis,
+// it should be "-", "+" or start with "[" or "(".
+func isValidLexRangeItem(item string) bool {
+	if item == "-" || item == "+" {
+		return true
+	}
+	return len(item) > 0 && (item[0] == '[' || item[0] == '(')
+}
+
+// isValidScoreRangeItem checks min or max of zrangebyscore like redis,
+// it should be a float with optional "(" prefix, -inf and +inf are valid.
+func isValidScoreRangeItem(item string) bool {
+	item = strings.TrimPrefix(item, "(")
+	score, err := strconv.ParseFloat(item, 64)
+	return err == nil && !math.IsNaN(score)
+}
+
+func parseZRangeLimit(options []string) (*zRangeLimit, error) {
 	if !isLimit(options[0]) {
-		return errSyntaxError
+		return nil, errSyntaxError
 	}
 	offset, err := strconv.ParseInt(options[1], 10, 64)
 	if err != nil {
-		return errInvalidInteger
+		return nil, errInvalidInteger
 	}
 	count, err := strconv.ParseInt(options[2], 10, 64)
 	if err != nil {
-		return errInvalidInteger
+		return nil, errInvalidInteger
 	}
-	limit := &zRangeLimit{offset: offset, count: count}
-	command.limit = limit
-	return nil
+	return &zRangeLimit{offset: offset, count: count}, nil
 }
 
 func (command *ZRangeByLexCommand) ReadKeys() []string {
@@ -472,31 +589,19 @@ func NewZRevRangeByLexCommand(args []string) (Commander, error) {
 	command.key = args[1]
 	command.max = args[2]
 	command.min = args[3]
+	if !isValidLexRangeItem(command.min) || !isValidLexRangeItem(command.max) {
+		return nil, errZRangeLexInvalidItem
+	}
 	if len(args) == 7 {
-		if err := command.parseLimitOptions(args[4:]); err != nil {
+		limit, err := parseZRangeLimit(args[4:])
+		if err != nil {
 			return nil, err
 		}
+		command.limit = limit
 	}
 	return command, nil
 }
 
-func (command *ZRevRangeByLexCommand) parseLimitOptions(options []string) error {
-	if !isLimit(options[0]) {
-		return errSyntaxError
-	}
-	offset, err := strconv.ParseInt(options[1], 10, 64)
-	if err != nil {
-		return errInvalidInteger
-	}
-	count, err := strconv.ParseInt(options[2], 10, 64)
-	if err != nil {
-		return errInvalidInteger
-	}
-	limit := &zRangeLimit{offset: offset, count: count}
-	command.limit = limit
-	return nil
-}
-
 func (command *ZRevRangeByLexCommand) ReadKeys() []string {
 	return []string{command.key}
 }
@@ -524,6 +629,9 @@ func NewZRangeByScoreCommand(args []string) (Commander, error) {
 	command.key = args[1]
 	command.min = args[2]
 	command.max = args[3]
+	if !isValidScoreRangeItem(command.min) || !isValidScoreRangeItem(command.max) {
+		return nil, errZRangeScoreInvalidItem
+	}
 	if err := command.parseOtherOptions(args[4:]); err != nil {
 		return nil, err
 	}
@@ -540,15 +648,10 @@ func (command *ZRangeByScoreCommand) parseOtherOptions(options []string) error {
 			if len(options) < 3 {
 				return newWrongNumberOfArgumentsError(command.name)
 			}
-			offset, err := strconv.ParseInt(options[1], 10, 64)
+			limit, err := parseZRangeLimit(options[:3])
 			if err != nil {
-				return errInvalidInteger
+				return err
 			}
-			count, err := strconv.ParseInt(options[2], 10, 64)
-			if err != nil {
-				return errInvalidInteger
-			}
-			limit := &zRangeLimit{offset: offset, count: count}
 			command.limit = limit
 			options = options[3:]
 		} else {
@@ -566,6 +669,155 @@ func (command *ZRangeByScoreCommand) Cmd() redis.Cmder {
 	return redis.NewStringSliceCmd(contextTODO, command.argsToInterfaceSlice()...)
 }
 
+// zrangestore dst src min max [BYSCORE|BYLEX] [REV] [LIMIT offset count]
+// Zrangestore is processed by room server, so that dst and src could have different hash tags, it is processed by
+// redis if they are in one slot of redis cluster,
+// range of src is queried by zrange, zrangebyscore or zrangebylex and their rev variants.
+type ZRangeStoreCommand struct {
+	destination string
+	source      string
+	min         string
+	max         string
+	zRangeOptions
+	commonCommand
+}
+
+const (
+	zRangeByScore = "byscore"
+	zRangeByLex   = "bylex"
+)
+
+// zRangeOptions are options of zrange and zrangestore, range is by index if by is empty.
+type zRangeOptions struct {
+	by         string
+	rev        bool
+	withScores bool
+	limit      *zRangeLimit
+}
+
+// parseZRangeOptions parses [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES] in any order,
+// min and max are checked by type of range.
+func parseZRangeOptions(min, max string, args []string) (zRangeOptions, error) {
+	options := zRangeOptions{}
+	for index := 0; index < len(args); index++ {
+		switch option := strings.ToLower(args[index]); option {
+		case zRangeByScore, zRangeByLex:
+			if options.by != "" && options.by != option {
+				return options, errSyntaxError
+			}
+			options.by = option
+		case "rev":
+			options.rev = true
+		case "withscores":
+			options.withScores = true
+		case "limit":
+			if index+2 >= len(args) {
+				return options, errSyntaxError
+			}
+			limit, err := parseZRangeLimit(args[index : index+3])
+			if err != nil {
+				return options, err
+			}
+			options.limit = limit
+			index += 2
+		default:
+			return options, errSyntaxError
+		}
+	}
+	switch options.by {
+	case zRangeByScore:
+		if !isValidScoreRangeItem(min) || !isValidScoreRangeItem(max) {
+			return options, errZRangeScoreInvalidItem
+		}
+	case zRangeByLex:
+		if !isValidLexRangeItem(min) || !isValidLexRangeItem(max) {
+			return options, errZRangeLexInvalidItem
+		}
+	default:
+		if options.limit != nil {
+			return options, errZRangeLimitWithoutBy
+		}
+		if _, err := strconv.ParseInt(min, 10, 64); err != nil {
+			return options, errInvalidInteger
+		}
+		if _, err := strconv.ParseInt(max, 10, 64); err != nil {
+			return options, errInvalidInteger
+		}
+	}
+	return options, nil
+}
+
+func NewZRangeStoreCommand(args []string) (Commander, error) {
+	command := &ZRangeStoreCommand{}
+	command.init(args)
+	if len(args) < 5 {
+		return nil, newWrongNumberOfArgumentsError(command.name)
+	}
+	command.destination = args[1]
+	command.source = args[2]
+	command.min = args[3]
+	command.max = args[4]
+	options, err := parseZRangeOptions(command.min, command.max, args[5:])
+	if err != nil {
+		return nil, err
+	}
+	if options.withScores {
+		return nil, errSyntaxError
+	}
+	command.zRangeOptions = options
+	return command, nil
+}
+
+func (command *ZRangeStoreCommand) Destination() string {
+	return command.destination
+}
+
+func (command *ZRangeStoreCommand) Source() string {
+	return command.source
+}
+
+// ByLex returns true if range is by lex, members are returned without scores by range command of lex.
+func (command *ZRangeStoreCommand) ByLex() bool {
+	return command.by == zRangeByLex
+}
+
+// RangeCmd returns command to query range of source key, members are returned with scores except range by lex.
+// min and max are passed in order of arguments, since they are already reversed by client for rev.
+func (command *ZRangeStoreCommand) RangeCmd() *redis.StringSliceCmd {
+	name := "zrange"
+	if command.by != "" {
+		name = name + command.by
+	}
+	if command.rev {
+		name = "zrev" + strings.TrimPrefix(name, "z")
+	}
+	args := []interface{}{name, command.source, command.min, command.max}
+	if command.by != zRangeByLex {
+		args = append(args, "withscores")
+	}
+	if command.limit != nil {
+		args = append(args, "limit", command.limit.offset, command.limit.count)
+	}
+	return redis.NewStringSliceCmd(contextTODO, args...)
+}
+
+func (command *ZRangeStoreCommand) ReadKeys() []string {
+	return []string{command.source}
+}
+
+func (command *ZRangeStoreCommand) WriteKeys() []string {
+	return []string{command.destination}
+}
+
+func (command *ZRangeStoreCommand) Cmd() redis.Cmder {
+	return redis.NewIntCmd(contextTODO, command.argsToInterfaceSlice()...)
+}
+
+// SplitByHashTag splits zrangestore into a read command of src and a write command of dst if their hash tags are different.
+func (command *ZRangeStoreCommand) SplitByHashTag() ([]Commander, error) {
+	return splitCommandKeysByHashTag(command)
+}
+
 type ZRankCommand struct {
 	key    string
 	member string
@@ -767,6 +1019,9 @@ func NewZRevRangeByScoreCommand(args []string) (Commander, error) {
 	command.key = args[1]
 	command.max = args[2]
 	command.min = args[3]
+	if !isValidScoreRangeItem(command.min) || !isValidScoreRangeItem(command.max) {
+		return nil, errZRangeScoreInvalidItem
+	}
 	if err := command.parseOtherOptions(args[4:]); err != nil {
 		return nil, err
 	}
@@ -783,15 +1038,10 @@ func (command *ZRevRangeByScoreCommand) parseOtherOptions(options []string) erro
 			if len(options) < 3 {
 				return newWrongNumberOfArgumentsError(command.name)
 			}
-			offset, err := strconv.ParseInt(options[1], 10, 64)
-			if err != nil {
-				return errInvalidInteger
-			}
-			count, err := strconv.ParseInt(options[2], 10, 64)
+			limit, err := parseZRangeLimit(options[:3])
 			if err != nil {
-				return errInvalidInteger
+				return err
 			}
-			limit := &zRangeLimit{offset: offset, count: count}
 			command.limit = limit
 			options = options[3:]
 		} else {
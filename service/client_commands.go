@@ -0,0 +1,34 @@
+package service
+
+import (
+	"bytepower_room/commands"
+	"errors"
+	"time"
+
+	"github.com/tidwall/redcon"
+)
+
+var errConnectionNotFound = errors.New("ERR connection is not found")
+
+func processClientCommand(conn redcon.Conn, command *commands.ClientCommand) commands.RESPData {
+	c := connectionManager.getConnection(conn)
+	if c == nil {
+		return commands.ConvertErrorToRESPData(errConnectionNotFound)
+	}
+	switch command.SubCommand() {
+	case commands.ClientSubCommandID:
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: c.id}
+	case commands.ClientSubCommandGetName:
+		name := c.getName()
+		if name == "" {
+			return commands.RESPData{DataType: commands.NilRespType, Value: nil}
+		}
+		return commands.RESPData{DataType: commands.BulkStringRespType, Value: name}
+	case commands.ClientSubCommandSetName:
+		c.setName(command.ClientName())
+		return commands.RESPData{DataType: commands.SimpleStringRespType, Value: "OK"}
+	case commands.ClientSubCommandList:
+		return commands.RESPData{DataType: commands.BulkStringRespType, Value: connectionManager.list(time.Now())}
+	}
+	return commands.ConvertErrorToRESPData(errors.New("ERR unknown client subcommand"))
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/utility"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/redcon"
+)
+
+var connectionManager = ConnectionManager{
+	connections: make(map[redcon.Conn]*connection),
+	mutex:       &sync.Mutex{},
+}
+
+var lastConnectionID int64
+
+type connection struct {
+	id          int64
+	addr        string
+	createdAt   time.Time
+	name        string
+	lastCommand string
+	limiter     *utility.TokenBucket
+	mutex       *sync.Mutex
+}
+
+func newConnection(addr string, rateLimit base.RateLimitConfig) *connection {
+	c := &connection{
+		id:        atomic.AddInt64(&lastConnectionID, 1),
+		addr:      addr,
+		createdAt: time.Now(),
+		mutex:     &sync.Mutex{},
+	}
+	if rateLimit.IsEnabled() {
+		c.limiter = utility.NewTokenBucket(rateLimit.MaxCommandsPerSecond, rateLimit.BurstSize)
+	}
+	return c
+}
+
+// allowCommand checks whether command is allowed by rate limit of connection.
+func (c *connection) allowCommand(commandName string) bool {
+	if c.limiter == nil || isRateLimitExemptCommand(commandName) {
+		return true
+	}
+	return c.limiter.Allow()
+}
+
+var rateLimitExemptCommands = []string{"client", "config", "info"}
+
+func isRateLimitExemptCommand(commandName string) bool {
+	return utility.StringSliceContains(rateLimitExemptCommands, commandName)
+}
+
+func (c *connection) getName() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.name
+}
+
+func (c *connection) setName(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.name = name
+}
+
+// reset resets state set by commands of the connection, id and address are kept.
+func (c *connection) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.name = ""
+}
+
+func (c *connection) setLastCommand(commandName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastCommand = commandName
+}
+
+// info returns connection information in the format of redis client list.
+func (c *connection) info(t time.Time) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return fmt.Sprintf(
+		"id=%d addr=%s name=%s age=%d cmd=%s",
+		c.id, c.addr, c.name, int64(t.Sub(c.createdAt).Seconds()), c.lastCommand,
+	)
+}
+
+type ConnectionManager struct {
+	connections map[redcon.Conn]*connection
+	mutex       *sync.Mutex
+}
+
+func (manager *ConnectionManager) addConnection(conn redcon.Conn, c *connection) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.connections[conn] = c
+}
+
+func (manager *ConnectionManager) getConnection(conn redcon.Conn) *connection {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	return manager.connections[conn]
+}
+
+func (manager *ConnectionManager) removeConnection(conn redcon.Conn) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	delete(manager.connections, conn)
+}
+
+// list returns information of all connections ordered by connection id.
+func (manager *ConnectionManager) list(t time.Time) string {
+	manager.mutex.Lock()
+	connections := make([]*connection, 0, len(manager.connections))
+	for _, c := range manager.connections {
+		connections = append(connections, c)
+	}
+	manager.mutex.Unlock()
+
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].id < connections[j].id
+	})
+	var builder strings.Builder
+	for _, c := range connections {
+		builder.WriteString(c.info(t))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
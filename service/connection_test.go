@@ -0,0 +1,53 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionAllowCommand(t *testing.T) {
+	// rate limit is disabled
+	c := newConnection("127.0.0.1:10001", base.RateLimitConfig{})
+	for i := 0; i < 100; i++ {
+		assert.True(t, c.allowCommand("get"))
+	}
+
+	c = newConnection("127.0.0.1:10001", base.RateLimitConfig{MaxCommandsPerSecond: 1, BurstSize: 2})
+	assert.True(t, c.allowCommand("get"))
+	assert.True(t, c.allowCommand("set"))
+	assert.False(t, c.allowCommand("get"))
+	// management commands are exempted
+	assert.True(t, c.allowCommand("client"))
+	assert.True(t, c.allowCommand("config"))
+	assert.True(t, c.allowCommand("info"))
+}
+
+func TestConnectionManagerList(t *testing.T) {
+	conn1 := &testConn{addr: "127.0.0.1:10001"}
+	c1 := newConnection(conn1.addr, base.RateLimitConfig{})
+	connectionManager.addConnection(conn1, c1)
+	defer connectionManager.removeConnection(conn1)
+
+	conn2 := &testConn{addr: "127.0.0.1:10002"}
+	c2 := newConnection(conn2.addr, base.RateLimitConfig{})
+	connectionManager.addConnection(conn2, c2)
+	defer connectionManager.removeConnection(conn2)
+
+	assert.Equal(t, c1.id+1, c2.id)
+	c1.setName("worker")
+	c1.setLastCommand("get")
+	c2.setLastCommand("client")
+
+	now := c1.createdAt.Add(3 * time.Second)
+	expected := fmt.Sprintf(
+		"id=%d addr=127.0.0.1:10001 name=worker age=3 cmd=get\nid=%d addr=127.0.0.1:10002 name= age=%d cmd=client\n",
+		c1.id, c2.id, int64(now.Sub(c2.createdAt).Seconds()))
+	assert.Equal(t, expected, connectionManager.list(now))
+
+	connectionManager.removeConnection(conn1)
+	assert.Nil(t, connectionManager.getConnection(conn1))
+}
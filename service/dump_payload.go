@@ -0,0 +1,692 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"math"
+	"strconv"
+)
+
+// Payloads of dump and restore commands use the serialization format of redis,
+// so keys could be migrated between room and redis:
+// 1 byte of rdb type, serialized value, 2 bytes of rdb version and 8 bytes of crc64 checksum (both little endian).
+// Values are dumped with the basic rdb types which are loadable by all redis versions,
+// payloads dumped by redis with compact encodings (ziplist, listpack, intset and quicklist) are restorable too.
+
+const (
+	// dumpRDBVersion is the rdb version of redis 6, payloads of newer redis versions are accepted by restore.
+	dumpRDBVersion    = 9
+	dumpMaxRDBVersion = 12
+	// dumpPayloadFooterSize is size of rdb version and checksum at the end of dump payload.
+	dumpPayloadFooterSize = 10
+)
+
+const (
+	rdbTypeString          = 0
+	rdbTypeList            = 1
+	rdbTypeSet             = 2
+	rdbTypeZSet            = 3
+	rdbTypeHash            = 4
+	rdbTypeZSet2           = 5
+	rdbTypeListZiplist     = 10
+	rdbTypeSetIntset       = 11
+	rdbTypeZSetZiplist     = 12
+	rdbTypeHashZiplist     = 13
+	rdbTypeListQuicklist   = 14
+	rdbTypeHashListpack    = 16
+	rdbTypeZSetListpack    = 17
+	rdbTypeListQuicklist2  = 18
+	rdbTypeSetListpack     = 20
+	rdbQuicklistNodePlain  = 1
+	rdbQuicklistNodePacked = 2
+)
+
+const (
+	rdbLen6Bit  = 0
+	rdbLen14Bit = 1
+	rdbLen32Bit = 0x80
+	rdbLen64Bit = 0x81
+	rdbEncVal   = 3
+	rdbEncInt8  = 0
+	rdbEncInt16 = 1
+	rdbEncInt32 = 2
+	rdbEncLZF   = 3
+)
+
+var (
+	errDumpPayloadInvalid   = errors.New("ERR DUMP payload version or checksum are wrong")
+	errRestoreBadDataFormat = errors.New("ERR Bad data format")
+)
+
+func newDumpUnsupportedTypeError(valueType string) error {
+	return fmt.Errorf("ERR DUMP of %s type is not supported", valueType)
+}
+
+// crc64Table is the table of crc-64-jones used by redis, in reversed bit order.
+var crc64Table = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// dumpChecksum returns crc64 of data like redis, whose initial value and final xor are 0.
+func dumpChecksum(data []byte) uint64 {
+	return ^crc64.Update(^uint64(0), crc64Table, data)
+}
+
+func encodeDumpPayload(value RedisValue) (string, error) {
+	data, err := encodeRDBValue(value)
+	if err != nil {
+		return "", err
+	}
+	footer := make([]byte, dumpPayloadFooterSize)
+	binary.LittleEndian.PutUint16(footer, dumpRDBVersion)
+	data = append(data, footer[:2]...)
+	binary.LittleEndian.PutUint64(footer[2:], dumpChecksum(data))
+	data = append(data, footer[2:]...)
+	return string(data), nil
+}
+
+// decodeDumpPayload returns value of payload, expiration of value is not included.
+func decodeDumpPayload(payload string) (RedisValue, error) {
+	data := []byte(payload)
+	if len(data) < dumpPayloadFooterSize+1 {
+		return RedisValue{}, errDumpPayloadInvalid
+	}
+	checksumIndex := len(data) - 8
+	if binary.LittleEndian.Uint16(data[checksumIndex-2:checksumIndex]) > dumpMaxRDBVersion {
+		return RedisValue{}, errDumpPayloadInvalid
+	}
+	if binary.LittleEndian.Uint64(data[checksumIndex:]) != dumpChecksum(data[:checksumIndex]) {
+		return RedisValue{}, errDumpPayloadInvalid
+	}
+	reader := &rdbReader{data: data[:checksumIndex-2]}
+	value, err := reader.readValue()
+	if err != nil || reader.pos != len(reader.data) {
+		return RedisValue{}, errRestoreBadDataFormat
+	}
+	return value, nil
+}
+
+func encodeRDBValue(value RedisValue) ([]byte, error) {
+	if value.Type == stringType {
+		return appendRDBString([]byte{rdbTypeString}, value.Value), nil
+	}
+	var items []string
+	if err := json.Unmarshal([]byte(value.Value), &items); err != nil {
+		return nil, err
+	}
+	var data []byte
+	switch value.Type {
+	case listType:
+		data = appendRDBLen([]byte{rdbTypeList}, uint64(len(items)))
+		for _, item := range items {
+			data = appendRDBString(data, item)
+		}
+	case setType:
+		data = appendRDBLen([]byte{rdbTypeSet}, uint64(len(items)))
+		for _, item := range items {
+			data = appendRDBString(data, item)
+		}
+	case hashType:
+		if len(items)%2 != 0 {
+			return nil, errDataFormatError
+		}
+		data = appendRDBLen([]byte{rdbTypeHash}, uint64(len(items)/2))
+		for _, item := range items {
+			data = appendRDBString(data, item)
+		}
+	case zsetType:
+		if len(items)%2 != 0 {
+			return nil, errDataFormatError
+		}
+		data = appendRDBLen([]byte{rdbTypeZSet2}, uint64(len(items)/2))
+		for i := 0; i < len(items); i += 2 {
+			score, err := strconv.ParseFloat(items[i+1], 64)
+			if err != nil {
+				return nil, errDataFormatError
+			}
+			data = appendRDBString(data, items[i])
+			data = appendUint(data, binary.LittleEndian, math.Float64bits(score), 8)
+		}
+	default:
+		return nil, newDumpUnsupportedTypeError(value.Type)
+	}
+	return data, nil
+}
+
+func appendRDBLen(data []byte, length uint64) []byte {
+	switch {
+	case length < 1<<6:
+		return append(data, byte(length))
+	case length < 1<<14:
+		return append(data, byte(length>>8)|rdbLen14Bit<<6, byte(length))
+	case length <= math.MaxUint32:
+		return appendUint(append(data, rdbLen32Bit), binary.BigEndian, length, 4)
+	}
+	return appendUint(append(data, rdbLen64Bit), binary.BigEndian, length, 8)
+}
+
+// appendUint appends the lowest size bytes of value in order, size is 2, 4 or 8.
+func appendUint(data []byte, order binary.ByteOrder, value uint64, size int) []byte {
+	bs := make([]byte, 8)
+	switch size {
+	case 2:
+		order.PutUint16(bs, uint16(value))
+	case 4:
+		order.PutUint32(bs, uint32(value))
+	default:
+		order.PutUint64(bs, value)
+	}
+	return append(data, bs[:size]...)
+}
+
+// appendRDBString saves strings of integers in 32 bits as integers like redis.
+func appendRDBString(data []byte, s string) []byte {
+	if len(s) <= 11 {
+		if i, err := strconv.ParseInt(s, 10, 32); err == nil && strconv.FormatInt(i, 10) == s {
+			switch {
+			case i >= math.MinInt8 && i <= math.MaxInt8:
+				return append(data, rdbEncVal<<6|rdbEncInt8, byte(i))
+			case i >= math.MinInt16 && i <= math.MaxInt16:
+				return appendUint(append(data, rdbEncVal<<6|rdbEncInt16), binary.LittleEndian, uint64(i), 2)
+			default:
+				return appendUint(append(data, rdbEncVal<<6|rdbEncInt32), binary.LittleEndian, uint64(i), 4)
+			}
+		}
+	}
+	return append(appendRDBLen(data, uint64(len(s))), s...)
+}
+
+type rdbReader struct {
+	data []byte
+	pos  int
+}
+
+func (reader *rdbReader) readBytes(n uint64) ([]byte, error) {
+	if n > uint64(len(reader.data)-reader.pos) {
+		return nil, errRestoreBadDataFormat
+	}
+	bs := reader.data[reader.pos : reader.pos+int(n)]
+	reader.pos += int(n)
+	return bs, nil
+}
+
+func (reader *rdbReader) readByte() (byte, error) {
+	bs, err := reader.readBytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return bs[0], nil
+}
+
+// readLen returns length, or the encoding type of a string if encoded is true.
+func (reader *rdbReader) readLen() (length uint64, encoded bool, err error) {
+	b, err := reader.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch b >> 6 {
+	case rdbLen6Bit:
+		return uint64(b & 0x3f), false, nil
+	case rdbLen14Bit:
+		next, err := reader.readByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b&0x3f)<<8 | uint64(next), false, nil
+	case rdbEncVal:
+		return uint64(b & 0x3f), true, nil
+	}
+	switch b {
+	case rdbLen32Bit:
+		bs, err := reader.readBytes(4)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint32(bs)), false, nil
+	case rdbLen64Bit:
+		bs, err := reader.readBytes(8)
+		if err != nil {
+			return 0, false, err
+		}
+		return binary.BigEndian.Uint64(bs), false, nil
+	}
+	return 0, false, errRestoreBadDataFormat
+}
+
+func (reader *rdbReader) readCount() (uint64, error) {
+	length, encoded, err := reader.readLen()
+	if err != nil {
+		return 0, err
+	}
+	if encoded {
+		return 0, errRestoreBadDataFormat
+	}
+	return length, nil
+}
+
+func (reader *rdbReader) readString() (string, error) {
+	length, encoded, err := reader.readLen()
+	if err != nil {
+		return "", err
+	}
+	if !encoded {
+		bs, err := reader.readBytes(length)
+		return string(bs), err
+	}
+	switch length {
+	case rdbEncInt8:
+		b, err := reader.readByte()
+		return strconv.FormatInt(int64(int8(b)), 10), err
+	case rdbEncInt16:
+		bs, err := reader.readBytes(2)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(bs))), 10), nil
+	case rdbEncInt32:
+		bs, err := reader.readBytes(4)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(bs))), 10), nil
+	case rdbEncLZF:
+		compressedLen, err := reader.readCount()
+		if err != nil {
+			return "", err
+		}
+		length, err := reader.readCount()
+		if err != nil {
+			return "", err
+		}
+		compressed, err := reader.readBytes(compressedLen)
+		if err != nil {
+			return "", err
+		}
+		bs, err := lzfDecompress(compressed, length)
+		return string(bs), err
+	}
+	return "", errRestoreBadDataFormat
+}
+
+func (reader *rdbReader) readStrings(count uint64) ([]string, error) {
+	items := make([]string, 0)
+	for i := uint64(0); i < count; i++ {
+		item, err := reader.readString()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// readDoubleString reads score of zset saved as string in rdbTypeZSet.
+func (reader *rdbReader) readDoubleString() (string, error) {
+	length, err := reader.readByte()
+	if err != nil {
+		return "", err
+	}
+	switch length {
+	case 253:
+		return "", errRestoreBadDataFormat
+	case 254:
+		return "inf", nil
+	case 255:
+		return "-inf", nil
+	}
+	bs, err := reader.readBytes(uint64(length))
+	return string(bs), err
+}
+
+func (reader *rdbReader) readValue() (RedisValue, error) {
+	rdbType, err := reader.readByte()
+	if err != nil {
+		return RedisValue{}, err
+	}
+	if rdbType == rdbTypeString {
+		s, err := reader.readString()
+		return RedisValue{Type: stringType, Value: s}, err
+	}
+	var valueType string
+	var items []string
+	switch rdbType {
+	case rdbTypeList, rdbTypeSet, rdbTypeHash:
+		valueType = map[byte]string{rdbTypeList: listType, rdbTypeSet: setType, rdbTypeHash: hashType}[rdbType]
+		count, err := reader.readCount()
+		if err != nil {
+			return RedisValue{}, err
+		}
+		if rdbType == rdbTypeHash {
+			count *= 2
+		}
+		items, err = reader.readStrings(count)
+		if err != nil {
+			return RedisValue{}, err
+		}
+	case rdbTypeZSet, rdbTypeZSet2:
+		valueType = zsetType
+		count, err := reader.readCount()
+		if err != nil {
+			return RedisValue{}, err
+		}
+		for i := uint64(0); i < count; i++ {
+			member, err := reader.readString()
+			if err != nil {
+				return RedisValue{}, err
+			}
+			var score string
+			if rdbType == rdbTypeZSet {
+				score, err = reader.readDoubleString()
+			} else {
+				var bs []byte
+				bs, err = reader.readBytes(8)
+				if err == nil {
+					score = formatZSetScore(math.Float64frombits(binary.LittleEndian.Uint64(bs)))
+				}
+			}
+			if err != nil {
+				return RedisValue{}, err
+			}
+			items = append(items, member, score)
+		}
+	case rdbTypeListQuicklist, rdbTypeListQuicklist2:
+		valueType = listType
+		count, err := reader.readCount()
+		if err != nil {
+			return RedisValue{}, err
+		}
+		for i := uint64(0); i < count; i++ {
+			container := uint64(rdbQuicklistNodePacked)
+			if rdbType == rdbTypeListQuicklist2 {
+				if container, err = reader.readCount(); err != nil {
+					return RedisValue{}, err
+				}
+			}
+			node, err := reader.readString()
+			if err != nil {
+				return RedisValue{}, err
+			}
+			var nodeItems []string
+			switch {
+			case container == rdbQuicklistNodePlain:
+				nodeItems = []string{node}
+			case container == rdbQuicklistNodePacked && rdbType == rdbTypeListQuicklist:
+				nodeItems, err = decodeZiplist([]byte(node))
+			case container == rdbQuicklistNodePacked:
+				nodeItems, err = decodeListpack([]byte(node))
+			default:
+				err = errRestoreBadDataFormat
+			}
+			if err != nil {
+				return RedisValue{}, err
+			}
+			items = append(items, nodeItems...)
+		}
+	case rdbTypeListZiplist, rdbTypeZSetZiplist, rdbTypeHashZiplist,
+		rdbTypeHashListpack, rdbTypeZSetListpack, rdbTypeSetListpack, rdbTypeSetIntset:
+		blob, err := reader.readString()
+		if err != nil {
+			return RedisValue{}, err
+		}
+		switch rdbType {
+		case rdbTypeListZiplist:
+			valueType = listType
+			items, err = decodeZiplist([]byte(blob))
+		case rdbTypeZSetZiplist:
+			valueType = zsetType
+			items, err = decodeZiplist([]byte(blob))
+		case rdbTypeHashZiplist:
+			valueType = hashType
+			items, err = decodeZiplist([]byte(blob))
+		case rdbTypeHashListpack:
+			valueType = hashType
+			items, err = decodeListpack([]byte(blob))
+		case rdbTypeZSetListpack:
+			valueType = zsetType
+			items, err = decodeListpack([]byte(blob))
+		case rdbTypeSetListpack:
+			valueType = setType
+			items, err = decodeListpack([]byte(blob))
+		case rdbTypeSetIntset:
+			valueType = setType
+			items, err = decodeIntset([]byte(blob))
+		}
+		if err != nil {
+			return RedisValue{}, err
+		}
+		if (valueType == hashType || valueType == zsetType) && len(items)%2 != 0 {
+			return RedisValue{}, errRestoreBadDataFormat
+		}
+	default:
+		return RedisValue{}, errRestoreBadDataFormat
+	}
+	if items == nil {
+		items = []string{}
+	}
+	value, err := json.Marshal(items)
+	if err != nil {
+		return RedisValue{}, err
+	}
+	return RedisValue{Type: valueType, Value: string(value)}, nil
+}
+
+func formatZSetScore(score float64) string {
+	if math.IsInf(score, 1) {
+		return "inf"
+	}
+	if math.IsInf(score, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'g', 17, 64)
+}
+
+func lzfDecompress(in []byte, length uint64) ([]byte, error) {
+	if length > uint64(len(in))*256 {
+		return nil, errRestoreBadDataFormat
+	}
+	out := make([]byte, 0, length)
+	for ip := 0; ip < len(in); {
+		ctrl := int(in[ip])
+		ip++
+		if ctrl < 1<<5 {
+			ctrl++
+			if ip+ctrl > len(in) {
+				return nil, errRestoreBadDataFormat
+			}
+			out = append(out, in[ip:ip+ctrl]...)
+			ip += ctrl
+			continue
+		}
+		n := ctrl >> 5
+		if n == 7 {
+			if ip >= len(in) {
+				return nil, errRestoreBadDataFormat
+			}
+			n += int(in[ip])
+			ip++
+		}
+		if ip >= len(in) {
+			return nil, errRestoreBadDataFormat
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(in[ip]) - 1
+		ip++
+		if ref < 0 {
+			return nil, errRestoreBadDataFormat
+		}
+		for i := 0; i < n+2; i++ {
+			out = append(out, out[ref+i])
+		}
+	}
+	if uint64(len(out)) != length {
+		return nil, errRestoreBadDataFormat
+	}
+	return out, nil
+}
+
+func decodeIntset(data []byte) ([]string, error) {
+	if len(data) < 8 {
+		return nil, errRestoreBadDataFormat
+	}
+	encoding := int(binary.LittleEndian.Uint32(data))
+	length := int(binary.LittleEndian.Uint32(data[4:]))
+	if (encoding != 2 && encoding != 4 && encoding != 8) || len(data)-8 != length*encoding {
+		return nil, errRestoreBadDataFormat
+	}
+	items := make([]string, 0, length)
+	for i := 8; i < len(data); i += encoding {
+		var item int64
+		switch encoding {
+		case 2:
+			item = int64(int16(binary.LittleEndian.Uint16(data[i:])))
+		case 4:
+			item = int64(int32(binary.LittleEndian.Uint32(data[i:])))
+		case 8:
+			item = int64(binary.LittleEndian.Uint64(data[i:]))
+		}
+		items = append(items, strconv.FormatInt(item, 10))
+	}
+	return items, nil
+}
+
+// littleEndianInt returns the signed integer saved in little endian bytes.
+func littleEndianInt(bs []byte) int64 {
+	var u uint64
+	for i := len(bs) - 1; i >= 0; i-- {
+		u = u<<8 | uint64(bs[i])
+	}
+	shift := uint(64 - 8*len(bs))
+	return int64(u<<shift) >> shift
+}
+
+// decodeZiplist returns entries of ziplist used by redis before 7.0.
+func decodeZiplist(data []byte) ([]string, error) {
+	if len(data) < 11 || int(binary.LittleEndian.Uint32(data)) != len(data) || data[len(data)-1] != 0xff {
+		return nil, errRestoreBadDataFormat
+	}
+	reader := &rdbReader{data: data[:len(data)-1], pos: 10}
+	items := make([]string, 0)
+	for reader.pos < len(reader.data) {
+		prevLen, err := reader.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if prevLen == 0xfe {
+			if _, err := reader.readBytes(4); err != nil {
+				return nil, err
+			}
+		}
+		encoding, err := reader.readByte()
+		if err != nil {
+			return nil, err
+		}
+		var item string
+		var bs []byte
+		switch {
+		case encoding>>6 == 0:
+			bs, err = reader.readBytes(uint64(encoding & 0x3f))
+			item = string(bs)
+		case encoding>>6 == 1:
+			var next byte
+			if next, err = reader.readByte(); err == nil {
+				bs, err = reader.readBytes(uint64(encoding&0x3f)<<8 | uint64(next))
+				item = string(bs)
+			}
+		case encoding == 0x80:
+			if bs, err = reader.readBytes(4); err == nil {
+				bs, err = reader.readBytes(uint64(binary.BigEndian.Uint32(bs)))
+				item = string(bs)
+			}
+		default:
+			size := map[byte]uint64{0xc0: 2, 0xd0: 4, 0xe0: 8, 0xf0: 3, 0xfe: 1}[encoding]
+			if size > 0 {
+				if bs, err = reader.readBytes(size); err == nil {
+					item = strconv.FormatInt(littleEndianInt(bs), 10)
+				}
+			} else if encoding >= 0xf1 && encoding <= 0xfd {
+				item = strconv.Itoa(int(encoding&0x0f) - 1)
+			} else {
+				err = errRestoreBadDataFormat
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// decodeListpack returns entries of listpack used by redis since 7.0.
+func decodeListpack(data []byte) ([]string, error) {
+	if len(data) < 7 || int(binary.LittleEndian.Uint32(data)) != len(data) || data[len(data)-1] != 0xff {
+		return nil, errRestoreBadDataFormat
+	}
+	reader := &rdbReader{data: data[:len(data)-1], pos: 6}
+	items := make([]string, 0)
+	for reader.pos < len(reader.data) {
+		start := reader.pos
+		encoding, err := reader.readByte()
+		if err != nil {
+			return nil, err
+		}
+		var item string
+		var bs []byte
+		switch {
+		case encoding>>7 == 0:
+			item = strconv.Itoa(int(encoding))
+		case encoding>>6 == 2:
+			bs, err = reader.readBytes(uint64(encoding & 0x3f))
+			item = string(bs)
+		case encoding>>5 == 6:
+			var next byte
+			if next, err = reader.readByte(); err == nil {
+				value := int64(encoding&0x1f)<<8 | int64(next)
+				if value >= 1<<12 {
+					value -= 1 << 13
+				}
+				item = strconv.FormatInt(value, 10)
+			}
+		case encoding>>4 == 0xe:
+			var next byte
+			if next, err = reader.readByte(); err == nil {
+				bs, err = reader.readBytes(uint64(encoding&0x0f)<<8 | uint64(next))
+				item = string(bs)
+			}
+		case encoding == 0xf0:
+			if bs, err = reader.readBytes(4); err == nil {
+				bs, err = reader.readBytes(uint64(binary.LittleEndian.Uint32(bs)))
+				item = string(bs)
+			}
+		default:
+			size := map[byte]uint64{0xf1: 2, 0xf2: 3, 0xf3: 4, 0xf4: 8}[encoding]
+			if size == 0 {
+				err = errRestoreBadDataFormat
+			} else if bs, err = reader.readBytes(size); err == nil {
+				item = strconv.FormatInt(littleEndianInt(bs), 10)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := reader.readBytes(listpackBackLenSize(reader.pos - start)); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// listpackBackLenSize returns bytes of the backward length of an entry whose size is entrySize.
+func listpackBackLenSize(entrySize int) uint64 {
+	switch {
+	case entrySize <= 127:
+		return 1
+	case entrySize < 16383:
+		return 2
+	case entrySize < 2097151:
+		return 3
+	case entrySize < 268435455:
+		return 4
+	}
+	return 5
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testNewDumpPayload appends rdb version and checksum to serialized value like redis.
+func testNewDumpPayload(data ...byte) string {
+	footer := make([]byte, dumpPayloadFooterSize)
+	binary.LittleEndian.PutUint16(footer, dumpRDBVersion)
+	data = append(data, footer[:2]...)
+	binary.LittleEndian.PutUint64(footer[2:], dumpChecksum(data))
+	return string(append(data, footer[2:]...))
+}
+
+func TestDumpChecksum(t *testing.T) {
+	assert.Equal(t, uint64(0xe9c6d914c4b8d9ca), dumpChecksum([]byte("123456789")))
+}
+
+func TestDumpPayload(t *testing.T) {
+	// payload of `SET mykey 10` dumped by redis 6.
+	redisPayload := "\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n"
+	payload, err := encodeDumpPayload(RedisValue{Type: stringType, Value: "10", ExpireTs: 100})
+	assert.Nil(t, err)
+	assert.Equal(t, redisPayload, payload)
+
+	values := []RedisValue{
+		{Type: stringType, Value: ""},
+		{Type: stringType, Value: "-40000"},
+		{Type: stringType, Value: "007"},
+		{Type: stringType, Value: strings.Repeat("a", 20000)},
+		{Type: listType, Value: `["a","1","b"]`},
+		{Type: setType, Value: `["a","300"]`},
+		{Type: hashType, Value: `["f1","v1","f2","2"]`},
+		{Type: zsetType, Value: `["a","1.5","b","-inf"]`},
+	}
+	for _, value := range values {
+		payload, err := encodeDumpPayload(value)
+		assert.Nil(t, err, value.String())
+		decoded, err := decodeDumpPayload(payload)
+		assert.Nil(t, err, value.String())
+		assert.Equal(t, value, decoded)
+	}
+
+	_, err = encodeDumpPayload(RedisValue{Type: streamType, Value: "[]"})
+	assert.Equal(t, newDumpUnsupportedTypeError(streamType), err)
+
+	corrupted := []byte(redisPayload)
+	corrupted[1] = 'x'
+	for _, invalid := range []string{"", "abc", string(corrupted), redisPayload[:len(redisPayload)-1]} {
+		_, err = decodeDumpPayload(invalid)
+		assert.Equal(t, errDumpPayloadInvalid, err)
+	}
+	for _, invalid := range []string{
+		testNewDumpPayload(rdbTypeString, 0x05, 'a'),
+		testNewDumpPayload(rdbTypeString, 0x01, 'a', 'b'),
+		testNewDumpPayload(15, 0x00),
+	} {
+		_, err = decodeDumpPayload(invalid)
+		assert.Equal(t, errRestoreBadDataFormat, err)
+	}
+}
+
+func TestDecodeDumpPayloadWithCompactEncodings(t *testing.T) {
+	ziplist := []byte{
+		0x15, 0, 0, 0, 0x10, 0, 0, 0, 0x03, 0,
+		0x00, 0x02, 'a', 'b', // "ab"
+		0x04, 0xf8, // 7
+		0x02, 0xc0, 0x2c, 0x01, // 300
+		0xff,
+	}
+	listpack := []byte{
+		0x15, 0, 0, 0, 0x04, 0,
+		0x82, 'a', 'b', 0x03, // "ab"
+		0x05, 0x01, // 5
+		0xdf, 0xfe, 0x02, // -2
+		0xf2, 0x70, 0x11, 0x01, 0x04, // 70000
+		0xff,
+	}
+	intset := []byte{0x02, 0, 0, 0, 0x02, 0, 0, 0, 0x01, 0x00, 0xff, 0xff}
+	testCases := []struct {
+		payload string
+		value   RedisValue
+	}{
+		{
+			testNewDumpPayload(rdbTypeString, 0xc3, 0x05, 0x14, 0x00, 'a', 0xe0, 0x0a, 0x00),
+			RedisValue{Type: stringType, Value: strings.Repeat("a", 20)},
+		},
+		{
+			testNewDumpPayload(append([]byte{rdbTypeListQuicklist, 0x01, byte(len(ziplist))}, ziplist...)...),
+			RedisValue{Type: listType, Value: `["ab","7","300"]`},
+		},
+		{
+			testNewDumpPayload(append([]byte{rdbTypeListQuicklist2, 0x01, rdbQuicklistNodePacked, byte(len(listpack))}, listpack...)...),
+			RedisValue{Type: listType, Value: `["ab","5","-2","70000"]`},
+		},
+		{
+			testNewDumpPayload(append([]byte{rdbTypeHashListpack, byte(len(listpack))}, listpack...)...),
+			RedisValue{Type: hashType, Value: `["ab","5","-2","70000"]`},
+		},
+		{
+			testNewDumpPayload(append([]byte{rdbTypeSetIntset, byte(len(intset))}, intset...)...),
+			RedisValue{Type: setType, Value: `["1","-1"]`},
+		},
+		{
+			testNewDumpPayload(rdbTypeZSet, 0x01, 0x01, 'a', 0xfe),
+			RedisValue{Type: zsetType, Value: `["a","inf"]`},
+		},
+	}
+	for _, testCase := range testCases {
+		value, err := decodeDumpPayload(testCase.payload)
+		assert.Nil(t, err, testCase.value.String())
+		assert.Equal(t, testCase.value, value)
+	}
+
+	// odd count of entries is invalid for hash.
+	_, err := decodeDumpPayload(testNewDumpPayload(append([]byte{rdbTypeHashZiplist, byte(len(ziplist))}, ziplist...)...))
+	assert.Equal(t, errRestoreBadDataFormat, err)
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"bytepower_room/commands"
+	"context"
+)
+
+// hGetAllByScanCommand is hgetall of a hash with more fields than max_hgetall_fields,
+// it is processed by room which reads the hash with hscan in batches of count fields,
+// so redis never builds the whole reply at once.
+type hGetAllByScanCommand struct {
+	*commands.HGetAllCommand
+	count int64
+}
+
+// hGetAllCommandByFields returns hGetAllByScanCommand if the hash of hgetall has more fields than max_hgetall_fields,
+// otherwise command is returned as it is.
+func (service *RoomService) hGetAllCommandByFields(command commands.Commander) commands.Commander {
+	c, ok := command.(*commands.HGetAllCommand)
+	limit := service.config.MaxHGetAllFields
+	if !ok || limit <= 0 {
+		return command
+	}
+	count, err := service.dep.Redis.HLen(contextTODO, c.Key()).Result()
+	if err != nil {
+		// errors like WRONGTYPE are returned by hgetall itself.
+		return command
+	}
+	if count <= int64(limit) {
+		return command
+	}
+	service.dep.Metric.MetricIncrease("hgetall.scan")
+	return &hGetAllByScanCommand{HGetAllCommand: c, count: int64(limit)}
+}
+
+// processHGetAllByScanCommand reads the hash with hscan until cursor is 0,
+// fields returned more than once by hscan are deduplicated and the reply is sorted by field like hgetall.
+func (service *RoomService) processHGetAllByScanCommand(ctx context.Context, command *hGetAllByScanCommand) commands.RESPData {
+	hash := make(map[string]string)
+	var cursor uint64
+	for {
+		items, next, err := service.dep.Redis.HScan(ctx, command.Key(), cursor, "", command.count).Result()
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		for index := 0; index+1 < len(items); index += 2 {
+			hash[items[index]] = items[index+1]
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	return commands.ConvertHashToRESPData(hash)
+}
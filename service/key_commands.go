@@ -0,0 +1,224 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"bytepower_room/commands"
+	"bytepower_room/utility"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const scanHashTagKeysBatchSize = 1000
+
+var (
+	errKeysCommandDisabled = errors.New("ERR keys command is disabled")
+	errRestoreBusyKey      = errors.New("BUSYKEY Target key name already exists.")
+	errCopySameObject      = errors.New("ERR source and destination objects are the same")
+)
+
+func newKeysCommandTooManyResultsError(maxCount int) error {
+	return fmt.Errorf("ERR keys matched by pattern exceed max_result_count %d, use a more specific pattern", maxCount)
+}
+
+func (service *RoomService) processKeysCommand(command *commands.KeysCommand) commands.RESPData {
+	config := service.config.KeysCommand
+	if !config.Enabled {
+		return commands.ConvertErrorToRESPData(errKeysCommandDisabled)
+	}
+	keys, err := scanHashTagKeysByPattern(service.dep.DB, command.Pattern(), config.MaxResultCount)
+	if err != nil {
+		service.dep.Metric.MetricIncrease("error.keys")
+		service.logWithAddressAndPid(
+			log.LevelError, "error.keys",
+			log.String("pattern", command.Pattern()),
+			log.Error(err),
+		)
+		return commands.ConvertErrorToRESPData(err)
+	}
+	replies := make([]commands.RESPData, 0, len(keys))
+	for _, key := range keys {
+		replies = append(replies, commands.RESPData{DataType: commands.BulkStringRespType, Value: key})
+	}
+	return commands.RESPData{DataType: commands.ArrayRespType, Value: replies}
+}
+
+// processObjectCommand checks keys in redis since keys of the hash tag have been loaded before the command is processed,
+// except idletime, which reads key and access time of its hash tag without loading it.
+func (service *RoomService) processObjectCommand(ctx context.Context, command *commands.ObjectCommand) commands.RESPData {
+	switch command.SubCommand() {
+	case commands.ObjectSubCommandRefCount:
+		count, err := service.dep.Redis.Exists(ctx, command.Key()).Result()
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		if count == 0 {
+			return commands.RESPData{DataType: commands.NilRespType, Value: nil}
+		}
+		// values are never shared between keys, so refcount is always 1 in room.
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(1)}
+	case commands.ObjectSubCommandFreq:
+		count, err := service.dep.Redis.Exists(ctx, command.Key()).Result()
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		if count == 0 {
+			return commands.RESPData{DataType: commands.NilRespType, Value: nil}
+		}
+		frequency, err := getHashTagFrequency(service.dep.DB, commands.ExtractHashTagFromKey(command.Key()), time.Now())
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(frequency)}
+	case commands.ObjectSubCommandEncoding:
+		value, err := getValueFromRedis(ctx, service.dep.Redis, command.Key())
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		if value.IsZero() {
+			return commands.RESPData{DataType: commands.NilRespType, Value: nil}
+		}
+		encoding, err := value.encoding(service.config.ObjectEncoding)
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		return commands.RESPData{DataType: commands.BulkStringRespType, Value: encoding}
+	case commands.ObjectSubCommandIdleTime:
+		// idle time is seconds since the hash tag is accessed, which is 0 if it has not been recorded yet.
+		value, accessTime, err := getValueAndAccessTime(ctx, service.dep, command.Key())
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		if value.IsZero() {
+			return commands.RESPData{DataType: commands.NilRespType, Value: nil}
+		}
+		idleSeconds := int64(0)
+		if !accessTime.IsZero() {
+			idleSeconds = int64(time.Since(accessTime) / time.Second)
+		}
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: idleSeconds}
+	}
+	return commands.ConvertErrorToRESPData(errors.New("ERR unknown object subcommand"))
+}
+
+// getHashTagFrequency returns the LFU counter of hash tag aged to t,
+// the initial counter is returned if no event of the hash tag is saved yet.
+func getHashTagFrequency(db *base.DBCluster, hashTag string, t time.Time) (int, error) {
+	model, err := loadHashTagKeysByHashTag(db, hashTag)
+	if err != nil {
+		return 0, err
+	}
+	if model == nil {
+		return lfuInitValue, nil
+	}
+	return lfuDecrement(model.Frequency, model.AccessedAt, t), nil
+}
+
+// scanHashTagKeysByPattern scans keys of hash tags in all tables and returns sorted keys matched by pattern,
+// it returns an error if count of matched keys exceeds maxCount.
+func scanHashTagKeysByPattern(db *base.DBCluster, pattern string, maxCount int) ([]string, error) {
+	keys := make([]string, 0)
+	for tableIndex := 0; tableIndex < db.GetShardingCount(); tableIndex++ {
+		lastHashTag := ""
+		for {
+			models, err := loadHashTagKeysModelsInTableByCondition(
+				db, scanHashTagKeysBatchSize, tableIndex,
+				dbWhereCondition{column: "hash_tag", operator: ">?", parameter: lastHashTag},
+			)
+			if err != nil {
+				return nil, err
+			}
+			for _, model := range models {
+				for _, key := range model.Keys {
+					if !utility.MatchPattern(pattern, key) {
+						continue
+					}
+					if len(keys) >= maxCount {
+						return nil, newKeysCommandTooManyResultsError(maxCount)
+					}
+					keys = append(keys, key)
+				}
+			}
+			if len(models) < scanHashTagKeysBatchSize {
+				break
+			}
+			lastHashTag = models[len(models)-1].HashTag
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (service *RoomService) processDumpCommand(ctx context.Context, command *commands.DumpCommand) commands.RESPData {
+	value, err := getValueFromRedis(ctx, service.dep.Redis, command.Key())
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	if value.IsZero() {
+		return commands.RESPData{DataType: commands.NilRespType, Value: nil}
+	}
+	payload, err := encodeDumpPayload(value)
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return commands.RESPData{DataType: commands.BulkStringRespType, Value: payload}
+}
+
+// processRestoreCommand saves value of payload to redis,
+// the value is synced to db by write event of the command like other written keys.
+func (service *RoomService) processRestoreCommand(ctx context.Context, command *commands.RestoreCommand) commands.RESPData {
+	value, err := decodeDumpPayload(command.Value())
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	redisCluster := service.dep.Redis
+	if !command.Replace() {
+		count, err := redisCluster.Exists(ctx, command.Key()).Result()
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		if count > 0 {
+			return commands.ConvertErrorToRESPData(errRestoreBusyKey)
+		}
+	}
+	if command.TTL() > 0 {
+		value.ExpireTs = utility.TimestampInMS(time.Now().Add(command.TTL()))
+	}
+	// existing key is deleted before its value is replaced.
+	if err := loadKeyToRedis(ctx, redisCluster, command.Key(), value); err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return commands.RESPData{DataType: commands.SimpleStringRespType, Value: "OK"}
+}
+
+// processCopyCommand copies value of source key in redis to destination key with its expiration,
+// keys of the hash tag have been loaded before, and destination key is synced to db by write event of the command.
+func (service *RoomService) processCopyCommand(ctx context.Context, command *commands.CopyCommand) commands.RESPData {
+	if command.Source() == command.Destination() {
+		return commands.ConvertErrorToRESPData(errCopySameObject)
+	}
+	redisCluster := service.dep.Redis
+	value, err := getValueFromRedis(ctx, redisCluster, command.Source())
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	if value.IsZero() {
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(0)}
+	}
+	if !command.Replace() {
+		count, err := redisCluster.Exists(ctx, command.Destination()).Result()
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		if count > 0 {
+			return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(0)}
+		}
+	}
+	if err := loadKeyToRedis(ctx, redisCluster, command.Destination(), value); err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(1)}
+}
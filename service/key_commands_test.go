@@ -0,0 +1,763 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"bytepower_room/utility"
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanHashTagKeysByPattern(t *testing.T) {
+	db := base.GetServerDependency().DB
+	hashTagKeys := map[string][]string{
+		"keys1": {"{keys1}a", "{keys1}ab", "{keys1}b"},
+		"keys2": {"{keys2}a", "{keys2}c"},
+	}
+	for hashTag, keys := range hashTagKeys {
+		defer testEmptyHashTagKeysRecordInDB(hashTag)
+		event, _ := base.NewHashTagEvent(hashTag, keys, base.HashTagAccessModeWrite, time.Now())
+		assert.Nil(t, upsertHashTagKeysRecordByEvent(context.TODO(), db, event, time.Now()))
+	}
+
+	testCases := []struct {
+		pattern string
+		keys    []string
+	}{
+		{"{keys?}a", []string{"{keys1}a", "{keys2}a"}},
+		{"{keys1}*", []string{"{keys1}a", "{keys1}ab", "{keys1}b"}},
+		{"{keys[2-3]}*", []string{"{keys2}a", "{keys2}c"}},
+		{"{keys*}[^a]", []string{"{keys1}b", "{keys2}c"}},
+		{"{keys3}*", []string{}},
+	}
+	for _, testCase := range testCases {
+		keys, err := scanHashTagKeysByPattern(db, testCase.pattern, 10)
+		assert.Nil(t, err, testCase.pattern)
+		assert.Equal(t, testCase.keys, keys, testCase.pattern)
+	}
+
+	_, err := scanHashTagKeysByPattern(db, "{keys*}*", 4)
+	assert.Equal(t, newKeysCommandTooManyResultsError(4), err)
+}
+
+func TestProcessObjectRefCountCommand(t *testing.T) {
+	service := &RoomService{dep: base.GetServerDependency()}
+	key := "{object}a"
+	defer testEmptyKeysInRedis(key)
+
+	command, err := commands.NewObjectCommand([]string{"object", "refcount", key})
+	assert.Nil(t, err)
+	assert.Equal(t, commands.RESPData{DataType: commands.NilRespType}, service.processObjectCommand(context.TODO(), command.(*commands.ObjectCommand)))
+
+	assert.Nil(t, service.dep.Redis.Set(contextTODO, key, "value", 0).Err())
+	assert.Equal(
+		t,
+		commands.RESPData{DataType: commands.IntegerRespType, Value: int64(1)},
+		service.processObjectCommand(context.TODO(), command.(*commands.ObjectCommand)),
+	)
+}
+
+func TestProcessObjectEncodingCommand(t *testing.T) {
+	service := &RoomService{dep: base.GetServerDependency(), config: &base.RoomServerConfig{}}
+	key := "{object}a"
+	defer testEmptyKeysInRedis(key)
+
+	command, err := commands.NewObjectCommand([]string{"object", "encoding", key})
+	assert.Nil(t, err)
+	assert.Equal(t, commands.RESPData{DataType: commands.NilRespType}, service.processObjectCommand(context.TODO(), command.(*commands.ObjectCommand)))
+
+	assert.Nil(t, service.dep.Redis.RPush(contextTODO, key, "a", "b").Err())
+	assert.Equal(
+		t,
+		commands.RESPData{DataType: commands.BulkStringRespType, Value: "listpack"},
+		service.processObjectCommand(context.TODO(), command.(*commands.ObjectCommand)),
+	)
+	service.config.ObjectEncoding.ListMaxListpackEntries = 1
+	assert.Equal(
+		t,
+		commands.RESPData{DataType: commands.BulkStringRespType, Value: "quicklist"},
+		service.processObjectCommand(context.TODO(), command.(*commands.ObjectCommand)),
+	)
+}
+
+func TestProcessObjectIdleTimeCommand(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{dep: dep}
+	hashTag := "object_idletime"
+	key := "{object_idletime}a"
+	defer testEmptyKeysInRedis(key)
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	testEmptyKeysInRedis(key)
+	testCleanLocalloadedCache(hashTag)
+
+	command, err := commands.ParseCommand([]string{"object", "idletime", key})
+	assert.Nil(t, err)
+	assert.Equal(t, commands.RESPData{DataType: commands.NilRespType}, service.processObjectCommand(context.TODO(), command.(*commands.ObjectCommand)))
+
+	// hash tag is not loaded and has no access record, value is read from db.
+	testInsertRoomData(hashTag, map[string]RedisValue{key: {Type: stringType, Value: "100"}})
+	assert.Equal(
+		t,
+		commands.RESPData{DataType: commands.IntegerRespType, Value: int64(0)},
+		service.processObjectCommand(context.TODO(), command.(*commands.ObjectCommand)),
+	)
+	count, _ := dep.Redis.Exists(contextTODO, getHashTagMetaKey(hashTag)).Result()
+	assert.Equal(t, int64(0), count)
+
+	// hash tag is loaded, access time is not updated by the command.
+	accessTime := time.Now().Add(-10 * time.Second)
+	assert.Nil(t, Load(dep, hashTag, accessTime, base.HashTagAccessModeRead))
+	for i := 0; i < 2; i++ {
+		result := service.processObjectCommand(context.TODO(), command.(*commands.ObjectCommand))
+		assert.Equal(t, commands.IntegerRespType, result.DataType)
+		idleSeconds := result.Value.(int64)
+		assert.True(t, idleSeconds >= 10 && idleSeconds < 20, idleSeconds)
+	}
+}
+
+func TestProcessDumpAndRestoreCommands(t *testing.T) {
+	service := &RoomService{dep: base.GetServerDependency()}
+	redisCluster := service.dep.Redis
+	key, restoredKey := "{dump}a", "{dump}b"
+	defer testEmptyKeysInRedis(key, restoredKey)
+
+	dump, _ := commands.NewDumpCommand([]string{"dump", key})
+	assert.Equal(t, commands.RESPData{DataType: commands.NilRespType}, service.processDumpCommand(context.TODO(), dump.(*commands.DumpCommand)))
+
+	assert.Nil(t, redisCluster.RPush(contextTODO, key, "a", "b").Err())
+	result := service.processDumpCommand(context.TODO(), dump.(*commands.DumpCommand))
+	assert.Equal(t, commands.BulkStringRespType, result.DataType)
+	payload := result.Value.(string)
+
+	restore, _ := commands.NewRestoreCommand([]string{"restore", restoredKey, "10000", payload})
+	assert.Equal(
+		t,
+		commands.RESPData{DataType: commands.SimpleStringRespType, Value: "OK"},
+		service.processRestoreCommand(context.TODO(), restore.(*commands.RestoreCommand)),
+	)
+	assert.Equal(t, []string{"a", "b"}, redisCluster.LRange(contextTODO, restoredKey, 0, -1).Val())
+	assert.True(t, redisCluster.PTTL(contextTODO, restoredKey).Val() > 0)
+
+	assert.Equal(
+		t,
+		commands.ConvertErrorToRESPData(errRestoreBusyKey),
+		service.processRestoreCommand(context.TODO(), restore.(*commands.RestoreCommand)),
+	)
+
+	assert.Nil(t, redisCluster.Set(contextTODO, restoredKey, "value", 0).Err())
+	restore, _ = commands.NewRestoreCommand([]string{"restore", restoredKey, "0", payload, "replace"})
+	assert.Equal(
+		t,
+		commands.RESPData{DataType: commands.SimpleStringRespType, Value: "OK"},
+		service.processRestoreCommand(context.TODO(), restore.(*commands.RestoreCommand)),
+	)
+	assert.Equal(t, []string{"a", "b"}, redisCluster.LRange(contextTODO, restoredKey, 0, -1).Val())
+	assert.Equal(t, time.Duration(-1), redisCluster.PTTL(contextTODO, restoredKey).Val())
+}
+
+func TestCopyCommandWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{dep: dep}
+	hashTag := "copy"
+	unexpiredTs := utility.TimestampInMS(time.Now().Add(time.Hour))
+	value := map[string]RedisValue{
+		"{copy}list":   {Type: listType, Value: `["a","b"]`},
+		"{copy}string": {Type: stringType, Value: "x", ExpireTs: unexpiredTs},
+	}
+	keys := []string{"{copy}list", "{copy}string", "{copy}dst", "{copy}not_exist"}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	execute := func(args ...string) commands.RESPData {
+		command, err := commands.ParseCommand(args)
+		assert.Nil(t, err)
+		if err := preProcessCommand(dep, command, time.Now()); err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		return service.processCopyCommand(context.TODO(), command.(*commands.CopyCommand))
+	}
+
+	assert.Equal(t, "i:0", execute("copy", "{copy}not_exist", "{copy}dst").String())
+	assert.Equal(t, "i:1", execute("copy", "{copy}list", "{copy}dst").String())
+	result, err := dep.Redis.LRange(context.TODO(), "{copy}dst", 0, -1).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, result)
+	assert.Equal(t, time.Duration(-1), dep.Redis.PTTL(context.TODO(), "{copy}dst").Val())
+
+	// destination is not overwritten without replace.
+	assert.Equal(t, "i:0", execute("copy", "{copy}string", "{copy}dst").String())
+	assert.Equal(t, "list", dep.Redis.Type(context.TODO(), "{copy}dst").Val())
+
+	// expiration of source is copied.
+	assert.Equal(t, "i:1", execute("copy", "{copy}string", "{copy}dst", "replace").String())
+	assert.Equal(t, "x", dep.Redis.Get(context.TODO(), "{copy}dst").Val())
+	assert.True(t, dep.Redis.PTTL(context.TODO(), "{copy}dst").Val() > 0)
+
+	assert.Equal(
+		t,
+		commands.ConvertErrorToRESPData(errCopySameObject),
+		execute("copy", "{copy}list", "{copy}list", "replace"),
+	)
+}
+
+func TestRenameCommandsWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "rename"
+	unexpiredTs := utility.TimestampInMS(time.Now().Add(time.Hour))
+	value := map[string]RedisValue{
+		"{rename}hash":   {Type: hashType, Value: `["f","v"]`},
+		"{rename}string": {Type: stringType, Value: "x", ExpireTs: unexpiredTs},
+		"{rename}set":    {Type: setType, Value: `["a"]`},
+	}
+	keys := []string{"{rename}hash", "{rename}string", "{rename}set", "{rename}new", "{rename}not_exist"}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	assert.Equal(t, "err:ERR no such key", testExecuteCommand(t, dep, "rename", "{rename}not_exist", "{rename}new").String())
+	assert.Equal(t, "err:ERR no such key", testExecuteCommand(t, dep, "renamenx", "{rename}not_exist", "{rename}new").String())
+
+	// expiration moves with the value.
+	assert.Equal(t, "s:OK", testExecuteCommand(t, dep, "rename", "{rename}string", "{rename}new").String())
+	assert.Equal(t, "i:0", testExecuteCommand(t, dep, "exists", "{rename}string").String())
+	assert.Equal(t, "bs:x", testExecuteCommand(t, dep, "get", "{rename}new").String())
+	assert.True(t, dep.Redis.PTTL(context.TODO(), "{rename}new").Val() > 0)
+
+	// renamenx does not overwrite existing key.
+	assert.Equal(t, "i:0", testExecuteCommand(t, dep, "renamenx", "{rename}hash", "{rename}new").String())
+	assert.Equal(t, "i:1", testExecuteCommand(t, dep, "exists", "{rename}hash").String())
+
+	// rename overwrites existing key and its expiration.
+	assert.Equal(t, "s:OK", testExecuteCommand(t, dep, "rename", "{rename}hash", "{rename}new").String())
+	result, err := dep.Redis.HGetAll(context.TODO(), "{rename}new").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"f": "v"}, result)
+	assert.Equal(t, time.Duration(-1), dep.Redis.PTTL(context.TODO(), "{rename}new").Val())
+
+	assert.Equal(t, "i:1", testExecuteCommand(t, dep, "renamenx", "{rename}set", "{rename}set2").String())
+	defer testEmptyKeysInRedis("{rename}set2")
+	assert.Equal(t, "i:0", testExecuteCommand(t, dep, "exists", "{rename}set").String())
+
+	// keys of different hash tags are rejected before loading.
+	assert.Equal(t, "err:ERR keys not have the same hash tag", testExecuteCommand(t, dep, "rename", "{rename}new", "{other}new").String())
+}
+
+func TestTypeCommandWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "type"
+	expiredTs := utility.TimestampInMS(time.Now().Add(-time.Minute))
+	unexpiredTs := utility.TimestampInMS(time.Now().Add(time.Hour))
+	value := map[string]RedisValue{
+		"{type}string":  {Type: stringType, Value: "a", ExpireTs: unexpiredTs},
+		"{type}list":    {Type: listType, Value: `["a","b"]`},
+		"{type}hash":    {Type: hashType, Value: `["f","v"]`},
+		"{type}set":     {Type: setType, Value: `["a"]`},
+		"{type}zset":    {Type: zsetType, Value: `["a","1"]`},
+		"{type}expired": {Type: stringType, Value: "a", ExpireTs: expiredTs},
+	}
+	keys := []string{"{type}not_exist"}
+	for key := range value {
+		keys = append(keys, key)
+	}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	testCases := []struct {
+		key     string
+		keyType string
+	}{
+		{"{type}string", "string"},
+		{"{type}list", "list"},
+		{"{type}hash", "hash"},
+		{"{type}set", "set"},
+		{"{type}zset", "zset"},
+		{"{type}expired", "none"},
+		{"{type}not_exist", "none"},
+	}
+	for _, testCase := range testCases {
+		command, err := commands.ParseCommand([]string{"type", testCase.key})
+		assert.Nil(t, err)
+		assert.Equal(t, base.HashTagAccessModeRead, commands.GetCommnadKeysAccessMode(command))
+		assert.Nil(t, preProcessCommand(dep, command, time.Now()), testCase.key)
+		assert.Equal(
+			t,
+			commands.RESPData{DataType: commands.SimpleStringRespType, Value: testCase.keyType},
+			commands.ExecuteCommand(dep.Redis, command),
+			testCase.key,
+		)
+	}
+}
+
+func TestIncrCommandsWithPersistedValues(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "incr"
+	value := map[string]RedisValue{
+		"{incr}max":    {Type: stringType, Value: strconv.FormatInt(math.MaxInt64, 10)},
+		"{incr}min":    {Type: stringType, Value: strconv.FormatInt(math.MinInt64, 10)},
+		"{incr}string": {Type: stringType, Value: "abc"},
+		"{incr}binary": {Type: stringType, Value: "1\x002"},
+		"{incr}number": {Type: stringType, Value: "10"},
+	}
+	keys := []string{}
+	for key := range value {
+		keys = append(keys, key)
+	}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	overflowErr := "ERR increment or decrement would overflow"
+	invalidErr := "ERR value is not an integer or out of range"
+	testCases := []struct {
+		args   []string
+		result interface{}
+	}{
+		{[]string{"incr", "{incr}max"}, overflowErr},
+		{[]string{"incrby", "{incr}max", "1"}, overflowErr},
+		{[]string{"decrby", "{incr}max", "-1"}, overflowErr},
+		{[]string{"decr", "{incr}min"}, overflowErr},
+		{[]string{"decrby", "{incr}min", "1"}, overflowErr},
+		{[]string{"incrby", "{incr}min", "-1"}, overflowErr},
+		{[]string{"incr", "{incr}string"}, invalidErr},
+		{[]string{"decrby", "{incr}string", "1"}, invalidErr},
+		{[]string{"incr", "{incr}binary"}, invalidErr},
+		{[]string{"incrby", "{incr}number", "-11"}, int64(-1)},
+		{[]string{"incrby", "{incr}max", "-1"}, int64(math.MaxInt64 - 1)},
+		{[]string{"decr", "{incr}number"}, int64(-2)},
+	}
+	for _, testCase := range testCases {
+		result := testExecuteCommand(t, dep, testCase.args...)
+		if message, ok := testCase.result.(string); ok {
+			assert.Equal(t, commands.ErrorRespType, result.DataType, testCase.args)
+			assert.Equal(t, message, result.Value.(error).Error(), testCase.args)
+		} else {
+			assert.Equal(t, commands.RESPData{DataType: commands.IntegerRespType, Value: testCase.result}, result, testCase.args)
+		}
+	}
+
+	// values are not modified by failed commands.
+	for key, v := range map[string]string{
+		"{incr}max":    strconv.FormatInt(math.MaxInt64-1, 10),
+		"{incr}min":    strconv.FormatInt(math.MinInt64, 10),
+		"{incr}string": "abc",
+		"{incr}binary": "1\x002",
+	} {
+		result, err := dep.Redis.Get(context.TODO(), key).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, v, result, key)
+	}
+}
+
+func TestWrongTypeWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "wrongtype"
+	value := map[string]RedisValue{
+		"{wrongtype}string": {Type: stringType, Value: "a"},
+	}
+	keys := []string{"{wrongtype}string", "{wrongtype}list"}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	command, err := commands.ParseCommand([]string{"lpush", "{wrongtype}string", "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, errWrongType, preProcessCommand(dep, command, time.Now()))
+	result, err := dep.Redis.Get(context.TODO(), "{wrongtype}string").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "a", result)
+
+	// key does not exist yet, the command decides.
+	command, err = commands.ParseCommand([]string{"lpush", "{wrongtype}list", "a"})
+	assert.Nil(t, err)
+	assert.Nil(t, preProcessCommand(dep, command, time.Now()))
+	assert.Equal(
+		t,
+		commands.RESPData{DataType: commands.IntegerRespType, Value: int64(1)},
+		commands.ExecuteCommand(dep.Redis, command),
+	)
+
+	// keys of any type are not checked.
+	command, err = commands.ParseCommand([]string{"del", "{wrongtype}string", "{wrongtype}list"})
+	assert.Nil(t, err)
+	assert.Nil(t, preProcessCommand(dep, command, time.Now()))
+}
+
+func TestSetCommandsWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "set_commands"
+	value := map[string]RedisValue{
+		"{set_commands}set":    {Type: setType, Value: `["a","b","c"]`},
+		"{set_commands}string": {Type: stringType, Value: "a"},
+	}
+	keys := []string{"{set_commands}set", "{set_commands}string", "{set_commands}new"}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	integer := func(n int64) commands.RESPData {
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: n}
+	}
+
+	assert.Equal(t, commands.ConvertErrorToRESPData(errWrongType), testExecuteCommand(t, dep, "sadd", "{set_commands}string", "a"))
+	assert.Equal(t, commands.ConvertErrorToRESPData(errWrongType), testExecuteCommand(t, dep, "scard", "{set_commands}string"))
+	assert.Equal(t, integer(3), testExecuteCommand(t, dep, "scard", "{set_commands}set"))
+	assert.Equal(t, integer(1), testExecuteCommand(t, dep, "sadd", "{set_commands}set", "a", "d", "d"))
+	assert.Equal(t, integer(1), testExecuteCommand(t, dep, "sismember", "{set_commands}set", "d"))
+	assert.Equal(t, integer(0), testExecuteCommand(t, dep, "sismember", "{set_commands}set", "e"))
+	members, err := dep.Redis.SMembers(context.TODO(), "{set_commands}set").Result()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, members)
+
+	result := testExecuteCommand(t, dep, "spop", "{set_commands}set", "2")
+	assert.Equal(t, commands.ArrayRespType, result.DataType)
+	assert.Equal(t, 2, len(result.Value.([]commands.RESPData)))
+	assert.Equal(t, integer(2), testExecuteCommand(t, dep, "scard", "{set_commands}set"))
+
+	members, err = dep.Redis.SMembers(context.TODO(), "{set_commands}set").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, integer(2), testExecuteCommand(t, dep, "srem", "{set_commands}set", members[0], members[1], "e"))
+	n, err := dep.Redis.Exists(context.TODO(), "{set_commands}set").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+
+	assert.Equal(t, integer(0), testExecuteCommand(t, dep, "scard", "{set_commands}new"))
+	assert.Equal(t, integer(0), testExecuteCommand(t, dep, "srem", "{set_commands}new", "a"))
+}
+
+func TestSMembersRoundTripWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "smembers_round_trip"
+	key := "{smembers_round_trip}set"
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(key)
+	reset := func(value string) {
+		testEmptyRoomDataRecordInDatabase(hashTag)
+		testEmptyKeysInRedis(key)
+		testCleanLocalloadedCache(hashTag)
+		testSetMetaKeyCleaned(hashTag)
+		testInsertRoomData(hashTag, map[string]RedisValue{key: {Type: setType, Value: value}})
+	}
+	// smembers returns members sorted, since order of members in redis is not deterministic.
+	smembers := func() []string {
+		result := testExecuteCommand(t, dep, "smembers", key)
+		assert.Equal(t, commands.ArrayRespType, result.DataType)
+		members := []string{}
+		for _, item := range result.Value.([]commands.RESPData) {
+			members = append(members, item.Value.(string))
+		}
+		sort.Strings(members)
+		return members
+	}
+	unique := func(items []string) []string {
+		members := utility.NewStringSet(items...).ToSlice()
+		sort.Strings(members)
+		return members
+	}
+
+	for _, value := range []string{"", "null", "[]"} {
+		reset(value)
+		assert.Equal(t, []string{}, smembers(), value)
+	}
+
+	config := &quick.Config{MaxCount: 50, Rand: rand.New(rand.NewSource(1))}
+	persisted := func(items []string) bool {
+		value, err := json.Marshal(append(items, items...))
+		assert.Nil(t, err)
+		reset(string(value))
+		return assert.Equal(t, unique(items), smembers())
+	}
+	assert.Nil(t, quick.Check(persisted, config))
+
+	added := func(items []string) bool {
+		reset("[]")
+		if len(items) > 0 {
+			testExecuteCommand(t, dep, append([]string{"sadd", key}, items...)...)
+		}
+		return assert.Equal(t, unique(items), smembers())
+	}
+	assert.Nil(t, quick.Check(added, config))
+}
+
+func TestLRangeWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "lrange_persisted"
+	key := "{lrange_persisted}list"
+	// reference key holds the same items in redis directly, results of room are compared with redis lrange on it.
+	referenceKey := "{lrange_reference}list"
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(key, referenceKey)
+	reset := func(items []string) {
+		testEmptyRoomDataRecordInDatabase(hashTag)
+		testEmptyKeysInRedis(key, referenceKey)
+		testCleanLocalloadedCache(hashTag)
+		testSetMetaKeyCleaned(hashTag)
+		if len(items) > 0 {
+			value, err := json.Marshal(items)
+			assert.Nil(t, err)
+			testInsertRoomData(hashTag, map[string]RedisValue{key: {Type: listType, Value: string(value)}})
+			values := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				values = append(values, item)
+			}
+			assert.Nil(t, dep.Redis.RPush(contextTODO, referenceKey, values...).Err())
+		}
+	}
+	lrange := func(start, stop int) []string {
+		command, err := commands.ParseCommand([]string{"lrange", key, strconv.Itoa(start), strconv.Itoa(stop)})
+		assert.Nil(t, err)
+		assert.Equal(t, base.HashTagAccessModeRead, commands.GetCommnadKeysAccessMode(command))
+		assert.Nil(t, preProcessCommand(dep, command, time.Now()))
+		result := commands.ExecuteCommand(dep.Redis, command)
+		assert.Equal(t, commands.ArrayRespType, result.DataType)
+		items := []string{}
+		for _, item := range result.Value.([]commands.RESPData) {
+			items = append(items, item.Value.(string))
+		}
+		return items
+	}
+	redisLRange := func(start, stop int) []string {
+		items, err := dep.Redis.LRange(contextTODO, referenceKey, int64(start), int64(stop)).Result()
+		assert.Nil(t, err)
+		return items
+	}
+
+	reset([]string{"a", "b", "c", "d", "e"})
+	testCases := []struct {
+		start, stop int
+	}{
+		{0, -1}, {-2, -1}, {-100, 1}, {2, 2}, {-1, -1},
+		{3, 100}, {5, 10}, {3, 1}, {-1, -2}, {0, -100},
+	}
+	for _, testCase := range testCases {
+		expected := redisLRange(testCase.start, testCase.stop)
+		assert.Equal(t, expected, lrange(testCase.start, testCase.stop), testCase.start, testCase.stop)
+	}
+	reset(nil)
+	assert.Equal(t, redisLRange(0, -1), lrange(0, -1))
+
+	config := &quick.Config{MaxCount: 50, Rand: rand.New(rand.NewSource(1))}
+	ranged := func(items []string, start, stop int8) bool {
+		reset(items)
+		expected := redisLRange(int(start), int(stop))
+		return assert.Equal(t, expected, lrange(int(start), int(stop)), items, start, stop)
+	}
+	assert.Nil(t, quick.Check(ranged, config))
+}
+
+func TestConcurrentSPopWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "concurrent_spop"
+	key := "{concurrent_spop}set"
+	members := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		members = append(members, strconv.Itoa(i))
+	}
+	value, err := json.Marshal(members)
+	assert.Nil(t, err)
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(key)
+	testEmptyKeysInRedis(key)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, map[string]RedisValue{key: {Type: setType, Value: string(value)}})
+
+	// every client loads the hash tag and pops members at the same time, no member is popped twice.
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	popped := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			command, err := commands.ParseCommand([]string{"spop", key, "3"})
+			assert.Nil(t, err)
+			assert.Nil(t, preProcessCommand(dep, command, time.Now()))
+			result := commands.ExecuteCommand(dep.Redis, command)
+			assert.Equal(t, commands.ArrayRespType, result.DataType)
+			mutex.Lock()
+			defer mutex.Unlock()
+			for _, item := range result.Value.([]commands.RESPData) {
+				popped = append(popped, item.Value.(string))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 15, len(popped))
+	assert.Equal(t, 15, utility.NewStringSet(popped...).Len())
+
+	remained, err := dep.Redis.SMembers(context.TODO(), key).Result()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, members, append(popped, remained...))
+}
+
+func TestSMoveWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "smove"
+	value := map[string]RedisValue{
+		"{smove}source": {Type: setType, Value: `["a"]`},
+		"{smove}dest":   {Type: setType, Value: `["b"]`},
+		"{smove}string": {Type: stringType, Value: "a"},
+	}
+	keys := []string{"{smove}source", "{smove}dest", "{smove}string"}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	integer := func(n int64) commands.RESPData {
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: n}
+	}
+
+	assert.Equal(t, commands.ConvertErrorToRESPData(errWrongType), testExecuteCommand(t, dep, "smove", "{smove}string", "{smove}dest", "a"))
+	assert.Equal(t, integer(0), testExecuteCommand(t, dep, "smove", "{smove}source", "{smove}dest", "c"))
+	assert.Equal(t, integer(1), testExecuteCommand(t, dep, "smove", "{smove}source", "{smove}dest", "a"))
+	// source is removed when it becomes empty.
+	n, err := dep.Redis.Exists(context.TODO(), "{smove}source").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+	members, err := dep.Redis.SMembers(context.TODO(), "{smove}dest").Result()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, members)
+
+	// keys of different hash tags are rejected like redis cluster.
+	command, err := commands.ParseCommand([]string{"smove", "{smove}dest", "{smove_other}dest", "a"})
+	assert.Nil(t, err)
+	assert.NotNil(t, preProcessCommand(dep, command, time.Now()))
+}
+
+func TestLInsertWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "linsert"
+	value := map[string]RedisValue{
+		"{linsert}list":   {Type: listType, Value: `["x","y","x"]`},
+		"{linsert}string": {Type: stringType, Value: "x"},
+	}
+	keys := []string{"{linsert}list", "{linsert}string", "{linsert}new"}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	integer := func(n int64) commands.RESPData {
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: n}
+	}
+
+	assert.Equal(t, commands.ConvertErrorToRESPData(errWrongType), testExecuteCommand(t, dep, "linsert", "{linsert}string", "before", "x", "a"))
+	// element is inserted around the first pivot.
+	assert.Equal(t, integer(4), testExecuteCommand(t, dep, "linsert", "{linsert}list", "before", "x", "head"))
+	assert.Equal(t, integer(5), testExecuteCommand(t, dep, "linsert", "{linsert}list", "AFTER", "x", "second"))
+	assert.Equal(t, integer(6), testExecuteCommand(t, dep, "linsert", "{linsert}list", "after", "x", "third"))
+	assert.Equal(t, integer(-1), testExecuteCommand(t, dep, "linsert", "{linsert}list", "after", "z", "a"))
+	assert.Equal(t, integer(0), testExecuteCommand(t, dep, "linsert", "{linsert}new", "after", "x", "a"))
+	items, err := dep.Redis.LRange(context.TODO(), "{linsert}list", 0, -1).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"head", "x", "third", "second", "y", "x"}, items)
+	n, err := dep.Redis.Exists(context.TODO(), "{linsert}new").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestLSetWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "lset"
+	items := make([]string, 10)
+	for i := range items {
+		items[i] = "x"
+	}
+	listValue, err := json.Marshal(items)
+	assert.Nil(t, err)
+	value := map[string]RedisValue{
+		"{lset}list":   {Type: listType, Value: string(listValue)},
+		"{lset}string": {Type: stringType, Value: "x"},
+	}
+	keys := []string{"{lset}list", "{lset}string", "{lset}new"}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	assert.Equal(t, commands.ConvertErrorToRESPData(errWrongType), testExecuteCommand(t, dep, "lset", "{lset}string", "0", "a"))
+	assert.Equal(t, "err:ERR no such key", testExecuteCommand(t, dep, "lset", "{lset}new", "0", "a").String())
+	assert.Equal(t, "err:ERR index out of range", testExecuteCommand(t, dep, "lset", "{lset}list", "10", "a").String())
+	assert.Equal(t, "err:ERR index out of range", testExecuteCommand(t, dep, "lset", "{lset}list", "-11", "a").String())
+
+	// elements set by clients at the same time are all kept.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			if index%2 == 0 {
+				assert.Equal(t, "s:OK", testExecuteCommand(t, dep, "lset", "{lset}list", strconv.Itoa(index), strconv.Itoa(index)).String())
+			} else {
+				assert.Equal(t, "s:OK", testExecuteCommand(t, dep, "lset", "{lset}list", strconv.Itoa(index-10), strconv.Itoa(index)).String())
+			}
+		}(i)
+	}
+	wg.Wait()
+	result, err := dep.Redis.LRange(context.TODO(), "{lset}list", 0, -1).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, result)
+}
+
+func TestLTrimWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "ltrim"
+	value := map[string]RedisValue{
+		"{ltrim}list1":  {Type: listType, Value: `["a","b","c","d","e"]`},
+		"{ltrim}list2":  {Type: listType, Value: `["a","b","c"]`},
+		"{ltrim}list3":  {Type: listType, Value: `["a","b","c"]`},
+		"{ltrim}string": {Type: stringType, Value: "x"},
+	}
+	keys := []string{"{ltrim}list1", "{ltrim}list2", "{ltrim}list3", "{ltrim}string", "{ltrim}new"}
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, value)
+
+	assert.Equal(t, "s:OK", testExecuteCommand(t, dep, "ltrim", "{ltrim}list1", "1", "-2").String())
+	result, err := dep.Redis.LRange(context.TODO(), "{ltrim}list1", 0, -1).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "c", "d"}, result)
+
+	// out of range indexes are clamped.
+	assert.Equal(t, "s:OK", testExecuteCommand(t, dep, "ltrim", "{ltrim}list1", "-100", "100").String())
+	result, err = dep.Redis.LRange(context.TODO(), "{ltrim}list1", 0, -1).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "c", "d"}, result)
+
+	// lists trimmed to empty are removed.
+	assert.Equal(t, "s:OK", testExecuteCommand(t, dep, "ltrim", "{ltrim}list2", "2", "1").String())
+	assert.Equal(t, "s:OK", testExecuteCommand(t, dep, "ltrim", "{ltrim}list3", "3", "10").String())
+	assert.Equal(t, "i:0", testExecuteCommand(t, dep, "exists", "{ltrim}list2", "{ltrim}list3").String())
+
+	assert.Equal(t, "s:OK", testExecuteCommand(t, dep, "ltrim", "{ltrim}new", "0", "1").String())
+	assert.Equal(t, "i:0", testExecuteCommand(t, dep, "exists", "{ltrim}new").String())
+	assert.Equal(t, commands.ConvertErrorToRESPData(errWrongType), testExecuteCommand(t, dep, "ltrim", "{ltrim}string", "0", "1"))
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"bufio"
+	"bytepower_room/base/log"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gogf/greuse"
+)
+
+const netstatFilePath = "/proc/net/netstat"
+
+// listenWithReusePort creates count listeners on address with SO_REUSEPORT, kernel balances connections among them,
+// backlog of listeners is changed if backlog is positive.
+func listenWithReusePort(address string, count int, backlog int) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, count)
+	closeListeners := func() {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}
+	for i := 0; i < count; i++ {
+		listener, err := greuse.Listen("tcp", address)
+		if err != nil {
+			closeListeners()
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+		if backlog > 0 {
+			if err := setListenerBacklog(listener, backlog); err != nil {
+				closeListeners()
+				return nil, err
+			}
+		}
+	}
+	return listeners, nil
+}
+
+// setListenerBacklog calls listen on socket of listener again with backlog,
+// which changes length of pending connections queue of a listening socket on linux.
+func setListenerBacklog(listener net.Listener, backlog int) error {
+	tcpListener, ok := listener.(*net.TCPListener)
+	if !ok {
+		return fmt.Errorf("listener %s is not a tcp listener", listener.Addr())
+	}
+	rawConn, err := tcpListener.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var listenErr error
+	err = rawConn.Control(func(fd uintptr) {
+		listenErr = syscall.Listen(int(fd), backlog)
+	})
+	if err != nil {
+		return err
+	}
+	return listenErr
+}
+
+// monitorBacklogDrop records increment of ListenOverflows in /proc/net/netstat by metric accept.backlog_drop every interval,
+// the counter is shared by all listeners in the network namespace, not only room server.
+// Monitor stops if the counter could not be read, e.g. on systems other than linux.
+func (service *RoomService) monitorBacklogDrop(interval time.Duration) {
+	last, err := readListenOverflows(netstatFilePath)
+	if err != nil {
+		service.logWithAddressAndPid(log.LevelWarn, "server.backlog_drop_check_disabled", log.Error(err))
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-service.stopCh:
+			return
+		case <-ticker.C:
+			current, err := readListenOverflows(netstatFilePath)
+			if err != nil {
+				service.logWithAddressAndPid(log.LevelError, "error.server.backlog_drop_check", log.Error(err))
+				continue
+			}
+			if current > last {
+				service.dep.Metric.MetricCount("accept.backlog_drop", current-last)
+			}
+			last = current
+		}
+	}
+}
+
+func readListenOverflows(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return parseListenOverflows(file)
+}
+
+// parseListenOverflows returns ListenOverflows of TcpExt in content of /proc/net/netstat,
+// in which a line of names is followed by a line of values with the same prefix.
+func parseListenOverflows(reader io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(reader)
+	var names []string
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "TcpExt:" {
+			continue
+		}
+		if names == nil {
+			names = fields
+			continue
+		}
+		if len(fields) != len(names) {
+			return 0, errors.New("count of TcpExt names and values mismatch")
+		}
+		for index, name := range names {
+			if name == "ListenOverflows" {
+				return strconv.ParseInt(fields[index], 10, 64)
+			}
+		}
+		return 0, errors.New("ListenOverflows is not found in TcpExt")
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("TcpExt is not found")
+}
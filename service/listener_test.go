@@ -0,0 +1,50 @@
+package service
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenWithReusePort(t *testing.T) {
+	listeners, err := listenWithReusePort("127.0.0.1:0", 1, 16)
+	assert.Nil(t, err)
+	address := listeners[0].Addr().String()
+	defer listeners[0].Close()
+
+	// listeners on the same address.
+	reusedListeners, err := listenWithReusePort(address, 2, 16)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(reusedListeners))
+	for _, listener := range reusedListeners {
+		assert.Equal(t, address, listener.Addr().String())
+		listener.Close()
+	}
+
+	conn, err := net.Dial("tcp", address)
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestParseListenOverflows(t *testing.T) {
+	content := "TcpExt: SyncookiesSent ListenOverflows ListenDrops\n" +
+		"TcpExt: 0 12 15\n" +
+		"IpExt: InNoRoutes InTruncatedPkts\n" +
+		"IpExt: 0 0\n"
+	count, err := parseListenOverflows(strings.NewReader(content))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), count)
+
+	for _, content := range []string{
+		"",
+		"IpExt: InNoRoutes\nIpExt: 0\n",
+		"TcpExt: SyncookiesSent ListenOverflows\nTcpExt: 0\n",
+		"TcpExt: SyncookiesSent ListenDrops\nTcpExt: 0 1\n",
+		"TcpExt: SyncookiesSent ListenOverflows\nTcpExt: 0 x\n",
+	} {
+		_, err := parseListenOverflows(strings.NewReader(content))
+		assert.NotNil(t, err, content)
+	}
+}
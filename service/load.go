@@ -6,7 +6,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -20,6 +23,10 @@ var (
 	ErrAccessAfterRecord = errors.New("hash tag is accessed after recording")
 )
 
+// hashTagLoadWarmTotal is count of loads whose keys are already in redis,
+// hashTagLoadColdTotal is count of loads whose keys are loaded from db.
+var hashTagLoadWarmTotal, hashTagLoadColdTotal int64
+
 func newParseError(err error) error {
 	return fmt.Errorf("parse value error, %w", err)
 }
@@ -111,50 +118,67 @@ func (tag HashTag) NeedToLoad() (bool, error) {
 	return status != HashTagStatusLoaded, nil
 }
 
-func (tag HashTag) Load(timeout time.Duration) (bool, int, error) {
+// Load loads keys of the hash tag within timeout and the deadline of ctx,
+// it returns the model loaded from db, whether the hash tag is loaded by this call and count of loaded keys.
+func (tag HashTag) Load(ctx context.Context, accessTime time.Time, timeout time.Duration) (*roomDataModelV2, bool, int, error) {
 	if err := tag.acquireLoadLock(); err != nil {
-		return false, 0, err
+		return nil, false, 0, err
 	}
 	defer tag.releaseLoadLock()
 	needToLoad, err := tag.NeedToLoad()
 	if err != nil {
-		return false, 0, err
+		return nil, false, 0, err
 	}
 	if !needToLoad {
-		return false, 0, nil
+		return nil, false, 0, nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	count, err := tag.loadKeys(ctx)
+	model, count, err := tag.loadKeys(ctx, accessTime)
 	if err != nil {
-		return true, 0, err
+		return nil, true, 0, err
 	}
-	return true, count, nil
+	return model, true, count, nil
 }
 
-func (tag HashTag) loadKeys(ctx context.Context) (int, error) {
+// loadKeys loads keys which are not expired at accessTime into redis,
+// expired keys are removed from db asynchronously.
+func (tag HashTag) loadKeys(ctx context.Context, accessTime time.Time) (*roomDataModelV2, int, error) {
 	startTime := time.Now()
 	count := 0
 	model, err := loadDataByIDWithContext(ctx, tag.dep.DB, tag.name)
 	if err != nil {
 		recordLoadDBError(tag.dep.Logger, tag.name, time.Since(startTime), err)
-		return count, err
+		return nil, count, err
 	}
 	if model == nil {
 		recordLoadDBRecordNotFound(tag.dep.Metric, tag.name, time.Since(startTime))
-		return count, nil
+		return nil, count, nil
 	}
 	recordLoadDBSuccess(tag.dep.Logger, tag.name, time.Since(startTime))
+	values, expiredCount := model.unexpiredValue(accessTime)
+	if expiredCount > 0 {
+		go tag.purgeExpiredKeys(model, accessTime)
+	}
 	startTime = time.Now()
-	for key, value := range model.Value {
+	for key, value := range values {
 		if err := loadKeyToRedis(ctx, tag.dep.Redis, key, value); err != nil {
 			recordLoadIntoRedisError(tag.dep.Logger, tag.dep.Metric, tag.name, time.Since(startTime), count, err)
-			return count, err
+			return nil, count, err
 		}
 		count += 1
 	}
 	recordLoadIntoRedisSuccess(tag.dep.Logger, tag.dep.Metric, tag.name, time.Since(startTime), count)
-	return count, nil
+	return model, count, nil
+}
+
+func (tag HashTag) purgeExpiredKeys(model *roomDataModelV2, t time.Time) {
+	count, _, err := purgeExpiredKeysInRoomData(tag.dep.DB, model, t)
+	if err != nil {
+		recordLoadKeyPurgeExpiredKeysError(tag.dep.Logger, tag.dep.Metric, tag.name, err)
+		return
+	}
+	recordLoadKeyPurgeExpiredKeysSuccess(tag.dep.Logger, tag.dep.Metric, tag.name, count)
 }
 
 func getHashTagLockKey(hashTag string) string {
@@ -259,36 +283,56 @@ func (meta HashTagMetaInfo) GetAccessTime() (time.Time, error) {
 }
 
 func Load(dep base.Dependency, tagName string, accessTime time.Time, accessMode base.HashTagAccessMode) error {
+	_, err := loadWithContext(context.Background(), dep, tagName, accessTime, accessMode)
+	return err
+}
+
+// loadWithContext loads keys of hash tag into redis if they are not loaded yet and updates its access time,
+// retries stop when ctx is done. It returns the model loaded from db, which is nil if nothing is loaded.
+func loadWithContext(
+	ctx context.Context, dep base.Dependency,
+	tagName string, accessTime time.Time, accessMode base.HashTagAccessMode) (*roomDataModelV2, error) {
+
 	if tagName == "" {
-		return nil
+		return nil, nil
 	}
 	hashTag, err := NewHashTag(tagName, dep)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	hashTagCacheService := base.GetHashTagLoadedCache()
 	_, loaded := hashTagCacheService.Get(tagName)
 	if loaded {
+		atomic.AddInt64(&hashTagLoadWarmTotal, 1)
 		hashTagCacheService.Set(tagName, true, 0)
-		return hashTag.meta.UpdateAccessTime(accessTime, accessMode)
+		return nil, hashTag.meta.UpdateAccessTime(accessTime, accessMode)
 	}
 	loadRetryTimes := base.GetServerConfig().LoadKey.GetRetryTimes()
 	loadRetryInterval := base.GetServerConfig().LoadKey.GetRetryInterval()
 	loadTimeout := base.GetServerConfig().LoadKey.GetLoadTimeout()
 	for i := 0; i < loadRetryTimes; i++ {
+		// retries are stopped when ctx is done, e.g. the command loading the hash tag is timed out.
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		needToLoad, needToLoadErr := hashTag.NeedToLoad()
 		if needToLoadErr != nil {
 			recordLoadKeyCheckNeedToLoadError(dep.Logger, dep.Metric, tagName, needToLoadErr)
-			return needToLoadErr
+			return nil, needToLoadErr
 		}
 		if !needToLoad {
+			atomic.AddInt64(&hashTagLoadWarmTotal, 1)
 			hashTagCacheService.Set(tagName, true, 0)
-			return hashTag.meta.UpdateAccessTime(accessTime, accessMode)
+			return nil, hashTag.meta.UpdateAccessTime(accessTime, accessMode)
 		}
 		startTime := time.Now()
-		loaded, count, loadErr := hashTag.Load(loadTimeout)
+		model, loaded, count, loadErr := hashTag.Load(ctx, accessTime, loadTimeout)
 		if loadErr != nil {
 			err = loadErr
+			if ctx.Err() != nil {
+				recordLoadKeyError(dep.Logger, dep.Metric, tagName, err, time.Since(startTime), count)
+				return nil, err
+			}
 			if errors.Is(err, errLoadKeysLockFailed) {
 				recordLoadKeyRetryLockFailed(dep.Logger, dep.Metric, tagName, i+1, count)
 				time.Sleep(loadRetryInterval)
@@ -300,15 +344,81 @@ func Load(dep base.Dependency, tagName string, accessTime time.Time, accessMode
 				continue
 			}
 			recordLoadKeyError(dep.Logger, dep.Metric, tagName, err, time.Since(startTime), count)
-			return err
+			return nil, err
 		}
 		if loaded {
+			atomic.AddInt64(&hashTagLoadColdTotal, 1)
 			recordLoadKeySuccess(dep.Logger, dep.Metric, tagName, time.Since(startTime), count)
 		}
 		hashTagCacheService.Set(tagName, true, 0)
-		return hashTag.meta.UpdateAccessTime(accessTime, accessMode)
+		return model, hashTag.meta.UpdateAccessTime(accessTime, accessMode)
 	}
-	return err
+	return nil, err
+}
+
+// HashTagLoadError is the error of loading a hash tag by LoadMultiple.
+type HashTagLoadError struct {
+	HashTag string
+	Err     error
+}
+
+func (err *HashTagLoadError) Error() string {
+	return fmt.Sprintf("load hash_tag %s error, %s", err.HashTag, err.Err.Error())
+}
+
+func (err *HashTagLoadError) Unwrap() error {
+	return err.Err
+}
+
+// LoadMultiple loads hash tags with their access modes in parallel,
+// hash tags of the same db shard are loaded one by one in a goroutine of the shard,
+// so that a command with keys of multiple hash tags waits for the slowest shard only.
+// It returns models loaded from db by hash tag, the model is nil if nothing is loaded for the hash tag,
+// and the error of the first failed hash tag in order of hashTags wrapped by HashTagLoadError.
+func LoadMultiple(
+	ctx context.Context, dep base.Dependency,
+	hashTags []string, accessModes map[string]base.HashTagAccessMode, accessTime time.Time) (map[string]*roomDataModelV2, error) {
+
+	models := make(map[string]*roomDataModelV2, len(hashTags))
+	errs := make(map[string]error)
+	if len(hashTags) == 1 {
+		model, err := loadWithContext(ctx, dep, hashTags[0], accessTime, accessModes[hashTags[0]])
+		models[hashTags[0]] = model
+		if err != nil {
+			return models, &HashTagLoadError{HashTag: hashTags[0], Err: err}
+		}
+		return models, nil
+	}
+
+	shards := make(map[int][]string)
+	for _, hashTag := range hashTags {
+		index := dep.DB.GetClientIndex(hashTag)
+		shards[index] = append(shards[index], hashTag)
+	}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, shardHashTags := range shards {
+		wg.Add(1)
+		go func(shardHashTags []string) {
+			defer wg.Done()
+			for _, hashTag := range shardHashTags {
+				model, err := loadWithContext(ctx, dep, hashTag, accessTime, accessModes[hashTag])
+				mutex.Lock()
+				models[hashTag] = model
+				if err != nil {
+					errs[hashTag] = err
+				}
+				mutex.Unlock()
+			}
+		}(shardHashTags)
+	}
+	wg.Wait()
+	for _, hashTag := range hashTags {
+		if err, ok := errs[hashTag]; ok {
+			return models, &HashTagLoadError{HashTag: hashTag, Err: err}
+		}
+	}
+	return models, nil
 }
 
 func loadKeyToRedis(ctx context.Context, client *redis.ClusterClient, key string, value RedisValue) error {
@@ -328,6 +438,9 @@ func loadKeyToRedis(ctx context.Context, client *redis.ClusterClient, key string
 		_, err := client.Set(ctx, key, value.Value, expiration).Result()
 		return err
 	}
+	if dataType == streamType {
+		return loadStreamToRedis(ctx, client, key, value.Value, ttl)
+	}
 	var size int
 	if dataType == hashType || value.Type == zsetType {
 		size = loadAndSaveStepSize * 2
@@ -344,13 +457,46 @@ func loadKeyToRedis(ctx context.Context, client *redis.ClusterClient, key string
 	case listType:
 		return loadListToRedis(ctx, client, key, slices, ttl)
 	case hashType:
-		return loadHashToRedis(ctx, client, key, slices, ttl)
+		return loadHashToRedis(ctx, client, key, slices, ttl, value.FieldExpireTs)
 	case zsetType:
 		return loadZSetToRedis(ctx, client, key, slices, ttl)
 	}
 	return nil
 }
 
+func loadStreamToRedis(ctx context.Context, client *redis.ClusterClient, key string, value string, ttl time.Duration) error {
+	if ttl == 0 {
+		return nil
+	}
+	entries := []streamEntry{}
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return newParseError(err)
+	}
+	pipeline := client.Pipeline()
+	pipeline.Del(ctx, key)
+	for _, entry := range entries {
+		if len(entry.Fields) == 0 {
+			return errDataFormatError
+		}
+		fields := make([]string, 0, len(entry.Fields))
+		for field := range entry.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		args := make([]interface{}, 0, 3+2*len(fields))
+		args = append(args, "xadd", key, entry.ID)
+		for _, field := range fields {
+			args = append(args, field, entry.Fields[field])
+		}
+		pipeline.Do(ctx, args...)
+	}
+	if ttl > 0 {
+		pipeline.Expire(ctx, key, ttl)
+	}
+	_, err := pipeline.Exec(ctx)
+	return err
+}
+
 func loadSetToRedis(ctx context.Context, client *redis.ClusterClient, key string, slices [][]interface{}, ttl time.Duration) error {
 	if ttl == 0 {
 		return nil
@@ -383,7 +529,12 @@ func loadListToRedis(ctx context.Context, client *redis.ClusterClient, key strin
 	return err
 }
 
-func loadHashToRedis(ctx context.Context, client *redis.ClusterClient, key string, slices [][]interface{}, ttl time.Duration) error {
+// loadHashToRedis sets fields of hash key and restores expiration of fields in fieldExpireTs,
+// expired fields are removed, so is the key if all fields expire.
+func loadHashToRedis(
+	ctx context.Context, client *redis.ClusterClient, key string,
+	slices [][]interface{}, ttl time.Duration, fieldExpireTs map[string]int64) error {
+
 	if ttl == 0 {
 		return nil
 	}
@@ -398,6 +549,19 @@ func loadHashToRedis(ctx context.Context, client *redis.ClusterClient, key strin
 	if ttl > 0 {
 		pipeline.Expire(ctx, key, ttl)
 	}
+	nowTs := utility.TimestampInMS(time.Now())
+	fields := make([]string, 0, len(fieldExpireTs))
+	for field := range fieldExpireTs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if ts := fieldExpireTs[field]; ts > nowTs {
+			pipeline.Do(ctx, "hpexpireat", key, ts, "fields", 1, field)
+		} else {
+			pipeline.HDel(ctx, key, field)
+		}
+	}
 	_, err := pipeline.Exec(ctx)
 	return err
 }
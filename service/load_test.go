@@ -116,6 +116,17 @@ func testInsertRoomData(hashTag string, value map[string]RedisValue) *roomDataMo
 	return model
 }
 
+// testExecuteCommand loads keys of command and executes it in redis,
+// error of loading keys is returned as the reply like the server does.
+func testExecuteCommand(t *testing.T, dep base.Dependency, args ...string) commands.RESPData {
+	command, err := commands.ParseCommand(args)
+	assert.Nil(t, err)
+	if err := preProcessCommand(dep, command, time.Now()); err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return commands.ExecuteCommand(dep.Redis, command)
+}
+
 func testMergeMaps(m1, m2 map[string]RedisValue) map[string]RedisValue {
 	m := make(map[string]RedisValue)
 	for key, value := range m1 {
@@ -688,7 +699,7 @@ func TestLoadHashToRedis(t *testing.T) {
 	ctx := context.TODO()
 	for _, c := range cases {
 		defer redisCluster.Del(ctx, c.key)
-		err := loadHashToRedis(ctx, redisCluster, c.key, c.slices, c.ttl)
+		err := loadHashToRedis(ctx, redisCluster, c.key, c.slices, c.ttl, nil)
 		assert.Nil(t, err)
 		length, _ := redisCluster.HLen(ctx, c.key).Result()
 		assert.Equal(t, len(c.members), int(length))
@@ -741,6 +752,52 @@ func TestLoadZsetToRedis(t *testing.T) {
 	}
 }
 
+func TestLoadStreamToRedis(t *testing.T) {
+	cases := []struct {
+		key     string
+		value   string
+		ttl     time.Duration
+		entries []redis.XMessage
+	}{
+		{
+			key:     "{a}stream",
+			value:   `[{"id":"1-1","fields":{"a":"1"}}]`,
+			ttl:     0,
+			entries: []redis.XMessage{},
+		}, {
+			key:   "{b}stream",
+			value: `[{"id":"1-1","fields":{"a":"1","b":"2"}},{"id":"1-2","fields":{"c":"3"}}]`,
+			ttl:   -1,
+			entries: []redis.XMessage{
+				{ID: "1-1", Values: map[string]interface{}{"a": "1", "b": "2"}},
+				{ID: "1-2", Values: map[string]interface{}{"c": "3"}},
+			},
+		}, {
+			key:     "{c}stream",
+			value:   `[{"id":"5-0","fields":{"a":"1"}}]`,
+			ttl:     5 * time.Second,
+			entries: []redis.XMessage{{ID: "5-0", Values: map[string]interface{}{"a": "1"}}},
+		},
+	}
+	redisCluster := base.GetServerDependency().Redis
+	ctx := context.TODO()
+	for _, c := range cases {
+		defer redisCluster.Del(ctx, c.key)
+		err := loadStreamToRedis(ctx, redisCluster, c.key, c.value, c.ttl)
+		assert.Nil(t, err)
+		entries, err := redisCluster.XRange(ctx, c.key, "-", "+").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, c.entries, entries)
+		if c.ttl > 0 {
+			ttl, _ := redisCluster.TTL(ctx, c.key).Result()
+			assert.Greater(t, int64(ttl), int64(0))
+		}
+	}
+
+	err := loadStreamToRedis(ctx, redisCluster, "{d}stream", `[{"id":"1-1","fields":{}}]`, -1)
+	assert.True(t, errors.Is(err, errDataFormatError))
+}
+
 func TestHashTagLoadWithTimeout(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Nanosecond)
 	defer cancel()
@@ -749,11 +806,109 @@ func TestHashTagLoadWithTimeout(t *testing.T) {
 	tag := "abc"
 	dep := base.GetServerDependency()
 	hashTag, _ := NewHashTag(tag, dep)
-	count, err := hashTag.loadKeys(ctx)
+	_, count, err := hashTag.loadKeys(ctx, time.Now())
 	assert.Equal(t, context.DeadlineExceeded, err)
 	assert.Equal(t, 0, count)
 }
 
+func TestHashTagLoadKeysFilterExpiredKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	tag := "load_expired"
+	accessTime := time.Now()
+	accessTs := utility.TimestampInMS(accessTime)
+	value := map[string]RedisValue{
+		"{load_expired}a": {Type: stringType, Value: "a"},
+		"{load_expired}b": {Type: stringType, Value: "b", ExpireTs: accessTs + 60000},
+		"{load_expired}c": {Type: stringType, Value: "c", ExpireTs: accessTs},
+		"{load_expired}d": {Type: stringType, Value: "d", ExpireTs: accessTs - 60000},
+	}
+	assert.Nil(t, testInsertDataToDB(dep.DB, tag, value, time.Time{}, accessTime, accessTime, 1))
+	defer testEmptyRoomDataRecordInDatabase(tag)
+	keys := []string{"{load_expired}a", "{load_expired}b", "{load_expired}c", "{load_expired}d"}
+	defer testEmptyKeysInRedis(keys...)
+
+	hashTag, _ := NewHashTag(tag, dep)
+	_, count, err := hashTag.loadKeys(context.Background(), accessTime)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	n, _ := dep.Redis.Exists(context.TODO(), keys...).Result()
+	assert.Equal(t, int64(2), n)
+	n, _ = dep.Redis.Exists(context.TODO(), "{load_expired}a", "{load_expired}b").Result()
+	assert.Equal(t, int64(2), n)
+
+	// expired keys are removed from db asynchronously
+	var model *roomDataModelV2
+	for i := 0; i < 50; i++ {
+		model, _ = loadDataByID(dep.DB, tag)
+		if model != nil && model.Version == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 2, model.Version)
+	assert.Equal(t, 2, len(model.Value))
+	assert.Contains(t, model.Value, "{load_expired}a")
+	assert.Contains(t, model.Value, "{load_expired}b")
+}
+
+func TestLoadMultiple(t *testing.T) {
+	dep := base.GetServerDependency()
+	accessTime := time.Now()
+	tags := []string{"load_multiple_a", "load_multiple_b", "load_multiple_c"}
+	keys := []string{"{load_multiple_a}a", "{load_multiple_b}b", "{load_multiple_c}c"}
+	defer testEmptyKeysInRedis(keys...)
+	for i, tag := range tags[:2] {
+		value := map[string]RedisValue{keys[i]: {Type: stringType, Value: tag}}
+		assert.Nil(t, testInsertDataToDB(dep.DB, tag, value, time.Time{}, accessTime, accessTime, 1))
+		defer testEmptyRoomDataRecordInDatabase(tag)
+	}
+	for _, tag := range tags {
+		testSetMetaKeyCleaned(tag)
+		testCleanLocalloadedCache(tag)
+	}
+
+	accessModes := map[string]base.HashTagAccessMode{
+		"load_multiple_a": base.HashTagAccessModeRead,
+		"load_multiple_b": base.HashTagAccessModeWrite,
+		"load_multiple_c": base.HashTagAccessModeRead,
+	}
+	models, err := LoadMultiple(context.Background(), dep, tags, accessModes, accessTime)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(models))
+	assert.Equal(t, "load_multiple_a", models["load_multiple_a"].HashTag)
+	assert.Equal(t, "load_multiple_b", models["load_multiple_b"].HashTag)
+	assert.Nil(t, models["load_multiple_c"])
+	for i, tag := range tags[:2] {
+		value, err := dep.Redis.Get(context.TODO(), keys[i]).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, tag, value)
+	}
+	for _, tag := range tags {
+		status, err := dep.Redis.HGet(context.TODO(), getHashTagMetaKey(tag), HashTagMetaInfoStatusFieldName).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, HashTagStatusLoaded, status)
+	}
+
+	// hash tags are loaded already, no models are returned.
+	models, err = LoadMultiple(context.Background(), dep, tags, accessModes, accessTime)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(models))
+	for _, tag := range tags {
+		assert.Nil(t, models[tag])
+	}
+
+	// error of a hash tag is returned with the hash tag.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	testSetMetaKeyCleaned("load_multiple_b")
+	testCleanLocalloadedCache("load_multiple_b")
+	_, err = LoadMultiple(ctx, dep, tags, accessModes, accessTime)
+	var loadErr *HashTagLoadError
+	assert.True(t, errors.As(err, &loadErr))
+	assert.Equal(t, "load_multiple_b", loadErr.HashTag)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
 func TestHashTagMetaUpdateAccessTime(t *testing.T) {
 	dep := base.GetServerDependency()
 	// update status, at, version field
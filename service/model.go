@@ -2,13 +2,19 @@ package service
 
 import (
 	"bytepower_room/base"
+	"bytepower_room/commands"
 	"bytepower_room/utility"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 )
 
 const (
@@ -17,14 +23,23 @@ const (
 	hashType   = "hash"
 	setType    = "set"
 	zsetType   = "zset"
+	streamType = "stream"
 )
 
-var supportedRedisDataTypes = []string{stringType, listType, hashType, setType, zsetType}
+var supportedRedisDataTypes = []string{stringType, listType, hashType, setType, zsetType, streamType}
+
+// streamEntry is an entry of stream, value of stream is saved as a json array of entries ordered by id.
+type streamEntry struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
 
 type RedisValue struct {
 	Type     string `json:"type"`
 	Value    string `json:"value"`
 	ExpireTs int64  `json:"expire_ts"`
+	// FieldExpireTs is expire timestamps in milliseconds of hash fields with expiration, fields without expiration are absent.
+	FieldExpireTs map[string]int64 `json:"field_expire_ts,omitempty"`
 }
 
 func (v RedisValue) IsExpired(t time.Time) bool {
@@ -62,6 +77,80 @@ func (v RedisValue) String() string {
 		v.Type, v.Value, v.ExpireTs)
 }
 
+// encoding returns encoding of value like redis 7 with thresholds of compact encodings in config,
+// e.g. small hashes are encoded as listpack.
+func (v RedisValue) encoding(config base.ObjectEncodingConfig) (string, error) {
+	config = config.WithDefaults()
+	switch v.Type {
+	case stringType:
+		if n, err := strconv.ParseInt(v.Value, 10, 64); err == nil && strconv.FormatInt(n, 10) == v.Value {
+			return "int", nil
+		}
+		if len(v.Value) <= 44 {
+			return "embstr", nil
+		}
+		return "raw", nil
+	case streamType:
+		return "stream", nil
+	}
+	items := []string{}
+	if err := json.Unmarshal([]byte(v.Value), &items); err != nil {
+		return "", newParseError(err)
+	}
+	// isListpack returns true if count of entries and size of every value are within limits.
+	isListpack := func(values []string, count, maxEntries, maxValue int) bool {
+		if count > maxEntries {
+			return false
+		}
+		for _, value := range values {
+			if len(value) > maxValue {
+				return false
+			}
+		}
+		return true
+	}
+	switch v.Type {
+	case listType:
+		if isListpack(items, len(items), config.ListMaxListpackEntries, config.ListMaxListpackValue) {
+			return "listpack", nil
+		}
+		return "quicklist", nil
+	case setType:
+		isIntset := len(items) <= config.SetMaxIntsetEntries
+		for _, item := range items {
+			if n, err := strconv.ParseInt(item, 10, 64); err != nil || strconv.FormatInt(n, 10) != item {
+				isIntset = false
+				break
+			}
+		}
+		if isIntset {
+			return "intset", nil
+		}
+		if isListpack(items, len(items), config.SetMaxListpackEntries, config.SetMaxListpackValue) {
+			return "listpack", nil
+		}
+		return "hashtable", nil
+	case hashType:
+		if isListpack(items, len(items)/2, config.HashMaxListpackEntries, config.HashMaxListpackValue) {
+			return "listpack", nil
+		}
+		return "hashtable", nil
+	case zsetType:
+		members := make([]string, 0, len(items)/2)
+		for i := 0; i < len(items); i += 2 {
+			members = append(members, items[i])
+		}
+		if isListpack(members, len(members), config.ZSetMaxListpackEntries, config.ZSetMaxListpackValue) {
+			return "listpack", nil
+		}
+		return "skiplist", nil
+	}
+	return "", fmt.Errorf("data type %s is not supported", v.Type)
+}
+
+// roomDataModelV2 is the only model which is soft deleted, a row is soft deleted by setting deleted_at,
+// loaders of room data filter out soft deleted rows and writers clear deleted_at when the row is written again.
+// Soft deleted rows are removed by purge deleted data task after retention duration.
 type roomDataModelV2 struct {
 	tableName struct{} `pg:"_"`
 
@@ -81,6 +170,29 @@ func (model *roomDataModelV2) GetTablePrefix() string {
 	return "room_data_v2"
 }
 
+// unexpiredValue returns values which are not expired at t and count of expired values.
+func (model *roomDataModelV2) unexpiredValue(t time.Time) (map[string]RedisValue, int) {
+	value := make(map[string]RedisValue, len(model.Value))
+	for key, v := range model.Value {
+		if v.IsExpired(t) {
+			continue
+		}
+		value[key] = v
+	}
+	return value, len(model.Value) - len(value)
+}
+
+// RoomServerDBTablePrefixes returns prefixes of db tables used by room server.
+func RoomServerDBTablePrefixes() []string {
+	return []string{(&roomDataModelV2{}).GetTablePrefix()}
+}
+
+// CheckDBColumns returns an error wrapping base.ErrColumnMissing if columns added after tables are created are missing,
+// they are added by migrations if auto_migrate is on, or by upgrade sql in docs/upgrade.md.
+func CheckDBColumns(ctx context.Context, db *base.DBCluster) error {
+	return db.CheckColumns(ctx, (&roomHashTagKeys{}).GetTablePrefix(), "frequency")
+}
+
 type loadResult struct {
 	model *roomDataModelV2
 	err   error
@@ -115,6 +227,28 @@ func loadDataByID(db *base.DBCluster, hashTag string) (*roomDataModelV2, error)
 	return model, nil
 }
 
+// loadRoomDataVersion returns version of room data of hash tag, soft deleted rows do not exist.
+func loadRoomDataVersion(db *base.DBCluster, hashTag string) (int, error) {
+	model := &roomDataModelV2{HashTag: hashTag}
+	query, err := db.Model(model)
+	if err != nil {
+		return 0, err
+	}
+	if err := query.Column("version").WherePK().Where("deleted_at is NULL").Select(); err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return commands.RoomDataVersionNotExist, nil
+		}
+		return 0, err
+	}
+	return model.Version, nil
+}
+
+func newRoomDataVersionLoader(db *base.DBCluster) commands.RoomDataVersionLoader {
+	return func(hashTag string) (int, error) {
+		return loadRoomDataVersion(db, hashTag)
+	}
+}
+
 var errNoRowsUpdated = errors.New("no rows is updated")
 
 func isRetryErrorForUpdateInTx(err error) bool {
@@ -131,6 +265,15 @@ func isRetryErrorForUpdateInTx(err error) bool {
 	return false
 }
 
+// isDBConnectivityError checks whether err is caused by failing to connect or talk to db.
+func isDBConnectivityError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 func upsertRoomDataValue(db *base.DBCluster, hashTag string, value map[string]RedisValue, tryTimes int) error {
 	var err error
 	for i := 0; i < tryTimes; i++ {
@@ -172,6 +315,7 @@ func _upsertRoomDataValue(dbCluster *base.DBCluster, hashTag string, value map[s
 
 		result, err := tx.Model(model).Table(tableName).
 			Set("value=?", value).
+			Set("deleted_at=NULL").
 			Set("updated_at=?", currentTime).
 			Set("version=?", model.Version+1).
 			WherePK().
@@ -188,6 +332,89 @@ func _upsertRoomDataValue(dbCluster *base.DBCluster, hashTag string, value map[s
 	return err
 }
 
+// upsertRoomDataKeyValue saves value of key to room data of hashTag, key is removed from room data if value is zero.
+// It is skipped if room data has been updated after writtenAt since the value in room data is newer,
+// updated_at is set to writtenAt, so that values of other keys written at the same time or later are still saved.
+func upsertRoomDataKeyValue(
+	db *base.DBCluster, hashTag, key string, value RedisValue, writtenAt time.Time, tryTimes int) error {
+
+	var err error
+	for i := 0; i < tryTimes; i++ {
+		if err = _upsertRoomDataKeyValue(db, hashTag, key, value, writtenAt); err != nil {
+			if !isRetryErrorForUpdateInTx(err) {
+				return err
+			}
+			continue
+		}
+		break
+	}
+	return err
+}
+
+func _upsertRoomDataKeyValue(
+	dbCluster *base.DBCluster, hashTag, key string, value RedisValue, writtenAt time.Time) error {
+
+	currentTime := time.Now()
+	model := &roomDataModelV2{HashTag: hashTag}
+	tableName, db, err := dbCluster.GetTableNameAndDBClientByModel(model)
+	if err != nil {
+		return err
+	}
+
+	err = db.RunInTransaction(context.TODO(), func(tx *pg.Tx) error {
+		err := tx.Model(model).Table(tableName).WherePK().Select()
+		if err != nil && !errors.Is(err, pg.ErrNoRows) {
+			return err
+		}
+		if err != nil && errors.Is(err, pg.ErrNoRows) {
+			if value.IsZero() {
+				return nil
+			}
+			model = &roomDataModelV2{
+				HashTag:   hashTag,
+				Value:     map[string]RedisValue{key: value},
+				CreatedAt: currentTime,
+				UpdatedAt: writtenAt,
+				Version:   0,
+			}
+			_, err = tx.Model(model).Table(tableName).Insert()
+			return err
+		}
+		if model.UpdatedAt.After(writtenAt) {
+			return nil
+		}
+
+		newValue := make(map[string]RedisValue, len(model.Value)+1)
+		// value of a soft deleted row is stale, it should not be saved again.
+		if model.DeletedAt.IsZero() {
+			for k, v := range model.Value {
+				newValue[k] = v
+			}
+		}
+		if value.IsZero() {
+			delete(newValue, key)
+		} else {
+			newValue[key] = value
+		}
+		result, err := tx.Model(model).Table(tableName).
+			Set("value=?", newValue).
+			Set("deleted_at=NULL").
+			Set("updated_at=?", writtenAt).
+			Set("version=?", model.Version+1).
+			WherePK().
+			Where("version=?", model.Version).
+			Update()
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return errNoRowsUpdated
+		}
+		return nil
+	})
+	return err
+}
+
 type HashTagKeysStatus string
 
 const (
@@ -215,6 +442,7 @@ func (err *hashTagKeysStatusConflictError) Unwrap() error {
 	return err.err
 }
 
+// roomHashTagKeys records are never deleted, keys of inactive hash tags are marked by status cleaned.
 type roomHashTagKeys struct {
 	tableName struct{} `pg:"_"`
 
@@ -227,6 +455,8 @@ type roomHashTagKeys struct {
 	UpdatedAt  time.Time         `pg:"updated_at"`
 	Status     HashTagKeysStatus `pg:"status"`
 	Version    int64             `pg:"version"`
+	// Frequency is a logarithmic access counter of the hash tag like LFU counter of redis.
+	Frequency int `pg:"frequency,use_zero"`
 }
 
 func (model *roomHashTagKeys) ShardingKey() string {
@@ -237,7 +467,61 @@ func (model *roomHashTagKeys) GetTablePrefix() string {
 	return "room_hash_tag_keys"
 }
 
+func loadHashTagKeysByHashTag(db *base.DBCluster, hashTag string) (*roomHashTagKeys, error) {
+	model := &roomHashTagKeys{HashTag: hashTag}
+	query, err := db.Model(model)
+	if err != nil {
+		return nil, err
+	}
+	if err := query.WherePK().Select(); err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return model, nil
+}
+
+// hashTagKeysStatusTransitions maps a status to statuses which it could be set from by SetStatusAs methods,
+// e.g. keys of a hash tag are cleaned only after they are synced, otherwise writes not synced are lost.
+// Statuses are set to need_synced by events of hash tags.
+var hashTagKeysStatusTransitions = map[HashTagKeysStatus][]HashTagKeysStatus{
+	HashTagKeysStatusSynced:  {HashTagKeysStatusNeedSynced},
+	HashTagKeysStatusCleaned: {HashTagKeysStatusSynced},
+}
+
+var errHashTagKeysStatusTransition = errors.New("hash_tag status transition is not allowed")
+
+type hashTagKeysStatusTransitionError struct {
+	hashTag string
+	from    HashTagKeysStatus
+	to      HashTagKeysStatus
+}
+
+func (err *hashTagKeysStatusTransitionError) Error() string {
+	return fmt.Sprintf("hash_tag %s status transition from %s to %s is not allowed", err.hashTag, err.from, err.to)
+}
+
+func (err *hashTagKeysStatusTransitionError) Unwrap() error {
+	return errHashTagKeysStatusTransition
+}
+
+// checkStatusTransition returns hashTagKeysStatusTransitionError if status of model could not be set to status.
+func (model *roomHashTagKeys) checkStatusTransition(status HashTagKeysStatus) error {
+	for _, from := range hashTagKeysStatusTransitions[status] {
+		if model.Status == from {
+			return nil
+		}
+	}
+	return &hashTagKeysStatusTransitionError{hashTag: model.HashTag, from: model.Status, to: status}
+}
+
+// SetStatusAsSynced sets status from need_synced to synced,
+// hashTagKeysStatusConflictError is returned if the record is updated after model is loaded.
 func (model *roomHashTagKeys) SetStatusAsSynced(db *base.DBCluster, t time.Time) error {
+	if err := model.checkStatusTransition(HashTagKeysStatusSynced); err != nil {
+		return err
+	}
 	query, err := db.Model(model)
 	if err != nil {
 		return err
@@ -248,6 +532,7 @@ func (model *roomHashTagKeys) SetStatusAsSynced(db *base.DBCluster, t time.Time)
 		Set("version=?", model.Version+1).
 		WherePK().
 		Where("version=?", model.Version).
+		Where("status=?", model.Status).
 		Update()
 	if err != nil {
 		return err
@@ -258,7 +543,12 @@ func (model *roomHashTagKeys) SetStatusAsSynced(db *base.DBCluster, t time.Time)
 	return nil
 }
 
+// SetStatusAsCleaned sets status from synced to cleaned,
+// hashTagKeysStatusConflictError is returned if the record is updated after model is loaded.
 func (model *roomHashTagKeys) SetStatusAsCleaned(db *base.DBCluster, t time.Time) error {
+	if err := model.checkStatusTransition(HashTagKeysStatusCleaned); err != nil {
+		return err
+	}
 	query, err := db.Model(model)
 	if err != nil {
 		return err
@@ -268,6 +558,7 @@ func (model *roomHashTagKeys) SetStatusAsCleaned(db *base.DBCluster, t time.Time
 		Set("version=?", model.Version+1).
 		WherePK().
 		Where("version=?", model.Version).
+		Where("status=?", model.Status).
 		Update()
 	if err != nil {
 		return err
@@ -278,8 +569,53 @@ func (model *roomHashTagKeys) SetStatusAsCleaned(db *base.DBCluster, t time.Time
 	return nil
 }
 
-func (model *roomHashTagKeys) updateFromEvent(event base.HashTagEvent) []string {
+// LFU counter of hash tags works like redis with the default lfu-log-factor and lfu-decay-time,
+// the counter is incremented once for every event of the hash tag.
+const (
+	lfuInitValue = 5
+	lfuMaxValue  = 255
+	lfuLogFactor = 10
+	lfuDecayTime = time.Minute
+)
+
+// lfuRandom is replaced in tests.
+var lfuRandom = rand.Float64
+
+// lfuDecrement decrements counter by one for every lfuDecayTime since lastAccessTime.
+func lfuDecrement(counter int, lastAccessTime, t time.Time) int {
+	if lastAccessTime.IsZero() || !t.After(lastAccessTime) {
+		return counter
+	}
+	periods := int(t.Sub(lastAccessTime) / lfuDecayTime)
+	if periods >= counter {
+		return 0
+	}
+	return counter - periods
+}
+
+// lfuLogIncrement increments counter with a probability which decreases as counter grows.
+func lfuLogIncrement(counter int) int {
+	if counter >= lfuMaxValue {
+		return lfuMaxValue
+	}
+	baseValue := float64(counter - lfuInitValue)
+	if baseValue < 0 {
+		baseValue = 0
+	}
+	if lfuRandom() < 1.0/(baseValue*lfuLogFactor+1) {
+		counter++
+	}
+	return counter
+}
+
+// updateFromEvent updates model by event and returns columns to be updated,
+// updates of accessed_at and frequency are skipped if they are the only columns to be updated and they are debounced,
+// so frequency is decayed from the accessed_at saved in db and increments within granularity are dropped.
+func (model *roomHashTagKeys) updateFromEvent(
+	event base.HashTagEvent, debounce base.AccessedAtDebounceConfig, currentTime time.Time) []string {
 	toBeUpdatedColumns := []string{}
+	originAccessedAt := model.AccessedAt
+	originFrequency := model.Frequency
 
 	originKeys := model.Keys
 	newKeys := utility.MergeStringSliceAndRemoveDuplicateItems(originKeys, event.Keys.ToSlice())
@@ -288,6 +624,11 @@ func (model *roomHashTagKeys) updateFromEvent(event base.HashTagEvent) []string
 		toBeUpdatedColumns = append(toBeUpdatedColumns, "keys")
 	}
 
+	frequency := lfuLogIncrement(lfuDecrement(model.Frequency, model.AccessedAt, event.AccessTime))
+	if frequency != model.Frequency {
+		model.Frequency = frequency
+		toBeUpdatedColumns = append(toBeUpdatedColumns, "frequency")
+	}
 	if event.AccessTime.After(model.AccessedAt) {
 		model.AccessedAt = event.AccessTime
 		toBeUpdatedColumns = append(toBeUpdatedColumns, "accessed_at")
@@ -307,10 +648,36 @@ func (model *roomHashTagKeys) updateFromEvent(event base.HashTagEvent) []string
 		model.Status = newStatus
 		toBeUpdatedColumns = append(toBeUpdatedColumns, "status")
 	}
+	if isAccessOnlyUpdate(toBeUpdatedColumns) && debounce.IsDebounced(originAccessedAt, event.AccessTime, currentTime) {
+		model.AccessedAt = originAccessedAt
+		model.Frequency = originFrequency
+		return []string{}
+	}
 	return toBeUpdatedColumns
 }
 
+// isAccessOnlyUpdate returns true if only accessed_at and frequency, which are changed by every access, are updated.
+func isAccessOnlyUpdate(columns []string) bool {
+	if len(columns) == 0 {
+		return false
+	}
+	for _, column := range columns {
+		if column != "accessed_at" && column != "frequency" {
+			return false
+		}
+	}
+	return true
+}
+
 func upsertHashTagKeysRecordByEvent(ctx context.Context, dbCluster *base.DBCluster, event base.HashTagEvent, currentTime time.Time) error {
+	return upsertHashTagKeysRecordByEventWithDebounce(ctx, dbCluster, event, currentTime, base.AccessedAtDebounceConfig{})
+}
+
+// upsertHashTagKeysRecordByEventWithDebounce upserts record of hash tag by event,
+// the record is not updated if only accessed_at and frequency are changed and their update is debounced.
+func upsertHashTagKeysRecordByEventWithDebounce(
+	ctx context.Context, dbCluster *base.DBCluster, event base.HashTagEvent, currentTime time.Time,
+	debounce base.AccessedAtDebounceConfig) error {
 	model := &roomHashTagKeys{HashTag: event.HashTag}
 	tableName, db, err := dbCluster.GetTableNameAndDBClientByModel(model)
 	if err != nil {
@@ -327,6 +694,7 @@ func upsertHashTagKeysRecordByEvent(ctx context.Context, dbCluster *base.DBClust
 				HashTag:    event.HashTag,
 				Keys:       event.Keys.ToSlice(),
 				AccessedAt: event.AccessTime,
+				Frequency:  lfuInitValue,
 				CreatedAt:  currentTime,
 				UpdatedAt:  currentTime,
 				Version:    0,
@@ -344,7 +712,7 @@ func upsertHashTagKeysRecordByEvent(ctx context.Context, dbCluster *base.DBClust
 		}
 		// update
 		originVersion := model.Version
-		toBeUpdatedColumns := model.updateFromEvent(event)
+		toBeUpdatedColumns := model.updateFromEvent(event, debounce, currentTime)
 		if len(toBeUpdatedColumns) == 0 {
 			return nil
 		}
@@ -384,19 +752,27 @@ func (condition dbWhereCondition) string() string {
 	return fmt.Sprintf("%s%s%v", condition.column, condition.operator, condition.parameter)
 }
 
+func newHashTagKeysQueryByCondition(db *base.DBCluster, models *[]*roomHashTagKeys, tableIndex int, conditions ...dbWhereCondition) (*orm.Query, error) {
+	tablePrefix := (&roomHashTagKeys{}).GetTablePrefix()
+	query, err := db.Models(models, tablePrefix, tableIndex)
+	if err != nil {
+		return nil, err
+	}
+	for _, condition := range conditions {
+		cond, parameter := condition.getConditionAndParameter()
+		query.Where(cond, parameter)
+	}
+	return query, nil
+}
+
 func loadHashTagKeysModelsByCondition(db *base.DBCluster, count int, startIndex int, conditions ...dbWhereCondition) (int, []*roomHashTagKeys, error) {
 	shardingCount := db.GetShardingCount()
-	tablePrefix := (&roomHashTagKeys{}).GetTablePrefix()
 	var models []*roomHashTagKeys
 	for index := startIndex; index < shardingCount; index++ {
-		query, err := db.Models(&models, tablePrefix, index)
+		query, err := newHashTagKeysQueryByCondition(db, &models, index, conditions...)
 		if err != nil {
 			return 0, nil, err
 		}
-		for _, condition := range conditions {
-			cond, parameter := condition.getConditionAndParameter()
-			query.Where(cond, parameter)
-		}
 		err = query.Limit(count).Select()
 		if err != nil {
 			if errors.Is(err, pg.ErrNoRows) {
@@ -410,3 +786,37 @@ func loadHashTagKeysModelsByCondition(db *base.DBCluster, count int, startIndex
 	}
 	return shardingCount, nil, nil
 }
+
+// loadHashTagKeysModelsInTableByCondition loads at most count models from the table of tableIndex,
+// models are ordered by hash tag so that they could be paged by hash tag.
+func loadHashTagKeysModelsInTableByCondition(db *base.DBCluster, count int, tableIndex int, conditions ...dbWhereCondition) ([]*roomHashTagKeys, error) {
+	var models []*roomHashTagKeys
+	query, err := newHashTagKeysQueryByCondition(db, &models, tableIndex, conditions...)
+	if err != nil {
+		return nil, err
+	}
+	err = query.Order("hash_tag ASC").Limit(count).Select()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		return nil, err
+	}
+	return models, nil
+}
+
+// countHashTagKeysByCondition returns count of hash tag keys records by table index.
+func countHashTagKeysByCondition(db *base.DBCluster, conditions ...dbWhereCondition) (map[int]int, error) {
+	shardingCount := db.GetShardingCount()
+	counts := make(map[int]int, shardingCount)
+	for index := 0; index < shardingCount; index++ {
+		var models []*roomHashTagKeys
+		query, err := newHashTagKeysQueryByCondition(db, &models, index, conditions...)
+		if err != nil {
+			return nil, err
+		}
+		count, err := query.Count()
+		if err != nil {
+			return nil, err
+		}
+		counts[index] = count
+	}
+	return counts, nil
+}
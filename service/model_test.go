@@ -2,8 +2,11 @@ package service
 
 import (
 	"bytepower_room/base"
+	"bytepower_room/utility"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -210,6 +213,32 @@ func TestRedisValue(t *testing.T) {
 	assert.Greater(t, int64(value.TTL(time.Time{})), int64(0))
 }
 
+func TestRoomDataUnexpiredValue(t *testing.T) {
+	currentTime := time.Now()
+	currentTs := utility.TimestampInMS(currentTime)
+	model := &roomDataModelV2{
+		Value: map[string]RedisValue{
+			"no_expiration":   {Type: stringType, Value: "a"},
+			"not_expired":     {Type: stringType, Value: "b", ExpireTs: currentTs + 1},
+			"just_expired":    {Type: stringType, Value: "c", ExpireTs: currentTs},
+			"already_expired": {Type: stringType, Value: "d", ExpireTs: currentTs - 1},
+		},
+	}
+	value, expiredCount := model.unexpiredValue(currentTime)
+	assert.Equal(t, 2, expiredCount)
+	assert.Equal(t, 2, len(value))
+	assert.Contains(t, value, "no_expiration")
+	assert.Contains(t, value, "not_expired")
+
+	// nothing is expired at zero time
+	value, expiredCount = model.unexpiredValue(time.Time{})
+	assert.Equal(t, 0, expiredCount)
+	assert.Equal(t, model.Value, value)
+
+	// model.Value is not changed
+	assert.Equal(t, 4, len(model.Value))
+}
+
 func TestUpsertHashTagKeysRecordByEvent(t *testing.T) {
 	db := base.GetServerDependency().DB
 
@@ -314,3 +343,216 @@ func TestUpsertHashTagKeysRecordByEvent(t *testing.T) {
 	assert.True(t, currentTime.Equal(model.UpdatedAt))
 	assert.True(t, currentTime.After(model.CreatedAt))
 }
+
+func TestDBHealthCheck(t *testing.T) {
+	db := base.GetServerDependency().DB
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.Nil(t, db.HealthCheck(ctx))
+	assert.Nil(t, db.HealthCheck(ctx, RoomServerDBTablePrefixes()...))
+	assert.Nil(t, db.HealthCheck(ctx, (&roomHashTagKeys{}).GetTablePrefix()))
+
+	err := db.HealthCheck(ctx, "room_table_not_exist")
+	assert.True(t, errors.Is(err, base.ErrTableMissing))
+	assert.Contains(t, err.Error(), "room_table_not_exist_0")
+}
+
+func TestDBCheckColumns(t *testing.T) {
+	db := base.GetServerDependency().DB
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.Nil(t, CheckDBColumns(ctx, db))
+	assert.Nil(t, db.CheckColumns(ctx, (&roomDataModelV2{}).GetTablePrefix(), "hash_tag", "version"))
+
+	err := db.CheckColumns(ctx, (&roomHashTagKeys{}).GetTablePrefix(), "frequency", "column_not_exist")
+	assert.True(t, errors.Is(err, base.ErrColumnMissing))
+	assert.Contains(t, err.Error(), "room_hash_tag_keys_0.column_not_exist")
+	assert.NotContains(t, err.Error(), "frequency")
+}
+
+func TestRunMigrations(t *testing.T) {
+	db := base.GetServerDependency().DB
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// migrations are idempotent
+	assert.Nil(t, base.RunMigrations(ctx, db))
+	assert.Nil(t, base.RunMigrations(ctx, db))
+	err := db.HealthCheck(ctx, (&roomDataModelV2{}).GetTablePrefix(), (&roomHashTagKeys{}).GetTablePrefix())
+	assert.Nil(t, err)
+}
+
+func TestRedisValueEncoding(t *testing.T) {
+	longString := strings.Repeat("a", 65)
+	ints := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		ints = append(ints, fmt.Sprintf(`"%d"`, i))
+	}
+	manyInts := fmt.Sprintf("[%s]", strings.Join(ints, ","))
+	testCases := []struct {
+		value    RedisValue
+		config   base.ObjectEncodingConfig
+		encoding string
+	}{
+		{RedisValue{Type: stringType, Value: "123"}, base.ObjectEncodingConfig{}, "int"},
+		{RedisValue{Type: stringType, Value: "0123"}, base.ObjectEncodingConfig{}, "embstr"},
+		{RedisValue{Type: stringType, Value: longString}, base.ObjectEncodingConfig{}, "raw"},
+		{RedisValue{Type: listType, Value: `["a"]`}, base.ObjectEncodingConfig{}, "listpack"},
+		{RedisValue{Type: listType, Value: fmt.Sprintf(`["%s"]`, longString)}, base.ObjectEncodingConfig{}, "quicklist"},
+		{RedisValue{Type: listType, Value: manyInts}, base.ObjectEncodingConfig{}, "quicklist"},
+		{RedisValue{Type: listType, Value: manyInts}, base.ObjectEncodingConfig{ListMaxListpackEntries: 200}, "listpack"},
+		{RedisValue{Type: setType, Value: `["1","2"]`}, base.ObjectEncodingConfig{}, "intset"},
+		{RedisValue{Type: setType, Value: manyInts}, base.ObjectEncodingConfig{SetMaxIntsetEntries: 100}, "hashtable"},
+		{RedisValue{Type: setType, Value: `["1","a"]`}, base.ObjectEncodingConfig{}, "listpack"},
+		{RedisValue{Type: setType, Value: fmt.Sprintf(`["1","%s"]`, longString)}, base.ObjectEncodingConfig{}, "hashtable"},
+		{RedisValue{Type: hashType, Value: `["f","v"]`}, base.ObjectEncodingConfig{}, "listpack"},
+		{RedisValue{Type: hashType, Value: fmt.Sprintf(`["f","%s"]`, longString)}, base.ObjectEncodingConfig{}, "hashtable"},
+		{RedisValue{Type: hashType, Value: `["f","v"]`}, base.ObjectEncodingConfig{HashMaxListpackValue: 1}, "listpack"},
+		{RedisValue{Type: hashType, Value: `["f","vv"]`}, base.ObjectEncodingConfig{HashMaxListpackValue: 1}, "hashtable"},
+		{RedisValue{Type: zsetType, Value: `["m","1"]`}, base.ObjectEncodingConfig{}, "listpack"},
+		{RedisValue{Type: zsetType, Value: fmt.Sprintf(`["%s","1"]`, longString)}, base.ObjectEncodingConfig{}, "skiplist"},
+		{RedisValue{Type: zsetType, Value: `["m","1","n","2"]`}, base.ObjectEncodingConfig{ZSetMaxListpackEntries: 1}, "skiplist"},
+		{RedisValue{Type: streamType, Value: `[]`}, base.ObjectEncodingConfig{}, "stream"},
+	}
+	for _, testCase := range testCases {
+		encoding, err := testCase.value.encoding(testCase.config)
+		assert.Nil(t, err, testCase.value.String())
+		assert.Equal(t, testCase.encoding, encoding, testCase.value.String())
+	}
+	_, err := RedisValue{Type: hashType, Value: "invalid"}.encoding(base.ObjectEncodingConfig{})
+	assert.NotNil(t, err)
+}
+
+func TestLFUCounter(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, 10, lfuDecrement(10, time.Time{}, now))
+	assert.Equal(t, 10, lfuDecrement(10, now, now))
+	assert.Equal(t, 10, lfuDecrement(10, now.Add(-30*time.Second), now))
+	assert.Equal(t, 7, lfuDecrement(10, now.Add(-3*time.Minute), now))
+	assert.Equal(t, 0, lfuDecrement(10, now.Add(-time.Hour), now))
+
+	originRandom := lfuRandom
+	defer func() { lfuRandom = originRandom }()
+
+	lfuRandom = func() float64 { return 0.5 }
+	assert.Equal(t, lfuInitValue+1, lfuLogIncrement(lfuInitValue))
+	assert.Equal(t, lfuInitValue+1, lfuLogIncrement(lfuInitValue+1))
+	assert.Equal(t, lfuMaxValue, lfuLogIncrement(lfuMaxValue))
+
+	lfuRandom = func() float64 { return 0 }
+	assert.Equal(t, lfuInitValue+2, lfuLogIncrement(lfuInitValue+1))
+}
+
+func TestUpdateHashTagKeysFromEventWithDebounce(t *testing.T) {
+	originRandom := lfuRandom
+	defer func() { lfuRandom = originRandom }()
+	// counter is always incremented on access.
+	lfuRandom = func() float64 { return 0 }
+
+	now := time.Now()
+	debounce := base.AccessedAtDebounceConfig{Enabled: true, Granularity: 5 * time.Minute, InactiveDuration: time.Hour}
+	newModel := func(accessedAt time.Time, frequency int) *roomHashTagKeys {
+		return &roomHashTagKeys{
+			HashTag: "debounce", Keys: []string{"{debounce}a"}, AccessedAt: accessedAt,
+			Frequency: frequency, Status: HashTagKeysStatusSynced,
+		}
+	}
+	readEvent := func(accessTime time.Time) base.HashTagEvent {
+		event, _ := base.NewHashTagEvent("debounce", []string{"{debounce}a"}, base.HashTagAccessModeRead, accessTime)
+		return event
+	}
+
+	// frequency is incremented by access, both columns are debounced.
+	model := newModel(now, lfuInitValue)
+	assert.Equal(t, []string{}, model.updateFromEvent(readEvent(now.Add(time.Second)), debounce, now))
+	assert.Equal(t, now, model.AccessedAt)
+	assert.Equal(t, lfuInitValue, model.Frequency)
+
+	// frequency is decayed and incremented, both columns are debounced.
+	model = newModel(now, 20)
+	assert.Equal(t, []string{}, model.updateFromEvent(readEvent(now.Add(3*time.Minute)), debounce, now))
+	assert.Equal(t, now, model.AccessedAt)
+	assert.Equal(t, 20, model.Frequency)
+
+	model = newModel(now, lfuInitValue)
+	assert.Equal(
+		t,
+		[]string{"frequency", "accessed_at"},
+		model.updateFromEvent(readEvent(now.Add(time.Second)), base.AccessedAtDebounceConfig{}, now),
+	)
+	assert.Equal(t, now.Add(time.Second), model.AccessedAt)
+	assert.Equal(t, lfuInitValue+1, model.Frequency)
+
+	// access time is beyond granularity.
+	model = newModel(now, 20)
+	assert.Equal(t, []string{"frequency", "accessed_at"}, model.updateFromEvent(readEvent(now.Add(10*time.Minute)), debounce, now))
+	assert.Equal(t, 20-10+1, model.Frequency)
+
+	// accessed_at and frequency are updated with other columns.
+	model = newModel(now, lfuInitValue)
+	event, _ := base.NewHashTagEvent("debounce", []string{"{debounce}b"}, base.HashTagAccessModeRead, now.Add(time.Second))
+	assert.Equal(t, []string{"keys", "frequency", "accessed_at", "status"}, model.updateFromEvent(event, debounce, now))
+	assert.Equal(t, now.Add(time.Second), model.AccessedAt)
+	assert.Equal(t, lfuInitValue+1, model.Frequency)
+}
+
+func TestHashTagKeysStatusTransition(t *testing.T) {
+	testCases := []struct {
+		from    HashTagKeysStatus
+		to      HashTagKeysStatus
+		allowed bool
+	}{
+		{from: HashTagKeysStatusNeedSynced, to: HashTagKeysStatusSynced, allowed: true},
+		{from: HashTagKeysStatusSynced, to: HashTagKeysStatusSynced, allowed: false},
+		{from: HashTagKeysStatusCleaned, to: HashTagKeysStatusSynced, allowed: false},
+		{from: HashTagKeysStatusSynced, to: HashTagKeysStatusCleaned, allowed: true},
+		{from: HashTagKeysStatusNeedSynced, to: HashTagKeysStatusCleaned, allowed: false},
+		{from: HashTagKeysStatusCleaned, to: HashTagKeysStatusCleaned, allowed: false},
+	}
+	db := base.GetServerDependency().DB
+	hashTag := "status_transition"
+	defer testEmptyHashTagKeysRecordInDB(hashTag)
+	for _, testCase := range testCases {
+		testEmptyHashTagKeysRecordInDB(hashTag)
+		model := &roomHashTagKeys{HashTag: hashTag, Keys: []string{"{status_transition}a"}, Status: testCase.from, Version: 1}
+		query, err := db.Model(model)
+		assert.Nil(t, err)
+		_, err = query.Insert()
+		assert.Nil(t, err)
+
+		if testCase.to == HashTagKeysStatusSynced {
+			err = model.SetStatusAsSynced(db, time.Now())
+		} else {
+			err = model.SetStatusAsCleaned(db, time.Now())
+		}
+		record, loadErr := loadHashTagKeysByHashTag(db, hashTag)
+		assert.Nil(t, loadErr)
+		if testCase.allowed {
+			assert.Nil(t, err, "%s -> %s", testCase.from, testCase.to)
+			assert.Equal(t, testCase.to, record.Status)
+			assert.Equal(t, int64(2), record.Version)
+		} else {
+			assert.True(t, errors.Is(err, errHashTagKeysStatusTransition), "%s -> %s", testCase.from, testCase.to)
+			assert.Equal(t, testCase.from, record.Status)
+			assert.Equal(t, int64(1), record.Version)
+		}
+	}
+
+	// status is changed by a write after the record is loaded.
+	testEmptyHashTagKeysRecordInDB(hashTag)
+	model := &roomHashTagKeys{HashTag: hashTag, Status: HashTagKeysStatusSynced, Version: 1}
+	query, err := db.Model(model)
+	assert.Nil(t, err)
+	_, err = query.Insert()
+	assert.Nil(t, err)
+	event, _ := base.NewHashTagEvent(hashTag, []string{"{status_transition}a"}, base.HashTagAccessModeWrite, time.Now())
+	assert.Nil(t, upsertHashTagKeysRecordByEvent(context.TODO(), db, event, time.Now()))
+	err = model.SetStatusAsCleaned(db, time.Now())
+	var conflictErr *hashTagKeysStatusConflictError
+	assert.True(t, errors.As(err, &conflictErr))
+	record, err := loadHashTagKeysByHashTag(db, hashTag)
+	assert.Nil(t, err)
+	assert.Equal(t, HashTagKeysStatusNeedSynced, record.Status)
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"bytepower_room/commands"
+	"bytepower_room/utility"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/redcon"
+)
+
+const subscriberMessageBufferSize = 1024
+
+var subscriberManager = SubscriberManager{
+	subscribers: make(map[redcon.Conn]*subscriber),
+	mutex:       &sync.Mutex{},
+}
+
+var subscribeModeCommands = []string{"subscribe", "unsubscribe", "psubscribe", "punsubscribe", "ping", "quit"}
+
+func newSubscribeModeError(commandName string) error {
+	return fmt.Errorf(
+		"ERR Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context",
+		commandName,
+	)
+}
+
+// subscriber is a connection which has subscribed channels.
+// The connection is detached from redcon server after its first subscribe command,
+// then commands of the connection are read and served by serveSubscriber,
+// and published messages are pushed to the connection by pushMessages.
+type subscriber struct {
+	conn      redcon.Conn
+	dconn     redcon.DetachedConn
+	messageCh chan base.PubSubMessage
+	channels  map[string]bool
+	mutex     *sync.Mutex
+	// writeMutex serializes writes to the connection after it is detached.
+	writeMutex *sync.Mutex
+	stopCh     chan struct{}
+	// detachClosedCh is closed after the close handler called by redcon for the detach is done.
+	detachClosedCh chan struct{}
+}
+
+func newSubscriber(conn redcon.Conn) *subscriber {
+	return &subscriber{
+		conn:           conn,
+		messageCh:      make(chan base.PubSubMessage, subscriberMessageBufferSize),
+		channels:       make(map[string]bool),
+		mutex:          &sync.Mutex{},
+		writeMutex:     &sync.Mutex{},
+		stopCh:         make(chan struct{}),
+		detachClosedCh: make(chan struct{}),
+	}
+}
+
+// subscribe subscribes channel and returns count of subscribed channels.
+func (sub *subscriber) subscribe(channel string) int {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if !sub.channels[channel] {
+		sub.channels[channel] = true
+		base.GetPubSubBroker().Subscribe(channel, sub.messageCh)
+	}
+	return len(sub.channels)
+}
+
+// unsubscribe unsubscribes channel and returns count of subscribed channels.
+func (sub *subscriber) unsubscribe(channel string) int {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if sub.channels[channel] {
+		delete(sub.channels, channel)
+		base.GetPubSubBroker().Unsubscribe(channel, sub.messageCh)
+	}
+	return len(sub.channels)
+}
+
+// unsubscribeAll unsubscribes all channels, the connection stays detached if it is.
+func (sub *subscriber) unsubscribeAll() {
+	for _, channel := range sub.subscribedChannels() {
+		sub.unsubscribe(channel)
+	}
+}
+
+func (sub *subscriber) subscribedChannels() []string {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	channels := make([]string, 0, len(sub.channels))
+	for channel := range sub.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+func (sub *subscriber) isSubscribing() bool {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	return len(sub.channels) > 0
+}
+
+func (sub *subscriber) isDetached() bool {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	return sub.dconn != nil
+}
+
+// detach detaches connection from redcon server, it returns false if connection is already detached.
+func (sub *subscriber) detach() bool {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if sub.dconn != nil {
+		return false
+	}
+	sub.dconn = sub.conn.Detach()
+	return true
+}
+
+// markDetachClosed returns true if it is the first time connection is closed after detached,
+// which is the close called by redcon for the detach.
+func (sub *subscriber) markDetachClosed() bool {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if sub.dconn == nil {
+		return false
+	}
+	select {
+	case <-sub.detachClosedCh:
+		return false
+	default:
+		close(sub.detachClosedCh)
+		return true
+	}
+}
+
+func (sub *subscriber) close() {
+	sub.writeMutex.Lock()
+	defer sub.writeMutex.Unlock()
+	sub.dconn.Close()
+}
+
+type SubscriberManager struct {
+	subscribers map[redcon.Conn]*subscriber
+	mutex       *sync.Mutex
+}
+
+func (manager *SubscriberManager) getSubscriber(conn redcon.Conn) *subscriber {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	return manager.subscribers[conn]
+}
+
+func (manager *SubscriberManager) getOrAddSubscriber(conn redcon.Conn) *subscriber {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	sub := manager.subscribers[conn]
+	if sub == nil {
+		sub = newSubscriber(conn)
+		manager.subscribers[conn] = sub
+	}
+	return sub
+}
+
+// removeSubscriber removes subscriber of conn and unsubscribes all its channels.
+func (manager *SubscriberManager) removeSubscriber(conn redcon.Conn) {
+	manager.mutex.Lock()
+	sub := manager.subscribers[conn]
+	delete(manager.subscribers, conn)
+	manager.mutex.Unlock()
+	if sub == nil {
+		return
+	}
+	sub.unsubscribeAll()
+}
+
+// closeDetached closes connections of detached subscribers,
+// which are not closed by redcon server when it is closed.
+func (manager *SubscriberManager) closeDetached() {
+	manager.mutex.Lock()
+	subscribers := make([]*subscriber, 0, len(manager.subscribers))
+	for _, sub := range manager.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	manager.mutex.Unlock()
+	for _, sub := range subscribers {
+		if sub.isDetached() {
+			sub.conn.NetConn().Close()
+		}
+	}
+}
+
+func newPubSubReply(kind string, channel *string, count int) commands.RESPData {
+	channelReply := commands.RESPData{DataType: commands.NilRespType, Value: nil}
+	if channel != nil {
+		channelReply = commands.RESPData{DataType: commands.BulkStringRespType, Value: *channel}
+	}
+	return commands.RESPData{
+		DataType: commands.ArrayRespType,
+		Value: []commands.RESPData{
+			{DataType: commands.BulkStringRespType, Value: kind},
+			channelReply,
+			{DataType: commands.IntegerRespType, Value: int64(count)},
+		},
+	}
+}
+
+func processSubscribeCommand(conn redcon.Conn, command *commands.SubscribeCommand) commands.RESPData {
+	sub := subscriberManager.getOrAddSubscriber(conn)
+	replies := make([]commands.RESPData, 0, len(command.Channels()))
+	for _, channel := range command.Channels() {
+		channel := channel
+		replies = append(replies, newPubSubReply("subscribe", &channel, sub.subscribe(channel)))
+	}
+	return commands.RESPData{DataType: commands.MultipleRespType, Value: replies}
+}
+
+func processUnsubscribeCommand(conn redcon.Conn, command *commands.UnsubscribeCommand) commands.RESPData {
+	sub := subscriberManager.getSubscriber(conn)
+	channels := command.Channels()
+	if len(channels) == 0 && sub != nil {
+		channels = sub.subscribedChannels()
+	}
+	if len(channels) == 0 {
+		return newPubSubReply("unsubscribe", nil, 0)
+	}
+	replies := make([]commands.RESPData, 0, len(channels))
+	for _, channel := range channels {
+		channel := channel
+		count := 0
+		if sub != nil {
+			count = sub.unsubscribe(channel)
+		}
+		replies = append(replies, newPubSubReply("unsubscribe", &channel, count))
+	}
+	return commands.RESPData{DataType: commands.MultipleRespType, Value: replies}
+}
+
+func processPublishCommand(command *commands.PublishCommand) commands.RESPData {
+	count := base.GetPubSubBroker().Publish(command.Channel(), command.Message())
+	return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(count)}
+}
+
+func processPubSubCommand(command *commands.PubSubCommand) commands.RESPData {
+	broker := base.GetPubSubBroker()
+	switch command.SubCommand() {
+	case commands.PubSubSubCommandChannels:
+		channels := broker.Channels(command.Pattern())
+		replies := make([]commands.RESPData, 0, len(channels))
+		for _, channel := range channels {
+			replies = append(replies, commands.RESPData{DataType: commands.BulkStringRespType, Value: channel})
+		}
+		return commands.RESPData{DataType: commands.ArrayRespType, Value: replies}
+	case commands.PubSubSubCommandNumSub:
+		replies := make([]commands.RESPData, 0, 2*len(command.Channels()))
+		for _, channel := range command.Channels() {
+			replies = append(
+				replies,
+				commands.RESPData{DataType: commands.BulkStringRespType, Value: channel},
+				commands.RESPData{DataType: commands.IntegerRespType, Value: int64(broker.NumSub(channel))},
+			)
+		}
+		return commands.RESPData{DataType: commands.ArrayRespType, Value: replies}
+	case commands.PubSubSubCommandNumPat:
+		// pattern subscription is not supported.
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(0)}
+	}
+	return commands.ConvertErrorToRESPData(fmt.Errorf("ERR unknown pubsub subcommand %s", command.SubCommand()))
+}
+
+// processCommandInSubscribeMode returns result and true if command is not allowed or processed differently
+// when connection is in subscribe mode.
+func processCommandInSubscribeMode(commandName string, args []string) (commands.RESPData, bool) {
+	if !utility.StringSliceContains(subscribeModeCommands, commandName) {
+		return commands.ConvertErrorToRESPData(newSubscribeModeError(commandName)), true
+	}
+	if commandName == "ping" {
+		message := ""
+		if len(args) > 1 {
+			message = args[1]
+		}
+		return commands.RESPData{
+			DataType: commands.ArrayRespType,
+			Value: []commands.RESPData{
+				{DataType: commands.BulkStringRespType, Value: "pong"},
+				{DataType: commands.BulkStringRespType, Value: message},
+			},
+		}, true
+	}
+	return commands.RESPData{}, false
+}
+
+// detachSubscriberIfNeeded detaches connection from redcon server after it subscribes channels,
+// so that published messages could be pushed to it.
+func (service *RoomService) detachSubscriberIfNeeded(conn redcon.Conn) {
+	sub := subscriberManager.getSubscriber(conn)
+	if sub == nil || !sub.isSubscribing() {
+		return
+	}
+	if sub.detach() {
+		go service.serveSubscriber(sub)
+	}
+}
+
+func (service *RoomService) serveSubscriber(sub *subscriber) {
+	var err error
+	defer func() {
+		close(sub.stopCh)
+		// wait for the close handler called by redcon for the detach,
+		// otherwise connection may be cleaned up twice.
+		<-sub.detachClosedCh
+		sub.close()
+		if err == io.EOF {
+			err = nil
+		}
+		service.connCloseHandler(sub.conn, err)
+	}()
+
+	go service.pushMessages(sub)
+
+	// replies of commands before connection is detached are not flushed by redcon.
+	sub.writeMutex.Lock()
+	err = sub.dconn.Flush()
+	sub.writeMutex.Unlock()
+	if err != nil {
+		return
+	}
+	for {
+		var cmd redcon.Command
+		cmd, err = sub.dconn.ReadCommand()
+		if err != nil {
+			return
+		}
+		sub.writeMutex.Lock()
+		if len(cmd.Args) > 0 && strings.ToLower(string(cmd.Args[0])) == "quit" {
+			sub.dconn.WriteString("OK")
+			err = sub.dconn.Flush()
+			sub.writeMutex.Unlock()
+			return
+		}
+		service.connServeHandler(sub.conn, []redcon.Command{cmd})
+		err = sub.dconn.Flush()
+		sub.writeMutex.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (service *RoomService) pushMessages(sub *subscriber) {
+	for {
+		select {
+		case <-sub.stopCh:
+			return
+		case message := <-sub.messageCh:
+			sub.writeMutex.Lock()
+			sub.dconn.WriteArray(3)
+			sub.dconn.WriteBulkString("message")
+			sub.dconn.WriteBulkString(message.Channel)
+			sub.dconn.WriteBulkString(message.Message)
+			err := sub.dconn.Flush()
+			sub.writeMutex.Unlock()
+			if err != nil {
+				service.dep.Metric.MetricIncrease("error.pubsub.push")
+				service.logWithAddressAndPid(
+					log.LevelError, "error.pubsub.push",
+					log.String("remote_addr", sub.conn.RemoteAddr()),
+					log.Error(err),
+				)
+				return
+			}
+		}
+	}
+}
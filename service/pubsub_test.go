@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessPubSubCommands(t *testing.T) {
+	conn := &testConn{addr: "127.0.0.1:10001"}
+	defer subscriberManager.removeSubscriber(conn)
+
+	command, err := commands.NewSubscribeCommand([]string{"subscribe", "{a}news", "{a}sports", "{a}news"})
+	assert.Nil(t, err)
+	result := processSubscribeCommand(conn, command.(*commands.SubscribeCommand))
+	assert.Equal(
+		t,
+		"m:3{ a:3{ bs:subscribe bs:{a}news i:1  } a:3{ bs:subscribe bs:{a}sports i:2  } a:3{ bs:subscribe bs:{a}news i:2  }  }",
+		result.String())
+	sub := subscriberManager.getSubscriber(conn)
+	assert.NotNil(t, sub)
+	assert.True(t, sub.isSubscribing())
+
+	command, err = commands.NewPublishCommand([]string{"publish", "{a}news", "hello"})
+	assert.Nil(t, err)
+	result = processPublishCommand(command.(*commands.PublishCommand))
+	assert.Equal(t, "i:1", result.String())
+	assert.Equal(t, base.PubSubMessage{Channel: "{a}news", Message: "hello"}, <-sub.messageCh)
+
+	command, err = commands.NewPubSubCommand([]string{"pubsub", "channels", "{a}n*"})
+	assert.Nil(t, err)
+	result = processPubSubCommand(command.(*commands.PubSubCommand))
+	assert.Equal(t, "a:1{ bs:{a}news  }", result.String())
+	command, err = commands.NewPubSubCommand([]string{"pubsub", "numsub", "{a}news", "{a}weather"})
+	assert.Nil(t, err)
+	result = processPubSubCommand(command.(*commands.PubSubCommand))
+	assert.Equal(t, "a:4{ bs:{a}news i:1 bs:{a}weather i:0  }", result.String())
+	command, err = commands.NewPubSubCommand([]string{"pubsub", "numpat"})
+	assert.Nil(t, err)
+	result = processPubSubCommand(command.(*commands.PubSubCommand))
+	assert.Equal(t, "i:0", result.String())
+
+	// only subscribe mode commands are allowed
+	result, ok := processCommandInSubscribeMode("get", []string{"get", "{a}1"})
+	assert.True(t, ok)
+	assert.Equal(t, newSubscribeModeError("get"), result.Value)
+	result, ok = processCommandInSubscribeMode("ping", []string{"ping"})
+	assert.True(t, ok)
+	assert.Equal(t, "a:2{ bs:pong bs:  }", result.String())
+	_, ok = processCommandInSubscribeMode("subscribe", []string{"subscribe", "{a}news"})
+	assert.False(t, ok)
+
+	command, err = commands.NewUnsubscribeCommand([]string{"unsubscribe", "{a}news"})
+	assert.Nil(t, err)
+	result = processUnsubscribeCommand(conn, command.(*commands.UnsubscribeCommand))
+	assert.Equal(t, "m:1{ a:3{ bs:unsubscribe bs:{a}news i:1  }  }", result.String())
+	command, err = commands.NewUnsubscribeCommand([]string{"unsubscribe"})
+	assert.Nil(t, err)
+	result = processUnsubscribeCommand(conn, command.(*commands.UnsubscribeCommand))
+	assert.Equal(t, "m:1{ a:3{ bs:unsubscribe bs:{a}sports i:0  }  }", result.String())
+	assert.False(t, sub.isSubscribing())
+	result = processUnsubscribeCommand(conn, command.(*commands.UnsubscribeCommand))
+	assert.Equal(t, "a:3{ bs:unsubscribe nil:nil i:0  }", result.String())
+	assert.Equal(t, []string{}, base.GetPubSubBroker().Channels(""))
+}
@@ -14,6 +14,7 @@ const (
 	metricLoadKeyIntoRedisError       = "error.loadkey.redis"
 	metricLoadKeyCheckNeedToLoadError = "error.loadkey.check_need_to_load"
 	metricLoadKeyRetryTimeoutError    = "error.loadkey.retry.timeout"
+	metricLoadKeyPurgeExpiredError    = "error.loadkey.purge_expired"
 
 	metricLoadKeySuccess                  = "loadkey.success"
 	metricLoadKeySuccessDuration          = "loadkey.duration"
@@ -25,9 +26,10 @@ const (
 	metricLoadKeyIntoRedisSuccess         = "loadkey.redis.success"
 	metricLoadKeyIntoRedisSuccessDuration = "loadkey.redis.success.duration"
 	metricLoadKeyRetryLockFailed          = "loadkey.retry.lock_failed"
+	metricLoadKeyPurgeExpiredSuccess      = "loadkey.purge_expired.success"
 )
 
-func recordLoadKeyError(logger *log.Logger, metric *base.MetricClient, hashTag string, err error, duration time.Duration, count int) {
+func recordLoadKeyError(logger *log.Logger, metric base.Metric, hashTag string, err error, duration time.Duration, count int) {
 	logger.Error(
 		metricLoadKeyError,
 		log.String("hash_tag", hashTag),
@@ -37,7 +39,7 @@ func recordLoadKeyError(logger *log.Logger, metric *base.MetricClient, hashTag s
 	metric.MetricIncrease(metricLoadKeyError)
 }
 
-func recordLoadKeyRetryTimeoutError(logger *log.Logger, metric *base.MetricClient, hashTag string, err error, times, count int) {
+func recordLoadKeyRetryTimeoutError(logger *log.Logger, metric base.Metric, hashTag string, err error, times, count int) {
 	logger.Error(
 		metricLoadKeyRetryTimeoutError,
 		log.String("hash_tag", hashTag),
@@ -47,7 +49,7 @@ func recordLoadKeyRetryTimeoutError(logger *log.Logger, metric *base.MetricClien
 	metric.MetricIncrease(metricLoadKeyRetryTimeoutError)
 }
 
-func recordLoadKeyRetryLockFailed(logger *log.Logger, metric *base.MetricClient, hashTag string, times, count int) {
+func recordLoadKeyRetryLockFailed(logger *log.Logger, metric base.Metric, hashTag string, times, count int) {
 	logger.Info(
 		metricLoadKeyRetryLockFailed,
 		log.String("hash_tag", hashTag),
@@ -56,7 +58,7 @@ func recordLoadKeyRetryLockFailed(logger *log.Logger, metric *base.MetricClient,
 	metric.MetricIncrease(metricLoadKeyRetryLockFailed)
 }
 
-func recordLoadKeyCheckNeedToLoadError(logger *log.Logger, metric *base.MetricClient, hashTag string, err error) {
+func recordLoadKeyCheckNeedToLoadError(logger *log.Logger, metric base.Metric, hashTag string, err error) {
 	logger.Error(
 		metricLoadKeyCheckNeedToLoadError,
 		log.String("hash_tag", hashTag),
@@ -65,7 +67,7 @@ func recordLoadKeyCheckNeedToLoadError(logger *log.Logger, metric *base.MetricCl
 	metric.MetricIncrease(metricLoadKeyCheckNeedToLoadError)
 }
 
-func recordLoadKeySuccess(logger *log.Logger, metric *base.MetricClient, hashTag string, duration time.Duration, count int) {
+func recordLoadKeySuccess(logger *log.Logger, metric base.Metric, hashTag string, duration time.Duration, count int) {
 	if count > 0 {
 		logger.Info(
 			metricLoadKeySuccess,
@@ -97,12 +99,12 @@ func recordLoadDBError(logger *log.Logger, hashTag string, duration time.Duratio
 	)
 }
 
-func recordLoadDBRecordNotFound(metric *base.MetricClient, hashTag string, duration time.Duration) {
+func recordLoadDBRecordNotFound(metric base.Metric, hashTag string, duration time.Duration) {
 	metric.MetricIncrease(metricLoadKeyFromDBNotFound)
 	metric.MetricTimeDuration(metricLoadKeyFromDBNotFoundDuration, duration)
 }
 
-func recordLoadIntoRedisError(logger *log.Logger, metric *base.MetricClient, hashTag string, duration time.Duration, count int, err error) {
+func recordLoadIntoRedisError(logger *log.Logger, metric base.Metric, hashTag string, duration time.Duration, count int, err error) {
 	logger.Error(
 		metricLoadKeyIntoRedisError,
 		log.String("hash_tag", hashTag),
@@ -113,7 +115,7 @@ func recordLoadIntoRedisError(logger *log.Logger, metric *base.MetricClient, has
 	metric.MetricIncrease(metricLoadKeyIntoRedisError)
 }
 
-func recordLoadIntoRedisSuccess(logger *log.Logger, metric *base.MetricClient, hashTag string, duration time.Duration, count int) {
+func recordLoadIntoRedisSuccess(logger *log.Logger, metric base.Metric, hashTag string, duration time.Duration, count int) {
 	logger.Info(
 		metricLoadKeyIntoRedisSuccess,
 		log.String("hash_tag", hashTag),
@@ -124,7 +126,25 @@ func recordLoadIntoRedisSuccess(logger *log.Logger, metric *base.MetricClient, h
 	metric.MetricTimeDuration(metricLoadKeyIntoRedisSuccessDuration, duration)
 }
 
-func recordTaskError(logger *log.Logger, metric *base.MetricClient, taskName string, err error, reason string, ctxInfo map[string]string) {
+func recordLoadKeyPurgeExpiredKeysError(logger *log.Logger, metric base.Metric, hashTag string, err error) {
+	logger.Error(
+		metricLoadKeyPurgeExpiredError,
+		log.String("hash_tag", hashTag),
+		log.Error(err),
+	)
+	metric.MetricIncrease(metricLoadKeyPurgeExpiredError)
+}
+
+func recordLoadKeyPurgeExpiredKeysSuccess(logger *log.Logger, metric base.Metric, hashTag string, count int) {
+	logger.Info(
+		metricLoadKeyPurgeExpiredSuccess,
+		log.String("hash_tag", hashTag),
+		log.Int("count", count),
+	)
+	metric.MetricCount(metricLoadKeyPurgeExpiredSuccess, count)
+}
+
+func recordTaskError(logger *log.Logger, metric base.Metric, taskName string, err error, reason string, ctxInfo map[string]string) {
 	recordTaskErrorLog(logger, taskName, err, reason, ctxInfo)
 	recordTaskErrorMetric(metric, taskName, reason)
 }
@@ -144,7 +164,7 @@ func recordTaskErrorLog(logger *log.Logger, taskName string, err error, reason s
 	logger.Error("task error", logPairs...)
 }
 
-func recordTaskErrorMetric(metric *base.MetricClient, taskName string, reasons ...string) {
+func recordTaskErrorMetric(metric base.Metric, taskName string, reasons ...string) {
 	metricName := fmt.Sprintf("%s.error", taskName)
 	metric.MetricIncrease(metricName)
 	for _, reason := range reasons {
@@ -153,13 +173,13 @@ func recordTaskErrorMetric(metric *base.MetricClient, taskName string, reasons .
 	}
 }
 
-func recordTaskSuccessInfo(logger *log.Logger, metric *base.MetricClient, taskName string, info string, count int) {
+func recordTaskSuccessInfo(logger *log.Logger, metric base.Metric, taskName string, info string, count int) {
 	metricName := fmt.Sprintf("%s.success.%s", taskName, info)
 	logger.Info(metricName, log.Int("count", count))
 	metric.MetricCount(metricName, count)
 }
 
-func recordTaskSuccess(logger *log.Logger, metric *base.MetricClient, taskName string, d time.Duration) {
+func recordTaskSuccess(logger *log.Logger, metric base.Metric, taskName string, d time.Duration) {
 	recordTaskSuccessLog(logger, taskName, d)
 	recordTaskSuccessMetric(metric, taskName, d)
 }
@@ -172,7 +192,7 @@ func recordTaskSuccessLog(logger *log.Logger, taskName string, d time.Duration)
 	)
 }
 
-func recordTaskSuccessMetric(metric *base.MetricClient, taskName string, d time.Duration) {
+func recordTaskSuccessMetric(metric base.Metric, taskName string, d time.Duration) {
 	metricName := fmt.Sprintf("%s.success", taskName)
 	metric.MetricIncrease(metricName)
 	if d != time.Duration(0) {
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"bytepower_room/commands"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const evictSyncTryTimes = 3
+
+var errAdminCommandsDisabled = errors.New("ERR admin commands are disabled")
+
+func newEvictError(hashTag string, err error) error {
+	return fmt.Errorf("ERR evict hash_tag %s error, %w", hashTag, err)
+}
+
+func (service *RoomService) processRoomEvictCommand(command *commands.RoomEvictCommand) commands.RESPData {
+	if !service.config.EnableAdminCommands {
+		return commands.ConvertErrorToRESPData(errAdminCommandsDisabled)
+	}
+	hashTag, err := command.HashTag()
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	count, err := evictHashTag(service.dep, hashTag, command.Force())
+	if err != nil {
+		service.dep.Metric.MetricIncrease("error.room.evict")
+		service.logWithAddressAndPid(
+			log.LevelError, "error.room.evict",
+			log.String("hash_tag", hashTag),
+			log.Error(err),
+		)
+		return commands.ConvertErrorToRESPData(newEvictError(hashTag, err))
+	}
+	service.dep.Metric.MetricIncrease("room.evict")
+	service.logWithAddressAndPid(
+		log.LevelInfo, "room.evict",
+		log.String("hash_tag", hashTag),
+		log.Int64("count", count),
+	)
+	return commands.RESPData{DataType: commands.IntegerRespType, Value: count}
+}
+
+// processRoomResetStatCommand resets cumulative counters of info command in the process,
+// metrics already sent to metric backend are not affected. Gauges like connected_clients are not reset.
+// Counters are only increased, so commands processed during reset never make them negative.
+func (service *RoomService) processRoomResetStatCommand(command *commands.RoomResetStatCommand) commands.RESPData {
+	if !service.config.EnableAdminCommands {
+		return commands.ConvertErrorToRESPData(errAdminCommandsDisabled)
+	}
+	resetStat()
+	service.dep.Metric.MetricIncrease("room.resetstat")
+	service.logWithAddressAndPid(log.LevelInfo, "room.resetstat")
+	return commands.RESPData{DataType: commands.SimpleStringRespType, Value: "OK"}
+}
+
+func resetStat() {
+	atomic.StoreInt64(&commandTotal, 0)
+	atomic.StoreInt64(&hashTagLoadWarmTotal, 0)
+	atomic.StoreInt64(&hashTagLoadColdTotal, 0)
+}
+
+var (
+	errEvictHashTagKeysNotFound = errors.New("keys record is not found")
+	errEvictHashTagNotSynced    = errors.New("hash_tag has unsynced writes, use force to sync it before eviction")
+)
+
+// evictHashTag removes keys of hash tag from redis and marks keys record as cleaned,
+// if hash tag has unsynced writes, it is synced to db first when force is true.
+// It returns the count of keys removed from redis.
+func evictHashTag(dep base.Dependency, hashTag string, force bool) (int64, error) {
+	model, err := loadHashTagKeysByHashTag(dep.DB, hashTag)
+	if err != nil {
+		return 0, err
+	}
+	if model == nil {
+		return 0, errEvictHashTagKeysNotFound
+	}
+	if model.Status == HashTagKeysStatusCleaned {
+		return 0, nil
+	}
+	if model.Status == HashTagKeysStatusNeedSynced {
+		if !force {
+			return 0, errEvictHashTagNotSynced
+		}
+		if err := syncRoomData(dep.DB, dep.Redis, model, time.Now(), evictSyncTryTimes); err != nil {
+			return 0, err
+		}
+		// reload model since version is changed after synced.
+		if model, err = loadHashTagKeysByHashTag(dep.DB, hashTag); err != nil {
+			return 0, err
+		}
+		if model == nil {
+			return 0, errEvictHashTagKeysNotFound
+		}
+		if model.Status != HashTagKeysStatusSynced {
+			return 0, errEvictHashTagNotSynced
+		}
+	}
+	count, err := cleanHashTagKeys(dep, model)
+	if err != nil {
+		return 0, err
+	}
+	base.GetHashTagLoadedCache().Delete(hashTag)
+	return count, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictHashTag(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "evict"
+	key := "{evict}a"
+	defer testEmptyKeysInRedis(key)
+	defer testEmptyHashTagKeysRecordInDB(hashTag)
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testCleanLocalloadedCache(hashTag)
+
+	// keys record not found
+	_, err := evictHashTag(dep, hashTag, false)
+	assert.True(t, errors.Is(err, errEvictHashTagKeysNotFound))
+
+	accessTime := time.Now()
+	assert.Nil(t, Load(dep, hashTag, accessTime, base.HashTagAccessModeWrite))
+	_, err = dep.Redis.Set(contextTODO, key, "a", 0).Result()
+	assert.Nil(t, err)
+	event, _ := base.NewHashTagEvent(hashTag, []string{key}, base.HashTagAccessModeWrite, accessTime)
+	assert.Nil(t, upsertHashTagKeysRecordByEvent(context.TODO(), dep.DB, event, time.Now()))
+
+	// unsynced writes without force
+	_, err = evictHashTag(dep, hashTag, false)
+	assert.True(t, errors.Is(err, errEvictHashTagNotSynced))
+	value, err := dep.Redis.Get(contextTODO, key).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "a", value)
+
+	// unsynced writes with force
+	count, err := evictHashTag(dep, hashTag, true)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+	_, err = dep.Redis.Get(contextTODO, key).Result()
+	assert.True(t, errors.Is(err, redis.Nil))
+	model, err := loadHashTagKeysByHashTag(dep.DB, hashTag)
+	assert.Nil(t, err)
+	assert.Equal(t, HashTagKeysStatusCleaned, model.Status)
+	data, err := loadDataByID(dep.DB, hashTag)
+	assert.Nil(t, err)
+	assert.Equal(t, RedisValue{Type: stringType, Value: "a"}, data.Value[key])
+
+	// already cleaned
+	count, err = evictHashTag(dep, hashTag, false)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestProcessRoomResetStatCommand(t *testing.T) {
+	command, err := commands.ParseCommand([]string{"room.resetstat"})
+	assert.Nil(t, err)
+	assert.True(t, isLocalCommand(command))
+
+	service := &RoomService{dep: base.GetServerDependency(), config: &base.RoomServerConfig{}}
+	result := service.processLocalCommand(context.TODO(), &testConn{}, command)
+	assert.Equal(t, commands.ConvertErrorToRESPData(errAdminCommandsDisabled), result)
+
+	service.config.EnableAdminCommands = true
+	atomic.AddInt64(&commandTotal, 10)
+	atomic.AddInt64(&hashTagLoadWarmTotal, 10)
+	atomic.AddInt64(&hashTagLoadColdTotal, 10)
+	result = service.processLocalCommand(context.TODO(), &testConn{}, command)
+	assert.Equal(t, commands.RESPData{DataType: commands.SimpleStringRespType, Value: "OK"}, result)
+	for _, counter := range []*int64{&commandTotal, &hashTagLoadWarmTotal, &hashTagLoadColdTotal} {
+		assert.Equal(t, int64(0), atomic.LoadInt64(counter))
+	}
+
+	// counters are increased during reset.
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				atomic.AddInt64(&commandTotal, 1)
+				atomic.AddInt64(&hashTagLoadColdTotal, 1)
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		service.processLocalCommand(context.TODO(), &testConn{}, command)
+	}
+	wg.Wait()
+	for _, counter := range []*int64{&commandTotal, &hashTagLoadColdTotal} {
+		value := atomic.LoadInt64(counter)
+		assert.True(t, value >= 0 && value <= 1000, value)
+	}
+}
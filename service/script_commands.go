@@ -0,0 +1,387 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"bytepower_room/commands"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+const scriptChunkName = "@user_script"
+
+var (
+	errNoScript                       = errors.New("NOSCRIPT No matching script. Please use EVAL.")
+	errScriptCommandNotAllowed        = errors.New("ERR This Redis command is not allowed from scripts")
+	errScriptCommandArgumentsInvalid  = errors.New("ERR Lua redis() command arguments must be strings or integers")
+	errScriptCommandArgumentsRequired = errors.New("ERR Please specify at least one argument for redis.call()")
+)
+
+func newScriptCompileError(err error) error {
+	return fmt.Errorf("ERR Error compiling script (new function): %s", err.Error())
+}
+
+func newScriptRunError(sha1 string, err error) error {
+	return fmt.Errorf("ERR Error running script (call to f_%s): %s", sha1, err.Error())
+}
+
+func newScriptKeyHashTagError(hashTag, scriptHashTag string) error {
+	return fmt.Errorf(
+		"ERR script accessed keys with hash tag '%s', only keys with hash tag '%s' declared by script keys are allowed",
+		hashTag, scriptHashTag)
+}
+
+// scriptCache saves compiled scripts by sha1 of their source,
+// scripts are cached in the room server instance and never evicted.
+var scriptCache = ScriptCache{
+	scripts: make(map[string]*lua.FunctionProto),
+	mutex:   &sync.RWMutex{},
+}
+
+type ScriptCache struct {
+	scripts map[string]*lua.FunctionProto
+	mutex   *sync.RWMutex
+}
+
+func getScriptSHA1(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// load compiles script and caches it, it returns sha1 of script.
+func (cache *ScriptCache) load(script string) (string, *lua.FunctionProto, error) {
+	sha1 := getScriptSHA1(script)
+	if proto := cache.get(sha1); proto != nil {
+		return sha1, proto, nil
+	}
+	chunk, err := parse.Parse(strings.NewReader(script), scriptChunkName)
+	if err != nil {
+		return "", nil, newScriptCompileError(err)
+	}
+	proto, err := lua.Compile(chunk, scriptChunkName)
+	if err != nil {
+		return "", nil, newScriptCompileError(err)
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.scripts[sha1] = proto
+	return sha1, proto, nil
+}
+
+func (cache *ScriptCache) get(sha1 string) *lua.FunctionProto {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	return cache.scripts[sha1]
+}
+
+// scriptLocks makes scripts of a hash tag run one by one, so that a script is atomic to other scripts of the hash tag,
+// scripts of different hash tags run concurrently.
+// Scripts do not block other commands like redis does, other commands of the hash tag could interleave with
+// commands called by a script, so a script is not atomic to them.
+var scriptLocks = hashTagLocks{
+	locks: make(map[string]*hashTagLock),
+	mutex: &sync.Mutex{},
+}
+
+type hashTagLocks struct {
+	locks map[string]*hashTagLock
+	mutex *sync.Mutex
+}
+
+type hashTagLock struct {
+	mutex sync.Mutex
+	// count is the number of goroutines holding or waiting for the lock, the lock is removed if it is 0.
+	count int
+}
+
+func (locks *hashTagLocks) lock(hashTag string) {
+	locks.mutex.Lock()
+	lock, ok := locks.locks[hashTag]
+	if !ok {
+		lock = &hashTagLock{}
+		locks.locks[hashTag] = lock
+	}
+	lock.count++
+	locks.mutex.Unlock()
+	lock.mutex.Lock()
+}
+
+func (locks *hashTagLocks) unlock(hashTag string) {
+	locks.mutex.Lock()
+	lock := locks.locks[hashTag]
+	lock.count--
+	if lock.count == 0 {
+		delete(locks.locks, hashTag)
+	}
+	locks.mutex.Unlock()
+	lock.mutex.Unlock()
+}
+
+func newScriptTimeoutError(timeout time.Duration) error {
+	return fmt.Errorf("script timed out after %s", timeout)
+}
+
+func (service *RoomService) processEvalCommand(ctx context.Context, command *commands.EvalCommand) commands.RESPData {
+	sha1, proto, err := scriptCache.load(command.Script())
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return service.runScript(ctx, command, sha1, proto, command.Keys(), command.ScriptArgs())
+}
+
+func (service *RoomService) processEvalShaCommand(ctx context.Context, command *commands.EvalShaCommand) commands.RESPData {
+	proto := scriptCache.get(command.SHA1())
+	if proto == nil {
+		return commands.ConvertErrorToRESPData(errNoScript)
+	}
+	return service.runScript(ctx, command, command.SHA1(), proto, command.Keys(), command.ScriptArgs())
+}
+
+func processScriptCommand(command *commands.ScriptCommand) commands.RESPData {
+	switch command.SubCommand() {
+	case commands.ScriptSubCommandLoad:
+		sha1, _, err := scriptCache.load(command.Script())
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		return commands.RESPData{DataType: commands.BulkStringRespType, Value: sha1}
+	case commands.ScriptSubCommandExists:
+		replies := make([]commands.RESPData, 0, len(command.SHA1s()))
+		for _, sha1 := range command.SHA1s() {
+			exists := int64(0)
+			if scriptCache.get(sha1) != nil {
+				exists = 1
+			}
+			replies = append(replies, commands.RESPData{DataType: commands.IntegerRespType, Value: exists})
+		}
+		return commands.RESPData{DataType: commands.ArrayRespType, Value: replies}
+	}
+	return commands.ConvertErrorToRESPData(errors.New("ERR unknown script subcommand"))
+}
+
+// runScript stops the script if ctx is done or it runs longer than script timeout.
+func (service *RoomService) runScript(
+	ctx context.Context, command commands.Commander, sha1 string, proto *lua.FunctionProto, keys, args []string) commands.RESPData {
+
+	hashTag, err := commands.CheckAndGetCommandKeysHashTag(command)
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	scriptLocks.lock(hashTag)
+	defer scriptLocks.unlock(hashTag)
+
+	scriptCtx := ctx
+	timeout := base.GetServerConfig().ScriptTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		scriptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	runner := newScriptRunner(service.dep.Redis, hashTag)
+	defer runner.close()
+	runner.state.SetContext(scriptCtx)
+	result, err := runner.run(proto, keys, args)
+	if err != nil {
+		// the script is stopped by the deadline of the command instead of script timeout if ctx is done.
+		if ctx.Err() == nil && errors.Is(scriptCtx.Err(), context.DeadlineExceeded) {
+			service.dep.Metric.MetricIncrease("error.script.timeout")
+			err = newScriptTimeoutError(timeout)
+		}
+		service.dep.Metric.MetricIncrease("error.script.run")
+		service.logWithAddressAndPid(
+			log.LevelError, "error.script.run",
+			log.String("sha1", sha1),
+			log.Error(err),
+		)
+		return commands.ConvertErrorToRESPData(newScriptRunError(sha1, err))
+	}
+	return result
+}
+
+// scriptRunner runs a script in a new lua state,
+// redis.call and redis.pcall of the script are executed by redis cluster.
+type scriptRunner struct {
+	state        *lua.LState
+	redisCluster *redis.ClusterClient
+	// hashTag is the hash tag of keys declared by the script,
+	// commands called by the script could only access keys with the hash tag.
+	hashTag string
+}
+
+func newScriptRunner(redisCluster *redis.ClusterClient, hashTag string) *scriptRunner {
+	runner := &scriptRunner{
+		state:        lua.NewState(lua.Options{SkipOpenLibs: true}),
+		redisCluster: redisCluster,
+		hashTag:      hashTag,
+	}
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		runner.state.Push(runner.state.NewFunction(lib.open))
+		runner.state.Push(lua.LString(lib.name))
+		runner.state.Call(1, 0)
+	}
+	redisTable := runner.state.NewTable()
+	runner.state.SetField(redisTable, "call", runner.state.NewFunction(func(state *lua.LState) int {
+		return runner.call(state, true)
+	}))
+	runner.state.SetField(redisTable, "pcall", runner.state.NewFunction(func(state *lua.LState) int {
+		return runner.call(state, false)
+	}))
+	runner.state.SetGlobal("redis", redisTable)
+	return runner
+}
+
+func (runner *scriptRunner) close() {
+	runner.state.Close()
+}
+
+func (runner *scriptRunner) run(proto *lua.FunctionProto, keys, args []string) (commands.RESPData, error) {
+	state := runner.state
+	state.SetGlobal("KEYS", newLuaStringTable(state, keys))
+	state.SetGlobal("ARGV", newLuaStringTable(state, args))
+	state.Push(state.NewFunctionFromProto(proto))
+	if err := state.PCall(0, 1, nil); err != nil {
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			return commands.RESPData{}, errors.New(apiErr.Object.String())
+		}
+		return commands.RESPData{}, err
+	}
+	result := state.Get(-1)
+	state.Pop(1)
+	return convertLuaValueToRESPData(result), nil
+}
+
+// call executes command of redis.call or redis.pcall,
+// errors are raised for redis.call and returned as error tables for redis.pcall.
+func (runner *scriptRunner) call(state *lua.LState, raiseError bool) int {
+	result := runner.execute(state)
+	if result.DataType == commands.ErrorRespType && raiseError {
+		state.RaiseError("%s", result.Value.(error).Error())
+		return 0
+	}
+	state.Push(convertRESPDataToLuaValue(state, result))
+	return 1
+}
+
+func (runner *scriptRunner) execute(state *lua.LState) commands.RESPData {
+	argCount := state.GetTop()
+	if argCount == 0 {
+		return commands.ConvertErrorToRESPData(errScriptCommandArgumentsRequired)
+	}
+	args := make([]string, 0, argCount)
+	for i := 1; i <= argCount; i++ {
+		switch arg := state.Get(i).(type) {
+		case lua.LString:
+			args = append(args, string(arg))
+		case lua.LNumber:
+			args = append(args, arg.String())
+		default:
+			return commands.ConvertErrorToRESPData(errScriptCommandArgumentsInvalid)
+		}
+	}
+	command, err := commands.ParseCommand(args)
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	if isLocalCommand(command) || isTransactionCommand(command) {
+		return commands.ConvertErrorToRESPData(errScriptCommandNotAllowed)
+	}
+	hashTag, err := commands.CheckAndGetCommandKeysHashTag(command)
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	if hashTag != "" && hashTag != runner.hashTag {
+		return commands.ConvertErrorToRESPData(newScriptKeyHashTagError(hashTag, runner.hashTag))
+	}
+	return commands.ExecuteCommandWithContext(state.Context(), runner.redisCluster, command)
+}
+
+func newLuaStringTable(state *lua.LState, values []string) *lua.LTable {
+	table := state.CreateTable(len(values), 0)
+	for _, value := range values {
+		table.Append(lua.LString(value))
+	}
+	return table
+}
+
+// convertRESPDataToLuaValue converts redis reply to lua value with the same rules of redis.
+func convertRESPDataToLuaValue(state *lua.LState, data commands.RESPData) lua.LValue {
+	switch data.DataType {
+	case commands.SimpleStringRespType:
+		table := state.NewTable()
+		state.SetField(table, "ok", lua.LString(fmt.Sprintf("%v", data.Value)))
+		return table
+	case commands.BulkStringRespType:
+		return lua.LString(fmt.Sprintf("%v", data.Value))
+	case commands.IntegerRespType:
+		num, _ := data.Value.(int64)
+		return lua.LNumber(num)
+	case commands.ErrorRespType:
+		table := state.NewTable()
+		message := errInvalidResponse.Error()
+		if err, ok := data.Value.(error); ok {
+			message = err.Error()
+		}
+		state.SetField(table, "err", lua.LString(message))
+		return table
+	case commands.ArrayRespType:
+		items, _ := data.Value.([]commands.RESPData)
+		table := state.CreateTable(len(items), 0)
+		for _, item := range items {
+			table.Append(convertRESPDataToLuaValue(state, item))
+		}
+		return table
+	}
+	// nil and nil array are converted to false.
+	return lua.LFalse
+}
+
+// convertLuaValueToRESPData converts lua value returned by script to redis reply with the same rules of redis.
+func convertLuaValueToRESPData(value lua.LValue) commands.RESPData {
+	switch v := value.(type) {
+	case lua.LString:
+		return commands.RESPData{DataType: commands.BulkStringRespType, Value: string(v)}
+	case lua.LNumber:
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(v)}
+	case lua.LBool:
+		if bool(v) {
+			return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(1)}
+		}
+	case *lua.LTable:
+		if message, ok := v.RawGetString("err").(lua.LString); ok {
+			return commands.ConvertErrorToRESPData(errors.New(string(message)))
+		}
+		if status, ok := v.RawGetString("ok").(lua.LString); ok {
+			return commands.RESPData{DataType: commands.SimpleStringRespType, Value: string(status)}
+		}
+		// array is truncated at the first nil like redis.
+		items := make([]commands.RESPData, 0)
+		for i := 1; ; i++ {
+			item := v.RawGetInt(i)
+			if item == lua.LNil {
+				break
+			}
+			items = append(items, convertLuaValueToRESPData(item))
+		}
+		return commands.RESPData{DataType: commands.ArrayRespType, Value: items}
+	}
+	return commands.RESPData{DataType: commands.NilRespType, Value: nil}
+}
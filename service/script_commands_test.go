@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptCommands(t *testing.T) {
+	service := &RoomService{dep: base.GetServerDependency()}
+	key := "{script}a"
+	defer testEmptyKeysInRedis(key)
+
+	script := "redis.call('set', KEYS[1], ARGV[1]); return {redis.call('get', KEYS[1]), redis.call('strlen', KEYS[1])}"
+	command, err := commands.NewEvalCommand([]string{"eval", script, "1", key, "hello"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{key}, command.WriteKeys())
+	result := service.processEvalCommand(context.TODO(), command.(*commands.EvalCommand))
+	assert.Equal(t, "a:2{ bs:hello i:5  }", result.String())
+
+	// script is cached after eval
+	sha1 := getScriptSHA1(script)
+	command, err = commands.NewScriptCommand([]string{"script", "exists", sha1, "ffffffffffffffffffffffffffffffffffffffff"})
+	assert.Nil(t, err)
+	result = processScriptCommand(command.(*commands.ScriptCommand))
+	assert.Equal(t, "a:2{ i:1 i:0  }", result.String())
+
+	script = "return redis.call('get', KEYS[1])"
+	command, err = commands.NewScriptCommand([]string{"script", "load", script})
+	assert.Nil(t, err)
+	result = processScriptCommand(command.(*commands.ScriptCommand))
+	assert.Equal(t, "bs:"+getScriptSHA1(script), result.String())
+	command, err = commands.NewEvalShaCommand([]string{"evalsha", getScriptSHA1(script), "1", key})
+	assert.Nil(t, err)
+	result = service.processEvalShaCommand(context.TODO(), command.(*commands.EvalShaCommand))
+	assert.Equal(t, "bs:hello", result.String())
+
+	command, err = commands.NewEvalShaCommand([]string{"evalsha", "ffffffffffffffffffffffffffffffffffffffff", "0"})
+	assert.Nil(t, err)
+	result = service.processEvalShaCommand(context.TODO(), command.(*commands.EvalShaCommand))
+	assert.Equal(t, errNoScript, result.Value)
+
+	// keys accessed by script should have the hash tag of declared keys
+	command, err = commands.NewEvalCommand([]string{"eval", "return redis.pcall('get', '{other}a')", "1", key})
+	assert.Nil(t, err)
+	result = service.processEvalCommand(context.TODO(), command.(*commands.EvalCommand))
+	assert.Equal(t, newScriptKeyHashTagError("other", "script"), result.Value)
+	command, err = commands.NewEvalCommand([]string{"eval", "return redis.call('get', '{other}a')", "0"})
+	assert.Nil(t, err)
+	result = service.processEvalCommand(context.TODO(), command.(*commands.EvalCommand))
+	assert.Equal(t, commands.ErrorRespType, result.DataType)
+
+	// local and transaction commands are not allowed
+	command, err = commands.NewEvalCommand([]string{"eval", "return redis.pcall('multi')", "0"})
+	assert.Nil(t, err)
+	result = service.processEvalCommand(context.TODO(), command.(*commands.EvalCommand))
+	assert.Equal(t, errScriptCommandNotAllowed, result.Value)
+
+	command, err = commands.NewEvalCommand([]string{"eval", "return (", "0"})
+	assert.Nil(t, err)
+	result = service.processEvalCommand(context.TODO(), command.(*commands.EvalCommand))
+	assert.Equal(t, commands.ErrorRespType, result.DataType)
+}
+
+func TestScriptTimeout(t *testing.T) {
+	service := &RoomService{dep: base.GetServerDependency()}
+	config := base.GetServerConfig()
+	scriptTimeout := config.ScriptTimeout
+	config.ScriptTimeout = 100 * time.Millisecond
+	defer func() { config.ScriptTimeout = scriptTimeout }()
+
+	command, err := commands.NewEvalCommand([]string{"eval", "while true do end", "0"})
+	assert.Nil(t, err)
+	startTime := time.Now()
+	result := service.processEvalCommand(context.TODO(), command.(*commands.EvalCommand))
+	assert.Less(t, int64(time.Since(startTime)), int64(time.Second))
+	sha1 := getScriptSHA1("while true do end")
+	assert.Equal(t, newScriptRunError(sha1, newScriptTimeoutError(100*time.Millisecond)), result.Value)
+
+	// the script is stopped by the deadline of the command before script timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	startTime = time.Now()
+	result = service.processEvalCommand(ctx, command.(*commands.EvalCommand))
+	assert.Less(t, int64(time.Since(startTime)), int64(100*time.Millisecond))
+	assert.Equal(t, commands.ErrorRespType, result.DataType)
+	assert.NotEqual(t, newScriptRunError(sha1, newScriptTimeoutError(100*time.Millisecond)), result.Value)
+}
+
+func TestScriptLocks(t *testing.T) {
+	// scripts of other hash tags are not blocked.
+	scriptLocks.lock("script_lock_a")
+	doneCh := make(chan bool)
+	go func() {
+		scriptLocks.lock("script_lock_b")
+		scriptLocks.unlock("script_lock_b")
+		doneCh <- true
+	}()
+	<-doneCh
+
+	// scripts of the same hash tag run one by one.
+	go func() {
+		scriptLocks.lock("script_lock_a")
+		scriptLocks.unlock("script_lock_a")
+		doneCh <- true
+	}()
+	select {
+	case <-doneCh:
+		t.Fatal("lock of the same hash tag should be blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	scriptLocks.unlock("script_lock_a")
+	<-doneCh
+
+	scriptLocks.mutex.Lock()
+	defer scriptLocks.mutex.Unlock()
+	assert.Equal(t, 0, len(scriptLocks.locks))
+}
+
+func TestConvertLuaValueToRESPData(t *testing.T) {
+	_, proto, err := scriptCache.load("return {1, 'a', true, {ok='OK'}, {err='ERR x'}, false, 2}")
+	assert.Nil(t, err)
+	runner := newScriptRunner(nil, "")
+	defer runner.close()
+	result, err := runner.run(proto, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "a:7{ i:1 bs:a i:1 s:OK err:ERR x nil:nil i:2  }", result.String())
+}
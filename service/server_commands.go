@@ -0,0 +1,235 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"bytepower_room/utility"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/tidwall/redcon"
+)
+
+var (
+	errDebugCommandsDisabled = errors.New("ERR debug commands are disabled")
+	errNoSuchKey             = errors.New("ERR no such key")
+)
+
+func (service *RoomService) processDebugCommand(command *commands.DebugCommand) commands.RESPData {
+	if !service.config.EnableDebugCommands {
+		return commands.ConvertErrorToRESPData(errDebugCommandsDisabled)
+	}
+	switch command.SubCommand() {
+	case commands.DebugSubCommandSleep:
+		// redcon serves commands of a connection in the goroutine reading it,
+		// so sleep is canceled when the server is stopped, which closes all connections.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-service.stopCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		return command.Sleep(ctx)
+	case commands.DebugSubCommandObject:
+		return service.processDebugObjectCommand(command)
+	case commands.DebugSubCommandSetActiveExpire:
+		return service.processDebugSetActiveExpireCommand(command)
+	}
+	return commands.ConvertErrorToRESPData(errors.New("ERR unknown debug subcommand"))
+}
+
+// processDebugSetActiveExpireCommand toggles active expiration of keys in all master nodes of redis cluster,
+// debug command should be enabled in redis, e.g. enable-debug-command of redis 7.
+func (service *RoomService) processDebugSetActiveExpireCommand(command *commands.DebugCommand) commands.RESPData {
+	value := 0
+	if command.ActiveExpire() {
+		value = 1
+	}
+	err := service.dep.Redis.ForEachMaster(contextTODO, func(ctx context.Context, client *redis.Client) error {
+		return client.Do(ctx, "debug", commands.DebugSubCommandSetActiveExpire, value).Err()
+	})
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return commands.RESPData{DataType: commands.SimpleStringRespType, Value: "OK"}
+}
+
+// processDebugObjectCommand returns metadata of key like redis, lru is seconds since its hash tag is accessed.
+func (service *RoomService) processDebugObjectCommand(command *commands.DebugCommand) commands.RESPData {
+	value, accessTime, err := getValueAndAccessTime(contextTODO, service.dep, command.Key())
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	if value.IsZero() {
+		return commands.ConvertErrorToRESPData(errNoSuchKey)
+	}
+	encoding, err := value.encoding(service.config.ObjectEncoding)
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	serialized, err := json.Marshal(value)
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	idleSeconds := int64(0)
+	if !accessTime.IsZero() {
+		idleSeconds = int64(time.Since(accessTime) / time.Second)
+	}
+	info := fmt.Sprintf(
+		"Value at 0x0 refcount:1 encoding:%s serializedlength:%d lru:%d type:%s expire_ts:%d",
+		encoding, len(serialized), idleSeconds, value.Type, value.ExpireTs,
+	)
+	return commands.RESPData{DataType: commands.SimpleStringRespType, Value: info}
+}
+
+// getValueAndAccessTime reads value of key and access time of its hash tag from redis if the hash tag is loaded,
+// otherwise they are read from db, the hash tag is not loaded.
+func getValueAndAccessTime(ctx context.Context, dep base.Dependency, key string) (RedisValue, time.Time, error) {
+	hashTag := commands.ExtractHashTagFromKey(key)
+	meta, err := NewHashTagMetaInfo(hashTag, dep)
+	if err != nil {
+		return RedisValue{}, time.Time{}, err
+	}
+	status, err := meta.GetLoadStatus()
+	if err != nil {
+		return RedisValue{}, time.Time{}, err
+	}
+	if status == HashTagStatusLoaded {
+		value, err := getValueFromRedis(ctx, dep.Redis, key)
+		if err != nil {
+			return RedisValue{}, time.Time{}, err
+		}
+		accessTime, err := meta.GetAccessTime()
+		if err != nil {
+			return RedisValue{}, time.Time{}, err
+		}
+		return value, accessTime, nil
+	}
+
+	value := RedisValue{}
+	model, err := loadDataByID(dep.DB, hashTag)
+	if err != nil {
+		return RedisValue{}, time.Time{}, err
+	}
+	if model != nil && !model.Value[key].IsExpired(time.Now()) {
+		value = model.Value[key]
+	}
+	accessTime := time.Time{}
+	record, err := loadHashTagKeysByHashTag(dep.DB, hashTag)
+	if err != nil {
+		return RedisValue{}, time.Time{}, err
+	}
+	if record != nil {
+		accessTime = record.AccessedAt
+	}
+	return value, accessTime, nil
+}
+
+// processWaitCommand returns count of db replicas which have replayed the latest writes,
+// it returns 0 immediately if no replica is configured.
+func (service *RoomService) processWaitCommand(command *commands.WaitCommand) commands.RESPData {
+	dbCluster := service.dep.DB
+	if dbCluster.ReplicaCount() == 0 {
+		return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(0)}
+	}
+	ctx := context.Background()
+	if command.Timeout() > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, command.Timeout())
+		defer cancel()
+	}
+	count := dbCluster.WaitForReplicas(ctx, command.NumReplicas())
+	return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(count)}
+}
+
+// infoDefaultSections are returned by info command without section, or with section default, all or everything.
+var infoDefaultSections = []string{"server", "clients", "stats", "room"}
+
+// processInfoCommand returns sections in the format of redis, "# Section" followed by "field:value" lines,
+// unknown sections are ignored so that an empty string is returned if no section is known.
+func (service *RoomService) processInfoCommand(command *commands.InfoCommand) commands.RESPData {
+	sections := command.Sections()
+	if len(sections) == 0 {
+		sections = infoDefaultSections
+	}
+	for _, section := range sections {
+		if section == "default" || section == "all" || section == "everything" {
+			sections = infoDefaultSections
+			break
+		}
+	}
+	contents := make([]string, 0, len(sections))
+	for _, section := range infoDefaultSections {
+		if !utility.StringSliceContains(sections, section) {
+			continue
+		}
+		fields := service.infoSectionFields(section)
+		lines := []string{"# " + strings.Title(section)}
+		for i := 0; i < len(fields); i += 2 {
+			lines = append(lines, fmt.Sprintf("%s:%s", fields[i], fields[i+1]))
+		}
+		contents = append(contents, strings.Join(lines, "\r\n")+"\r\n")
+	}
+	return commands.RESPData{DataType: commands.BulkStringRespType, Value: strings.Join(contents, "\r\n")}
+}
+
+// infoSectionFields returns fields and values of section in turn.
+func (service *RoomService) infoSectionFields(section string) []string {
+	switch section {
+	case "server":
+		_, port, _ := net.SplitHostPort(service.address)
+		uptime := int64(0)
+		if !service.startTime.IsZero() {
+			uptime = int64(time.Since(service.startTime) / time.Second)
+		}
+		return []string{
+			"redis_mode", "standalone",
+			"process_id", strconv.Itoa(service.pid),
+			"tcp_port", port,
+			"uptime_in_seconds", strconv.FormatInt(uptime, 10),
+		}
+	case "clients":
+		return []string{
+			"connected_clients", strconv.FormatInt(atomic.LoadInt64(&connectionTotal), 10),
+		}
+	case "stats":
+		return []string{
+			"total_commands_processed", strconv.FormatInt(atomic.LoadInt64(&commandTotal), 10),
+		}
+	case "room":
+		eventService := base.GetHashTagEventService()
+		return []string{
+			"transactions", strconv.Itoa(transactionManager.transactionCount()),
+			"event_buffer_events", strconv.FormatInt(eventService.GetEventCountInEventBuffer(), 10),
+			"collected_event_buffer_events", strconv.FormatInt(eventService.GetEventCountInCollectedEventBuffer(), 10),
+			"aggregated_events", strconv.FormatInt(eventService.GetAggregatedEventCount(), 10),
+			"db_sharding_count", strconv.Itoa(service.dep.DB.GetShardingCount()),
+			"hash_tag_load_warm", strconv.FormatInt(atomic.LoadInt64(&hashTagLoadWarmTotal), 10),
+			"hash_tag_load_cold", strconv.FormatInt(atomic.LoadInt64(&hashTagLoadColdTotal), 10),
+		}
+	}
+	return []string{}
+}
+
+// resetConnection discards transaction and watched keys, clears client name
+// and unsubscribes all channels of the connection, the connection is not closed.
+func resetConnection(conn redcon.Conn) commands.RESPData {
+	transactionManager.removeTransaction(conn, commands.TransactionCloseReasonResetConn)
+	if c := connectionManager.getConnection(conn); c != nil {
+		c.reset()
+	}
+	if sub := subscriberManager.getSubscriber(conn); sub != nil {
+		sub.unsubscribeAll()
+	}
+	return commands.RESPData{DataType: commands.SimpleStringRespType, Value: "RESET"}
+}
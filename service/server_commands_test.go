@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResetConnection(t *testing.T) {
+	dep := base.GetServerDependency()
+	conn := &testConn{addr: "127.0.0.1:10001"}
+	c := newConnection(conn.addr, base.RateLimitConfig{})
+	connectionManager.addConnection(conn, c)
+	defer connectionManager.removeConnection(conn)
+	defer subscriberManager.removeSubscriber(conn)
+
+	c.setName("worker")
+	transactionManager.addTransaction(conn, commands.NewTransaction(dep))
+	command, err := commands.NewSubscribeCommand([]string{"subscribe", "{a}news"})
+	assert.Nil(t, err)
+	processSubscribeCommand(conn, command.(*commands.SubscribeCommand))
+	assert.True(t, subscriberManager.getSubscriber(conn).isSubscribing())
+
+	service := &RoomService{dep: dep}
+	command, err = commands.ParseCommand([]string{"reset"})
+	assert.Nil(t, err)
+	assert.True(t, isLocalCommand(command))
+	result := service.processLocalCommand(context.TODO(), conn, command)
+	assert.Equal(t, "s:RESET", result.String())
+
+	assert.Nil(t, transactionManager.getTransaction(conn))
+	assert.Equal(t, "", c.getName())
+	assert.False(t, subscriberManager.getSubscriber(conn).isSubscribing())
+	assert.Equal(t, 0, base.GetPubSubBroker().NumSub("{a}news"))
+	// connection is kept after reset.
+	assert.Equal(t, c, connectionManager.getConnection(conn))
+
+	// reset of a clean connection is a no-op.
+	result = resetConnection(conn)
+	assert.Equal(t, "s:RESET", result.String())
+}
+
+func TestProcessDebugSleepCommand(t *testing.T) {
+	command, err := commands.ParseCommand([]string{"debug", "sleep", "0.1"})
+	assert.Nil(t, err)
+	debugCommand := command.(*commands.DebugCommand)
+
+	service := &RoomService{config: &base.RoomServerConfig{EnableDebugCommands: false}}
+	result := service.processDebugCommand(debugCommand)
+	assert.Equal(t, commands.ConvertErrorToRESPData(errDebugCommandsDisabled), result)
+
+	service.config.EnableDebugCommands = true
+	startTime := time.Now()
+	result = service.processDebugCommand(debugCommand)
+	assert.Equal(t, "s:OK", result.String())
+	assert.True(t, time.Since(startTime) >= 100*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	result = debugCommand.Sleep(ctx)
+	assert.Equal(t, commands.ConvertErrorToRESPData(context.DeadlineExceeded), result)
+
+	// sleep is canceled when the server is stopped.
+	command, err = commands.ParseCommand([]string{"debug", "sleep", "10"})
+	assert.Nil(t, err)
+	service.stopCh = make(chan struct{})
+	time.AfterFunc(10*time.Millisecond, func() { close(service.stopCh) })
+	startTime = time.Now()
+	result = service.processDebugCommand(command.(*commands.DebugCommand))
+	assert.Equal(t, commands.ConvertErrorToRESPData(context.Canceled), result)
+	assert.True(t, time.Since(startTime) < time.Second)
+}
+
+func TestProcessDebugSetActiveExpireCommand(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{dep: dep, config: &base.RoomServerConfig{EnableDebugCommands: true}}
+	for _, value := range []string{"0", "1"} {
+		command, err := commands.ParseCommand([]string{"debug", "set-active-expire", value})
+		assert.Nil(t, err)
+		result := service.processDebugCommand(command.(*commands.DebugCommand))
+		assert.Equal(t, "s:OK", result.String())
+	}
+}
+
+func TestProcessDebugObjectCommand(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{dep: dep, config: &base.RoomServerConfig{EnableDebugCommands: true}}
+	hashTag := "debug_object"
+	key := "{debug_object}a"
+	defer testEmptyKeysInRedis(key)
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	testEmptyKeysInRedis(key)
+	testCleanLocalloadedCache(hashTag)
+
+	command, err := commands.ParseCommand([]string{"debug", "object", key})
+	assert.Nil(t, err)
+	result := service.processDebugCommand(command.(*commands.DebugCommand))
+	assert.Equal(t, commands.ConvertErrorToRESPData(errNoSuchKey), result)
+
+	// hash tag is not loaded, value is read from db.
+	testInsertRoomData(hashTag, map[string]RedisValue{key: {Type: stringType, Value: "100"}})
+	result = service.processDebugCommand(command.(*commands.DebugCommand))
+	assert.Equal(t, "s:Value at 0x0 refcount:1 encoding:int serializedlength:45 lru:0 type:string expire_ts:0", result.String())
+	status, _ := dep.Redis.Exists(contextTODO, getHashTagMetaKey(hashTag)).Result()
+	assert.Equal(t, int64(0), status)
+
+	// hash tag is loaded, value is read from redis.
+	accessTime := time.Now().Add(-10 * time.Second)
+	assert.Nil(t, Load(dep, hashTag, accessTime, base.HashTagAccessModeRead))
+	assert.Nil(t, dep.Redis.Set(contextTODO, key, "value", 0).Err())
+	result = service.processDebugCommand(command.(*commands.DebugCommand))
+	matched, _ := regexp.MatchString(
+		`^s:Value at 0x0 refcount:1 encoding:embstr serializedlength:\d+ lru:1\d type:string expire_ts:0$`,
+		result.String(),
+	)
+	assert.True(t, matched, result.String())
+}
+
+func TestProcessInfoCommand(t *testing.T) {
+	service := &RoomService{dep: base.GetServerDependency(), address: "127.0.0.1:6380", pid: 100, startTime: time.Now()}
+	info := func(args ...string) string {
+		command, err := commands.ParseCommand(append([]string{"info"}, args...))
+		assert.Nil(t, err)
+		assert.True(t, isLocalCommand(command))
+		result := service.processLocalCommand(context.TODO(), &testConn{}, command)
+		assert.Equal(t, commands.BulkStringRespType, result.DataType)
+		return result.Value.(string)
+	}
+
+	result := info()
+	for _, section := range []string{"# Server\r\n", "# Clients\r\n", "# Stats\r\n", "# Room\r\n"} {
+		assert.Contains(t, result, section)
+	}
+	assert.Contains(t, result, "process_id:100\r\ntcp_port:6380\r\n")
+	assert.Contains(t, result, "connected_clients:")
+	assert.Contains(t, result, "total_commands_processed:")
+	assert.Contains(t, result, "db_sharding_count:2\r\n")
+	assert.Equal(t, result, info("all"))
+	assert.Equal(t, result, info("Default"))
+
+	result = info("room", "CLIENTS")
+	assert.True(t, regexp.MustCompile(`^# Clients\r\nconnected_clients:\d+\r\n\r\n# Room\r\ntransactions:\d+\r\n`).MatchString(result), result)
+	assert.Contains(t, result, "hash_tag_load_warm:")
+	assert.Contains(t, result, "hash_tag_load_cold:")
+	assert.NotContains(t, result, "# Server")
+
+	// unknown sections are empty.
+	assert.Equal(t, "", info("not_exist"))
+	assert.Equal(t, info("stats"), info("stats", "not_exist"))
+}
@@ -5,6 +5,7 @@ import (
 	"bytepower_room/base"
 	"bytepower_room/base/log"
 	"bytepower_room/utility"
+	"compress/gzip"
 	"context"
 	"io/ioutil"
 	"net"
@@ -13,6 +14,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync/atomic"
 
@@ -39,6 +41,7 @@ const (
 	metricAggregatedEventMemoryUsage       = "aggregated_event_memory_usage.total"
 	metricEventFileCount                   = "event_file.total"
 	metricRequestBodyLength                = "request_body_length.total"
+	metricAggIntervalMS                    = "agg_interval_ms.total"
 )
 
 type CollectEventService struct {
@@ -54,7 +57,7 @@ type CollectEventService struct {
 	eventCountInCollectedEventBuffer int64
 
 	logger *log.Logger
-	metric *base.MetricClient
+	metric base.Metric
 	db     *base.DBCluster
 
 	wg     sync.WaitGroup
@@ -69,7 +72,7 @@ type CollectEventService struct {
 
 func NewCollectEventService(
 	config *base.RoomCollectEventConfig,
-	logger *log.Logger, metric *base.MetricClient,
+	logger *log.Logger, metric base.Metric,
 	db *base.DBCluster,
 ) (*CollectEventService, error) {
 
@@ -221,13 +224,17 @@ func (service *CollectEventService) aggregateEvent(event base.HashTagEvent) erro
 
 func (service *CollectEventService) collectAggregatedEvents() {
 	jobName := "collect aggregated events"
-	ticker := time.NewTicker(service.config.AggInterval)
+	interval := service.config.AggInterval
+	if service.config.AdaptiveAgg.Enabled {
+		interval = boundAggInterval(service.config.AdaptiveAgg, interval)
+	}
+	timer := time.NewTimer(interval)
 	defer func() {
 		service.logger.Info(
 			fmt.Sprintf("stop %s", jobName),
 			log.String("time", time.Now().String()),
 		)
-		ticker.Stop()
+		timer.Stop()
 		service.wg.Done()
 	}()
 	service.logger.Info(
@@ -236,18 +243,45 @@ func (service *CollectEventService) collectAggregatedEvents() {
 	)
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			events := service.collectEvents()
 			for _, event := range events {
 				service.collectedEventBuffer <- event
 				atomic.AddInt64(&service.eventCountInCollectedEventBuffer, 1)
 			}
+			if service.config.AdaptiveAgg.Enabled {
+				interval = adaptAggInterval(service.config.AdaptiveAgg, interval, int64(len(events)))
+			}
+			service.recordGaugeMetric(metricAggIntervalMS, interval.Milliseconds())
+			timer.Reset(interval)
 		case <-service.stopCh:
 			return
 		}
 	}
 }
 
+// adaptAggInterval returns the next interval of collecting aggregated events by count of events collected,
+// it is halved when count reaches high water mark and doubled when count is not greater than low water mark.
+func adaptAggInterval(config base.CollectEventAdaptiveAggConfig, interval time.Duration, eventCount int64) time.Duration {
+	if eventCount >= config.HighWaterMark {
+		interval /= 2
+	} else if eventCount <= config.LowWaterMark {
+		interval *= 2
+	}
+	return boundAggInterval(config, interval)
+}
+
+// boundAggInterval keeps interval within min and max intervals.
+func boundAggInterval(config base.CollectEventAdaptiveAggConfig, interval time.Duration) time.Duration {
+	if interval < config.MinInterval {
+		return config.MinInterval
+	}
+	if interval > config.MaxInterval {
+		return config.MaxInterval
+	}
+	return interval
+}
+
 func (service *CollectEventService) collectEvents() []base.HashTagEvent {
 	events := make([]base.HashTagEvent, 0)
 	service.mutex.Lock()
@@ -494,7 +528,7 @@ func (service *CollectEventService) saveEvent(event base.HashTagEvent) error {
 	defer cancel()
 	retryInterval := time.Duration(config.RetryIntervalMS) * time.Millisecond
 	for i := 0; i < config.RetryTimes; i++ {
-		err = upsertHashTagKeysRecordByEvent(ctx, service.db, event, time.Now())
+		err = upsertHashTagKeysRecordByEventWithDebounce(ctx, service.db, event, time.Now(), config.AccessedAtDebounce)
 		if err != nil {
 			if isRetryErrorForUpdateInTx(err) {
 				service.logger.Warn(
@@ -723,7 +757,37 @@ func (service *CollectEventService) recordSuccessWithCount(metricName string, co
 }
 
 type CollectEventsRequestBody struct {
-	Events []base.HashTagEvent `json:"events"`
+	Metadata *base.EventPayloadMetadata `json:"metadata"`
+	Events   []base.HashTagEvent        `json:"events"`
+}
+
+var errEventPayloadVersionMismatch = errors.New("event payload version mismatch")
+
+// getEventPayloadVersion returns version in header X-Room-Event-Version, version 1 is returned if it is absent.
+func getEventPayloadVersion(request *http.Request) (int, error) {
+	value := request.Header.Get(base.HTTPHeaderEventPayloadVersion)
+	if value == "" {
+		return base.EventPayloadVersion1, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || (version != base.EventPayloadVersion1 && version != base.EventPayloadVersion2) {
+		return 0, fmt.Errorf("%w, version %s is not supported", errEventPayloadVersionMismatch, value)
+	}
+	return version, nil
+}
+
+// checkEventPayloadVersion checks metadata of body is consistent with version in header.
+func checkEventPayloadVersion(version int, body CollectEventsRequestBody) error {
+	if version == base.EventPayloadVersion1 {
+		if body.Metadata != nil {
+			return fmt.Errorf("%w, metadata is not expected in version %d", errEventPayloadVersionMismatch, version)
+		}
+		return nil
+	}
+	if body.Metadata == nil || body.Metadata.Version != version {
+		return fmt.Errorf("%w, metadata version does not match version %d", errEventPayloadVersionMismatch, version)
+	}
+	return nil
 }
 
 func (service *CollectEventService) postEventsHandler(writer http.ResponseWriter, request *http.Request) {
@@ -736,8 +800,23 @@ func (service *CollectEventService) postEventsHandler(writer http.ResponseWriter
 		}
 		return
 	}
-	body, err := ioutil.ReadAll(request.Body)
+	version, err := getEventPayloadVersion(request)
+	if err != nil {
+		service.recordError("payload_version", err, nil)
+		if err = writeErrorResponse(writer, base.HTTPStatusEventPayloadVersionMismatch, err); err != nil {
+			service.recordWriteResponseError(err, []byte{})
+		}
+		return
+	}
+	body, err := readRequestBody(request)
 	if err != nil {
+		if errors.Is(err, errContentEncodingNotSupported) {
+			service.recordError("content_encoding", err, nil)
+			if err = writeErrorResponse(writer, http.StatusUnsupportedMediaType, err); err != nil {
+				service.recordWriteResponseError(err, []byte{})
+			}
+			return
+		}
 		service.recordError("read_body", err, nil)
 		if err = writeErrorResponse(writer, http.StatusInternalServerError, err); err != nil {
 			service.recordWriteResponseError(err, []byte{})
@@ -753,6 +832,13 @@ func (service *CollectEventService) postEventsHandler(writer http.ResponseWriter
 		}
 		return
 	}
+	if err = checkEventPayloadVersion(version, requestBodyStruct); err != nil {
+		service.recordError("payload_version", err, nil)
+		if err = writeErrorResponse(writer, base.HTTPStatusEventPayloadVersionMismatch, err); err != nil {
+			service.recordWriteResponseError(err, body)
+		}
+		return
+	}
 	events := requestBodyStruct.Events
 	for _, event := range events {
 		if err = event.Check(); err != nil {
@@ -779,6 +865,25 @@ func (service *CollectEventService) postEventsHandler(writer http.ResponseWriter
 	service.recordSuccessWithCount("add_event.events", len(events))
 }
 
+var errContentEncodingNotSupported = errors.New("content encoding is not supported")
+
+// readRequestBody decompresses body if Content-Encoding header is gzip.
+func readRequestBody(request *http.Request) ([]byte, error) {
+	switch encoding := request.Header.Get(base.HTTPHeaderContentEncoding); encoding {
+	case "", "identity":
+		return ioutil.ReadAll(request.Body)
+	case base.CompressionAlgorithmGzip:
+		reader, err := gzip.NewReader(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	default:
+		return nil, fmt.Errorf("%w, content_encoding=%s", errContentEncodingNotSupported, encoding)
+	}
+}
+
 func writeErrorResponse(writer http.ResponseWriter, code int, err error) error {
 	writer.Header().Set(HTTPHeaderContentType, HTTPContentTypeJSON)
 	writer.WriteHeader(code)
@@ -820,7 +925,7 @@ type EventFile struct {
 	maxAge    time.Duration
 
 	logger *log.Logger
-	metric *base.MetricClient
+	metric base.Metric
 
 	mutex sync.Mutex
 
@@ -828,7 +933,7 @@ type EventFile struct {
 }
 
 func NewEventFile(
-	logger *log.Logger, metric *base.MetricClient,
+	logger *log.Logger, metric base.Metric,
 	directory string, maxEventCount int, maxAge time.Duration,
 ) (*EventFile, error) {
 
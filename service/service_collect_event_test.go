@@ -0,0 +1,80 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptAggInterval(t *testing.T) {
+	config := base.CollectEventAdaptiveAggConfig{
+		Enabled:       true,
+		MinInterval:   time.Minute,
+		MaxInterval:   8 * time.Minute,
+		HighWaterMark: 100,
+		LowWaterMark:  10,
+	}
+	testCases := []struct {
+		interval   time.Duration
+		eventCount int64
+		expected   time.Duration
+	}{
+		{4 * time.Minute, 100, 2 * time.Minute},
+		{4 * time.Minute, 1000, 2 * time.Minute},
+		{time.Minute, 1000, time.Minute},
+		{4 * time.Minute, 50, 4 * time.Minute},
+		{4 * time.Minute, 10, 8 * time.Minute},
+		{8 * time.Minute, 0, 8 * time.Minute},
+		{20 * time.Minute, 50, 8 * time.Minute},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, adaptAggInterval(config, testCase.interval, testCase.eventCount), testCase)
+	}
+	assert.Equal(t, time.Minute, boundAggInterval(config, time.Second))
+	assert.Equal(t, 8*time.Minute, boundAggInterval(config, time.Hour))
+	assert.Equal(t, 2*time.Minute, boundAggInterval(config, 2*time.Minute))
+}
+
+func TestEventPayloadVersion(t *testing.T) {
+	testCases := []struct {
+		header  string
+		version int
+		valid   bool
+	}{
+		{"", base.EventPayloadVersion1, true},
+		{"1", base.EventPayloadVersion1, true},
+		{"2", base.EventPayloadVersion2, true},
+		{"3", 0, false},
+		{"v2", 0, false},
+	}
+	for _, testCase := range testCases {
+		request := httptest.NewRequest(http.MethodPost, "/events", nil)
+		if testCase.header != "" {
+			request.Header.Set(base.HTTPHeaderEventPayloadVersion, testCase.header)
+		}
+		version, err := getEventPayloadVersion(request)
+		if testCase.valid {
+			assert.Nil(t, err, testCase.header)
+			assert.Equal(t, testCase.version, version, testCase.header)
+		} else {
+			assert.True(t, errors.Is(err, errEventPayloadVersionMismatch), testCase.header)
+		}
+	}
+
+	events := []base.HashTagEvent{}
+	v1 := base.NewEventPayload(base.EventPayloadVersion1, events, time.Now())
+	v2 := base.NewEventPayload(base.EventPayloadVersion2, events, time.Now())
+	assert.Nil(t, checkEventPayloadVersion(base.EventPayloadVersion1, CollectEventsRequestBody{Metadata: v1.Metadata}))
+	assert.Nil(t, checkEventPayloadVersion(base.EventPayloadVersion2, CollectEventsRequestBody{Metadata: v2.Metadata}))
+	assert.True(t, errors.Is(
+		checkEventPayloadVersion(base.EventPayloadVersion1, CollectEventsRequestBody{Metadata: v2.Metadata}),
+		errEventPayloadVersionMismatch))
+	assert.True(t, errors.Is(
+		checkEventPayloadVersion(base.EventPayloadVersion2, CollectEventsRequestBody{Metadata: v1.Metadata}),
+		errEventPayloadVersionMismatch))
+}
@@ -8,12 +8,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/gogf/greuse"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/tidwall/redcon"
@@ -23,6 +26,9 @@ var contextTODO = context.TODO()
 
 var connectionTotal int64
 
+// commandTotal is count of commands received since the server is started.
+var commandTotal int64
+
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 func newInternalError(err error) error {
@@ -33,20 +39,48 @@ func newLoadError(err error) error {
 	return fmt.Errorf("ERR load data error, %w", err)
 }
 
+// newTryAgainError is returned for errors of a degraded db shard, clients could retry the command later.
+func newTryAgainError(err error) error {
+	return fmt.Errorf("TRYAGAIN %w", err)
+}
+
 func newInvalidKeyError(key string) error {
 	return fmt.Errorf("ERR key %s is not valid", key)
 }
 
 var errInvalidResponse = errors.New("ERR invalid command response")
 
+var errRateLimitExceeded = errors.New("ERR rate limit exceeded")
+
+var errCommandNotAllowed = errors.New("ERR command is not allowed")
+
+var errCommandTimeout = errors.New("ERR command timed out")
+
+// errKeysCrossSlot is returned like redis cluster if keys of a command processed by redis are in different slots,
+// keys of one hash tag could be in different slots with prefix hash tag scheme.
+var errKeysCrossSlot = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+func newLocalCommandInTransactionError(name string) error {
+	return fmt.Errorf("ERR command %s is not allowed in transaction", name)
+}
+
+func newValueSizeExceedsLimitError(limit int) error {
+	return fmt.Errorf("ERR value size exceeds limit of %d bytes", limit)
+}
+
 type RoomService struct {
 	config       *base.RoomServerConfig
 	dep          base.Dependency
 	address      string
-	server       *redcon.Server
+	servers      []*redcon.Server
 	pprofAddress string
 	pprofServer  *http.Server
 	pid          int
+	stopCh       chan struct{}
+	stopOnce     sync.Once
+	startTime    time.Time
 }
 
 func NewRoomService(config *base.RoomServerConfig, dep base.Dependency, host string, port int) (*RoomService, error) {
@@ -62,31 +96,54 @@ func NewRoomService(config *base.RoomServerConfig, dep base.Dependency, host str
 	if port <= 0 {
 		return nil, errors.New("port should be greater than 0")
 	}
+	hashTagExtractor, err := commands.NewHashTagExtractor(config.HashTag)
+	if err != nil {
+		return nil, err
+	}
+	commands.SetHashTagExtractor(hashTagExtractor)
 
 	roomService := &RoomService{
 		config:       config,
 		dep:          dep,
 		address:      fmt.Sprintf("%s:%d", host, port),
 		pprofAddress: fmt.Sprintf("%s:%d", host, port+10000),
-		pid:          os.Getpid()}
+		pid:          os.Getpid(),
+		stopCh:       make(chan struct{})}
 	return roomService, nil
 }
 
 func (service *RoomService) Run() {
 	service.logWithAddressAndPid(log.LevelInfo, "server.start")
-	service.server = redcon.NewServer(service.address, service.connServeHandler, service.connAcceptHandler, service.connCloseHandler)
-	service.server.AcceptError = service.connAcceptErrorHandler
-	listener, err := greuse.Listen("tcp", service.address)
+	service.startTime = time.Now()
+	listenerConfig := service.config.Listener
+	listeners, err := listenWithReusePort(service.address, listenerConfig.GetReusePortCount(), listenerConfig.Backlog)
 	if err != nil {
 		service.logWithAddressAndPid(log.LevelError, "error.server.listen", log.Error(err))
 		panic(err)
 	}
-	go func() {
-		if err := service.server.Serve(listener); err != nil {
-			service.logWithAddressAndPid(log.LevelError, "error.server.serve", log.Error(err))
-			panic(err)
-		}
-	}()
+	// every listener is served by its own server, connections of all servers are handled in the same way.
+	for _, listener := range listeners {
+		server := redcon.NewServer(service.address, service.connServeHandler, service.connAcceptHandler, service.connCloseHandler)
+		server.AcceptError = service.connAcceptErrorHandler
+		service.servers = append(service.servers, server)
+		go func(listener net.Listener) {
+			if err := server.Serve(listener); err != nil {
+				service.logWithAddressAndPid(log.LevelError, "error.server.serve", log.Error(err))
+				panic(err)
+			}
+		}(listener)
+	}
+	if listenerConfig.BacklogDropCheckInterval > 0 {
+		go service.monitorBacklogDrop(listenerConfig.BacklogDropCheckInterval)
+	}
+
+	if service.config.TransactionTimeout > 0 {
+		go transactionManager.runTimeoutReaper(service.dep, service.config.TransactionTimeout, service.stopCh)
+	}
+
+	if service.config.WarmUp.Enabled {
+		go service.warmUp()
+	}
 
 	// start pprof server
 	if service.config.EnablePProf {
@@ -106,10 +163,19 @@ func (service *RoomService) Run() {
 	}
 }
 
+// Stop stops servers of the service, it could be called more than once.
 func (service *RoomService) Stop() {
-	if err := service.server.Close(); err != nil {
-		service.logWithAddressAndPid(log.LevelError, "error.server.close", log.Error(err))
+	service.stopOnce.Do(service.stop)
+}
+
+func (service *RoomService) stop() {
+	close(service.stopCh)
+	for _, server := range service.servers {
+		if err := server.Close(); err != nil {
+			service.logWithAddressAndPid(log.LevelError, "error.server.close", log.Error(err))
+		}
 	}
+	subscriberManager.closeDetached()
 	if service.pprofServer != nil {
 		if err := service.pprofServer.Close(); err != nil {
 			service.logWithAddressAndPid(log.LevelError, "error.server.pprof_close", log.Error(err))
@@ -119,6 +185,7 @@ func (service *RoomService) Stop() {
 
 func (service *RoomService) connAcceptHandler(conn redcon.Conn) bool {
 	service.dep.Metric.MetricIncrease("connection.accept")
+	connectionManager.addConnection(conn, newConnection(conn.RemoteAddr(), service.config.RateLimit))
 	connectionCount := atomic.AddInt64(&connectionTotal, 1)
 	service.dep.Metric.MetricGauge("connection.total", connectionCount)
 	service.dep.Metric.MetricGauge("transaction.total", transactionManager.transactionCount())
@@ -140,19 +207,64 @@ func (service *RoomService) connAcceptErrorHandler(err error) {
 func (service *RoomService) connServeHandler(conn redcon.Conn, cmds []redcon.Command) {
 	serveStartTime := time.Now()
 
-	redisCluster := service.dep.Redis
 	metric := service.dep.Metric
 
 	cmdCount := len(cmds)
 	toBeExecutedCommandBatch := commands.NewCommandBatch()
 	allCommands := make([]commands.Commander, 0, cmdCount)
+	// commandIndexes are indexes of allCommands in results.
+	commandIndexes := make([]int, 0, cmdCount)
 	results := make([]commands.RESPData, cmdCount)
+	appendIndexes := make([]int, 0)
+	// batchDeadline is the earliest deadline of commands in toBeExecutedCommandBatch, zero means no deadline.
+	var batchDeadline time.Time
+	executeBatch := func() {
+		resultMap := service.executeCommandBatch(toBeExecutedCommandBatch, batchDeadline)
+		for index, result := range resultMap {
+			results[index] = result
+		}
+		toBeExecutedCommandBatch = commands.NewCommandBatch()
+		batchDeadline = time.Time{}
+	}
 
 	metric.MetricCount("receive.command", cmdCount)
+	atomic.AddInt64(&commandTotal, int64(cmdCount))
 	metric.MetricGauge("command.batch.total", cmdCount)
 
+	connection := connectionManager.getConnection(conn)
+	sub := subscriberManager.getSubscriber(conn)
 	for index, cmd := range cmds {
-		command, err := service.preProcessCommand(cmd, serveStartTime)
+		if connection != nil && len(cmd.Args) > 0 && !connection.allowCommand(strings.ToLower(string(cmd.Args[0]))) {
+			metric.MetricIncrease("command.rate_limited")
+			results[index] = commands.ConvertErrorToRESPData(errRateLimitExceeded)
+			// a rejected command aborts the transaction in multi, so that exec does not commit part of it.
+			transaction := transactionManager.getTransaction(conn)
+			if transaction != nil && transaction.IsStarted() {
+				transaction.Abort()
+			}
+			continue
+		}
+		if connection != nil && len(cmd.Args) > 0 {
+			connection.setLastCommand(strings.ToLower(string(cmd.Args[0])))
+		}
+		// reset is processed before subscribe mode and transaction checks since it cleans up both of them.
+		if len(cmd.Args) > 0 && strings.ToLower(string(cmd.Args[0])) == "reset" {
+			executeBatch()
+			results[index] = service.processResetCommand(conn, cmd, service.commandDeadline(conn, serveStartTime))
+			sub = subscriberManager.getSubscriber(conn)
+			continue
+		}
+		if sub != nil && sub.isSubscribing() && len(cmd.Args) > 0 {
+			args := make([]string, 0, len(cmd.Args))
+			for _, arg := range cmd.Args {
+				args = append(args, string(arg))
+			}
+			if result, ok := processCommandInSubscribeMode(strings.ToLower(args[0]), args); ok {
+				results[index] = result
+				continue
+			}
+		}
+		command, err := service.preProcessCommandWithTimeout(conn, cmd, serveStartTime)
 		if err != nil {
 			metric.MetricIncrease("error.pre_process")
 			service.logWithAddressAndPid(
@@ -160,14 +272,20 @@ func (service *RoomService) connServeHandler(conn redcon.Conn, cmds []redcon.Com
 				log.String("command", string(cmd.Raw)),
 				log.Error(err),
 			)
+			// the error is only the reply of this command, other commands in the pipeline are still processed,
+			// a transaction in multi is aborted like redis and exec returns EXECABORT error.
 			results[index] = commands.ConvertErrorToRESPData(err)
 			transaction := transactionManager.getTransaction(conn)
-			if transaction != nil {
+			if transaction != nil && transaction.IsStarted() {
 				metric.MetricIncrease("error.in_transaction")
-				transactionManager.removeTransaction(conn, commands.TransactionCloseReasonInvalidCommand)
+				transaction.Abort()
 			}
 			continue
 		}
+		// fields of hash are counted after keys are loaded, hgetall queued in transaction is executed by redis.
+		if transaction := transactionManager.getTransaction(conn); transaction == nil || !transaction.IsStarted() {
+			command = service.hGetAllCommandByFields(command)
+		}
 		service.logWithAddressAndPid(
 			log.LevelDebug,
 			"receive.command",
@@ -175,13 +293,27 @@ func (service *RoomService) connServeHandler(conn redcon.Conn, cmds []redcon.Com
 		)
 
 		allCommands = append(allCommands, command)
+		commandIndexes = append(commandIndexes, index)
+		if _, ok := command.(*commands.AppendCommand); ok {
+			appendIndexes = append(appendIndexes, index)
+		}
+		if isLocalCommand(command) {
+			// local commands can not be queued in redis transactions, the transaction in multi is aborted like
+			// commands which fail to be queued, instead of running the command before exec.
+			if transaction := transactionManager.getTransaction(conn); transaction != nil && transaction.IsStarted() {
+				metric.MetricIncrease("error.in_transaction")
+				results[index] = commands.ConvertErrorToRESPData(newLocalCommandInTransactionError(command.Name()))
+				transaction.Abort()
+				continue
+			}
+			executeBatch()
+			results[index] = service.processLocalCommandWithDeadline(conn, command, service.commandDeadline(conn, serveStartTime))
+			sub = subscriberManager.getSubscriber(conn)
+			continue
+		}
 		transaction := getTransactionIfNeeded(service.dep, conn, command)
 		if transaction != nil && (transaction.IsStarted() || isTransactionCommand(command)) {
-			resultMap := toBeExecutedCommandBatch.Execute(context.TODO(), redisCluster)
-			for index, result := range resultMap {
-				results[index] = result
-			}
-			toBeExecutedCommandBatch = commands.NewCommandBatch()
+			executeBatch()
 			startTime := time.Now()
 			results[index] = transaction.Process(command)
 			if transaction.IsClosed() {
@@ -189,22 +321,110 @@ func (service *RoomService) connServeHandler(conn redcon.Conn, cmds []redcon.Com
 				metric.MetricIncrease(fmt.Sprintf("process.transaction.by_%s", command.Name()))
 				metric.MetricTimeDuration(fmt.Sprintf("process.transaction.by_%s.duration", command.Name()), time.Since(startTime))
 			}
+		} else if cmds, ok := splitIfMultipleHashTags(command); ok {
+			executeBatch()
+			results[index] = service.executeMultipleHashTagsCommands(cmds, service.commandDeadline(conn, serveStartTime))
 		} else {
+			if deadline := service.commandDeadline(conn, serveStartTime); !deadline.IsZero() &&
+				(batchDeadline.IsZero() || deadline.Before(batchDeadline)) {
+				batchDeadline = deadline
+			}
 			toBeExecutedCommandBatch.AddCommand(index, command)
 		}
 	}
-	resultMap := toBeExecutedCommandBatch.Execute(context.TODO(), redisCluster)
-	for index, result := range resultMap {
-		results[index] = result
-	}
+	executeBatch()
 	for _, result := range results {
 		writeDataToConnection(conn, result)
 	}
-	service.sendEvents(allCommands, serveStartTime)
+	eventCommands := make([]commands.Commander, 0, len(allCommands))
+	for i, command := range allCommands {
+		eventCommands = append(eventCommands, commands.CommandForEvent(command, results[commandIndexes[i]]))
+	}
+	service.sendEvents(eventCommands, serveStartTime)
 	service.recordCommands(allCommands, results, serveStartTime)
+	service.recordAppendLengths(results, appendIndexes)
+	service.detachSubscriberIfNeeded(conn)
 }
 
-func (service *RoomService) preProcessCommand(cmd redcon.Command, serveStartTime time.Time) (commands.Commander, error) {
+// commandTimeout returns timeout of commands of conn, commands in transactions have a larger timeout.
+func (service *RoomService) commandTimeout(conn redcon.Conn) time.Duration {
+	if transaction := transactionManager.getTransaction(conn); transaction != nil && transaction.IsStarted() {
+		return service.config.TransactionCommandTimeout
+	}
+	return service.config.CommandTimeout
+}
+
+// commandDeadline returns deadline of commands of conn served since serveStartTime, zero means no deadline.
+func (service *RoomService) commandDeadline(conn redcon.Conn, serveStartTime time.Time) time.Time {
+	timeout := service.commandTimeout(conn)
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return serveStartTime.Add(timeout)
+}
+
+// contextWithDeadline returns a context canceled at deadline, zero deadline means no deadline.
+func contextWithDeadline(deadline time.Time) (context.Context, context.CancelFunc) {
+	if deadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), deadline)
+}
+
+// preProcessCommandWithTimeout pre-processes cmd before deadline of conn, the rest of the deadline
+// bounds execution of the command, in a batch of pipelined commands or by room for local commands.
+func (service *RoomService) preProcessCommandWithTimeout(
+	conn redcon.Conn, cmd redcon.Command, serveStartTime time.Time) (commands.Commander, error) {
+
+	ctx, cancel := contextWithDeadline(service.commandDeadline(conn, serveStartTime))
+	defer cancel()
+	return service.preProcessCommand(ctx, cmd, serveStartTime)
+}
+
+// executeCommandBatch executes batch in redis before deadline, zero deadline means no deadline,
+// commands of the batch which fail after the deadline is exceeded reply errCommandTimeout.
+func (service *RoomService) executeCommandBatch(batch commands.CommandBatch, deadline time.Time) map[int]commands.RESPData {
+	ctx, cancel := contextWithDeadline(deadline)
+	defer cancel()
+	resultMap := batch.Execute(ctx, service.dep.Redis)
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return resultMap
+	}
+	timeoutCount := 0
+	for index, result := range resultMap {
+		if result.DataType == commands.ErrorRespType {
+			resultMap[index] = commands.ConvertErrorToRESPData(errCommandTimeout)
+			timeoutCount++
+		}
+	}
+	if timeoutCount > 0 {
+		service.dep.Metric.MetricCount("command.timeout", timeoutCount)
+	}
+	return resultMap
+}
+
+// processLocalCommandWithDeadline processes local command before deadline, zero deadline means no deadline,
+// errCommandTimeout is returned if the command fails after the deadline is exceeded.
+func (service *RoomService) processLocalCommandWithDeadline(
+	conn redcon.Conn, command commands.Commander, deadline time.Time) commands.RESPData {
+
+	ctx, cancel := contextWithDeadline(deadline)
+	defer cancel()
+	result := service.processLocalCommand(ctx, conn, command)
+	if result.DataType == commands.ErrorRespType && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		service.dep.Metric.MetricIncrease("command.timeout")
+		service.logWithAddressAndPid(
+			log.LevelError, "command.timeout",
+			log.String("command", command.String()),
+			log.String("error", result.String()),
+		)
+		return commands.ConvertErrorToRESPData(errCommandTimeout)
+	}
+	return result
+}
+
+func (service *RoomService) preProcessCommand(
+	ctx context.Context, cmd redcon.Command, serveStartTime time.Time) (commands.Commander, error) {
 	args := make([]string, 0, len(cmd.Args))
 	for _, arg := range cmd.Args {
 		args = append(args, string(arg))
@@ -216,13 +436,51 @@ func (service *RoomService) preProcessCommand(cmd redcon.Command, serveStartTime
 		return nil, err
 	}
 
+	// Denied commands are rejected before keys are loaded and they are not recorded for events.
+	if !service.config.CommandFilter.IsAllowed(command.Name()) {
+		service.dep.Metric.MetricIncrease("command.denied")
+		return nil, errCommandNotAllowed
+	}
+
+	// Oversized values are rejected before keys are loaded, so they are never written to redis or db.
+	if err = service.checkValueSize(command); err != nil {
+		return nil, err
+	}
+
 	// Pre Porcess related keys
-	if err = preProcessCommand(service.dep, command, serveStartTime); err != nil {
+	if err = preProcessCommandWithContext(ctx, service.dep, command, serveStartTime); err != nil {
 		return nil, err
 	}
 	return command, nil
 }
 
+// checkValueSize returns an error if values written by command exceed the max value size of its key type.
+func (service *RoomService) checkValueSize(command commands.Commander) error {
+	c, ok := command.(commands.ValueWriterCommander)
+	if !ok {
+		return nil
+	}
+	valueType, size := c.WrittenValueSize()
+	limit := service.config.MaxValueSize(valueType)
+	if limit > 0 && size > limit {
+		service.dep.Metric.MetricIncrease("write.oversized")
+		return newValueSizeExceedsLimitError(limit)
+	}
+	return nil
+}
+
+func (service *RoomService) processResetCommand(conn redcon.Conn, cmd redcon.Command, deadline time.Time) commands.RESPData {
+	args := make([]string, 0, len(cmd.Args))
+	for _, arg := range cmd.Args {
+		args = append(args, string(arg))
+	}
+	command, err := commands.ParseCommand(args)
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return service.processLocalCommandWithDeadline(conn, command, deadline)
+}
+
 func (service *RoomService) sendEvents(cmds []commands.Commander, serveStartTime time.Time) {
 	startTime := time.Now()
 	metric := service.dep.Metric
@@ -260,8 +518,129 @@ func (service *RoomService) recordCommands(cmds []commands.Commander, results []
 	service.dep.Metric.MetricTimeDuration("process.commands.duration", duration)
 }
 
+// recordAppendLengths records lengths of strings after append commands at indexes of results,
+// so that strings growing without limit by appends could be detected.
+func (service *RoomService) recordAppendLengths(results []commands.RESPData, indexes []int) {
+	for _, index := range indexes {
+		result := results[index]
+		if length, ok := result.Value.(int64); ok && result.DataType == commands.IntegerRespType {
+			service.dep.Metric.MetricGauge("command.append.length", length)
+		}
+	}
+}
+
+// splitIfMultipleHashTags returns commands of each hash tag if keys of command have different hash tags.
+func splitIfMultipleHashTags(command commands.Commander) ([]commands.Commander, bool) {
+	cmds, err := commands.SplitCommandByHashTag(command)
+	if err != nil || len(cmds) <= 1 {
+		return nil, false
+	}
+	return cmds, true
+}
+
+// executeMultipleHashTagsCommands executes commands split from a command in a batch before deadline
+// and sums up their results.
+func (service *RoomService) executeMultipleHashTagsCommands(cmds []commands.Commander, deadline time.Time) commands.RESPData {
+	batch := commands.NewCommandBatch()
+	for index, cmd := range cmds {
+		batch.AddCommand(index, cmd)
+	}
+	resultMap := service.executeCommandBatch(batch, deadline)
+	total := int64(0)
+	for index := range cmds {
+		result := resultMap[index]
+		if result.DataType == commands.ErrorRespType {
+			return result
+		}
+		count, ok := result.Value.(int64)
+		if !ok {
+			return commands.ConvertErrorToRESPData(errInvalidResponse)
+		}
+		total += count
+	}
+	return commands.RESPData{DataType: commands.IntegerRespType, Value: total}
+}
+
+// local commands are processed by room server instead of redis.
+// Commands which room processes for keys of different hash tags are processed by redis if their keys are in one slot.
+func isLocalCommand(command commands.Commander) bool {
+	switch command.(type) {
+	case *commands.CopyCommand, *commands.ZRangeStoreCommand, *commands.ZSetAggregateCommand, *commands.SetAggregateCommand:
+		return !isKeysInOneHashTagAndSlot(command)
+	case *commands.RoomEvictCommand, *commands.RoomResetStatCommand, *commands.ClientCommand,
+		*commands.SubscribeCommand, *commands.UnsubscribeCommand, *commands.PublishCommand, *commands.PubSubCommand,
+		*commands.EvalCommand, *commands.EvalShaCommand, *commands.ScriptCommand,
+		*commands.WaitCommand, *commands.ResetCommand, *commands.KeysCommand,
+		*commands.ObjectCommand, *commands.DumpCommand, *commands.RestoreCommand,
+		*commands.DebugCommand, *commands.InfoCommand, *hGetAllByScanCommand:
+		return true
+	}
+	return false
+}
+
+// isKeysInOneHashTagAndSlot returns true if keys of command have one hash tag and are in one slot of redis cluster,
+// keys of one hash tag could be in different slots with prefix hash tag scheme.
+func isKeysInOneHashTagAndSlot(command commands.Commander) bool {
+	if _, err := commands.CheckAndGetCommandKeysHashTag(command); err != nil {
+		return false
+	}
+	return redis.AreKeysInSameSlot(append(append([]string{}, command.ReadKeys()...), command.WriteKeys()...)...)
+}
+
+func (service *RoomService) processLocalCommand(ctx context.Context, conn redcon.Conn, command commands.Commander) commands.RESPData {
+	switch c := command.(type) {
+	case *commands.RoomEvictCommand:
+		return service.processRoomEvictCommand(c)
+	case *commands.RoomResetStatCommand:
+		return service.processRoomResetStatCommand(c)
+	case *commands.ClientCommand:
+		return processClientCommand(conn, c)
+	case *commands.SubscribeCommand:
+		return processSubscribeCommand(conn, c)
+	case *commands.UnsubscribeCommand:
+		return processUnsubscribeCommand(conn, c)
+	case *commands.PublishCommand:
+		return processPublishCommand(c)
+	case *commands.PubSubCommand:
+		return processPubSubCommand(c)
+	case *commands.EvalCommand:
+		return service.processEvalCommand(ctx, c)
+	case *commands.EvalShaCommand:
+		return service.processEvalShaCommand(ctx, c)
+	case *commands.ScriptCommand:
+		return processScriptCommand(c)
+	case *commands.WaitCommand:
+		return service.processWaitCommand(c)
+	case *commands.DebugCommand:
+		return service.processDebugCommand(c)
+	case *commands.InfoCommand:
+		return service.processInfoCommand(c)
+	case *hGetAllByScanCommand:
+		return service.processHGetAllByScanCommand(ctx, c)
+	case *commands.ResetCommand:
+		return resetConnection(conn)
+	case *commands.KeysCommand:
+		return service.processKeysCommand(c)
+	case *commands.ObjectCommand:
+		return service.processObjectCommand(ctx, c)
+	case *commands.DumpCommand:
+		return service.processDumpCommand(ctx, c)
+	case *commands.RestoreCommand:
+		return service.processRestoreCommand(ctx, c)
+	case *commands.CopyCommand:
+		return service.processCopyCommand(ctx, c)
+	case *commands.ZRangeStoreCommand:
+		return service.processZRangeStoreCommand(ctx, c)
+	case *commands.ZSetAggregateCommand:
+		return service.processZSetAggregateCommand(ctx, c)
+	case *commands.SetAggregateCommand:
+		return service.processSetAggregateCommand(ctx, c)
+	}
+	return commands.ConvertErrorToRESPData(fmt.Errorf("ERR unknown local command %s", command.Name()))
+}
+
 func isTransactionNeeded(command commands.Commander) bool {
-	transactionCommands := []string{"watch", "multi"}
+	transactionCommands := []string{"watch", "multi", "discard"}
 	return utility.StringSliceContains(transactionCommands, command.Name())
 }
 
@@ -271,21 +650,97 @@ func isTransactionCommand(command commands.Commander) bool {
 }
 
 func preProcessCommand(dep base.Dependency, command commands.Commander, accessTime time.Time) error {
+	return preProcessCommandWithContext(context.Background(), dep, command, accessTime)
+}
+
+// preProcessCommandWithContext loads keys of command, loading is canceled and errCommandTimeout is returned
+// if the deadline of ctx is exceeded.
+func preProcessCommandWithContext(ctx context.Context, dep base.Dependency, command commands.Commander, accessTime time.Time) error {
 	logger := dep.Logger
 
-	hashTag, err := commands.CheckAndGetCommandKeysHashTag(command)
+	cmds, err := commands.SplitCommandByHashTag(command)
 	if err != nil {
 		return err
 	}
-	if err := Load(dep, hashTag, accessTime, commands.GetCommnadKeysAccessMode(command)); err != nil {
+	hashTags := make([]string, 0, len(cmds))
+	accessModes := make(map[string]base.HashTagAccessMode, len(cmds))
+	for _, cmd := range cmds {
+		hashTag, err := commands.CheckAndGetCommandKeysHashTag(cmd)
+		if err != nil {
+			return err
+		}
+		if !isLocalCommand(command) && !redis.AreKeysInSameSlot(append(append([]string{}, cmd.ReadKeys()...), cmd.WriteKeys()...)...) {
+			return errKeysCrossSlot
+		}
+		accessMode := commands.GetCommnadKeysAccessMode(cmd)
+		if mode, ok := accessModes[hashTag]; ok {
+			if mode != base.HashTagAccessModeWrite {
+				accessModes[hashTag] = accessMode
+			}
+			continue
+		}
+		hashTags = append(hashTags, hashTag)
+		accessModes[hashTag] = accessMode
+	}
+	models, err := LoadMultiple(ctx, dep, hashTags, accessModes, accessTime)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			dep.Metric.MetricIncrease("command.timeout")
+			logger.Error("command timeout", log.String("command", command.String()), log.Error(err))
+			return errCommandTimeout
+		}
+		var loadErr *HashTagLoadError
+		hashTag := ""
+		if errors.As(err, &loadErr) {
+			hashTag = loadErr.HashTag
+			err = loadErr.Err
+		}
 		logger.Error(
 			"load hash_tag error",
 			log.String("command", command.String()),
 			log.String("hash_tag", hashTag),
 			log.Error(err),
 		)
+		var shardErr *base.ShardUnavailableError
+		if errors.As(err, &shardErr) {
+			dep.Metric.MetricIncrease(fmt.Sprintf("error.shard_unavailable.%d", shardErr.TableIndex))
+			return newTryAgainError(err)
+		}
 		return newLoadError(err)
 	}
+	return checkValueType(dep, command, models, accessTime)
+}
+
+// checkValueType returns WRONGTYPE error if a key of command holds a value of another type than the command expects,
+// it is checked before the command runs, keys which do not exist are left to the command.
+// Values are checked with models just loaded from db, keys loaded before are checked in redis for local commands,
+// other commands are checked by redis itself.
+func checkValueType(dep base.Dependency, command commands.Commander, models map[string]*roomDataModelV2, accessTime time.Time) error {
+	valueType := commands.GetCommandValueType(command)
+	if valueType == "" {
+		return nil
+	}
+	keys := append(append([]string{}, command.WriteKeys()...), command.ReadKeys()...)
+	for _, key := range keys {
+		model := models[commands.ExtractHashTagFromKey(key)]
+		if model != nil {
+			value, ok := model.Value[key]
+			if ok && !value.IsExpired(accessTime) && value.Type != valueType {
+				return errWrongType
+			}
+			continue
+		}
+		if !isLocalCommand(command) {
+			continue
+		}
+		keyType, err := dep.Redis.Type(contextTODO, key).Result()
+		if err != nil {
+			return newInternalError(err)
+		}
+		if keyType != redisKeyNotExist && keyType != valueType {
+			return errWrongType
+		}
+	}
 	return nil
 }
 
@@ -296,6 +751,8 @@ func getTransactionIfNeeded(dep base.Dependency, conn redcon.Conn, command comma
 	if transaction == nil {
 		if isTransactionNeeded(command) {
 			transaction = commands.NewTransaction(dep)
+			transaction.SetMaxQueuedCommands(base.GetServerConfig().TransactionMaxQueuedCommands)
+			transaction.SetRoomDataVersionLoader(newRoomDataVersionLoader(dep.DB))
 			transactionManager.addTransaction(conn, transaction)
 			metric.MetricIncrease("transaction.new")
 			logger.Debug(
@@ -343,29 +800,56 @@ func writeDataToConnection(conn redcon.Conn, data commands.RESPData) {
 		}
 	case commands.NilArrayRespType:
 		conn.WriteRaw([]byte("*-1\r\n"))
+	case commands.MultipleRespType:
+		replies, ok := data.Value.([]commands.RESPData)
+		if !ok {
+			conn.WriteError(errInvalidResponse.Error())
+		} else {
+			for _, reply := range replies {
+				writeDataToConnection(conn, reply)
+			}
+		}
+	default:
+		// every command must have a reply, otherwise replies of following commands are mismatched by clients.
+		conn.WriteError(errInvalidResponse.Error())
 	}
 }
 
 func sendCommandEvents(command commands.Commander, accessTime time.Time) error {
 	hashTagEventService := base.GetHashTagEventService()
-	hashTag, err := commands.CheckAndGetCommandKeysHashTag(command)
-	if err != nil {
-		return err
-	}
-	if hashTag == "" {
+	if hashTagEventService.IsDisabled() {
 		return nil
 	}
-	keys := append(command.ReadKeys(), command.WriteKeys()...)
-	if err := hashTagEventService.SendEvent(hashTag, keys, commands.GetCommnadKeysAccessMode(command), accessTime); err != nil {
+	cmds, err := commands.SplitCommandByHashTag(command)
+	if err != nil {
 		return err
 	}
+	for _, cmd := range cmds {
+		hashTag, err := commands.CheckAndGetCommandKeysHashTag(cmd)
+		if err != nil {
+			return err
+		}
+		if hashTag == "" {
+			continue
+		}
+		keys := append(cmd.ReadKeys(), cmd.WriteKeys()...)
+		if err := hashTagEventService.SendEvent(hashTag, keys, commands.GetCommnadKeysAccessMode(cmd), accessTime); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (service *RoomService) connCloseHandler(conn redcon.Conn, err error) {
+	// connection detached for pub/sub is closed by serveSubscriber later.
+	if sub := subscriberManager.getSubscriber(conn); sub != nil && sub.markDetachClosed() {
+		return
+	}
 	metric := service.dep.Metric
 	metric.MetricIncrease("connection.close")
 	transactionManager.removeTransaction(conn, commands.TransactionCloseReasonConnClosed)
+	connectionManager.removeConnection(conn)
+	subscriberManager.removeSubscriber(conn)
 	transactionCount := transactionManager.transactionCount()
 	connectionCount := atomic.AddInt64(&connectionTotal, -1)
 	if err == nil {
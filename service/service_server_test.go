@@ -0,0 +1,549 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"bytepower_room/utility"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
+)
+
+func TestIsTransactionNeeded(t *testing.T) {
+	testCases := []struct {
+		args   []string
+		needed bool
+	}{
+		{[]string{"watch", "{a}1"}, true},
+		{[]string{"multi"}, true},
+		{[]string{"discard"}, true},
+		{[]string{"exec"}, false},
+		{[]string{"unwatch"}, false},
+		{[]string{"get", "{a}1"}, false},
+	}
+	for _, testCase := range testCases {
+		command, err := commands.ParseCommand(testCase.args)
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.needed, isTransactionNeeded(command), testCase.args)
+	}
+}
+
+func TestExistsAndDelWithMultipleHashTags(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{dep: dep}
+	// keys of hash tag exists_a are persisted in db, hash tag exists_b has no persisted data.
+	keyA1, keyA2, keyB := "{exists_a}1", "{exists_a}2", "{exists_b}1"
+	defer testEmptyRoomDataRecordInDatabase("exists_a")
+	defer testEmptyKeysInRedis(keyA1, keyA2, keyB)
+	testCleanLocalloadedCache("exists_a")
+	testCleanLocalloadedCache("exists_b")
+	testInsertRoomData("exists_a", map[string]RedisValue{
+		keyA1: {Type: stringType, Value: "1"},
+		keyA2: {Type: stringType, Value: "2"},
+	})
+
+	command, err := commands.ParseCommand([]string{"exists", keyA1, keyB, keyA1, "{exists_a}3"})
+	assert.Nil(t, err)
+	assert.Nil(t, preProcessCommand(dep, command, time.Now()))
+	cmds, ok := splitIfMultipleHashTags(command)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(cmds))
+	assert.Equal(t, []string{keyA1, keyA1, "{exists_a}3"}, cmds[0].ReadKeys())
+	assert.Equal(t, []string{keyB}, cmds[1].ReadKeys())
+	assert.Equal(t, commands.RESPData{DataType: commands.IntegerRespType, Value: int64(2)}, service.executeMultipleHashTagsCommands(cmds, time.Time{}))
+
+	command, err = commands.ParseCommand([]string{"del", keyA1, keyA2, keyB})
+	assert.Nil(t, err)
+	assert.Nil(t, preProcessCommand(dep, command, time.Now()))
+	cmds, ok = splitIfMultipleHashTags(command)
+	assert.True(t, ok)
+	assert.Equal(t, base.HashTagAccessModeWrite, commands.GetCommnadKeysAccessMode(cmds[1]))
+	assert.Equal(t, commands.RESPData{DataType: commands.IntegerRespType, Value: int64(2)}, service.executeMultipleHashTagsCommands(cmds, time.Time{}))
+	assert.Equal(t, int64(0), dep.Redis.Exists(contextTODO, keyA1, keyA2).Val())
+
+	// keys with the same hash tag are executed as a single command.
+	command, _ = commands.ParseCommand([]string{"exists", keyA1, keyA2})
+	_, ok = splitIfMultipleHashTags(command)
+	assert.False(t, ok)
+}
+
+func TestCheckValueSize(t *testing.T) {
+	// redis and db are nil, so oversized commands must be rejected before keys are loaded.
+	service := &RoomService{
+		config: &base.RoomServerConfig{
+			MaxValueSizeBytes:  4,
+			MaxValueSizeByType: map[string]int{"hash": 8, "set": 0},
+		},
+		dep: base.Dependency{Metric: base.NewNoopMetricClient()},
+	}
+	large := strings.Repeat("a", 5)
+	testCases := []struct {
+		args  []string
+		limit int
+	}{
+		{[]string{"set", "{a}1", large}, 4},
+		{[]string{"append", "{a}1", large}, 4},
+		{[]string{"lpush", "{a}1", "a", large}, 4},
+		{[]string{"rpush", "{a}1", "abc", "de"}, 4},
+		{[]string{"zadd", "{a}1", "1", large}, 4},
+		{[]string{"hset", "{a}1", "f", large, "g", large}, 8},
+	}
+	for _, testCase := range testCases {
+		cmd := redcon.Command{}
+		for _, arg := range testCase.args {
+			cmd.Args = append(cmd.Args, []byte(arg))
+		}
+		_, err := service.preProcessCommand(context.TODO(), cmd, time.Now())
+		assert.Equal(t, newValueSizeExceedsLimitError(testCase.limit), err, testCase.args)
+	}
+
+	for _, args := range [][]string{
+		{"set", "{a}1", "abcd"},
+		{"hset", "{a}1", "f", "abcdef"},
+		{"sadd", "{a}1", large},
+		{"get", "{a}1"},
+	} {
+		command, err := commands.ParseCommand(args)
+		assert.Nil(t, err)
+		assert.Nil(t, service.checkValueSize(command), args)
+	}
+}
+
+func TestHGetAllByScan(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{config: &base.RoomServerConfig{MaxHGetAllFields: 2}, dep: dep}
+	hash, str := "{hgetall}hash", "{hgetall}string"
+	defer testEmptyKeysInRedis(hash, str)
+	assert.Nil(t, dep.Redis.HSet(contextTODO, hash, "b", "2", "a", "1").Err())
+	assert.Nil(t, dep.Redis.Set(contextTODO, str, "x", 0).Err())
+
+	byFields := func(args ...string) commands.Commander {
+		command, err := commands.ParseCommand(args)
+		assert.Nil(t, err)
+		return service.hGetAllCommandByFields(command)
+	}
+	command := byFields("hgetall", hash)
+	assert.IsType(t, &commands.HGetAllCommand{}, command)
+	assert.False(t, isLocalCommand(command))
+	assert.IsType(t, &commands.HGetAllCommand{}, byFields("hgetall", "{hgetall}not_exist"))
+	// wrong type error is returned by hgetall.
+	assert.IsType(t, &commands.HGetAllCommand{}, byFields("hgetall", str))
+	assert.IsType(t, &commands.HKeysCommand{}, byFields("hkeys", hash))
+
+	items := []interface{}{"b", "2", "a", "1"}
+	for index := 0; index < 10; index++ {
+		items = append(items, fmt.Sprintf("f%d", index), fmt.Sprintf("v%d", index))
+	}
+	assert.Nil(t, dep.Redis.HSet(contextTODO, hash, items...).Err())
+	command = byFields("hgetall", hash)
+	assert.True(t, isLocalCommand(command))
+	result := service.processLocalCommand(context.TODO(), nil, command)
+	expected := commands.ExecuteCommand(dep.Redis, command.(*hGetAllByScanCommand).HGetAllCommand)
+	assert.Equal(t, expected, result)
+	values := result.Value.([]commands.RESPData)
+	assert.Equal(t, 24, len(values))
+	assert.Equal(t, "a", values[0].Value)
+	assert.Equal(t, "1", values[1].Value)
+	assert.Equal(t, "b", values[2].Value)
+	assert.Equal(t, "f9", values[22].Value)
+
+	service.config.MaxHGetAllFields = 0
+	assert.IsType(t, &commands.HGetAllCommand{}, byFields("hgetall", hash))
+}
+
+func TestCommandFilter(t *testing.T) {
+	testCases := []struct {
+		filter  base.CommandFilterConfig
+		args    []string
+		allowed bool
+	}{
+		{base.CommandFilterConfig{}, []string{"keys", "*"}, true},
+		{base.CommandFilterConfig{Denylist: []string{"KEYS"}}, []string{"keys", "*"}, false},
+		{base.CommandFilterConfig{Denylist: []string{"keys"}}, []string{"KEYS", "*"}, false},
+		{base.CommandFilterConfig{Allowlist: []string{"get"}}, []string{"keys", "*"}, false},
+		{base.CommandFilterConfig{Allowlist: []string{"Keys"}}, []string{"keys", "*"}, true},
+		// denylist takes precedence over allowlist.
+		{base.CommandFilterConfig{Allowlist: []string{"keys"}, Denylist: []string{"keys"}}, []string{"keys", "*"}, false},
+	}
+	for _, testCase := range testCases {
+		// redis and db are nil, so denied commands must be rejected before keys are loaded.
+		service := &RoomService{
+			config: &base.RoomServerConfig{CommandFilter: testCase.filter},
+			dep:    base.Dependency{Metric: base.NewNoopMetricClient()},
+		}
+		assert.Equal(t, testCase.allowed, testCase.filter.IsAllowed(testCase.args[0]), testCase)
+		if !testCase.allowed {
+			cmd := redcon.Command{}
+			for _, arg := range testCase.args {
+				cmd.Args = append(cmd.Args, []byte(arg))
+			}
+			_, err := service.preProcessCommand(context.TODO(), cmd, time.Now())
+			assert.Equal(t, errCommandNotAllowed, err, testCase)
+		}
+	}
+}
+
+func TestCheckValueType(t *testing.T) {
+	// redis is nil, keys of commands sent to redis are checked with loaded models only.
+	dep := base.Dependency{Metric: base.NewNoopMetricClient()}
+	accessTime := time.Now()
+	expiredTs := utility.TimestampInMS(accessTime.Add(-time.Minute))
+	models := map[string]*roomDataModelV2{
+		"a": {
+			HashTag: "a",
+			Value: map[string]RedisValue{
+				"{a}string":  {Type: stringType, Value: "a"},
+				"{a}list":    {Type: listType, Value: `["a"]`},
+				"{a}expired": {Type: listType, Value: `["a"]`, ExpireTs: expiredTs},
+			},
+		},
+	}
+	testCases := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"lpush", "{a}string", "a"}, errWrongType},
+		{[]string{"lrange", "{a}string", "0", "-1"}, errWrongType},
+		{[]string{"get", "{a}list"}, errWrongType},
+		{[]string{"rpoplpush", "{a}list", "{a}string"}, errWrongType},
+		{[]string{"lpush", "{a}list", "a"}, nil},
+		{[]string{"get", "{a}string"}, nil},
+		{[]string{"get", "{a}expired"}, nil},
+		{[]string{"sadd", "{a}not_exist", "a"}, nil},
+		{[]string{"sadd", "{b}not_loaded", "a"}, nil},
+		{[]string{"del", "{a}string", "{a}list"}, nil},
+		{[]string{"expire", "{a}string", "10"}, nil},
+		{[]string{"type", "{a}list"}, nil},
+		{[]string{"exists", "{a}string"}, nil},
+		{[]string{"set", "{a}list", "a"}, nil},
+	}
+	for _, testCase := range testCases {
+		command, err := commands.ParseCommand(testCase.args)
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.err, checkValueType(dep, command, models, accessTime), testCase.args)
+	}
+}
+
+type testMetricBackend struct {
+	base.NoopMetricBackend
+	gauges []string
+}
+
+func (backend *testMetricBackend) Gauge(bucket string, value interface{}) {
+	backend.gauges = append(backend.gauges, fmt.Sprintf("%s:%v", bucket, value))
+}
+
+func TestRecordAppendLengths(t *testing.T) {
+	backend := &testMetricBackend{}
+	service := &RoomService{dep: base.Dependency{Metric: base.NewMetricClient(backend)}}
+	results := []commands.RESPData{
+		{DataType: commands.IntegerRespType, Value: int64(5)},
+		{DataType: commands.SimpleStringRespType, Value: "OK"},
+		{DataType: commands.IntegerRespType, Value: int64(1024)},
+		{DataType: commands.ErrorRespType, Value: errWrongType},
+	}
+	service.recordAppendLengths(results, []int{0, 2, 3})
+	assert.Equal(t, []string{"gauge.command.append.length:5", "gauge.command.append.length:1024"}, backend.gauges)
+}
+
+// testReplyConn records replies written to the connection.
+type testReplyConn struct {
+	testConn
+	replies []string
+}
+
+func (conn *testReplyConn) WriteString(str string) {
+	conn.replies = append(conn.replies, "s:"+str)
+}
+
+func (conn *testReplyConn) WriteBulkString(bulk string) {
+	conn.replies = append(conn.replies, "bs:"+bulk)
+}
+
+func (conn *testReplyConn) WriteError(msg string) {
+	conn.replies = append(conn.replies, "err:"+msg)
+}
+
+func (conn *testReplyConn) WriteInt64(num int64) {
+	conn.replies = append(conn.replies, fmt.Sprintf("i:%d", num))
+}
+
+func (conn *testReplyConn) WriteNull() {
+	conn.replies = append(conn.replies, "nil")
+}
+
+func (conn *testReplyConn) WriteArray(count int) {
+	conn.replies = append(conn.replies, fmt.Sprintf("array:%d", count))
+}
+
+func (conn *testReplyConn) WriteRaw(data []byte) {
+	conn.replies = append(conn.replies, "raw:"+string(data))
+}
+
+func testNewRedconCommands(cmds ...[]string) []redcon.Command {
+	result := make([]redcon.Command, 0, len(cmds))
+	for _, args := range cmds {
+		cmd := redcon.Command{Raw: []byte(strings.Join(args, " "))}
+		for _, arg := range args {
+			cmd.Args = append(cmd.Args, []byte(arg))
+		}
+		result = append(result, cmd)
+	}
+	return result
+}
+
+func TestPipelineWithErrorCommand(t *testing.T) {
+	hashTag := "pipeline"
+	key := "{pipeline}a"
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(key)
+	testEmptyRoomDataRecordInDatabase(hashTag)
+	testEmptyKeysInRedis(key)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	service := &RoomService{dep: base.GetServerDependency(), config: base.GetServerConfig()}
+
+	// errors of commands in the middle of pipeline are only replies of themselves.
+	conn := &testReplyConn{testConn: testConn{addr: "127.0.0.1:10011"}}
+	service.connServeHandler(conn, testNewRedconCommands(
+		[]string{"set", key, "1"},
+		[]string{"get"},
+		[]string{"not_exist_command", key},
+		[]string{"incr", key},
+		[]string{"get", key},
+	))
+	assert.Equal(t, 5, len(conn.replies))
+	assert.Equal(t, "s:OK", conn.replies[0])
+	assert.True(t, strings.HasPrefix(conn.replies[1], "err:"), conn.replies[1])
+	assert.True(t, strings.HasPrefix(conn.replies[2], "err:"), conn.replies[2])
+	assert.Equal(t, []string{"i:2", "bs:2"}, conn.replies[3:])
+
+	// transaction with a rejected command is aborted, commands after exec are still processed.
+	conn = &testReplyConn{testConn: testConn{addr: "127.0.0.1:10012"}}
+	defer transactionManager.removeTransaction(conn, commands.TransactionCloseReasonConnClosed)
+	service.connServeHandler(conn, testNewRedconCommands(
+		[]string{"multi"},
+		[]string{"incr", key},
+		[]string{"get"},
+		[]string{"incr", key},
+		[]string{"exec"},
+		[]string{"get", key},
+	))
+	assert.Equal(t, 6, len(conn.replies))
+	assert.Equal(t, []string{"s:OK", "s:QUEUED"}, conn.replies[:2])
+	assert.True(t, strings.HasPrefix(conn.replies[2], "err:"), conn.replies[2])
+	assert.Equal(t, []string{"s:QUEUED", "err:EXECABORT Transaction discarded because of previous errors.", "bs:2"}, conn.replies[3:])
+}
+
+func TestRateLimitedCommandAbortsTransaction(t *testing.T) {
+	hashTag := "rate_limit_tx"
+	key := "{rate_limit_tx}a"
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(key)
+	testEmptyRoomDataRecordInDatabase(hashTag)
+	testEmptyKeysInRedis(key)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	service := &RoomService{dep: base.GetServerDependency(), config: base.GetServerConfig()}
+
+	conn := &testReplyConn{testConn: testConn{addr: "127.0.0.1:10013"}}
+	connection := newConnection(conn.addr, base.RateLimitConfig{MaxCommandsPerSecond: 1, BurstSize: 2})
+	connectionManager.addConnection(conn, connection)
+	defer connectionManager.removeConnection(conn)
+	defer transactionManager.removeTransaction(conn, commands.TransactionCloseReasonConnClosed)
+
+	service.connServeHandler(conn, testNewRedconCommands(
+		[]string{"multi"},
+		[]string{"incr", key},
+		[]string{"incr", key},
+	))
+	assert.Equal(t, []string{"s:OK", "s:QUEUED", "err:" + errRateLimitExceeded.Error()}, conn.replies)
+
+	// exec is allowed after tokens are refilled, the transaction is discarded.
+	connection.limiter = utility.NewTokenBucket(1, 2)
+	conn.replies = nil
+	service.connServeHandler(conn, testNewRedconCommands([]string{"exec"}, []string{"get", key}))
+	assert.Equal(t, []string{"err:EXECABORT Transaction discarded because of previous errors.", "nil"}, conn.replies)
+}
+
+func TestIsLocalCommandWithKeysInOneSlot(t *testing.T) {
+	testCases := []struct {
+		args  []string
+		local bool
+	}{
+		{[]string{"sunionstore", "{a}:d", "{a}:1", "{a}:2"}, false},
+		{[]string{"sunionstore", "{a}:d", "{a}:1", "{b}:1"}, true},
+		{[]string{"zinter", "2", "{a}:1", "{a}:2"}, false},
+		{[]string{"zinter", "2", "{a}:1", "{b}:1"}, true},
+		{[]string{"zrangestore", "{a}:d", "{a}:1", "0", "-1"}, false},
+		{[]string{"copy", "{a}:1", "{b}:1"}, true},
+		{[]string{"copy", "{a}:1", "{a}:2"}, false},
+	}
+	for _, testCase := range testCases {
+		command, err := commands.ParseCommand(testCase.args)
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.local, isLocalCommand(command), testCase.args)
+	}
+}
+
+func TestLocalCommandInTransaction(t *testing.T) {
+	hashTags := []string{"local_tx_a", "local_tx_b"}
+	keys := []string{"{local_tx_a}1", "{local_tx_a}2", "{local_tx_b}1"}
+	for _, hashTag := range hashTags {
+		defer testEmptyRoomDataRecordInDatabase(hashTag)
+		testEmptyRoomDataRecordInDatabase(hashTag)
+		testCleanLocalloadedCache(hashTag)
+		testSetMetaKeyCleaned(hashTag)
+	}
+	defer testEmptyKeysInRedis(keys...)
+	testEmptyKeysInRedis(keys...)
+	service := &RoomService{dep: base.GetServerDependency(), config: base.GetServerConfig()}
+
+	// commands processed by room abort the transaction instead of running before exec.
+	conn := &testReplyConn{testConn: testConn{addr: "127.0.0.1:10014"}}
+	defer transactionManager.removeTransaction(conn, commands.TransactionCloseReasonConnClosed)
+	service.connServeHandler(conn, testNewRedconCommands(
+		[]string{"sadd", keys[0], "a"},
+		[]string{"multi"},
+		[]string{"sunionstore", keys[2], keys[0]},
+		[]string{"exec"},
+		[]string{"exists", keys[2]},
+	))
+	assert.Equal(t, []string{
+		"i:1", "s:OK", "err:ERR command sunionstore is not allowed in transaction",
+		"err:EXECABORT Transaction discarded because of previous errors.", "i:0",
+	}, conn.replies)
+
+	// commands of keys in one slot are queued in redis transactions.
+	conn.replies = nil
+	service.connServeHandler(conn, testNewRedconCommands(
+		[]string{"multi"},
+		[]string{"sunionstore", keys[1], keys[0]},
+		[]string{"exec"},
+		[]string{"smembers", keys[1]},
+	))
+	assert.Equal(t, []string{"s:OK", "s:QUEUED", "array:1", "i:1", "array:1", "bs:a"}, conn.replies)
+}
+
+func TestKeysInDifferentSlotsWithPrefixHashTagScheme(t *testing.T) {
+	extractor, err := commands.NewHashTagExtractor(base.HashTagConfig{Scheme: base.HashTagSchemePrefix, Delimiter: ":"})
+	assert.Nil(t, err)
+	commands.SetHashTagExtractor(extractor)
+	defer func() {
+		extractor, _ := commands.NewHashTagExtractor(base.HashTagConfig{})
+		commands.SetHashTagExtractor(extractor)
+	}()
+	hashTag := "prefix_slot"
+	key1, key2, key3 := "prefix_slot:1", "prefix_slot:2", "prefix_slot:3"
+	sameSlotKey1, sameSlotKey2 := "prefix_slot:{a}1", "prefix_slot:{a}2"
+	keys := []string{key1, key2, key3, sameSlotKey1, sameSlotKey2}
+	assert.False(t, redis.AreKeysInSameSlot(key1, key2))
+	assert.False(t, redis.AreKeysInSameSlot(key2, key3))
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(keys...)
+	testEmptyRoomDataRecordInDatabase(hashTag)
+	testEmptyKeysInRedis(keys...)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	service := &RoomService{dep: base.GetServerDependency(), config: base.GetServerConfig()}
+
+	conn := &testReplyConn{testConn: testConn{addr: "127.0.0.1:10015"}}
+	service.connServeHandler(conn, testNewRedconCommands(
+		[]string{"mset", key1, "1", key2, "2"},
+		[]string{"set", key1, "1"},
+		[]string{"mget", key1, key2},
+		[]string{"mset", sameSlotKey1, "1", sameSlotKey2, "2"},
+		[]string{"mget", sameSlotKey1, sameSlotKey2},
+		// commands processed by room are not limited.
+		[]string{"sadd", key2, "a"},
+		[]string{"sunionstore", key3, key2},
+	))
+	assert.Equal(t, []string{
+		"err:" + errKeysCrossSlot.Error(),
+		"s:OK",
+		"err:" + errKeysCrossSlot.Error(),
+		"s:OK",
+		"array:2", "bs:1", "bs:2",
+		"i:1",
+		"i:1",
+	}, conn.replies)
+}
+
+func TestWriteDataToConnectionWithUnknownType(t *testing.T) {
+	conn := &testReplyConn{}
+	writeDataToConnection(conn, commands.RESPData{})
+	writeDataToConnection(conn, commands.RESPData{DataType: commands.IntegerRespType, Value: int64(1)})
+	assert.Equal(t, []string{"err:" + errInvalidResponse.Error(), "i:1"}, conn.replies)
+}
+
+func TestCommandTimeout(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{
+		dep:    dep,
+		config: &base.RoomServerConfig{CommandTimeout: time.Second, TransactionCommandTimeout: 3 * time.Second},
+	}
+	conn := &testConn{addr: "127.0.0.1:10021"}
+	assert.Equal(t, time.Second, service.commandTimeout(conn))
+	transaction := commands.NewTransaction(dep)
+	transactionManager.addTransaction(conn, transaction)
+	defer transactionManager.removeTransaction(conn, commands.TransactionCloseReasonConnClosed)
+	assert.Equal(t, time.Second, service.commandTimeout(conn))
+	command, _ := commands.ParseCommand([]string{"multi"})
+	transaction.Process(command)
+	assert.Equal(t, 3*time.Second, service.commandTimeout(conn))
+
+	hashTag := "command_timeout"
+	key := "{command_timeout}a"
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(key)
+	testCleanLocalloadedCache(hashTag)
+	testSetMetaKeyCleaned(hashTag)
+	testInsertRoomData(hashTag, map[string]RedisValue{key: {Type: stringType, Value: "1"}})
+
+	// loading is canceled when the command is timed out.
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Nanosecond)
+	defer cancel()
+	time.Sleep(time.Millisecond)
+	command, _ = commands.ParseCommand([]string{"get", key})
+	assert.Equal(t, errCommandTimeout, preProcessCommandWithContext(ctx, dep, command, time.Now()))
+	assert.Equal(t, int64(0), dep.Redis.Exists(contextTODO, key).Val())
+
+	assert.Nil(t, preProcessCommandWithContext(context.TODO(), dep, command, time.Now()))
+	assert.Equal(t, "1", dep.Redis.Get(contextTODO, key).Val())
+}
+
+func TestCommandDeadline(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{dep: dep, config: &base.RoomServerConfig{CommandTimeout: time.Second}}
+	conn := &testConn{addr: "127.0.0.1:10022"}
+	serveStartTime := time.Now()
+	assert.Equal(t, serveStartTime.Add(time.Second), service.commandDeadline(conn, serveStartTime))
+	service.config.CommandTimeout = 0
+	assert.True(t, service.commandDeadline(conn, serveStartTime).IsZero())
+
+	key := "{command_deadline}a"
+	defer testEmptyKeysInRedis(key)
+	assert.Nil(t, dep.Redis.Set(contextTODO, key, "1", 0).Err())
+	command, err := commands.ParseCommand([]string{"get", key})
+	assert.Nil(t, err)
+	batch := commands.NewCommandBatch()
+	batch.AddCommand(0, command)
+	timeoutResult := commands.ConvertErrorToRESPData(errCommandTimeout)
+
+	// commands of a batch are timed out if the deadline is exceeded.
+	assert.Equal(t, timeoutResult, service.executeCommandBatch(batch, time.Now().Add(-time.Second))[0])
+	assert.Equal(t, "bs:1", service.executeCommandBatch(batch, time.Now().Add(time.Second))[0].String())
+	assert.Equal(t, "bs:1", service.executeCommandBatch(batch, time.Time{})[0].String())
+
+	// local commands are timed out if the deadline is exceeded.
+	command, err = commands.ParseCommand([]string{"dump", key})
+	assert.Nil(t, err)
+	assert.Equal(t, timeoutResult, service.processLocalCommandWithDeadline(conn, command, time.Now().Add(-time.Second)))
+	assert.Equal(t, commands.BulkStringRespType, service.processLocalCommandWithDeadline(conn, command, time.Time{}).DataType)
+}
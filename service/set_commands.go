@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bytepower_room/commands"
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// processSetAggregateCommand computes diff, intersection or union of sets in memory,
+// keys of all hash tags have been loaded before, so keys could have different hash tags.
+// Result of store variants replaces destination key with sources watched, which is synced to db
+// by write event of its hash tag, destination key is removed if result is empty.
+func (service *RoomService) processSetAggregateCommand(ctx context.Context, command *commands.SetAggregateCommand) commands.RESPData {
+	redisCluster := service.dep.Redis
+	aggregate := func() ([]zsetMember, error) {
+		sets, err := getSetsFromRedis(ctx, redisCluster, command.Keys())
+		if err != nil {
+			return nil, err
+		}
+		weights := make([]float64, len(sets))
+		for index := range weights {
+			weights[index] = 1
+		}
+		return aggregateZSets(command.Operation(), commands.ZSetAggregateSum, sets, weights), nil
+	}
+	if command.Destination() == "" {
+		members, err := aggregate()
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		replies := make([]commands.RESPData, 0, len(members))
+		for _, member := range members {
+			replies = append(replies, commands.RESPData{DataType: commands.BulkStringRespType, Value: member.member})
+		}
+		return commands.RESPData{DataType: commands.ArrayRespType, Value: replies}
+	}
+	count := 0
+	err := storeWithWatchedSources(ctx, redisCluster, command.Keys(), command.Destination(), func() (storeFunc, error) {
+		members, err := aggregate()
+		if err != nil {
+			return nil, err
+		}
+		count = len(members)
+		items := make([]string, 0, len(members))
+		for _, member := range members {
+			items = append(items, member.member)
+		}
+		return func(pipeline redis.Pipeliner) {
+			storeSetMembers(ctx, pipeline, command.Destination(), items)
+		}, nil
+	})
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(count)}
+}
+
+// getSetsFromRedis returns members of keys in pipelines as sorted sets with score 1, keys which do not exist are empty,
+// WRONGTYPE error is returned if a key is not a set.
+func getSetsFromRedis(ctx context.Context, redisCluster *redis.ClusterClient, keys []string) ([]map[string]float64, error) {
+	pipeline := redisCluster.Pipeline()
+	typeCmds := make([]*redis.StatusCmd, 0, len(keys))
+	for _, key := range keys {
+		typeCmds = append(typeCmds, pipeline.Type(ctx, key))
+	}
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return nil, err
+	}
+	pipeline = redisCluster.Pipeline()
+	memberCmds := make([]*redis.StringSliceCmd, len(keys))
+	for index, key := range keys {
+		switch typeCmds[index].Val() {
+		case setType:
+			memberCmds[index] = pipeline.SMembers(ctx, key)
+		case redisKeyNotExist:
+		default:
+			return nil, errWrongType
+		}
+	}
+	if _, err := pipeline.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	sets := make([]map[string]float64, len(keys))
+	for index, cmd := range memberCmds {
+		set := make(map[string]float64)
+		if cmd != nil {
+			for _, member := range cmd.Val() {
+				set[member] = 1
+			}
+		}
+		sets[index] = set
+	}
+	return sets, nil
+}
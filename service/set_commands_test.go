@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetAggregateCommandsWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{dep: dep}
+	srcHashTag, dstHashTag := "saggregate_src", "saggregate_dst"
+	// sources and destination are saved in different tables.
+	assert.NotEqual(t, dep.DB.GetShardingIndex(srcHashTag), dep.DB.GetShardingIndex(dstHashTag))
+	set1, set2, set3, same := "{saggregate_src}set1", "{saggregate_src}set2", "{saggregate_dst}set3", "{saggregate_dst}same"
+	dst, empty := "{saggregate_dst}dst", "{saggregate_src}empty"
+	keys := []string{set1, set2, set3, same, dst, "{saggregate_src}string"}
+	for _, hashTag := range []string{srcHashTag, dstHashTag} {
+		defer testEmptyRoomDataRecordInDatabase(hashTag)
+		testCleanLocalloadedCache(hashTag)
+		testSetMetaKeyCleaned(hashTag)
+	}
+	defer testEmptyKeysInRedis(keys...)
+	testInsertRoomData(srcHashTag, map[string]RedisValue{
+		set1:                     {Type: setType, Value: `["a","b","c"]`},
+		set2:                     {Type: setType, Value: `["x","y"]`},
+		"{saggregate_src}string": {Type: stringType, Value: "x"},
+	})
+	testInsertRoomData(dstHashTag, map[string]RedisValue{
+		set3: {Type: setType, Value: `["b","c","d"]`},
+		same: {Type: setType, Value: `["a","b","c"]`},
+		dst:  {Type: stringType, Value: "x"},
+	})
+
+	execute := func(args ...string) string {
+		command, err := commands.ParseCommand(args)
+		assert.Nil(t, err)
+		if err := preProcessCommand(dep, command, time.Now()); err != nil {
+			return commands.ConvertErrorToRESPData(err).String()
+		}
+		return service.processSetAggregateCommand(context.TODO(), command.(*commands.SetAggregateCommand)).String()
+	}
+	array := func(values ...string) string {
+		data := make([]commands.RESPData, 0, len(values))
+		for _, value := range values {
+			data = append(data, commands.RESPData{DataType: commands.BulkStringRespType, Value: value})
+		}
+		return commands.RESPData{DataType: commands.ArrayRespType, Value: data}.String()
+	}
+	members := func() []string {
+		result, err := dep.Redis.SMembers(context.TODO(), dst).Result()
+		assert.Nil(t, err)
+		return result
+	}
+
+	// overlapping sets.
+	assert.Equal(t, array("a"), execute("sdiff", set1, set3))
+	assert.Equal(t, array("b", "c"), execute("sinter", set1, set3))
+	assert.Equal(t, array("a", "b", "c", "d"), execute("sunion", set1, set3))
+	// disjoint sets.
+	assert.Equal(t, array("a", "b", "c"), execute("sdiff", set1, set2, empty))
+	assert.Equal(t, array(), execute("sinter", set1, set2))
+	assert.Equal(t, array("a", "b", "c", "x", "y"), execute("sunion", set1, set2))
+	// identical sets.
+	assert.Equal(t, array(), execute("sdiff", set1, same))
+	assert.Equal(t, array("a", "b", "c"), execute("sinter", set1, same))
+	assert.Equal(t, array("a", "b", "c"), execute("sunion", same, set1))
+
+	// destination of a string is replaced.
+	assert.Equal(t, "i:2", execute("sinterstore", dst, set1, set3))
+	assert.ElementsMatch(t, []string{"b", "c"}, members())
+	assert.Equal(t, "i:1", execute("sdiffstore", dst, set1, set3, empty))
+	assert.ElementsMatch(t, []string{"a"}, members())
+	assert.Equal(t, "i:5", execute("sunionstore", dst, set1, set2))
+	assert.ElementsMatch(t, []string{"a", "b", "c", "x", "y"}, members())
+	// destination is removed if result is empty.
+	assert.Equal(t, "i:0", execute("sinterstore", dst, set1, empty))
+	assert.Equal(t, int64(0), dep.Redis.Exists(context.TODO(), dst).Val())
+
+	assert.Equal(t, commands.ConvertErrorToRESPData(errWrongType).String(), execute("sunion", set1, "{saggregate_src}string"))
+}
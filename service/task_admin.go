@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const taskAdminServerName = "task_admin_server"
+
+// taskAdminJob is a task could be triggered by task admin server.
+type taskAdminJob struct {
+	run func(run *taskRun)
+	// queueConditions returns conditions of room_hash_tag_keys records waiting to be processed,
+	// nil means queue depth is not available.
+	queueConditions func(t time.Time) []dbWhereCondition
+}
+
+// TaskAdminServer is a http server to trigger tasks and show stats of tasks.
+//
+//	POST /tasks/run?name=xxx  starts a task run in background.
+//	GET  /tasks/stats         shows stats of the current or the last run of tasks.
+//	GET  /tasks/queues        shows count of records waiting to be processed by tasks.
+type TaskAdminServer struct {
+	dep    base.Dependency
+	config base.TaskAdminServerConfig
+	jobs   map[string]taskAdminJob
+	server *http.Server
+}
+
+func NewTaskAdminServer(dep base.Dependency, config *base.RoomTaskConfig) *TaskAdminServer {
+	syncConfig := config.SyncKeyTask
+	cleanConfig := config.CleanKeyTask
+	purgeConfig := config.PurgeExpiredKeyTask
+	purgeDeletedConfig := config.PurgeDeletedDataTask
+	jobs := map[string]taskAdminJob{
+		SyncKeysTaskName: {
+			run: func(run *taskRun) {
+				syncKeysTask(
+					run, dep, syncConfig.UpSertTryTimes,
+					syncConfig.NoWrittenDuration, syncConfig.RateLimitPerSecond)
+			},
+			queueConditions: func(t time.Time) []dbWhereCondition {
+				return []dbWhereCondition{
+					{column: "status", operator: "=?", parameter: HashTagKeysStatusNeedSynced},
+				}
+			},
+		},
+		CleanKeysTaskName: {
+			run: func(run *taskRun) {
+				cleanKeysTask(
+					run, dep, cleanConfig.InactiveDuration, cleanConfig.RateLimitPerSecond,
+					cleanConfig.GetBatchSize(), cleanConfig.GetMaxExcludedHashTags())
+			},
+			queueConditions: func(t time.Time) []dbWhereCondition {
+				return []dbWhereCondition{
+					{column: "status", operator: "=?", parameter: HashTagKeysStatusSynced},
+					{column: "accessed_at", operator: "<=?", parameter: t.Add(-cleanConfig.InactiveDuration)},
+				}
+			},
+		},
+	}
+	// tasks which are off are not configured, they could not be triggered.
+	if !purgeConfig.IsOff() {
+		jobs[PurgeExpiredKeysTaskName] = taskAdminJob{
+			run: func(run *taskRun) {
+				timeout := time.Duration(purgeConfig.IntervalMinutes) * time.Minute
+				purgeExpiredKeysTask(run, dep, purgeConfig.BatchSize, purgeConfig.RateLimitPerSecond, timeout)
+			},
+		}
+	}
+	if !purgeDeletedConfig.IsOff() {
+		jobs[PurgeDeletedDataTaskName] = taskAdminJob{
+			run: func(run *taskRun) {
+				timeout := time.Duration(purgeDeletedConfig.IntervalMinutes) * time.Minute
+				purgeDeletedDataTask(run, dep, purgeDeletedConfig.BatchSize, purgeDeletedConfig.RetentionDuration, timeout)
+			},
+		}
+	}
+	adminServer := &TaskAdminServer{
+		dep:    dep,
+		config: config.AdminServer,
+		jobs:   jobs,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tasks/run", adminServer.authenticate(adminServer.runTaskHandler))
+	mux.HandleFunc("/tasks/stats", adminServer.authenticate(adminServer.taskStatsHandler))
+	mux.HandleFunc("/tasks/queues", adminServer.authenticate(adminServer.taskQueuesHandler))
+	adminServer.server = &http.Server{
+		Addr:         config.AdminServer.URL,
+		Handler:      mux,
+		ReadTimeout:  time.Duration(config.AdminServer.ReadTimeoutMS) * time.Millisecond,
+		WriteTimeout: time.Duration(config.AdminServer.WriteTimeoutMS) * time.Millisecond,
+	}
+	return adminServer
+}
+
+func (adminServer *TaskAdminServer) Run() {
+	adminServer.dep.Logger.Info(
+		fmt.Sprintf("start %s", taskAdminServerName),
+		log.String("url", adminServer.config.URL))
+	if err := adminServer.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		adminServer.dep.Logger.Error(
+			fmt.Sprintf("%s: listen and serve error", taskAdminServerName),
+			log.Error(err))
+	}
+}
+
+func (adminServer *TaskAdminServer) Stop(ctx context.Context) error {
+	return adminServer.server.Shutdown(ctx)
+}
+
+func (adminServer *TaskAdminServer) authenticate(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminServer.config.Token)) != 1 {
+			writeTaskAdminResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (adminServer *TaskAdminServer) runTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeTaskAdminResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	name := r.URL.Query().Get("name")
+	job, ok := adminServer.jobs[name]
+	if !ok {
+		writeTaskAdminResponse(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("task %q not found", name)})
+		return
+	}
+	run, ok := startTaskRun(adminServer.dep, name)
+	if !ok {
+		writeTaskAdminResponse(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("task %s is running", name)})
+		return
+	}
+	adminServer.dep.Logger.Info(
+		fmt.Sprintf("%s: run task", taskAdminServerName),
+		log.String("task", name),
+		log.String("remote_addr", r.RemoteAddr))
+	go job.run(run)
+	writeTaskAdminResponse(w, http.StatusAccepted, map[string]string{"task": name, "status": "started"})
+}
+
+func (adminServer *TaskAdminServer) taskStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeTaskAdminResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	stats := make(map[string]interface{}, len(adminServer.jobs))
+	for name := range adminServer.jobs {
+		if stat, ok := taskRuns.getStat(name); ok {
+			stats[name] = stat
+		} else {
+			stats[name] = nil
+		}
+	}
+	writeTaskAdminResponse(w, http.StatusOK, stats)
+}
+
+type taskQueueDepth struct {
+	Total  int         `json:"total"`
+	Shards map[int]int `json:"shards"`
+}
+
+func (adminServer *TaskAdminServer) taskQueuesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeTaskAdminResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	currentTime := time.Now()
+	queues := make(map[string]taskQueueDepth)
+	for name, job := range adminServer.jobs {
+		if job.queueConditions == nil {
+			continue
+		}
+		counts, err := countHashTagKeysByCondition(adminServer.dep.DB, job.queueConditions(currentTime)...)
+		if err != nil {
+			adminServer.dep.Logger.Error(
+				fmt.Sprintf("%s: count queue error", taskAdminServerName),
+				log.String("task", name),
+				log.Error(err))
+			writeTaskAdminResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		depth := taskQueueDepth{Shards: counts}
+		for _, count := range counts {
+			depth.Total += count
+		}
+		queues[name] = depth
+	}
+	writeTaskAdminResponse(w, http.StatusOK, queues)
+}
+
+func writeTaskAdminResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set(HTTPHeaderContentType, HTTPContentTypeJSON)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskRunManager(t *testing.T) {
+	name := "test_task_run"
+	run, ok := taskRuns.start(name)
+	assert.True(t, ok)
+	_, ok = taskRuns.start(name)
+	assert.False(t, ok)
+
+	run.addProcessed(0, 10)
+	run.addProcessed(0, 5)
+	run.addProcessed(2, 1)
+	run.addError(errors.New("test error"))
+	run.addError(nil)
+	stat, ok := taskRuns.getStat(name)
+	assert.True(t, ok)
+	assert.True(t, stat.Running)
+	assert.Equal(t, map[int]int{0: 15, 2: 1}, stat.ProcessedPerShard)
+	assert.Equal(t, []string{"test error"}, stat.Errors)
+
+	run.finish()
+	stat, _ = taskRuns.getStat(name)
+	assert.False(t, stat.Running)
+	assert.NotEmpty(t, stat.Duration)
+
+	run, ok = taskRuns.start(name)
+	assert.True(t, ok)
+	run.finish()
+	stat, _ = taskRuns.getStat(name)
+	assert.Equal(t, 0, len(stat.ProcessedPerShard))
+	assert.Equal(t, 0, len(stat.Errors))
+}
+
+func TestStartTaskRunWithRunLock(t *testing.T) {
+	dep := base.GetTaskDependency()
+	name := "test_task_run_lock"
+	lockKey := getTaskRunLockKey(name)
+	dep.Redis.Del(contextTODO, lockKey)
+
+	// lock is held by other instance.
+	assert.Nil(t, dep.Redis.Set(contextTODO, lockKey, "other", taskRunLockDuration).Err())
+	_, ok := startTaskRun(dep, name)
+	assert.False(t, ok)
+	dep.Redis.Del(contextTODO, lockKey)
+
+	run, ok := startTaskRun(dep, name)
+	assert.True(t, ok)
+	exists, err := dep.Redis.Exists(contextTODO, lockKey).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), exists)
+	_, ok = startTaskRun(dep, name)
+	assert.False(t, ok)
+
+	run.finish()
+	exists, err = dep.Redis.Exists(contextTODO, lockKey).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), exists)
+}
+
+func TestTaskAdminServerRunTask(t *testing.T) {
+	name := "test_admin_task"
+	blockCh := make(chan bool)
+	doneCh := make(chan bool)
+	adminServer := &TaskAdminServer{
+		dep:    base.GetTaskDependency(),
+		config: base.TaskAdminServerConfig{Token: "token"},
+		jobs: map[string]taskAdminJob{
+			name: {run: func(run *taskRun) {
+				defer run.finish()
+				<-blockCh
+				run.addProcessed(1, 3)
+				doneCh <- true
+			}},
+		},
+	}
+	handler := adminServer.authenticate(adminServer.runTaskHandler)
+	testCases := []struct {
+		desc       string
+		method     string
+		url        string
+		token      string
+		statusCode int
+	}{
+		{"without token", http.MethodPost, "/tasks/run?name=" + name, "", http.StatusUnauthorized},
+		{"with invalid token", http.MethodPost, "/tasks/run?name=" + name, "invalid", http.StatusUnauthorized},
+		{"with invalid method", http.MethodGet, "/tasks/run?name=" + name, "token", http.StatusMethodNotAllowed},
+		{"with unknown task", http.MethodPost, "/tasks/run?name=unknown", "token", http.StatusNotFound},
+		{"start task", http.MethodPost, "/tasks/run?name=" + name, "token", http.StatusAccepted},
+		{"start running task", http.MethodPost, "/tasks/run?name=" + name, "token", http.StatusConflict},
+	}
+	for _, testCase := range testCases {
+		request := httptest.NewRequest(testCase.method, testCase.url, nil)
+		if testCase.token != "" {
+			request.Header.Set("Authorization", "Bearer "+testCase.token)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		assert.Equal(t, testCase.statusCode, recorder.Code, testCase.desc)
+	}
+	close(blockCh)
+	<-doneCh
+
+	request := httptest.NewRequest(http.MethodGet, "/tasks/stats", nil)
+	request.Header.Set("Authorization", "Bearer token")
+	recorder := httptest.NewRecorder()
+	adminServer.authenticate(adminServer.taskStatsHandler)(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), name)
+}
@@ -15,10 +15,33 @@ import (
 
 const CleanKeysTaskName = "clean_keys"
 
+// cleanKeysExcludedHashTagsChunkSize is max count of hash tags in a "not in" list of queries,
+// excluded hash tags are split into chunks so that each list is bounded.
+const cleanKeysExcludedHashTagsChunkSize = 500
+
+// cleanKeysNextTableIndex is the table index which the next run starts from,
+// it is the table where the last run stopped, so that a run stopped early does not restart from table 0.
+var cleanKeysNextTableIndex = 0
+
 // find keys to clean
 // select * from table where status != "cleaned" and accessed_at < ?;
 // update table set status = "cheaned" where hash_tag = "xxx" and version = "xxx"
-func CleanKeysTask(dep base.Dependency, inactiveDuration time.Duration, rateLimitPerSecond int) {
+func CleanKeysTask(
+	dep base.Dependency, inactiveDuration time.Duration,
+	rateLimitPerSecond int, batchSize int, maxExcludedHashTags int) {
+
+	run, ok := startTaskRun(dep, CleanKeysTaskName)
+	if !ok {
+		return
+	}
+	cleanKeysTask(run, dep, inactiveDuration, rateLimitPerSecond, batchSize, maxExcludedHashTags)
+}
+
+func cleanKeysTask(
+	run *taskRun, dep base.Dependency, inactiveDuration time.Duration,
+	rateLimitPerSecond int, batchSize int, maxExcludedHashTags int) {
+
+	defer run.finish()
 	startTime := time.Now()
 	logTaskStart(
 		dep.Logger,
@@ -26,13 +49,16 @@ func CleanKeysTask(dep base.Dependency, inactiveDuration time.Duration, rateLimi
 		startTime,
 		log.String("inactive_duration", inactiveDuration.String()),
 		log.Int("limit", rateLimitPerSecond),
+		log.Int("batch_size", batchSize),
+		log.Int("max_excluded_hash_tags", maxExcludedHashTags),
 	)
 
-	count := 100
 	accessedAt := startTime.Add(-inactiveDuration)
+	stat := &cleanKeysStat{}
 	var err error
 	defer func() {
 		if panicInfo := recover(); panicInfo != nil {
+			run.addError(fmt.Errorf("%w: %+v", errTaskPanic, panicInfo))
 			recordTaskError(
 				dep.Logger, dep.Metric, SyncKeysTaskName,
 				errTaskPanic, "panic",
@@ -44,40 +70,64 @@ func CleanKeysTask(dep base.Dependency, inactiveDuration time.Duration, rateLimi
 		} else if err == nil {
 			recordTaskSuccess(dep.Logger, dep.Metric, CleanKeysTaskName, time.Since(startTime))
 		}
+		dep.Metric.MetricCount(fmt.Sprintf("%s.run.scanned", CleanKeysTaskName), stat.scanned)
+		dep.Metric.MetricCount(fmt.Sprintf("%s.run.cleaned", CleanKeysTaskName), stat.cleaned)
 	}()
-	excludedHashTags := make([]string, 0)
 	ratelimitBucket := ratelimit.New(rateLimitPerSecond)
-	tableIndex := 0
-	for {
+	shardingCount := dep.DB.GetShardingCount()
+	startIndex := 0
+	if shardingCount > 0 {
+		startIndex = cleanKeysNextTableIndex % shardingCount
+	}
+	for i := 0; i < shardingCount; i++ {
+		tableIndex := (startIndex + i) % shardingCount
+		cleanKeysNextTableIndex = tableIndex
 		conditions := []dbWhereCondition{
 			{column: "status", operator: "=?", parameter: HashTagKeysStatusSynced},
 			{column: "accessed_at", operator: "<=?", parameter: accessedAt},
 		}
-		if len(excludedHashTags) > 0 {
-			conditions = append(conditions, dbWhereCondition{column: "hash_tag", operator: "not in (?)", parameter: pg.In(excludedHashTags)})
+		if err = cleanKeysInTable(
+			run, dep, tableIndex, conditions, batchSize, maxExcludedHashTags, ratelimitBucket, stat); err != nil {
+			return
 		}
-		index, models, loadErr := loadHashTagKeysModelsByCondition(dep.DB, count, tableIndex, conditions...)
-		if loadErr != nil {
-			err = loadErr
+	}
+	cleanKeysNextTableIndex = startIndex
+}
+
+type cleanKeysStat struct {
+	scanned int
+	cleaned int
+}
+
+// cleanKeysInTable cleans keys of models matching conditions in the table of tableIndex batch by batch,
+// hash tags failed to clean because of conflicts are excluded from later batches,
+// it moves on when there are no more models or count of excluded hash tags reaches maxExcludedHashTags.
+func cleanKeysInTable(
+	run *taskRun, dep base.Dependency, tableIndex int, conditions []dbWhereCondition,
+	batchSize int, maxExcludedHashTags int, ratelimitBucket ratelimit.Limiter, stat *cleanKeysStat) error {
+
+	excludedHashTags := make([]string, 0)
+	for {
+		batchConditions := append(conditions[:len(conditions):len(conditions)], excludedHashTagsConditions(excludedHashTags)...)
+		models, err := loadHashTagKeysModelsInTableByCondition(dep.DB, batchSize, tableIndex, batchConditions...)
+		if err != nil {
+			run.addError(err)
 			recordTaskError(
 				dep.Logger, dep.Metric, CleanKeysTaskName,
 				err, "load_hash_tag_keys", nil)
-			return
+			return err
 		}
 		if len(models) == 0 {
-			break
-		}
-		if tableIndex < index {
-			excludedHashTags = make([]string, 0)
-			tableIndex = index
+			return nil
 		}
+		stat.scanned += len(models)
 		processHashTagCount := 0
 		processKeyCount := 0
 		for _, model := range models {
 			ratelimitBucket.Take()
-			keyCount, cleanKeysErr := cleanHashTagKeys(dep, model)
-			err = cleanKeysErr
+			keyCount, err := cleanHashTagKeys(dep, model)
 			if err != nil {
+				run.addError(fmt.Errorf("hash_tag=%s, %w", model.HashTag, err))
 				if errors.Is(err, ErrAccessAfterRecord) || errors.Is(err, errLoadKeysLockFailed) || isRetryErrorForUpdateInTx(err) {
 					recordTaskError(
 						dep.Logger, dep.Metric,
@@ -99,11 +149,12 @@ func CleanKeysTask(dep base.Dependency, inactiveDuration time.Duration, rateLimi
 						"hash_tag": model.HashTag,
 						"keys":     strings.Join(model.Keys, " "),
 					})
-				return
+				return err
 			}
 			processHashTagCount = processHashTagCount + 1
 			processKeyCount = processKeyCount + int(keyCount)
 		}
+		stat.cleaned += processHashTagCount
 		conditionStrs := make([]string, 0, len(conditions))
 		for _, cond := range conditions {
 			conditionStrs = append(conditionStrs, cond.string())
@@ -114,14 +165,47 @@ func CleanKeysTask(dep base.Dependency, inactiveDuration time.Duration, rateLimi
 			log.Int("hash_tag_count", processHashTagCount),
 			log.Int("key_count", processKeyCount),
 			log.Int("table_index", tableIndex),
+			log.Int("excluded_hash_tag_count", len(excludedHashTags)),
 			log.String("condition", strings.Join(conditionStrs, " and ")),
 		)
+		run.addProcessed(tableIndex, processHashTagCount)
 		dep.Metric.MetricCount(fmt.Sprintf("%s.success.clean_hashtag", CleanKeysTaskName), processHashTagCount)
 		dep.Metric.MetricCount(fmt.Sprintf("%s.success.clean_key", CleanKeysTaskName), processKeyCount)
+		if len(excludedHashTags) >= maxExcludedHashTags {
+			dep.Logger.Info(
+				"clean_keys skip table",
+				log.String("task", CleanKeysTaskName),
+				log.Int("table_index", tableIndex),
+				log.Int("excluded_hash_tag_count", len(excludedHashTags)),
+			)
+			dep.Metric.MetricIncrease(fmt.Sprintf("%s.skip_table", CleanKeysTaskName))
+			return nil
+		}
 	}
 }
 
+// excludedHashTagsConditions returns conditions excluding hashTags,
+// hash tags are split into chunks of cleanKeysExcludedHashTagsChunkSize with a condition for each chunk.
+func excludedHashTagsConditions(hashTags []string) []dbWhereCondition {
+	conditions := make([]dbWhereCondition, 0)
+	for start := 0; start < len(hashTags); start += cleanKeysExcludedHashTagsChunkSize {
+		end := start + cleanKeysExcludedHashTagsChunkSize
+		if end > len(hashTags) {
+			end = len(hashTags)
+		}
+		conditions = append(
+			conditions,
+			dbWhereCondition{column: "hash_tag", operator: "not in (?)", parameter: pg.In(hashTags[start:end])},
+		)
+	}
+	return conditions
+}
+
 func cleanHashTagKeys(dep base.Dependency, model *roomHashTagKeys) (int64, error) {
+	// keys are not cleaned from redis if they could not be set as cleaned, e.g. they are not synced.
+	if err := model.checkStatusTransition(HashTagKeysStatusCleaned); err != nil {
+		return 0, err
+	}
 	tag, err := NewHashTag(model.HashTag, dep)
 	if err != nil {
 		return 0, err
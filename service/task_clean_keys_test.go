@@ -0,0 +1,33 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExcludedHashTagsConditions(t *testing.T) {
+	assert.Equal(t, []dbWhereCondition{}, excludedHashTagsConditions(nil))
+
+	hashTags := make([]string, 0)
+	for i := 0; i < 2*cleanKeysExcludedHashTagsChunkSize+1; i++ {
+		hashTags = append(hashTags, fmt.Sprintf("tag%d", i))
+	}
+	conditions := excludedHashTagsConditions(hashTags)
+	assert.Equal(t, 3, len(conditions))
+	chunks := [][]string{
+		hashTags[:cleanKeysExcludedHashTagsChunkSize],
+		hashTags[cleanKeysExcludedHashTagsChunkSize : 2*cleanKeysExcludedHashTagsChunkSize],
+		hashTags[2*cleanKeysExcludedHashTagsChunkSize:],
+	}
+	for i, chunk := range chunks {
+		assert.Equal(t, "hash_tag", conditions[i].column)
+		assert.Equal(t, "not in (?)", conditions[i].operator)
+		expected, _ := pg.In(chunk).(types.ValueAppender).AppendValue(nil, 1)
+		actual, _ := conditions[i].parameter.(types.ValueAppender).AppendValue(nil, 1)
+		assert.Equal(t, string(expected), string(actual))
+	}
+}
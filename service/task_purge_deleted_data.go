@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+const PurgeDeletedDataTaskName = "purge_deleted_data"
+
+// rows in room_data_v2 are soft deleted, the task removes rows soft deleted before retention duration from db.
+// delete from table where deleted_at < ? and hash_tag in (select hash_tag from table where deleted_at < ? order by hash_tag limit ?);
+func PurgeDeletedDataTask(dep base.Dependency, batchSize int, retentionDuration time.Duration, timeout time.Duration) {
+	run, ok := startTaskRun(dep, PurgeDeletedDataTaskName)
+	if !ok {
+		return
+	}
+	purgeDeletedDataTask(run, dep, batchSize, retentionDuration, timeout)
+}
+
+func purgeDeletedDataTask(run *taskRun, dep base.Dependency, batchSize int, retentionDuration time.Duration, timeout time.Duration) {
+	defer run.finish()
+	startTime := time.Now()
+	logTaskStart(
+		dep.Logger,
+		PurgeDeletedDataTaskName,
+		startTime,
+		log.Int("batch_size", batchSize),
+		log.String("retention_duration", retentionDuration.String()),
+		log.String("timeout", timeout.String()),
+	)
+	var err error
+	defer func() {
+		if panicInfo := recover(); panicInfo != nil {
+			run.addError(fmt.Errorf("%w: %+v", errTaskPanic, panicInfo))
+			recordTaskError(
+				dep.Logger, dep.Metric, PurgeDeletedDataTaskName,
+				errTaskPanic, "panic",
+				map[string]string{
+					"info":  fmt.Sprintf("%+v", panicInfo),
+					"stack": string(debug.Stack()),
+				},
+			)
+		} else if err == nil {
+			recordTaskSuccess(dep.Logger, dep.Metric, PurgeDeletedDataTaskName, time.Since(startTime))
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = purgeDeletedData(ctx, run, dep, batchSize, startTime.Add(-retentionDuration))
+}
+
+// purgeDeletedData removes rows soft deleted before deletedBefore table by table,
+// count of removed rows of each table is recorded by metric purge_deleted_data.success.purge_room_data.table_<index>.
+func purgeDeletedData(ctx context.Context, run *taskRun, dep base.Dependency, batchSize int, deletedBefore time.Time) error {
+	for tableIndex := 0; tableIndex < dep.DB.GetShardingCount(); tableIndex++ {
+		purgedCount := 0
+		for {
+			if err := ctx.Err(); err != nil {
+				run.addError(err)
+				recordTaskError(
+					dep.Logger, dep.Metric, PurgeDeletedDataTaskName,
+					err, "cancelled", map[string]string{"table_index": fmt.Sprintf("%d", tableIndex)})
+				return err
+			}
+			count, err := purgeDeletedRoomDataInTable(dep.DB, tableIndex, deletedBefore, batchSize)
+			if err != nil {
+				run.addError(err)
+				recordTaskError(
+					dep.Logger, dep.Metric, PurgeDeletedDataTaskName,
+					err, "purge_room_data", map[string]string{"table_index": fmt.Sprintf("%d", tableIndex)})
+				return err
+			}
+			run.addProcessed(tableIndex, count)
+			purgedCount += count
+			if count < batchSize {
+				break
+			}
+		}
+		recordTaskSuccessInfo(
+			dep.Logger, dep.Metric, PurgeDeletedDataTaskName,
+			fmt.Sprintf("purge_room_data.table_%d", tableIndex), purgedCount)
+	}
+	return nil
+}
+
+// purgeDeletedRoomDataInTable removes at most count rows soft deleted before deletedBefore from the table of tableIndex,
+// rows written again after they are selected are kept since their deleted_at are cleared.
+func purgeDeletedRoomDataInTable(db *base.DBCluster, tableIndex int, deletedBefore time.Time, count int) (int, error) {
+	tablePrefix := (&roomDataModelV2{}).GetTablePrefix()
+	var hashTags []*roomDataModelV2
+	subQuery, err := db.Models(&hashTags, tablePrefix, tableIndex)
+	if err != nil {
+		return 0, err
+	}
+	subQuery.Column("hash_tag").
+		Where("deleted_at < ?", deletedBefore).
+		OrderExpr("hash_tag ASC").
+		Limit(count)
+
+	var models []*roomDataModelV2
+	query, err := db.Models(&models, tablePrefix, tableIndex)
+	if err != nil {
+		return 0, err
+	}
+	result, err := query.Where("deleted_at < ?", deletedBefore).
+		Where("hash_tag in (?)", subQuery).
+		Delete()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeDeletedRoomDataInTable(t *testing.T) {
+	db := base.GetServerDependency().DB
+	currentTime := time.Now()
+	value := map[string]RedisValue{"{purge_deleted}a": {Type: stringType, Value: "a"}}
+	hashTag := "purge_deleted"
+	tableIndex := db.GetShardingIndex(hashTag)
+
+	// row is not deleted.
+	testInsertDataToDB(db, hashTag, value, time.Time{}, currentTime, currentTime, 0)
+	defer testCleanDataInDB(db, hashTag)
+	count, err := purgeDeletedRoomDataInTable(db, tableIndex, currentTime.Add(-time.Hour), 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	// row is deleted within retention duration.
+	testCleanDataInDB(db, hashTag)
+	testInsertDataToDB(db, hashTag, value, currentTime.Add(-time.Minute), currentTime, currentTime, 0)
+	count, err = purgeDeletedRoomDataInTable(db, tableIndex, currentTime.Add(-time.Hour), 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+	model, err := loadDataByID(db, hashTag)
+	assert.Nil(t, err)
+	assert.Nil(t, model)
+
+	// deleted value is not saved again when a key is written.
+	assert.Nil(t, upsertRoomDataKeyValue(
+		db, hashTag, "{purge_deleted}b", RedisValue{Type: stringType, Value: "b"}, currentTime.Add(time.Second), 1))
+	model, err = loadDataByID(db, hashTag)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]RedisValue{"{purge_deleted}b": {Type: stringType, Value: "b"}}, model.Value)
+
+	// row is deleted before retention duration.
+	testCleanDataInDB(db, hashTag)
+	testInsertDataToDB(db, hashTag, value, currentTime.Add(-2*time.Hour), currentTime, currentTime, 0)
+	count, err = purgeDeletedRoomDataInTable(db, tableIndex, currentTime.Add(-time.Hour), 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	// row is removed, so it could be inserted again.
+	assert.Nil(t, testInsertDataToDB(db, hashTag, value, time.Time{}, currentTime, currentTime, 0))
+	model, err = loadDataByID(db, hashTag)
+	assert.Nil(t, err)
+	assert.Equal(t, value, model.Value)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"bytepower_room/utility"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"go.uber.org/ratelimit"
+)
+
+const PurgeExpiredKeysTaskName = "purge_expired_keys"
+
+// expired keys in room_data_v2 are only filtered when loaded, the task removes them from db.
+// select * from table where deleted_at is NULL and hash_tag > ? and value has expired keys order by hash_tag limit ?;
+// update table set value = value - key1 - key2, version = version + 1 where hash_tag = "xxx" and version = xxx;
+// update table set deleted_at = now, version = version + 1 where hash_tag = "xxx" and version = xxx;
+func PurgeExpiredKeysTask(dep base.Dependency, batchSize int, rateLimitPerSecond int, timeout time.Duration) {
+	run, ok := startTaskRun(dep, PurgeExpiredKeysTaskName)
+	if !ok {
+		return
+	}
+	purgeExpiredKeysTask(run, dep, batchSize, rateLimitPerSecond, timeout)
+}
+
+func purgeExpiredKeysTask(run *taskRun, dep base.Dependency, batchSize int, rateLimitPerSecond int, timeout time.Duration) {
+	defer run.finish()
+	startTime := time.Now()
+	logTaskStart(
+		dep.Logger,
+		PurgeExpiredKeysTaskName,
+		startTime,
+		log.Int("batch_size", batchSize),
+		log.Int("limit", rateLimitPerSecond),
+		log.String("timeout", timeout.String()),
+	)
+	var err error
+	defer func() {
+		if panicInfo := recover(); panicInfo != nil {
+			run.addError(fmt.Errorf("%w: %+v", errTaskPanic, panicInfo))
+			recordTaskError(
+				dep.Logger, dep.Metric, PurgeExpiredKeysTaskName,
+				errTaskPanic, "panic",
+				map[string]string{
+					"info":  fmt.Sprintf("%+v", panicInfo),
+					"stack": string(debug.Stack()),
+				},
+			)
+		} else if err == nil {
+			recordTaskSuccess(dep.Logger, dep.Metric, PurgeExpiredKeysTaskName, time.Since(startTime))
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = purgeExpiredKeys(ctx, run, dep, batchSize, ratelimit.New(rateLimitPerSecond))
+}
+
+func purgeExpiredKeys(ctx context.Context, run *taskRun, dep base.Dependency, batchSize int, limiter ratelimit.Limiter) error {
+	for tableIndex := 0; tableIndex < dep.DB.GetShardingCount(); tableIndex++ {
+		lastHashTag := ""
+		for {
+			if err := ctx.Err(); err != nil {
+				run.addError(err)
+				recordTaskError(
+					dep.Logger, dep.Metric, PurgeExpiredKeysTaskName,
+					err, "cancelled", map[string]string{"table_index": fmt.Sprintf("%d", tableIndex)})
+				return err
+			}
+			now := time.Now()
+			models, err := loadRoomDataWithExpiredKeys(dep.DB, tableIndex, lastHashTag, batchSize, now)
+			if err != nil {
+				run.addError(err)
+				recordTaskError(
+					dep.Logger, dep.Metric, PurgeExpiredKeysTaskName,
+					err, "load_room_data", map[string]string{"table_index": fmt.Sprintf("%d", tableIndex)})
+				return err
+			}
+			purgedKeyCount := 0
+			deletedHashTagCount := 0
+			for _, model := range models {
+				limiter.Take()
+				keyCount, deleted, err := purgeExpiredKeysInRoomData(dep.DB, model, now)
+				if err != nil {
+					run.addError(fmt.Errorf("hash_tag=%s, %w", model.HashTag, err))
+					recordTaskError(
+						dep.Logger, dep.Metric, PurgeExpiredKeysTaskName,
+						err, "purge_room_data", map[string]string{"hash_tag": model.HashTag})
+					if errors.Is(err, errNoRowsUpdated) {
+						continue
+					}
+					return err
+				}
+				purgedKeyCount += keyCount
+				if deleted {
+					deletedHashTagCount++
+				}
+			}
+			run.addProcessed(tableIndex, len(models))
+			recordTaskSuccessInfo(dep.Logger, dep.Metric, PurgeExpiredKeysTaskName, "purge_key", purgedKeyCount)
+			recordTaskSuccessInfo(dep.Logger, dep.Metric, PurgeExpiredKeysTaskName, "delete_hash_tag", deletedHashTagCount)
+			if len(models) < batchSize {
+				break
+			}
+			lastHashTag = models[len(models)-1].HashTag
+		}
+	}
+	return nil
+}
+
+func loadRoomDataWithExpiredKeys(
+	db *base.DBCluster, tableIndex int, lastHashTag string, count int, t time.Time) ([]*roomDataModelV2, error) {
+	var models []*roomDataModelV2
+	tablePrefix := (&roomDataModelV2{}).GetTablePrefix()
+	query, err := db.Models(&models, tablePrefix, tableIndex)
+	if err != nil {
+		return nil, err
+	}
+	query.Where("deleted_at is NULL").
+		Where(
+			"exists (select 1 from jsonb_each(value) as v where (v.value->>'expire_ts')::bigint between 1 and ?)",
+			utility.TimestampInMS(t))
+	if lastHashTag != "" {
+		query.Where("hash_tag > ?", lastHashTag)
+	}
+	if err := query.OrderExpr("hash_tag ASC").Limit(count).Select(); err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return models, nil
+}
+
+// purgeExpiredKeysInRoomData removes expired keys from room data,
+// the row is soft deleted if all keys are expired.
+// It updates the row only if version is not changed, so no row lock is held while processing.
+func purgeExpiredKeysInRoomData(db *base.DBCluster, model *roomDataModelV2, t time.Time) (int, bool, error) {
+	expiredKeys := make([]interface{}, 0)
+	for key, value := range model.Value {
+		if value.IsExpired(t) {
+			expiredKeys = append(expiredKeys, key)
+		}
+	}
+	if len(expiredKeys) == 0 {
+		return 0, false, nil
+	}
+	query, err := db.Model(model)
+	if err != nil {
+		return 0, false, err
+	}
+	deleted := len(expiredKeys) == len(model.Value)
+	if deleted {
+		query.Set("deleted_at=?", t)
+	} else {
+		expr := "value=value"
+		for range expiredKeys {
+			expr = expr + "-?"
+		}
+		query.Set(expr, expiredKeys...)
+	}
+	result, err := query.Set("updated_at=?", t).
+		Set("version=?", model.Version+1).
+		WherePK().
+		Where("version=?", model.Version).
+		Update()
+	if err != nil {
+		return 0, false, err
+	}
+	if result.RowsAffected() == 0 {
+		return 0, false, errNoRowsUpdated
+	}
+	return len(expiredKeys), deleted, nil
+}
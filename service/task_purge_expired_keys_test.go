@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeExpiredKeysInRoomData(t *testing.T) {
+	db := base.GetServerDependency().DB
+	currentTime := time.Now()
+	expiredTs := currentTime.Add(-time.Minute).Unix() * 1000
+	validTs := currentTime.Add(time.Hour).Unix() * 1000
+
+	// no expired keys
+	hashTag := "purge_hash_tag1"
+	value := map[string]RedisValue{
+		"{purge_hash_tag1}a": {Type: stringType, Value: "a", ExpireTs: 0},
+		"{purge_hash_tag1}b": {Type: stringType, Value: "b", ExpireTs: validTs},
+	}
+	testInsertDataToDB(db, hashTag, value, time.Time{}, currentTime, currentTime, 0)
+	defer testCleanDataInDB(db, hashTag)
+	model, _ := loadDataByID(db, hashTag)
+	count, deleted, err := purgeExpiredKeysInRoomData(db, model, currentTime)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+	assert.False(t, deleted)
+
+	// part of keys are expired
+	hashTag = "purge_hash_tag2"
+	value = map[string]RedisValue{
+		"{purge_hash_tag2}a": {Type: stringType, Value: "a", ExpireTs: 0},
+		"{purge_hash_tag2}b": {Type: stringType, Value: "b", ExpireTs: expiredTs},
+		"{purge_hash_tag2}c": {Type: stringType, Value: "c", ExpireTs: expiredTs},
+	}
+	testInsertDataToDB(db, hashTag, value, time.Time{}, currentTime, currentTime, 0)
+	defer testCleanDataInDB(db, hashTag)
+	model, _ = loadDataByID(db, hashTag)
+	count, deleted, err = purgeExpiredKeysInRoomData(db, model, currentTime)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	assert.False(t, deleted)
+	model, _ = loadDataByID(db, hashTag)
+	assert.Equal(t, 1, model.Version)
+	assert.Equal(t, 1, len(model.Value))
+	assert.Contains(t, model.Value, "{purge_hash_tag2}a")
+
+	// all keys are expired
+	hashTag = "purge_hash_tag3"
+	value = map[string]RedisValue{
+		"{purge_hash_tag3}a": {Type: stringType, Value: "a", ExpireTs: expiredTs},
+	}
+	testInsertDataToDB(db, hashTag, value, time.Time{}, currentTime, currentTime, 0)
+	defer testCleanDataInDB(db, hashTag)
+	model, _ = loadDataByID(db, hashTag)
+	count, deleted, err = purgeExpiredKeysInRoomData(db, model, currentTime)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.True(t, deleted)
+	model, err = loadDataByID(db, hashTag)
+	assert.Nil(t, err)
+	assert.Nil(t, model)
+
+	// version is changed by others
+	hashTag = "purge_hash_tag4"
+	value = map[string]RedisValue{
+		"{purge_hash_tag4}a": {Type: stringType, Value: "a", ExpireTs: expiredTs},
+	}
+	testInsertDataToDB(db, hashTag, value, time.Time{}, currentTime, currentTime, 0)
+	defer testCleanDataInDB(db, hashTag)
+	model, _ = loadDataByID(db, hashTag)
+	model.Version = 10
+	_, _, err = purgeExpiredKeysInRoomData(db, model, currentTime)
+	assert.True(t, errors.Is(err, errNoRowsUpdated))
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// TaskRunStat is stats of the current or the last run of a task.
+type TaskRunStat struct {
+	Name              string      `json:"name"`
+	Running           bool        `json:"running"`
+	StartTime         time.Time   `json:"start_time"`
+	Duration          string      `json:"duration"`
+	ProcessedPerShard map[int]int `json:"processed_per_shard"`
+	Errors            []string    `json:"errors"`
+}
+
+// taskRunMaxErrorCount limits errors kept in stats of a task run.
+const taskRunMaxErrorCount = 100
+
+type taskRun struct {
+	mutex sync.Mutex
+	stat  TaskRunStat
+	// lock is released when the run is finished, it is nil for runs which are not started by startTaskRun.
+	lock *taskRunLock
+}
+
+func (run *taskRun) addProcessed(tableIndex int, count int) {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	run.stat.ProcessedPerShard[tableIndex] += count
+}
+
+func (run *taskRun) addError(err error) {
+	if err == nil {
+		return
+	}
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if len(run.stat.Errors) < taskRunMaxErrorCount {
+		run.stat.Errors = append(run.stat.Errors, err.Error())
+	}
+}
+
+func (run *taskRun) finish() {
+	run.mutex.Lock()
+	run.stat.Running = false
+	run.stat.Duration = time.Since(run.stat.StartTime).String()
+	run.mutex.Unlock()
+	taskRuns.finish(run.stat.Name)
+	if run.lock != nil {
+		run.lock.release()
+	}
+}
+
+func (run *taskRun) getStat() TaskRunStat {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	stat := run.stat
+	stat.ProcessedPerShard = make(map[int]int, len(run.stat.ProcessedPerShard))
+	for index, count := range run.stat.ProcessedPerShard {
+		stat.ProcessedPerShard[index] = count
+	}
+	stat.Errors = append([]string{}, run.stat.Errors...)
+	if stat.Running {
+		stat.Duration = time.Since(stat.StartTime).String()
+	}
+	return stat
+}
+
+// taskRunManager records runs of tasks, a task can not run concurrently.
+type taskRunManager struct {
+	mutex   sync.Mutex
+	running map[string]bool
+	runs    map[string]*taskRun
+}
+
+var taskRuns = &taskRunManager{
+	running: make(map[string]bool),
+	runs:    make(map[string]*taskRun),
+}
+
+func (manager *taskRunManager) start(name string) (*taskRun, bool) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if manager.running[name] {
+		return nil, false
+	}
+	run := &taskRun{
+		stat: TaskRunStat{
+			Name:              name,
+			Running:           true,
+			StartTime:         time.Now(),
+			ProcessedPerShard: make(map[int]int),
+			Errors:            make([]string, 0),
+		},
+	}
+	manager.running[name] = true
+	manager.runs[name] = run
+	return run, true
+}
+
+func (manager *taskRunManager) finish(name string) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	delete(manager.running, name)
+}
+
+func (manager *taskRunManager) getStat(name string) (TaskRunStat, bool) {
+	manager.mutex.Lock()
+	run, ok := manager.runs[name]
+	manager.mutex.Unlock()
+	if !ok {
+		return TaskRunStat{}, false
+	}
+	return run.getStat(), true
+}
+
+const (
+	taskRunLockDuration        = time.Minute
+	taskRunLockRefreshInterval = 20 * time.Second
+)
+
+// refreshTaskRunLockScript extends the lock only if it is still held by the run.
+var refreshTaskRunLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseTaskRunLockScript deletes the lock only if it is still held by the run.
+var releaseTaskRunLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+// taskRunLock is a lock in redis held by a run of a task, so that a task runs on one instance at a time,
+// whether the run is scheduled or triggered by task admin server.
+// Scheduled jobs are also coordinated by the scheduler, which only decides the instance of each scheduled time,
+// it does not know about runs triggered by task admin server.
+// The lock expires after taskRunLockDuration, it is refreshed while the run is running.
+type taskRunLock struct {
+	redisCluster *redis.ClusterClient
+	key          string
+	token        string
+	stopCh       chan struct{}
+}
+
+func getTaskRunLockKey(name string) string {
+	return fmt.Sprintf("room:task:{%s}:_l", name)
+}
+
+func acquireTaskRunLock(redisCluster *redis.ClusterClient, name string) (*taskRunLock, bool, error) {
+	hostname, _ := os.Hostname()
+	lock := &taskRunLock{
+		redisCluster: redisCluster,
+		key:          getTaskRunLockKey(name),
+		token:        fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		stopCh:       make(chan struct{}),
+	}
+	locked, err := redisCluster.SetNX(contextTODO, lock.key, lock.token, taskRunLockDuration).Result()
+	if err != nil || !locked {
+		return nil, false, err
+	}
+	go lock.refresh()
+	return lock, true, nil
+}
+
+func (lock *taskRunLock) refresh() {
+	ticker := time.NewTicker(taskRunLockRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lock.stopCh:
+			return
+		case <-ticker.C:
+			refreshTaskRunLockScript.Run(
+				contextTODO, lock.redisCluster, []string{lock.key},
+				lock.token, taskRunLockDuration.Milliseconds())
+		}
+	}
+}
+
+func (lock *taskRunLock) release() {
+	close(lock.stopCh)
+	releaseTaskRunLockScript.Run(contextTODO, lock.redisCluster, []string{lock.key}, lock.token)
+}
+
+// startTaskRun starts a run of task name if the task is not running on any instance.
+func startTaskRun(dep base.Dependency, name string) (*taskRun, bool) {
+	lock, ok, err := acquireTaskRunLock(dep.Redis, name)
+	if err != nil {
+		dep.Logger.Error("acquire task run lock error", log.String("task", name), log.Error(err))
+		return nil, false
+	}
+	if !ok {
+		dep.Logger.Info("task is running on other instance, skip", log.String("task", name))
+		return nil, false
+	}
+	run, ok := taskRuns.start(name)
+	if !ok {
+		dep.Logger.Info("task is running, skip", log.String("task", name))
+		// the lock of the running run has expired, release the lock acquired by this run.
+		lock.release()
+		return nil, false
+	}
+	run.lock = lock
+	return run, true
+}
@@ -23,6 +23,15 @@ var errTaskPanic = errors.New("task panic")
 // select * from table where status = "syncing";
 // update table set status = "synced", syncedAt = time.Now() where hash_tag = "xxx" and version = xx
 func SyncKeysTask(dep base.Dependency, upsertTryTimes int, noWrittenDuration time.Duration, rateLimitPerSecond int) {
+	run, ok := startTaskRun(dep, SyncKeysTaskName)
+	if !ok {
+		return
+	}
+	syncKeysTask(run, dep, upsertTryTimes, noWrittenDuration, rateLimitPerSecond)
+}
+
+func syncKeysTask(run *taskRun, dep base.Dependency, upsertTryTimes int, noWrittenDuration time.Duration, rateLimitPerSecond int) {
+	defer run.finish()
 	startTime := time.Now()
 	logTaskStart(
 		dep.Logger,
@@ -45,6 +54,7 @@ func SyncKeysTask(dep base.Dependency, upsertTryTimes int, noWrittenDuration tim
 			}
 			info["info"] = fmt.Sprintf("%+v", panicInfo)
 			info["stack"] = string(debug.Stack())
+			run.addError(fmt.Errorf("%w: %+v", errTaskPanic, panicInfo))
 			recordTaskError(
 				dep.Logger, dep.Metric, SyncKeysTaskName,
 				errTaskPanic, "panic", info,
@@ -71,6 +81,7 @@ func SyncKeysTask(dep base.Dependency, upsertTryTimes int, noWrittenDuration tim
 			// dbWhereCondition{column: "status", operator: "=?", parameter: HashTagKeysStatusNeedSynced},
 			// dbWhereCondition{column: "written_at", operator: "<=?", parameter: writtenAt})
 			if loadErr != nil {
+				run.addError(loadErr)
 				recordTaskError(dep.Logger, dep.Metric, SyncKeysTaskName, loadErr, "load_hash_tag_keys", nil)
 				err = loadErr
 				return
@@ -85,6 +96,13 @@ func SyncKeysTask(dep base.Dependency, upsertTryTimes int, noWrittenDuration tim
 				ratelimitBucket.Take()
 				lastModel = model
 				if err = syncRoomData(dep.DB, dep.Redis, model, time.Now(), upsertTryTimes); err != nil {
+					// status of hash tag is kept as need_synced, it is synced again by the next run.
+					if errors.Is(err, errRoomDataSavedToWAL) {
+						err = nil
+						dep.Metric.MetricIncrease(metricSyncKeysWALSaved)
+						continue
+					}
+					run.addError(fmt.Errorf("hash_tag=%s, %w", model.HashTag, err))
 					if isRetryErrorForUpdateInTx(err) {
 						recordTaskError(
 							dep.Logger, dep.Metric,
@@ -117,6 +135,7 @@ func SyncKeysTask(dep base.Dependency, upsertTryTimes int, noWrittenDuration tim
 				log.Int("table_index", tableIndex),
 				log.String("condition", strings.Join(conditionStrs, " and ")),
 			)
+			run.addProcessed(tableIndex, processCount)
 			metricName := fmt.Sprintf("%s.success.sync_hash_tag", SyncKeysTaskName)
 			dep.Metric.MetricCount(metricName, processCount)
 		}
@@ -133,10 +152,13 @@ func syncRoomData(db *base.DBCluster, redisCluster *redis.ClusterClient, model *
 	return nil
 }
 
+// syncHashTagKeys saves values of keys in redis to db,
+// values are saved to wal if wal is enabled and db is unreachable, errRoomDataSavedToWAL is returned in this case.
 func syncHashTagKeys(db *base.DBCluster, redisCluster *redis.ClusterClient, hashTag string, keys []string, tryTimes int) error {
+	readAt := time.Now()
 	value := make(map[string]RedisValue)
 	for _, key := range keys {
-		v, err := getValueFromRedis(redisCluster, key)
+		v, err := getValueFromRedis(contextTODO, redisCluster, key)
 		if err != nil {
 			return err
 		}
@@ -145,6 +167,12 @@ func syncHashTagKeys(db *base.DBCluster, redisCluster *redis.ClusterClient, hash
 		}
 	}
 	err := upsertRoomDataValue(db, hashTag, value, tryTimes)
+	if err != nil && syncKeysWAL != nil && isDBConnectivityError(err) {
+		if walErr := syncKeysWAL.append(newWriteAheadLogEntries(hashTag, keys, value, readAt)); walErr != nil {
+			return fmt.Errorf("%w, save to wal error %s", err, walErr.Error())
+		}
+		return errRoomDataSavedToWAL
+	}
 	if err != nil {
 		return err
 	}
@@ -153,11 +181,11 @@ func syncHashTagKeys(db *base.DBCluster, redisCluster *redis.ClusterClient, hash
 
 const redisKeyNotExist = "none"
 
-func getValueFromRedis(redisCluster *redis.ClusterClient, key string) (RedisValue, error) {
+func getValueFromRedis(ctx context.Context, redisCluster *redis.ClusterClient, key string) (RedisValue, error) {
 	currentTime := time.Now()
 
 	// Get redis key type.
-	keyType, err := redisCluster.Type(contextTODO, key).Result()
+	keyType, err := redisCluster.Type(ctx, key).Result()
 	if err != nil {
 		return RedisValue{}, err
 	}
@@ -165,7 +193,13 @@ func getValueFromRedis(redisCluster *redis.ClusterClient, key string) (RedisValu
 		return RedisValue{}, nil
 	}
 
-	keyValue, err := serializeValue(redisCluster, keyType, key)
+	var keyValue string
+	var fieldExpireTs map[string]int64
+	if keyType == hashType {
+		keyValue, fieldExpireTs, err = serializeHashValue(ctx, redisCluster, key)
+	} else {
+		keyValue, err = serializeValue(ctx, redisCluster, keyType, key)
+	}
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return RedisValue{}, nil
@@ -173,7 +207,7 @@ func getValueFromRedis(redisCluster *redis.ClusterClient, key string) (RedisValu
 		return RedisValue{}, err
 	}
 
-	ttl, err := redisCluster.PTTL(contextTODO, key).Result()
+	ttl, err := redisCluster.PTTL(ctx, key).Result()
 	if err != nil {
 		return RedisValue{}, err
 	}
@@ -182,7 +216,7 @@ func getValueFromRedis(redisCluster *redis.ClusterClient, key string) (RedisValu
 		return RedisValue{}, nil
 	}
 
-	value := RedisValue{Type: keyType, Value: keyValue}
+	value := RedisValue{Type: keyType, Value: keyValue, FieldExpireTs: fieldExpireTs}
 
 	if ttl > 0 {
 		value.ExpireTs = utility.TimestampInMS(currentTime.Add(ttl))
@@ -190,8 +224,11 @@ func getValueFromRedis(redisCluster *redis.ClusterClient, key string) (RedisValu
 	return value, nil
 }
 
-func serializeValue(redisCluster *redis.ClusterClient, keyType, key string) (string, error) {
-	value, err := getValueByKeyFromRedis(redisCluster, keyType, key)
+func serializeValue(ctx context.Context, redisCluster *redis.ClusterClient, keyType, key string) (string, error) {
+	if keyType == streamType {
+		return serializeStreamValue(ctx, redisCluster, key)
+	}
+	value, err := getValueByKeyFromRedis(ctx, redisCluster, keyType, key)
 	if err != nil {
 		return "", err
 	}
@@ -207,26 +244,106 @@ func serializeValue(redisCluster *redis.ClusterClient, keyType, key string) (str
 	}
 }
 
-func getValueByKeyFromRedis(redisCluster *redis.ClusterClient, keyType, key string) ([]string, error) {
+// serializeHashValue returns fields and values of hash key and expire timestamps of fields with expiration,
+// fields expired after they are scanned are skipped.
+func serializeHashValue(ctx context.Context, redisCluster *redis.ClusterClient, key string) (string, map[string]int64, error) {
+	items, err := serializeNonStringValue(ctx, redisCluster, key, hashType)
+	if err != nil {
+		return "", nil, err
+	}
+	fields := make([]string, 0, len(items)/2)
+	for i := 0; i < len(items)-1; i += 2 {
+		fields = append(fields, items[i])
+	}
+	expireTs, err := getHashFieldsExpireTs(ctx, redisCluster, key, fields)
+	if err != nil {
+		return "", nil, err
+	}
+	var fieldExpireTs map[string]int64
+	values := make([]string, 0, len(items))
+	for i := 0; i < len(items)-1; i += 2 {
+		ts, ok := expireTs[items[i]]
+		if !ok {
+			values = append(values, items[i], items[i+1])
+			continue
+		}
+		if ts == hashFieldNotExist {
+			continue
+		}
+		if fieldExpireTs == nil {
+			fieldExpireTs = make(map[string]int64)
+		}
+		fieldExpireTs[items[i]] = ts
+		values = append(values, items[i], items[i+1])
+	}
+	if len(values) == 0 {
+		return "", nil, redis.Nil
+	}
+	v, err := json.Marshal(values)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(v), fieldExpireTs, nil
+}
+
+// hashFieldNotExist is returned by hpexpiretime for fields which do not exist,
+// and hashFieldNoExpiration is returned for fields without expiration.
+const (
+	hashFieldNotExist     = -2
+	hashFieldNoExpiration = -1
+)
+
+// getHashFieldsExpireTs returns expire timestamps in milliseconds of fields by hpexpiretime in batches,
+// fields which do not exist are hashFieldNotExist, and fields without expiration are absent.
+// Nothing is returned if redis does not support expiration of fields, which is supported since redis 7.4.
+func getHashFieldsExpireTs(ctx context.Context, redisCluster *redis.ClusterClient, key string, fields []string) (map[string]int64, error) {
+	expireTs := make(map[string]int64)
+	for start := 0; start < len(fields); start += loadAndSaveStepSize {
+		end := start + loadAndSaveStepSize
+		if end > len(fields) {
+			end = len(fields)
+		}
+		args := make([]interface{}, 0, 4+end-start)
+		args = append(args, "hpexpiretime", key, "fields", end-start)
+		for _, field := range fields[start:end] {
+			args = append(args, field)
+		}
+		cmd := redis.NewIntSliceCmd(ctx, args...)
+		if err := redisCluster.Process(ctx, cmd); err != nil {
+			if strings.HasPrefix(err.Error(), "ERR unknown command") {
+				return map[string]int64{}, nil
+			}
+			return nil, err
+		}
+		for index, ts := range cmd.Val() {
+			if ts != hashFieldNoExpiration {
+				expireTs[fields[start+index]] = ts
+			}
+		}
+	}
+	return expireTs, nil
+}
+
+func getValueByKeyFromRedis(ctx context.Context, redisCluster *redis.ClusterClient, keyType, key string) ([]string, error) {
 	var result []string
 	var err error
 	switch keyType {
 	case stringType:
-		value, stringErr := redisCluster.Get(contextTODO, key).Result()
+		value, stringErr := redisCluster.Get(ctx, key).Result()
 		err = stringErr
 		result = []string{value}
 	case listType, hashType, zsetType, setType:
-		result, err = serializeNonStringValue(redisCluster, key, keyType)
+		result, err = serializeNonStringValue(ctx, redisCluster, key, keyType)
 	default:
 		err = fmt.Errorf("not supported key type: %s", keyType)
 	}
 	return result, err
 }
 
-func serializeNonStringValue(redisCluster *redis.ClusterClient, key, keyType string) ([]string, error) {
+func serializeNonStringValue(ctx context.Context, redisCluster *redis.ClusterClient, key, keyType string) ([]string, error) {
 	// list type
 	if keyType == listType {
-		items, err := redisCluster.LRange(contextTODO, key, 0, -1).Result()
+		items, err := redisCluster.LRange(ctx, key, 0, -1).Result()
 		if err != nil {
 			return nil, err
 		}
@@ -249,7 +366,7 @@ func serializeNonStringValue(redisCluster *redis.ClusterClient, key, keyType str
 	var items []string
 	var cursor uint64 = 0
 	for {
-		itemsInScan, c, err := scan(contextTODO, key, cursor, "", scanStep).Result()
+		itemsInScan, c, err := scan(ctx, key, cursor, "", scanStep).Result()
 		if err != nil {
 			return nil, err
 		}
@@ -264,3 +381,26 @@ func serializeNonStringValue(redisCluster *redis.ClusterClient, key, keyType str
 	}
 	return items, nil
 }
+
+func serializeStreamValue(ctx context.Context, redisCluster *redis.ClusterClient, key string) (string, error) {
+	messages, err := redisCluster.XRange(ctx, key, "-", "+").Result()
+	if err != nil {
+		return "", err
+	}
+	if len(messages) == 0 {
+		return "", redis.Nil
+	}
+	entries := make([]streamEntry, 0, len(messages))
+	for _, message := range messages {
+		fields := make(map[string]string, len(message.Values))
+		for field, value := range message.Values {
+			fields[field] = utility.AnyToString(value)
+		}
+		entries = append(entries, streamEntry{ID: message.ID, Fields: fields})
+	}
+	v, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(v), nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytepower_room/base"
+	"bytepower_room/commands"
 	"bytepower_room/utility"
 	"context"
 	"math"
@@ -81,7 +82,7 @@ func TestSerializeNonStringValue(t *testing.T) {
 		defer testEmptyKeysInRedis(key)
 		values := testGenerateListValueForRedis(item.count)
 		redisCluster.RPush(testContextTODO, key, values...).Result()
-		result, err := serializeNonStringValue(redisCluster, key, listType)
+		result, err := serializeNonStringValue(context.TODO(), redisCluster, key, listType)
 		assert.Nil(t, err)
 		assert.Equal(t, len(values), len(result))
 		for index, value := range values {
@@ -119,7 +120,7 @@ func TestSerializeNonStringValue(t *testing.T) {
 		defer testEmptyKeysInRedis(key)
 		values := testGenerateHashValueForRedis(item.count)
 		redisCluster.HSet(testContextTODO, key, values).Result()
-		result, err := serializeNonStringValue(redisCluster, key, hashType)
+		result, err := serializeNonStringValue(context.TODO(), redisCluster, key, hashType)
 		assert.Nil(t, err)
 		assert.Equal(t, len(values)*2, len(result))
 		for index := 0; index < len(result)-1; index += 2 {
@@ -159,7 +160,7 @@ func TestSerializeNonStringValue(t *testing.T) {
 		defer testEmptyKeysInRedis(key)
 		values := testGenerateSetValueForRedis(item.count)
 		redisCluster.SAdd(testContextTODO, key, values...).Result()
-		result, err := serializeNonStringValue(redisCluster, key, setType)
+		result, err := serializeNonStringValue(context.TODO(), redisCluster, key, setType)
 		assert.Nil(t, err)
 		assert.Equal(t, len(values), len(result))
 		for _, item := range values {
@@ -197,7 +198,7 @@ func TestSerializeNonStringValue(t *testing.T) {
 		defer testEmptyKeysInRedis(key)
 		values, m := testGenerateZSetValueForRedis(item.count)
 		redisCluster.ZAdd(testContextTODO, key, values...).Result()
-		result, err := serializeNonStringValue(redisCluster, key, zsetType)
+		result, err := serializeNonStringValue(context.TODO(), redisCluster, key, zsetType)
 		assert.Nil(t, err)
 		assert.Equal(t, len(m)*2, len(result))
 		for i := 0; i < len(result)-1; i += 2 {
@@ -209,11 +210,50 @@ func TestSerializeNonStringValue(t *testing.T) {
 	}
 }
 
+func TestHashFieldExpiration(t *testing.T) {
+	redisCluster := base.GetServerDependency().Redis
+	ctx := context.TODO()
+	key, loadedKey := "{hash_field_expiration}a", "{hash_field_expiration}b"
+	defer testEmptyKeysInRedis(key, loadedKey)
+	testEmptyKeysInRedis(key, loadedKey)
+
+	assert.Nil(t, redisCluster.HSet(ctx, key, "a", "1", "b", "2", "c", "3").Err())
+	command, err := commands.ParseCommand([]string{"hexpire", key, "100", "fields", "2", "a", "not_exist"})
+	assert.Nil(t, err)
+	result := commands.ExecuteCommand(redisCluster, command)
+	if result.DataType == commands.ErrorRespType {
+		t.Skip("expiration of hash fields is not supported by redis", result.String())
+	}
+	replies := result.Value.([]commands.RESPData)
+	assert.Equal(t, int64(1), replies[0].Value)
+	assert.Equal(t, int64(-2), replies[1].Value)
+
+	// expiration of fields is saved with value.
+	value, err := getValueFromRedis(context.TODO(), redisCluster, key)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(value.FieldExpireTs))
+	assert.True(t, value.FieldExpireTs["a"] > utility.TimestampInMS(time.Now()))
+
+	// expiration of fields is restored, expired fields are removed.
+	value.FieldExpireTs["b"] = utility.TimestampInMS(time.Now().Add(-time.Second))
+	assert.Nil(t, loadKeyToRedis(ctx, redisCluster, loadedKey, value))
+	assert.Equal(t, map[string]string{"a": "1", "c": "3"}, redisCluster.HGetAll(ctx, loadedKey).Val())
+	command, _ = commands.ParseCommand([]string{"httl", loadedKey, "fields", "2", "a", "c"})
+	ttls := commands.ExecuteCommand(redisCluster, command).Value.([]commands.RESPData)
+	assert.True(t, ttls[0].Value.(int64) > 90)
+	assert.Equal(t, int64(-1), ttls[1].Value)
+
+	// the key is removed if all fields expire.
+	value.FieldExpireTs = map[string]int64{"a": 1, "b": 1, "c": 1}
+	assert.Nil(t, loadKeyToRedis(ctx, redisCluster, loadedKey, value))
+	assert.Equal(t, int64(0), redisCluster.Exists(ctx, loadedKey).Val())
+}
+
 func TestGetValueFromRedis(t *testing.T) {
 	redisCluster := base.GetTaskDependency().Redis
 	//get not exist key
 	key := "{a}not_exist"
-	value, err := getValueFromRedis(redisCluster, key)
+	value, err := getValueFromRedis(context.TODO(), redisCluster, key)
 	assert.Nil(t, err)
 	assert.Equal(t, "", value.Type)
 	assert.Equal(t, "", value.Value)
@@ -224,7 +264,7 @@ func TestGetValueFromRedis(t *testing.T) {
 	stringValue := "abc"
 	defer redisCluster.Del(context.TODO(), key)
 	redisCluster.Set(context.TODO(), key, stringValue, 0)
-	value, err = getValueFromRedis(redisCluster, key)
+	value, err = getValueFromRedis(context.TODO(), redisCluster, key)
 	assert.Nil(t, err)
 	assert.Equal(t, stringType, value.Type)
 	assert.Equal(t, stringValue, value.Value)
@@ -235,7 +275,7 @@ func TestGetValueFromRedis(t *testing.T) {
 	stringValue = "abcd"
 	defer redisCluster.Del(context.TODO(), key)
 	redisCluster.Set(context.TODO(), key, stringValue, 10*time.Second)
-	value, err = getValueFromRedis(redisCluster, key)
+	value, err = getValueFromRedis(context.TODO(), redisCluster, key)
 	assert.Nil(t, err)
 	assert.Equal(t, stringType, value.Type)
 	assert.Equal(t, stringValue, value.Value)
@@ -246,7 +286,7 @@ func TestGetValueFromRedis(t *testing.T) {
 	listValue := []interface{}{"a", "b", "c", "d"}
 	defer redisCluster.Del(context.TODO(), key)
 	redisCluster.RPush(context.TODO(), key, listValue...)
-	value, err = getValueFromRedis(redisCluster, key)
+	value, err = getValueFromRedis(context.TODO(), redisCluster, key)
 	assert.Nil(t, err)
 	assert.Equal(t, listType, value.Type)
 
@@ -261,7 +301,7 @@ func TestGetValueFromRedis(t *testing.T) {
 	setValue := []interface{}{"a", "b", "c", "d"}
 	defer redisCluster.Del(context.TODO(), key)
 	redisCluster.SAdd(context.TODO(), key, setValue...)
-	value, err = getValueFromRedis(redisCluster, key)
+	value, err = getValueFromRedis(context.TODO(), redisCluster, key)
 	assert.Nil(t, err)
 	assert.Equal(t, setType, value.Type)
 
@@ -279,7 +319,7 @@ func TestGetValueFromRedis(t *testing.T) {
 	hashValue := map[string]interface{}{"a": "b", "c": "d", "e": "f"}
 	defer redisCluster.Del(context.TODO(), key)
 	redisCluster.HSet(context.TODO(), key, hashValue)
-	value, err = getValueFromRedis(redisCluster, key)
+	value, err = getValueFromRedis(context.TODO(), redisCluster, key)
 	assert.Nil(t, err)
 	assert.Equal(t, hashType, value.Type)
 
@@ -306,7 +346,7 @@ func TestGetValueFromRedis(t *testing.T) {
 		redisCluster.ZAdd(context.TODO(), key, &z)
 	}
 	redisCluster.Expire(context.TODO(), key, 10*time.Second)
-	value, err = getValueFromRedis(redisCluster, key)
+	value, err = getValueFromRedis(context.TODO(), redisCluster, key)
 	assert.Nil(t, err)
 	assert.Equal(t, zsetType, value.Type)
 
@@ -322,4 +362,23 @@ func TestGetValueFromRedis(t *testing.T) {
 	}
 
 	assert.Greater(t, value.ExpireTs, int64(0))
+
+	// get a stream key
+	key = "{b}stream"
+	defer redisCluster.Del(context.TODO(), key)
+	redisCluster.Do(context.TODO(), "xadd", key, "1-1", "a", "1", "b", "2")
+	redisCluster.Do(context.TODO(), "xadd", key, "2-1", "c", "3")
+	value, err = getValueFromRedis(context.TODO(), redisCluster, key)
+	assert.Nil(t, err)
+	assert.Equal(t, streamType, value.Type)
+	entries := make([]streamEntry, 0)
+	assert.Nil(t, json.Unmarshal([]byte(value.Value), &entries))
+	assert.Equal(
+		t,
+		[]streamEntry{
+			{ID: "1-1", Fields: map[string]string{"a": "1", "b": "2"}},
+			{ID: "2-1", Fields: map[string]string{"c": "3"}},
+		},
+		entries)
+	assert.Equal(t, int64(0), value.ExpireTs)
 }
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"bytepower_room/utility"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	metricSyncKeysWALSaved       = SyncKeysTaskName + ".wal.saved"
+	metricSyncKeysWALReplayed    = SyncKeysTaskName + ".wal.replayed"
+	metricSyncKeysWALReplayError = SyncKeysTaskName + ".wal.replay_error"
+)
+
+var (
+	errSyncKeysWALFull = errors.New("sync keys wal is full")
+	// errRoomDataSavedToWAL means room data is saved to wal instead of db, it is saved to db when wal is replayed.
+	errRoomDataSavedToWAL = errors.New("room data is saved to wal")
+)
+
+// syncKeysWAL is the local write ahead log of sync keys task, it is nil if wal is disabled.
+var syncKeysWAL *writeAheadLog
+
+// InitSyncKeysWAL enables wal of sync keys task and replays wal to db every replay interval.
+func InitSyncKeysWAL(dep base.Dependency, config base.SyncKeyTaskWALConfig, upsertTryTimes int) {
+	if !config.Enabled {
+		return
+	}
+	syncKeysWAL = newWriteAheadLog(config.FilePath, config.MaxFileSize)
+	go syncKeysWAL.runReplayer(dep, config.ReplayInterval, upsertTryTimes)
+}
+
+type writeAheadLogEntry struct {
+	HashTag string `json:"hash_tag"`
+	Key     string `json:"key"`
+	// Value is zero if key does not exist.
+	Value RedisValue `json:"value"`
+	// WrittenAt is the timestamp in milliseconds when value is read from redis.
+	WrittenAt int64 `json:"written_at"`
+}
+
+func newWriteAheadLogEntries(hashTag string, keys []string, value map[string]RedisValue, t time.Time) []writeAheadLogEntry {
+	entries := make([]writeAheadLogEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, writeAheadLogEntry{
+			HashTag:   hashTag,
+			Key:       key,
+			Value:     value[key],
+			WrittenAt: utility.TimestampInMS(t),
+		})
+	}
+	return entries
+}
+
+// writeAheadLog saves entries as json lines in file,
+// entries are replayed in the order they are appended so that writes to a key are kept in order.
+type writeAheadLog struct {
+	filePath    string
+	maxFileSize int64
+	mutex       *sync.Mutex
+}
+
+func newWriteAheadLog(filePath string, maxFileSize int64) *writeAheadLog {
+	return &writeAheadLog{filePath: filePath, maxFileSize: maxFileSize, mutex: &sync.Mutex{}}
+}
+
+// append saves entries to file and flushes file to disk,
+// entries are rejected if file size exceeds maxFileSize after they are appended.
+func (wal *writeAheadLog) append(entries []writeAheadLogEntry) error {
+	var buffer bytes.Buffer
+	for _, entry := range entries {
+		bs, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buffer.Write(bs)
+		buffer.WriteByte('\n')
+	}
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	file, err := os.OpenFile(wal.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size()+int64(buffer.Len()) > wal.maxFileSize {
+		return fmt.Errorf("%w, size=%d, max_file_size=%d", errSyncKeysWALFull, info.Size(), wal.maxFileSize)
+	}
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// replay upserts entries to db in order and stops at the first failed entry,
+// replayed entries are removed from file, file is removed if all entries are replayed.
+// Lines which can not be decoded are partially written lines, they are dropped.
+// It returns count of replayed entries.
+func (wal *writeAheadLog) replay(db *base.DBCluster, tryTimes int) (int, error) {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	content, err := ioutil.ReadFile(wal.filePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	lines := bytes.Split(content, []byte{'\n'})
+	for index, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		entry := writeAheadLogEntry{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		writtenAt := time.Unix(utility.GetSecondsAndNanoSecondsFromTsInMs(entry.WrittenAt))
+		if err := upsertRoomDataKeyValue(db, entry.HashTag, entry.Key, entry.Value, writtenAt, tryTimes); err != nil {
+			if rewriteErr := wal.rewrite(bytes.Join(lines[index:], []byte{'\n'})); rewriteErr != nil {
+				return count, rewriteErr
+			}
+			return count, err
+		}
+		count++
+	}
+	return count, os.Remove(wal.filePath)
+}
+
+// rewrite replaces content of file with a temporary file so that file is never partially written.
+func (wal *writeAheadLog) rewrite(content []byte) error {
+	tempFilePath := wal.filePath + ".tmp"
+	file, err := os.OpenFile(tempFilePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFilePath, wal.filePath)
+}
+
+func (wal *writeAheadLog) runReplayer(dep base.Dependency, interval time.Duration, tryTimes int) {
+	wal.replayAndRecord(dep, tryTimes)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		wal.replayAndRecord(dep, tryTimes)
+	}
+}
+
+func (wal *writeAheadLog) replayAndRecord(dep base.Dependency, tryTimes int) {
+	count, err := wal.replay(dep.DB, tryTimes)
+	if count > 0 {
+		dep.Logger.Info(
+			metricSyncKeysWALReplayed,
+			log.String("file", wal.filePath),
+			log.Int("count", count),
+		)
+		dep.Metric.MetricCount(metricSyncKeysWALReplayed, count)
+	}
+	if err != nil {
+		dep.Logger.Error(
+			metricSyncKeysWALReplayError,
+			log.String("file", wal.filePath),
+			log.Error(err),
+		)
+		dep.Metric.MetricIncrease(metricSyncKeysWALReplayError)
+	}
+}
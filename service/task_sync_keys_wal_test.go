@@ -0,0 +1,81 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/utility"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAheadLogAppend(t *testing.T) {
+	directory, err := ioutil.TempDir("", "room_wal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+
+	wal := newWriteAheadLog(filepath.Join(directory, "wal.jsonl"), 200)
+	entries := newWriteAheadLogEntries(
+		"wal", []string{"{wal}a", "{wal}b"},
+		map[string]RedisValue{"{wal}a": {Type: stringType, Value: "a"}}, time.Now(),
+	)
+	assert.Equal(t, RedisValue{}, entries[1].Value)
+	assert.Nil(t, wal.append(entries[:1]))
+	err = wal.append(entries)
+	assert.True(t, errors.Is(err, errSyncKeysWALFull))
+}
+
+func TestWriteAheadLogReplay(t *testing.T) {
+	db := base.GetServerDependency().DB
+	directory, err := ioutil.TempDir("", "room_wal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+
+	hashTag := "wal_hash_tag"
+	a, b, c := "{wal_hash_tag}a", "{wal_hash_tag}b", "{wal_hash_tag}c"
+	// entries are replayed after the sync failed, they are written before now.
+	currentTime := time.Now()
+	updatedAt := currentTime.Add(-time.Hour)
+	testInsertDataToDB(
+		db, hashTag, map[string]RedisValue{a: {Type: stringType, Value: "a"}},
+		time.Time{}, updatedAt, updatedAt, 0,
+	)
+	defer testCleanDataInDB(db, hashTag)
+
+	wal := newWriteAheadLog(filepath.Join(directory, "wal.jsonl"), 1024)
+	// keys of one sync are written at the same time.
+	firstWrittenAt := currentTime.Add(-30 * time.Minute)
+	assert.Nil(t, wal.append(newWriteAheadLogEntries(
+		hashTag, []string{a, b, c},
+		map[string]RedisValue{b: {Type: stringType, Value: "b1"}, c: {Type: stringType, Value: "c1"}}, firstWrittenAt,
+	)))
+	// the later entries of the same hash tag are replayed after the former ones.
+	secondWrittenAt := currentTime.Add(-20 * time.Minute)
+	assert.Nil(t, wal.append(newWriteAheadLogEntries(
+		hashTag, []string{b, c},
+		map[string]RedisValue{b: {Type: stringType, Value: "b2"}}, secondWrittenAt,
+	)))
+	count, err := wal.replay(db, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, count)
+	model, _ := loadDataByID(db, hashTag)
+	assert.Equal(t, map[string]RedisValue{b: {Type: stringType, Value: "b2"}}, model.Value)
+	assert.Equal(t, utility.TimestampInMS(secondWrittenAt), utility.TimestampInMS(model.UpdatedAt))
+	_, err = os.Stat(wal.filePath)
+	assert.True(t, os.IsNotExist(err))
+
+	// entries older than room data are skipped.
+	assert.Nil(t, wal.append(newWriteAheadLogEntries(
+		hashTag, []string{b},
+		map[string]RedisValue{b: {Type: stringType, Value: "b0"}}, firstWrittenAt,
+	)))
+	count, err = wal.replay(db, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	model, _ = loadDataByID(db, hashTag)
+	assert.Equal(t, map[string]RedisValue{b: {Type: stringType, Value: "b2"}}, model.Value)
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
 	"bytepower_room/commands"
 	"sync"
+	"time"
 
 	"github.com/tidwall/redcon"
 )
@@ -49,3 +52,65 @@ func (manager *TransactionManager) transactionCount() int {
 	defer manager.mutex.Unlock()
 	return len(manager.connTransMap)
 }
+
+// reapTimeoutTransactions handles transactions created before (now - timeout), it returns the count of them.
+// Transactions in multi or watch are expired but kept for their connections, so exec returns EXECABORT error
+// instead of running commands out of a transaction. Other transactions are closed and removed.
+func (manager *TransactionManager) reapTimeoutTransactions(dep base.Dependency, timeout time.Duration, now time.Time) int {
+	manager.mutex.Lock()
+	timeoutTransactions := make(map[redcon.Conn]*commands.Transaction)
+	for conn, tx := range manager.connTransMap {
+		if now.Sub(tx.CreatedAt()) > timeout {
+			timeoutTransactions[conn] = tx
+		}
+	}
+	manager.mutex.Unlock()
+
+	count := 0
+	for conn, tx := range timeoutTransactions {
+		if tx.IsWatching() {
+			if !tx.Expire() {
+				continue
+			}
+		} else {
+			manager.mutex.Lock()
+			if manager.connTransMap[conn] == tx {
+				delete(manager.connTransMap, conn)
+			}
+			manager.mutex.Unlock()
+			tx.Close(commands.TransactionCloseReasonTimeout)
+		}
+		count++
+		dep.Logger.Warn(
+			"close timeout transaction",
+			log.String("remote_addr", conn.RemoteAddr()),
+			log.String("status", string(tx.Status())),
+			log.String("created_at", tx.CreatedAt().String()),
+			log.String("timeout", timeout.String()),
+		)
+		dep.Metric.MetricIncrease("transaction.timeout")
+	}
+	return count
+}
+
+func (manager *TransactionManager) runTimeoutReaper(dep base.Dependency, timeout time.Duration, stopCh chan struct{}) {
+	ticker := time.NewTicker(timeoutReaperInterval(timeout))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			manager.reapTimeoutTransactions(dep, timeout, time.Now())
+		}
+	}
+}
+
+const maxTimeoutReaperInterval = time.Second
+
+func timeoutReaperInterval(timeout time.Duration) time.Duration {
+	if interval := timeout / 2; interval < maxTimeoutReaperInterval {
+		return interval
+	}
+	return maxTimeoutReaperInterval
+}
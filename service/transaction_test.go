@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
+)
+
+type testConn struct {
+	redcon.Conn
+	addr string
+}
+
+func (conn *testConn) RemoteAddr() string {
+	return conn.addr
+}
+
+func TestReapTimeoutTransactions(t *testing.T) {
+	dep := base.GetServerDependency()
+	timeout := 10 * time.Millisecond
+
+	conn1 := &testConn{addr: "127.0.0.1:10001"}
+	tx1 := commands.NewTransaction(dep)
+	transactionManager.addTransaction(conn1, tx1)
+
+	time.Sleep(2 * timeout)
+
+	conn2 := &testConn{addr: "127.0.0.1:10002"}
+	tx2 := commands.NewTransaction(dep)
+	transactionManager.addTransaction(conn2, tx2)
+
+	count := transactionManager.reapTimeoutTransactions(dep, timeout, time.Now())
+	assert.Equal(t, 1, count)
+	assert.True(t, tx1.IsClosed())
+	assert.Nil(t, transactionManager.getTransaction(conn1))
+	assert.False(t, tx2.IsClosed())
+	assert.Equal(t, tx2, transactionManager.getTransaction(conn2))
+
+	transactionManager.removeTransaction(conn2, commands.TransactionCloseReasonConnClosed)
+	assert.True(t, tx2.IsClosed())
+}
+
+func TestReapTimeoutTransactionInMulti(t *testing.T) {
+	dep := base.GetServerDependency()
+	timeout := 10 * time.Millisecond
+	process := func(tx *commands.Transaction, args ...string) commands.RESPData {
+		command, err := commands.ParseCommand(args)
+		assert.Nil(t, err)
+		return tx.Process(command)
+	}
+
+	conn := &testConn{addr: "127.0.0.1:10003"}
+	tx := commands.NewTransaction(dep)
+	transactionManager.addTransaction(conn, tx)
+	defer transactionManager.removeTransaction(conn, commands.TransactionCloseReasonConnClosed)
+	assert.Equal(t, "s:OK", process(tx, "multi").String())
+	assert.Equal(t, "s:QUEUED", process(tx, "set", "{reap}a", "1").String())
+
+	time.Sleep(2 * timeout)
+	assert.Equal(t, 1, transactionManager.reapTimeoutTransactions(dep, timeout, time.Now()))
+	// the transaction is kept for the connection and it is reaped only once.
+	assert.Equal(t, tx, transactionManager.getTransaction(conn))
+	assert.True(t, tx.IsStarted())
+	assert.Equal(t, 0, transactionManager.reapTimeoutTransactions(dep, timeout, time.Now()))
+
+	assert.Equal(t, "err:ERR transaction is timeout", process(tx, "set", "{reap}b", "1").String())
+	assert.Equal(t, "err:EXECABORT Transaction discarded because of previous errors.", process(tx, "exec").String())
+	assert.True(t, tx.IsClosed())
+}
+
+func TestTimeoutReaperInterval(t *testing.T) {
+	assert.Equal(t, 5*time.Millisecond, timeoutReaperInterval(10*time.Millisecond))
+	assert.Equal(t, time.Second, timeoutReaperInterval(time.Minute))
+}
+
+// test commands:
+// tx1: watch {tx_create}1
+// room data of hash tag tx_create is created in db
+// tx1: multi
+// tx1: set {tx_create}1 b
+// tx1: exec
+func TestTransactionFailWithDBRoomDataCreated(t *testing.T) {
+	dep := base.GetServerDependency()
+	hashTag := "tx_create"
+	key := "{tx_create}1"
+	defer testEmptyRoomDataRecordInDatabase(hashTag)
+	defer testEmptyKeysInRedis(key)
+	process := func(tx *commands.Transaction, args ...string) commands.RESPData {
+		command, err := commands.ParseCommand(args)
+		assert.Nil(t, err)
+		return tx.Process(command)
+	}
+
+	version, err := loadRoomDataVersion(dep.DB, hashTag)
+	assert.Nil(t, err)
+	assert.Equal(t, commands.RoomDataVersionNotExist, version)
+
+	tx1 := commands.NewTransaction(dep)
+	tx1.SetRoomDataVersionLoader(newRoomDataVersionLoader(dep.DB))
+	assert.Equal(t, "s:OK", process(tx1, "watch", key).String())
+
+	testInsertRoomData(hashTag, map[string]RedisValue{})
+	version, err = loadRoomDataVersion(dep.DB, hashTag)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, version)
+
+	assert.Equal(t, "s:OK", process(tx1, "multi").String())
+	assert.Equal(t, "s:QUEUED", process(tx1, "set", key, "b").String())
+	assert.Equal(t, commands.RESPData{DataType: commands.NilArrayRespType}, process(tx1, "exec"))
+	assert.True(t, tx1.IsClosed())
+
+	exists, err := dep.Redis.Exists(contextTODO, key).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), exists)
+}
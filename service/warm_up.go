@@ -0,0 +1,108 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/base/log"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// loadWarmUpHashTags loads at most count hash tags accessed at or after accessedAfter from all tables,
+// hash tags are ordered by accessed_at desc.
+func loadWarmUpHashTags(db *base.DBCluster, count int, accessedAfter time.Time) ([]*roomHashTagKeys, error) {
+	models := make([]*roomHashTagKeys, 0)
+	for index := 0; index < db.GetShardingCount(); index++ {
+		var tableModels []*roomHashTagKeys
+		query, err := newHashTagKeysQueryByCondition(
+			db, &tableModels, index, dbWhereCondition{column: "accessed_at", operator: ">=?", parameter: accessedAfter})
+		if err != nil {
+			return nil, err
+		}
+		err = query.Order("accessed_at DESC").Limit(count).Select()
+		if err != nil && !errors.Is(err, pg.ErrNoRows) {
+			return nil, err
+		}
+		models = append(models, tableModels...)
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		return models[i].AccessedAt.After(models[j].AccessedAt)
+	})
+	if len(models) > count {
+		models = models[:count]
+	}
+	return models, nil
+}
+
+// warmUpHashTags loads keys of the most recently accessed hash tags into redis by config.Concurrency workers,
+// it returns count of loaded and failed hash tags, hash tags left are skipped when ctx is done.
+func warmUpHashTags(ctx context.Context, dep base.Dependency, config base.WarmUpConfig, t time.Time) (int64, int64, error) {
+	models, err := loadWarmUpHashTags(dep.DB, config.Count, t.Add(-config.AccessedWithin))
+	if err != nil {
+		return 0, 0, err
+	}
+	var loaded, failed int64
+	var wg sync.WaitGroup
+	modelCh := make(chan *roomHashTagKeys)
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for model := range modelCh {
+				// accessed time of the record is used, so that preloading is not taken as an access of the hash tag.
+				_, err := loadWithContext(ctx, dep, model.HashTag, model.AccessedAt, base.HashTagAccessModeRead)
+				if err != nil {
+					atomic.AddInt64(&failed, 1)
+					dep.Logger.Error("warm up load hash tag error", log.String("hash_tag", model.HashTag), log.Error(err))
+					continue
+				}
+				atomic.AddInt64(&loaded, 1)
+			}
+		}()
+	}
+	for _, model := range models {
+		if ctx.Err() != nil {
+			break
+		}
+		modelCh <- model
+	}
+	close(modelCh)
+	wg.Wait()
+	return loaded, failed, nil
+}
+
+// warmUp preloads hash tags in background, it is stopped when the service is stopped.
+func (service *RoomService) warmUp() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-service.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	startTime := time.Now()
+	metric := service.dep.Metric
+	loaded, failed, err := warmUpHashTags(ctx, service.dep, service.config.WarmUp, startTime)
+	if err != nil {
+		metric.MetricIncrease("error.warm_up")
+		service.logWithAddressAndPid(log.LevelError, "error.server.warm_up", log.Error(err))
+		return
+	}
+	metric.MetricCount("warm_up.loaded", loaded)
+	metric.MetricCount("warm_up.failed", failed)
+	metric.MetricTimeDuration("warm_up.duration", time.Since(startTime))
+	service.logWithAddressAndPid(
+		log.LevelInfo, "server.warm_up",
+		log.Int64("loaded", loaded),
+		log.Int64("failed", failed),
+		log.String("duration", time.Since(startTime).String()),
+	)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmUpHashTags(t *testing.T) {
+	dep := base.GetServerDependency()
+	// access time of other tests' records are out of the window.
+	startTime := time.Now().Add(24 * time.Hour)
+	hashTagAccessTimes := map[string]time.Time{
+		"warm_up1": startTime.Add(-time.Minute),
+		"warm_up2": startTime.Add(-2 * time.Minute),
+		"warm_up3": startTime.Add(-3 * time.Minute),
+		"warm_up4": startTime.Add(-2 * time.Hour),
+	}
+	for hashTag, accessTime := range hashTagAccessTimes {
+		key := "{" + hashTag + "}a"
+		defer testEmptyHashTagKeysRecordInDB(hashTag)
+		defer testEmptyRoomDataRecordInDatabase(hashTag)
+		defer testEmptyKeysInRedis(key)
+		testEmptyHashTagKeysRecordInDB(hashTag)
+		testEmptyRoomDataRecordInDatabase(hashTag)
+		testEmptyKeysInRedis(key)
+		testCleanLocalloadedCache(hashTag)
+		testSetMetaKeyCleaned(hashTag)
+		event, _ := base.NewHashTagEvent(hashTag, []string{key}, base.HashTagAccessModeRead, accessTime)
+		assert.Nil(t, upsertHashTagKeysRecordByEvent(context.TODO(), dep.DB, event, accessTime))
+		testInsertRoomData(hashTag, map[string]RedisValue{key: {Type: stringType, Value: hashTag}})
+	}
+
+	models, err := loadWarmUpHashTags(dep.DB, 3, startTime.Add(-time.Hour))
+	assert.Nil(t, err)
+	hashTags := make([]string, 0, len(models))
+	for _, model := range models {
+		hashTags = append(hashTags, model.HashTag)
+	}
+	assert.Equal(t, []string{"warm_up1", "warm_up2", "warm_up3"}, hashTags)
+
+	config := base.WarmUpConfig{Enabled: true, Count: 2, AccessedWithin: time.Hour, Concurrency: 2}
+	loaded, failed, err := warmUpHashTags(context.TODO(), dep, config, startTime)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), loaded)
+	assert.Equal(t, int64(0), failed)
+	for hashTag, expected := range map[string]bool{"warm_up1": true, "warm_up2": true, "warm_up3": false, "warm_up4": false} {
+		n, err := dep.Redis.Exists(context.TODO(), "{"+hashTag+"}a").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, n == 1, hashTag)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	loaded, failed, err = warmUpHashTags(ctx, dep, config, startTime)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), loaded+failed)
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"bytepower_room/commands"
+	"context"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// processZRangeStoreCommand stores range of source key to destination key,
+// keys of both hash tags have been loaded before, so source and destination could have different hash tags.
+// destination key is replaced with source watched, and is synced to db by write event of its hash tag like other written keys.
+func (service *RoomService) processZRangeStoreCommand(ctx context.Context, command *commands.ZRangeStoreCommand) commands.RESPData {
+	redisCluster := service.dep.Redis
+	count := 0
+	err := storeWithWatchedSources(ctx, redisCluster, []string{command.Source()}, command.Destination(), func() (storeFunc, error) {
+		cmd := command.RangeCmd()
+		if err := redisCluster.Process(ctx, cmd); err != nil {
+			return nil, err
+		}
+		items := cmd.Val()
+		if command.ByLex() {
+			var err error
+			if items, err = getZSetMemberScores(ctx, redisCluster, command.Source(), items); err != nil {
+				return nil, err
+			}
+		}
+		count = len(items) / 2
+		return func(pipeline redis.Pipeliner) {
+			storeZSetItems(ctx, pipeline, command.Destination(), items)
+		}, nil
+	})
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(count)}
+}
+
+// getZSetMemberScores returns members followed by their scores of key in a pipeline,
+// members removed after range is queried are skipped.
+func getZSetMemberScores(ctx context.Context, redisCluster *redis.ClusterClient, key string, members []string) ([]string, error) {
+	if len(members) == 0 {
+		return members, nil
+	}
+	pipeline := redisCluster.Pipeline()
+	cmds := make([]*redis.StringCmd, 0, len(members))
+	for _, member := range members {
+		cmd := redis.NewStringCmd(ctx, "zscore", key, member)
+		_ = pipeline.Process(ctx, cmd)
+		cmds = append(cmds, cmd)
+	}
+	if _, err := pipeline.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	items := make([]string, 0, 2*len(members))
+	for index, cmd := range cmds {
+		if cmd.Err() != nil {
+			continue
+		}
+		items = append(items, members[index], cmd.Val())
+	}
+	return items, nil
+}
+
+// processZSetAggregateCommand computes diff, intersection or union of sorted sets in memory,
+// keys of all hash tags have been loaded before, so keys could have different hash tags.
+// Sets are taken as sorted sets with score 1 like redis, result of store variants replaces destination key with sources watched,
+// which is synced to db by write event of its hash tag.
+func (service *RoomService) processZSetAggregateCommand(ctx context.Context, command *commands.ZSetAggregateCommand) commands.RESPData {
+	redisCluster := service.dep.Redis
+	aggregate := func() ([]zsetMember, error) {
+		sets, err := getZSetsFromRedis(ctx, redisCluster, command.Keys())
+		if err != nil {
+			return nil, err
+		}
+		return aggregateZSets(command.Operation(), command.Aggregate(), sets, command.Weights()), nil
+	}
+	if command.Destination() == "" {
+		members, err := aggregate()
+		if err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		replies := make([]commands.RESPData, 0, 2*len(members))
+		for _, member := range members {
+			replies = append(replies, commands.RESPData{DataType: commands.BulkStringRespType, Value: member.member})
+			if command.WithScores() {
+				replies = append(replies, commands.RESPData{DataType: commands.BulkStringRespType, Value: formatZSetScore(member.score)})
+			}
+		}
+		return commands.RESPData{DataType: commands.ArrayRespType, Value: replies}
+	}
+	count := 0
+	err := storeWithWatchedSources(ctx, redisCluster, command.Keys(), command.Destination(), func() (storeFunc, error) {
+		members, err := aggregate()
+		if err != nil {
+			return nil, err
+		}
+		count = len(members)
+		items := make([]string, 0, 2*len(members))
+		for _, member := range members {
+			items = append(items, member.member, formatZSetScore(member.score))
+		}
+		return func(pipeline redis.Pipeliner) {
+			storeZSetItems(ctx, pipeline, command.Destination(), items)
+		}, nil
+	})
+	if err != nil {
+		return commands.ConvertErrorToRESPData(err)
+	}
+	return commands.RESPData{DataType: commands.IntegerRespType, Value: int64(count)}
+}
+
+type zsetMember struct {
+	member string
+	score  float64
+}
+
+// getZSetsFromRedis returns members and scores of keys in pipelines, keys which do not exist are empty,
+// WRONGTYPE error is returned if a key is neither a sorted set nor a set.
+func getZSetsFromRedis(ctx context.Context, redisCluster *redis.ClusterClient, keys []string) ([]map[string]float64, error) {
+	pipeline := redisCluster.Pipeline()
+	typeCmds := make([]*redis.StatusCmd, 0, len(keys))
+	for _, key := range keys {
+		typeCmds = append(typeCmds, pipeline.Type(ctx, key))
+	}
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return nil, err
+	}
+	pipeline = redisCluster.Pipeline()
+	valueCmds := make([]redis.Cmder, len(keys))
+	for index, key := range keys {
+		switch typeCmds[index].Val() {
+		case zsetType:
+			valueCmds[index] = pipeline.ZRangeWithScores(ctx, key, 0, -1)
+		case setType:
+			valueCmds[index] = pipeline.SMembers(ctx, key)
+		case redisKeyNotExist:
+		default:
+			return nil, errWrongType
+		}
+	}
+	if _, err := pipeline.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	sets := make([]map[string]float64, len(keys))
+	for index, cmd := range valueCmds {
+		set := make(map[string]float64)
+		switch c := cmd.(type) {
+		case *redis.ZSliceCmd:
+			for _, z := range c.Val() {
+				set[z.Member.(string)] = z.Score
+			}
+		case *redis.StringSliceCmd:
+			for _, member := range c.Val() {
+				set[member] = 1
+			}
+		}
+		sets[index] = set
+	}
+	return sets, nil
+}
+
+// aggregateZSets returns members of operation on sets ordered by score and member like redis,
+// scores are multiplied by weights and aggregated by aggregate, NaN scores are taken as 0.
+func aggregateZSets(operation, aggregate string, sets []map[string]float64, weights []float64) []zsetMember {
+	weighted := func(score, weight float64) float64 {
+		score = score * weight
+		if math.IsNaN(score) {
+			return 0
+		}
+		return score
+	}
+	result := make(map[string]float64)
+	switch operation {
+	case commands.ZSetAggregateDiff:
+	loop:
+		for member, score := range sets[0] {
+			for _, set := range sets[1:] {
+				if _, ok := set[member]; ok {
+					continue loop
+				}
+			}
+			result[member] = score
+		}
+	case commands.ZSetAggregateInter:
+		for member, score := range sets[0] {
+			result[member] = weighted(score, weights[0])
+		}
+		for index, set := range sets[1:] {
+			for member, score := range result {
+				value, ok := set[member]
+				if !ok {
+					delete(result, member)
+					continue
+				}
+				result[member] = aggregateZSetScore(aggregate, score, weighted(value, weights[index+1]))
+			}
+		}
+	case commands.ZSetAggregateUnion:
+		for index, set := range sets {
+			for member, value := range set {
+				score := weighted(value, weights[index])
+				if current, ok := result[member]; ok {
+					score = aggregateZSetScore(aggregate, current, score)
+				}
+				result[member] = score
+			}
+		}
+	}
+	members := make([]zsetMember, 0, len(result))
+	for member, score := range result {
+		members = append(members, zsetMember{member: member, score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score != members[j].score {
+			return members[i].score < members[j].score
+		}
+		return members[i].member < members[j].member
+	})
+	return members
+}
+
+func aggregateZSetScore(aggregate string, current, score float64) float64 {
+	switch aggregate {
+	case commands.ZSetAggregateMin:
+		return math.Min(current, score)
+	case commands.ZSetAggregateMax:
+		return math.Max(current, score)
+	}
+	// inf + -inf is NaN, which is taken as 0 like redis.
+	if sum := current + score; !math.IsNaN(sum) {
+		return sum
+	}
+	return 0
+}
+
+var errStoreSourcesChanged = errors.New("ERR source keys are changed by other clients while they are stored, please try again")
+
+const storeMaxTryTimes = 3
+
+// storeFunc writes destination key in a transaction.
+type storeFunc func(pipeline redis.Pipeliner)
+
+// storeWithWatchedSources runs compute with source keys watched in transactions of their slots,
+// and runs the returned storeFunc in a transaction of the slot of destination,
+// so that destination is not written with values of sources which are changed by other clients in the meantime.
+// A transaction of redis cluster could not cover keys of different slots, so sources in other slots
+// are checked by empty transactions just before destination is written.
+// It is retried if sources are changed, errStoreSourcesChanged is returned if all tries fail.
+func storeWithWatchedSources(ctx context.Context, redisCluster *redis.ClusterClient, sources []string, destination string, compute func() (storeFunc, error)) error {
+	// keys are grouped by slot, the group of destination is the first one.
+	slotKeys := [][]string{{destination}}
+	for _, key := range sources {
+		index := 0
+		for ; index < len(slotKeys); index++ {
+			if redis.AreKeysInSameSlot(slotKeys[index][0], key) {
+				break
+			}
+		}
+		if index == len(slotKeys) {
+			slotKeys = append(slotKeys, []string{})
+		}
+		slotKeys[index] = append(slotKeys[index], key)
+	}
+	var watch func(index int, sourceTxs []*redis.Tx) error
+	watch = func(index int, sourceTxs []*redis.Tx) error {
+		if index < len(slotKeys) {
+			return redisCluster.Watch(ctx, func(tx *redis.Tx) error {
+				return watch(index+1, append(sourceTxs, tx))
+			}, slotKeys[index]...)
+		}
+		return redisCluster.Watch(ctx, func(tx *redis.Tx) error {
+			store, err := compute()
+			if err != nil {
+				return err
+			}
+			for _, sourceTx := range sourceTxs {
+				// an empty transaction fails if its watched keys are changed.
+				if _, err := sourceTx.TxPipelined(ctx, func(pipeline redis.Pipeliner) error {
+					pipeline.Ping(ctx)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			_, err = tx.TxPipelined(ctx, func(pipeline redis.Pipeliner) error {
+				store(pipeline)
+				return nil
+			})
+			return err
+		}, slotKeys[0]...)
+	}
+	for i := 0; i < storeMaxTryTimes; i++ {
+		if err := watch(1, nil); !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return errStoreSourcesChanged
+}
+
+// storeSetMembers replaces key with a set of members, key is removed if members are empty.
+func storeSetMembers(ctx context.Context, pipeline redis.Pipeliner, key string, members []string) {
+	pipeline.Del(ctx, key)
+	for start := 0; start < len(members); start += loadAndSaveStepSize {
+		end := start + loadAndSaveStepSize
+		if end > len(members) {
+			end = len(members)
+		}
+		args := make([]interface{}, 0, end-start)
+		for _, member := range members[start:end] {
+			args = append(args, member)
+		}
+		pipeline.SAdd(ctx, key, args...)
+	}
+}
+
+// storeZSetItems replaces key with a sorted set of items, which are members followed by their scores,
+// key is removed if items are empty.
+func storeZSetItems(ctx context.Context, pipeline redis.Pipeliner, key string, items []string) {
+	pipeline.Del(ctx, key)
+	for start := 0; start < len(items); start += 2 * loadAndSaveStepSize {
+		end := start + 2*loadAndSaveStepSize
+		if end > len(items) {
+			end = len(items)
+		}
+		args := []interface{}{"zadd", key}
+		for index := start; index < end; index += 2 {
+			args = append(args, items[index+1], items[index])
+		}
+		pipeline.Do(ctx, args...)
+	}
+}
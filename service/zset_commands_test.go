@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bytepower_room/base"
+	"bytepower_room/commands"
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZRangeStoreCommandWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{dep: dep}
+	srcHashTag, dstHashTag := "zrangestore_src", "zrangestore_dst"
+	// source and destination are saved in different tables.
+	assert.NotEqual(t, dep.DB.GetShardingIndex(srcHashTag), dep.DB.GetShardingIndex(dstHashTag))
+	src, lexSrc, dst := "{zrangestore_src}zset", "{zrangestore_src}lex", "{zrangestore_dst}zset"
+	keys := []string{src, lexSrc, dst, "{zrangestore_src}string"}
+	for _, hashTag := range []string{srcHashTag, dstHashTag} {
+		defer testEmptyRoomDataRecordInDatabase(hashTag)
+		testCleanLocalloadedCache(hashTag)
+		testSetMetaKeyCleaned(hashTag)
+	}
+	defer testEmptyKeysInRedis(keys...)
+	testInsertRoomData(srcHashTag, map[string]RedisValue{
+		src:                       {Type: zsetType, Value: `["a","1","b","2","c","3","d","4"]`},
+		lexSrc:                    {Type: zsetType, Value: `["a","0","b","0","c","0"]`},
+		"{zrangestore_src}string": {Type: stringType, Value: "x"},
+	})
+	testInsertRoomData(dstHashTag, map[string]RedisValue{
+		dst: {Type: zsetType, Value: `["x","10"]`},
+	})
+
+	execute := func(args ...string) commands.RESPData {
+		command, err := commands.ParseCommand(args)
+		assert.Nil(t, err)
+		if err := preProcessCommand(dep, command, time.Now()); err != nil {
+			return commands.ConvertErrorToRESPData(err)
+		}
+		return service.processZRangeStoreCommand(context.TODO(), command.(*commands.ZRangeStoreCommand))
+	}
+	members := func() []redis.Z {
+		result, err := dep.Redis.ZRangeWithScores(context.TODO(), dst, 0, -1).Result()
+		assert.Nil(t, err)
+		return result
+	}
+
+	// existing destination is overwritten.
+	assert.Equal(t, "i:2", execute("zrangestore", dst, src, "1", "2").String())
+	assert.Equal(t, []redis.Z{{Score: 2, Member: "b"}, {Score: 3, Member: "c"}}, members())
+
+	assert.Equal(t, "i:3", execute("zrangestore", dst, src, "0", "2", "rev").String())
+	assert.Equal(t, []redis.Z{{Score: 2, Member: "b"}, {Score: 3, Member: "c"}, {Score: 4, Member: "d"}}, members())
+
+	assert.Equal(t, "i:2", execute("zrangestore", dst, src, "(1", "+inf", "byscore", "limit", "1", "5").String())
+	assert.Equal(t, []redis.Z{{Score: 3, Member: "c"}, {Score: 4, Member: "d"}}, members())
+
+	assert.Equal(t, "i:1", execute("zrangestore", dst, src, "+inf", "-inf", "byscore", "rev", "limit", "0", "1").String())
+	assert.Equal(t, []redis.Z{{Score: 4, Member: "d"}}, members())
+
+	assert.Equal(t, "i:2", execute("zrangestore", dst, lexSrc, "[b", "+", "bylex").String())
+	assert.Equal(t, []redis.Z{{Score: 0, Member: "b"}, {Score: 0, Member: "c"}}, members())
+
+	// destination is removed if range is empty.
+	assert.Equal(t, "i:0", execute("zrangestore", dst, src, "10", "20").String())
+	assert.Equal(t, int64(0), dep.Redis.Exists(context.TODO(), dst).Val())
+
+	assert.Equal(t, commands.ErrorRespType, execute("zrangestore", dst, "{zrangestore_src}string", "0", "-1").DataType)
+}
+
+func TestAggregateZSets(t *testing.T) {
+	sets := []map[string]float64{
+		{"a": 1, "b": 2, "c": 3, "inf": math.Inf(1)},
+		{"b": 10, "c": 20, "d": 30, "inf": math.Inf(-1)},
+		{"c": 100, "e": 0},
+	}
+	testCases := []struct {
+		operation string
+		aggregate string
+		sets      []map[string]float64
+		weights   []float64
+		members   []zsetMember
+	}{
+		{
+			commands.ZSetAggregateDiff, commands.ZSetAggregateSum, sets, []float64{1, 1, 1},
+			[]zsetMember{{"a", 1}},
+		},
+		{
+			commands.ZSetAggregateDiff, commands.ZSetAggregateSum, sets[:1], []float64{1},
+			[]zsetMember{{"a", 1}, {"b", 2}, {"c", 3}, {"inf", math.Inf(1)}},
+		},
+		{
+			commands.ZSetAggregateInter, commands.ZSetAggregateSum, sets[:2], []float64{1, 1},
+			[]zsetMember{{"inf", 0}, {"b", 12}, {"c", 23}},
+		},
+		{
+			commands.ZSetAggregateInter, commands.ZSetAggregateSum, sets, []float64{1, 1, 1},
+			[]zsetMember{{"c", 123}},
+		},
+		{
+			commands.ZSetAggregateInter, commands.ZSetAggregateMin, sets[:2], []float64{2, 0.5},
+			[]zsetMember{{"inf", math.Inf(-1)}, {"b", 4}, {"c", 6}},
+		},
+		{
+			commands.ZSetAggregateInter, commands.ZSetAggregateMax, sets[:2], []float64{2, 0.5},
+			[]zsetMember{{"b", 5}, {"c", 10}, {"inf", math.Inf(1)}},
+		},
+		{
+			commands.ZSetAggregateUnion, commands.ZSetAggregateSum, sets, []float64{1, 1, 1},
+			[]zsetMember{{"e", 0}, {"inf", 0}, {"a", 1}, {"b", 12}, {"d", 30}, {"c", 123}},
+		},
+		{
+			commands.ZSetAggregateUnion, commands.ZSetAggregateMax, sets, []float64{1, 2, -1},
+			[]zsetMember{{"e", 0}, {"a", 1}, {"b", 20}, {"c", 40}, {"d", 60}, {"inf", math.Inf(1)}},
+		},
+		{
+			commands.ZSetAggregateUnion, commands.ZSetAggregateMin, sets, []float64{0, 1, 1},
+			[]zsetMember{{"inf", math.Inf(-1)}, {"a", 0}, {"b", 0}, {"c", 0}, {"e", 0}, {"d", 30}},
+		},
+		{
+			commands.ZSetAggregateUnion, commands.ZSetAggregateSum, []map[string]float64{{}, {}}, []float64{1, 1},
+			[]zsetMember{},
+		},
+	}
+	for _, testCase := range testCases {
+		members := aggregateZSets(testCase.operation, testCase.aggregate, testCase.sets, testCase.weights)
+		assert.Equal(t, testCase.members, members, testCase.operation, testCase.aggregate, testCase.weights)
+	}
+}
+
+func TestZSetAggregateCommandsWithPersistedKeys(t *testing.T) {
+	dep := base.GetServerDependency()
+	service := &RoomService{dep: dep}
+	hashTag1, hashTag2 := "zaggregate1", "zaggregate2"
+	zset1, set1, zset2, dst := "{zaggregate1}zset", "{zaggregate1}set", "{zaggregate2}zset", "{zaggregate2}dst"
+	keys := []string{zset1, set1, zset2, dst, "{zaggregate1}string"}
+	for _, hashTag := range []string{hashTag1, hashTag2} {
+		defer testEmptyRoomDataRecordInDatabase(hashTag)
+		testCleanLocalloadedCache(hashTag)
+		testSetMetaKeyCleaned(hashTag)
+	}
+	defer testEmptyKeysInRedis(keys...)
+	testInsertRoomData(hashTag1, map[string]RedisValue{
+		zset1:                 {Type: zsetType, Value: `["a","1","b","2","c","3"]`},
+		set1:                  {Type: setType, Value: `["a","d"]`},
+		"{zaggregate1}string": {Type: stringType, Value: "x"},
+	})
+	testInsertRoomData(hashTag2, map[string]RedisValue{
+		zset2: {Type: zsetType, Value: `["b","10","c","20","e","0.5"]`},
+		dst:   {Type: stringType, Value: "x"},
+	})
+
+	execute := func(args ...string) string {
+		command, err := commands.ParseCommand(args)
+		assert.Nil(t, err)
+		if err := preProcessCommand(dep, command, time.Now()); err != nil {
+			return commands.ConvertErrorToRESPData(err).String()
+		}
+		return service.processZSetAggregateCommand(context.TODO(), command.(*commands.ZSetAggregateCommand)).String()
+	}
+	array := func(values ...string) string {
+		data := make([]commands.RESPData, 0, len(values))
+		for _, value := range values {
+			data = append(data, commands.RESPData{DataType: commands.BulkStringRespType, Value: value})
+		}
+		return commands.RESPData{DataType: commands.ArrayRespType, Value: data}.String()
+	}
+	members := func() []redis.Z {
+		result, err := dep.Redis.ZRangeWithScores(context.TODO(), dst, 0, -1).Result()
+		assert.Nil(t, err)
+		return result
+	}
+
+	assert.Equal(t, array("a"), execute("zdiff", "2", zset1, zset2))
+	assert.Equal(t, array("a", "1", "b", "2"), execute("zdiff", "2", zset1, "{zaggregate2}not_exist", "withscores"))
+	assert.Equal(t, array("b", "c"), execute("zinter", "2", zset1, zset2))
+	assert.Equal(t, array("b", "12", "c", "23"), execute("zinter", "2", zset1, zset2, "withscores"))
+	assert.Equal(
+		t,
+		array("b", "5", "c", "10"),
+		execute("zinter", "2", zset1, zset2, "weights", "2", "0.5", "aggregate", "max", "withscores"),
+	)
+	assert.Equal(
+		t,
+		array("d", "1", "e", "1", "a", "2", "b", "22", "c", "43"),
+		execute("zunion", "3", set1, zset1, zset2, "weights", "1", "1", "2", "withscores"),
+	)
+	assert.Equal(t, array("e", "a", "d", "b", "c"), execute("zunion", "3", zset1, zset2, set1, "aggregate", "min"))
+
+	// destination of a string is replaced.
+	assert.Equal(t, "i:2", execute("zinterstore", dst, "2", zset1, zset2, "aggregate", "min"))
+	assert.Equal(t, []redis.Z{{Score: 2, Member: "b"}, {Score: 3, Member: "c"}}, members())
+	assert.Equal(t, "i:1", execute("zdiffstore", dst, "3", zset1, zset2, "{zaggregate1}not_exist"))
+	assert.Equal(t, []redis.Z{{Score: 1, Member: "a"}}, members())
+	assert.Equal(t, "i:4", execute("zunionstore", dst, "2", zset1, set1, "weights", "1", "-1"))
+	assert.Equal(
+		t,
+		[]redis.Z{{Score: -1, Member: "d"}, {Score: 0, Member: "a"}, {Score: 2, Member: "b"}, {Score: 3, Member: "c"}},
+		members(),
+	)
+	// destination is removed if result is empty.
+	assert.Equal(t, "i:0", execute("zinterstore", dst, "2", zset2, set1))
+	assert.Equal(t, int64(0), dep.Redis.Exists(context.TODO(), dst).Val())
+
+	assert.Equal(t, commands.ConvertErrorToRESPData(errWrongType).String(), execute("zunion", "2", zset1, "{zaggregate1}string"))
+}
+
+func TestStoreWithWatchedSources(t *testing.T) {
+	redisCluster := base.GetServerDependency().Redis
+	source1, source2, destination := "{store_watch_a}s", "{store_watch_b}s", "{store_watch_c}d"
+	defer testEmptyKeysInRedis(source1, source2, destination)
+	testEmptyKeysInRedis(source1, source2, destination)
+	assert.Nil(t, redisCluster.SAdd(context.TODO(), source1, "a").Err())
+
+	// sources changed by other clients while they are computed are computed again.
+	tryTimes := 0
+	err := storeWithWatchedSources(context.TODO(), redisCluster, []string{source1, source2}, destination, func() (storeFunc, error) {
+		tryTimes++
+		members, err := redisCluster.SMembers(context.TODO(), source1).Result()
+		if err != nil {
+			return nil, err
+		}
+		if tryTimes == 1 {
+			assert.Nil(t, redisCluster.SAdd(context.TODO(), source2, "b").Err())
+		}
+		return func(pipeline redis.Pipeliner) {
+			storeSetMembers(context.TODO(), pipeline, destination, members)
+		}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, tryTimes)
+	assert.Equal(t, []string{"a"}, redisCluster.SMembers(context.TODO(), destination).Val())
+
+	tryTimes = 0
+	err = storeWithWatchedSources(context.TODO(), redisCluster, []string{source1}, destination, func() (storeFunc, error) {
+		tryTimes++
+		assert.Nil(t, redisCluster.SAdd(context.TODO(), source1, tryTimes).Err())
+		return func(pipeline redis.Pipeliner) {
+			storeSetMembers(context.TODO(), pipeline, destination, nil)
+		}, nil
+	})
+	assert.Equal(t, errStoreSourcesChanged, err)
+	assert.Equal(t, storeMaxTryTimes, tryTimes)
+	assert.Equal(t, []string{"a"}, redisCluster.SMembers(context.TODO(), destination).Val())
+}
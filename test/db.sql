@@ -135,7 +135,8 @@ CREATE TABLE public.room_hash_tag_keys_0 (
     created_at timestamp with time zone NOT NULL DEFAULT now(),
     updated_at timestamp with time zone NOT NULL DEFAULT now(),
     status character varying NOT NULL,
-    version bigint NOT NULL DEFAULT 0
+    version bigint NOT NULL DEFAULT 0,
+    frequency integer NOT NULL DEFAULT 0
 );
 
 ALTER TABLE ONLY public.room_hash_tag_keys_0
@@ -155,7 +156,8 @@ CREATE TABLE public.room_hash_tag_keys_1 (
     created_at timestamp with time zone NOT NULL DEFAULT now(),
     updated_at timestamp with time zone NOT NULL DEFAULT now(),
     status character varying NOT NULL,
-    version bigint NOT NULL DEFAULT 0
+    version bigint NOT NULL DEFAULT 0,
+    frequency integer NOT NULL DEFAULT 0
 );
 
 ALTER TABLE ONLY public.room_hash_tag_keys_1
@@ -175,7 +177,8 @@ CREATE TABLE public.room_hash_tag_keys_2 (
     created_at timestamp with time zone NOT NULL DEFAULT now(),
     updated_at timestamp with time zone NOT NULL DEFAULT now(),
     status character varying NOT NULL,
-    version bigint NOT NULL DEFAULT 0
+    version bigint NOT NULL DEFAULT 0,
+    frequency integer NOT NULL DEFAULT 0
 );
 
 ALTER TABLE ONLY public.room_hash_tag_keys_2
@@ -195,7 +198,8 @@ CREATE TABLE public.room_hash_tag_keys_3 (
     created_at timestamp with time zone NOT NULL DEFAULT now(),
     updated_at timestamp with time zone NOT NULL DEFAULT now(),
     status character varying NOT NULL,
-    version bigint NOT NULL DEFAULT 0
+    version bigint NOT NULL DEFAULT 0,
+    frequency integer NOT NULL DEFAULT 0
 );
 
 ALTER TABLE ONLY public.room_hash_tag_keys_3
@@ -215,7 +219,8 @@ CREATE TABLE public.room_hash_tag_keys_4 (
     created_at timestamp with time zone NOT NULL DEFAULT now(),
     updated_at timestamp with time zone NOT NULL DEFAULT now(),
     status character varying NOT NULL,
-    version bigint NOT NULL DEFAULT 0
+    version bigint NOT NULL DEFAULT 0,
+    frequency integer NOT NULL DEFAULT 0
 );
 
 ALTER TABLE ONLY public.room_hash_tag_keys_4
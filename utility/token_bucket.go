@@ -0,0 +1,49 @@
+package utility
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket rate limiter,
+// tokens are refilled at rate per second and at most burst tokens are kept.
+// Only non-blocking Allow is needed by rate limit of connections, so it is implemented here
+// instead of adding golang.org/x/time/rate as a new dependency.
+type TokenBucket struct {
+	rate       float64
+	burst      float64
+	tokens     float64
+	lastRefill time.Time
+	mutex      sync.Mutex
+}
+
+func NewTokenBucket(rate, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:       float64(rate),
+		burst:      float64(burst),
+		tokens:     float64(burst),
+		lastRefill: time.Now(),
+	}
+}
+
+// Allow takes one token from bucket, it returns false if there is no token left.
+func (bucket *TokenBucket) Allow() bool {
+	return bucket.allowAt(time.Now())
+}
+
+func (bucket *TokenBucket) allowAt(t time.Time) bool {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+	if elapsed := t.Sub(bucket.lastRefill); elapsed > 0 {
+		bucket.tokens += elapsed.Seconds() * bucket.rate
+		if bucket.tokens > bucket.burst {
+			bucket.tokens = bucket.burst
+		}
+		bucket.lastRefill = t
+	}
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
@@ -662,11 +662,16 @@ func GetSecondsAndNanoSecondsFromTsInMs(ts int64) (int64, int64) {
 
 var ErrSizeNotPositive = errors.New("size should be greater than 0")
 
+// ConvertJSONArrayIntoSlices decodes json array v and splits it into slices of size,
+// empty string and json null are decoded as an empty array.
 func ConvertJSONArrayIntoSlices(v string, size int) ([][]interface{}, error) {
 	if size <= 0 {
 		return nil, ErrSizeNotPositive
 	}
 	value := []interface{}{}
+	if strings.TrimSpace(v) == "" {
+		return SplitSliceBySize(value, size)
+	}
 	if err := json.Unmarshal([]byte(v), &value); err != nil {
 		return nil, err
 	}
@@ -719,3 +724,89 @@ func MergeStringSlicesToStringSet(slices ...[]string) *StringSet {
 	}
 	return set
 }
+
+// MatchPattern reports whether s matches glob-style pattern in the same way as redis,
+// `*` matches any sequence, `?` matches any character, `[abc]`, `[^abc]` and `[a-z]` match character classes,
+// and `\` escapes the next character.
+func MatchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if MatchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			matched, rest := matchCharacterClass(pattern[1:], s[0])
+			if !matched {
+				return false
+			}
+			s = s[1:]
+			pattern = rest
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchCharacterClass matches c with character class pattern after `[`,
+// it returns the pattern after `]`.
+func matchCharacterClass(pattern string, c byte) (bool, string) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			if pattern[1] == c {
+				matched = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			if c >= start && c <= end {
+				matched = true
+			}
+			pattern = pattern[3:]
+		default:
+			if pattern[0] == c {
+				matched = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return matched != not, pattern
+}
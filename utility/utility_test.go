@@ -2,6 +2,7 @@ package utility
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -96,6 +97,13 @@ func TestConvertJSONArrayIntoSlices(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, c.result, result)
 	}
+	for _, empty := range []string{"", " ", "null", "[]"} {
+		result, err := ConvertJSONArrayIntoSlices(empty, 2)
+		assert.Nil(t, err, empty)
+		assert.Equal(t, [][]interface{}{}, result, empty)
+	}
+	_, err := ConvertJSONArrayIntoSlices("{}", 2)
+	assert.NotNil(t, err)
 }
 
 func TestStringSet(t *testing.T) {
@@ -167,3 +175,58 @@ func TestMergeStringSliceAndRemoveDuplicateItems(t *testing.T) {
 	assert.Equal(t, len(uniqueItems), len(result))
 	assert.ElementsMatch(t, result, uniqueItems)
 }
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTokenBucket(10, 2)
+	now := bucket.lastRefill
+	assert.True(t, bucket.allowAt(now))
+	assert.True(t, bucket.allowAt(now))
+	assert.False(t, bucket.allowAt(now))
+
+	// 1 token is refilled after 100ms
+	now = now.Add(100 * time.Millisecond)
+	assert.True(t, bucket.allowAt(now))
+	assert.False(t, bucket.allowAt(now))
+
+	// tokens are no more than burst
+	now = now.Add(time.Second)
+	assert.True(t, bucket.allowAt(now))
+	assert.True(t, bucket.allowAt(now))
+	assert.False(t, bucket.allowAt(now))
+}
+
+func TestMatchPattern(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		s       string
+		matched bool
+	}{
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a*", "abc", true},
+		{"a*", "bac", false},
+		{"*c", "abc", true},
+		{"a**c", "abc", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h[b-a]llo", "hallo", true},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`h[\]]llo`, "h]llo", true},
+		{"news.*", "news.sport", true},
+		{"news.*", "new.sport", false},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.matched, MatchPattern(testCase.pattern, testCase.s), testCase)
+	}
+}